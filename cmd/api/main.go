@@ -12,6 +12,7 @@ import (
 	"github.com/taiwoajasa245/memory-verse-api/internal/database"
 	"github.com/taiwoajasa245/memory-verse-api/internal/server"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/tracing"
 )
 
 func gracefulShutdown(apiServer *http.Server, done chan bool) {
@@ -41,12 +42,30 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 
 func main() {
 	cfg := config.LoadConfig()
+
+	// Tracing stays off unless a collector is configured
+	if cfg.OTLPEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, cfg.ServiceName)
+		if err != nil {
+			log.Fatalf("Invalid OTEL_EXPORTER_OTLP_ENDPOINT: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				log.Printf("Error flushing traces: %v", err)
+			}
+		}()
+	}
+
 	db := database.New(cfg)
 
 	server := server.NewServer(db, cfg)
 	httpServer := server.HTTPServer()
 
-	server.StartBackgroundJobs()
+	if err := server.StartBackgroundJobs(); err != nil {
+		log.Fatalf("Could not start background jobs: %v", err)
+	}
 
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartCleanupJob purges expired password reset codes every interval until
+// ctx is cancelled.
+func (h *AuthService) StartCleanupJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Cleanup job started (%s interval)\n", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Cleanup job stopped gracefully")
+			return
+		case <-ticker.C:
+			h.runCleanup(ctx, time.Now())
+		}
+	}
+}
+
+func (h *AuthService) runCleanup(ctx context.Context, now time.Time) {
+	n, err := h.repo.DeleteExpiredPasswordResets(ctx, now)
+	if err != nil {
+		log.Printf("Failed to purge expired password resets: %v", err)
+		return
+	}
+
+	log.Printf("Purged %d expired password resets", n)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunCleanupRemovesOnlyExpiredResets(t *testing.T) {
+	now := time.Now()
+	repo := &resetRepo{resets: map[int]*PasswordReset{
+		1: {UserID: 1, ExpiresAt: now.Add(-time.Minute)},
+		2: {UserID: 2, ExpiresAt: now.Add(-time.Hour)},
+		3: {UserID: 3, ExpiresAt: now.Add(5 * time.Minute)},
+	}}
+	svc := &AuthService{repo: repo}
+
+	svc.runCleanup(context.Background(), now)
+
+	if len(repo.resets) != 1 {
+		t.Fatalf("expected 1 reset left, got %d", len(repo.resets))
+	}
+	if _, ok := repo.resets[3]; !ok {
+		t.Fatal("fresh reset was removed")
+	}
+}
@@ -2,9 +2,16 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/validator"
 )
 
 type AuthHandler struct {
@@ -22,11 +29,12 @@ func (h *AuthHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		response.Error(w, http.StatusBadRequest, "Missing required fields", map[string]string{
-			"email":    "Email is required",
-			"password": "Password is required",
-		})
+	errs := validator.Validate(req)
+	if req.Password != "" {
+		errs = append(errs, ValidatePassword(req.Password)...)
+	}
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
 		return
 	}
 
@@ -34,6 +42,16 @@ func (h *AuthHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	usr, err := h.service.Register(r.Context(), user.Email, user.Password)
 	if err != nil {
+		if errors.Is(err, ErrUserAlreadyExists) {
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeUserExists, "Failed to create user", err.Error())
+			return
+		}
+		if errors.Is(err, ErrPasswordPwned) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "password", Message: err.Error()},
+			})
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to create user", err.Error())
 		return
 	}
@@ -41,6 +59,45 @@ func (h *AuthHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, usr, "User registered successfully")
 }
 
+// ValidateRegistrationHandler runs the registration checks without creating
+// an account, for inline validation on signup forms
+func (h *AuthHandler) ValidateRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	errs, err := h.service.ValidateRegistration(r.Context(), req)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to validate registration", err.Error())
+		return
+	}
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	response.Success(w, "Registration data is valid", "successfully")
+}
+
+// CheckEmailHandler tells signup forms whether ?email= is still free
+func (h *AuthHandler) CheckEmailHandler(w http.ResponseWriter, r *http.Request) {
+	req := CheckEmailRequest{Email: strings.TrimSpace(r.URL.Query().Get("email"))}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", errs)
+		return
+	}
+
+	available, err := h.service.IsEmailAvailable(r.Context(), req.Email)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to check email", err.Error())
+		return
+	}
+
+	response.Success(w, map[string]bool{"available": available}, "successfully")
+}
+
 func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -48,12 +105,8 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		response.Error(w, http.StatusBadRequest, "Missing required fields", map[string]string{
-			"email":    "Email is required",
-			"password": "Password is required",
-		})
-
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
 		return
 	}
 
@@ -61,6 +114,10 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.Login(r.Context(), user.Email, user.Password)
 	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeInvalidCredentials, "User not found", err.Error())
+			return
+		}
 		response.Error(w, http.StatusNotFound, "User not found", err.Error())
 		return
 	}
@@ -75,6 +132,16 @@ func (h *AuthHandler) CompleteProfileHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	req.Normalize()
+	errs := validator.Validate(req)
+	if req.UserName != "" {
+		errs = append(errs, ValidateUserName(req.UserName)...)
+	}
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
 	userID, ok := GetUserIDFromContext(r)
 	if !ok {
 		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not found")
@@ -83,9 +150,361 @@ func (h *AuthHandler) CompleteProfileHandler(w http.ResponseWriter, r *http.Requ
 
 	err := h.service.CompleteUserProfile(r.Context(), userID, req)
 	if err != nil {
+		if errors.Is(err, ErrUserNameTaken) {
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeUserNameTaken, "Validation failed", []validator.FieldError{
+				{Field: "user_name", Message: err.Error()},
+			})
+			return
+		}
+		if errors.Is(err, ErrUnknownInspiration) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "inspiration", Message: err.Error()},
+			})
+			return
+		}
+		if errors.Is(err, ErrUnknownTranslation) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "bible_translation", Message: err.Error()},
+			})
+			return
+		}
 		response.Error(w, http.StatusBadRequest, err.Error(), err.Error())
 		return
 	}
 
 	response.Success(w, "Profile completed successfully", "OK")
 }
+
+func (h *AuthHandler) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	req.Normalize()
+	errs := validator.Validate(req)
+	if req.UserName != nil {
+		errs = append(errs, ValidateUserName(*req.UserName)...)
+	}
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not found")
+		return
+	}
+
+	confirmSent, err := h.service.UpdateProfile(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNameTaken):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeUserNameTaken, "Validation failed", []validator.FieldError{
+				{Field: "user_name", Message: err.Error()},
+			})
+		case errors.Is(err, ErrUserAlreadyExists):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeUserExists, "Validation failed", []validator.FieldError{
+				{Field: "email", Message: "email is already in use"},
+			})
+		case errors.Is(err, ErrUnknownInspiration):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "inspiration", Message: err.Error()},
+			})
+		case errors.Is(err, ErrUnknownTranslation):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "bible_translation", Message: err.Error()},
+			})
+		case errors.Is(err, ErrProfileIncomplete):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeProfileIncomplete, err.Error(), err.Error())
+		default:
+			response.Error(w, http.StatusBadRequest, err.Error(), err.Error())
+		}
+		return
+	}
+
+	if confirmSent {
+		response.Success(w, "Profile updated; confirm the new email from the link sent to it", "OK")
+		return
+	}
+	response.Success(w, "Profile updated successfully", "OK")
+}
+
+// ConfirmEmailChangeHandler applies a pending email change from the token in
+// the confirmation link. It needs no session: the token proves the user
+// owns the new address.
+func (h *AuthHandler) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	err := h.service.ConfirmEmailChange(r.Context(), req.Token)
+	if err != nil {
+		var tooSoon *EmailChangeTooSoonError
+		switch {
+		case errors.As(err, &tooSoon):
+			writeEmailChangeTooSoon(w, tooSoon)
+		case errors.Is(err, ErrInvalidEmailLink):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeInvalidEmailLink, err.Error(), err.Error())
+		case errors.Is(err, ErrUserAlreadyExists):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeUserExists, "Validation failed", []validator.FieldError{
+				{Field: "email", Message: "email is already in use"},
+			})
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to confirm email", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, "Email updated successfully", "OK")
+}
+
+// writeEmailChangeTooSoon rejects an email change with a Retry-After header
+// pointing at when the next one is allowed.
+func writeEmailChangeTooSoon(w http.ResponseWriter, tooSoon *EmailChangeTooSoonError) {
+	retryAfter := int(math.Ceil(time.Until(tooSoon.NextAllowedAt).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	response.ErrorWithCode(w, http.StatusTooManyRequests, response.CodeTooManyRequests, "Email was changed recently", map[string]interface{}{
+		"next_allowed_at": tooSoon.NextAllowedAt.UTC(),
+	})
+}
+
+// InspirationsHandler lists the inspirations users can choose from
+func (h *AuthHandler) InspirationsHandler(w http.ResponseWriter, r *http.Request) {
+	inspirations, err := h.service.ListInspirations(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get inspirations", err.Error())
+		return
+	}
+
+	if inspirations == nil {
+		inspirations = []Inspiration{}
+	}
+
+	response.Success(w, inspirations, "successfully")
+}
+
+func (h *AuthHandler) ForgetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	if err := h.service.ForgetPassword(r.Context(), req.Email); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to send reset code", err.Error())
+		return
+	}
+
+	response.Success(w, "If the email is registered, a reset code has been sent", "successfully")
+}
+
+func (h *AuthHandler) VerifyOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerifyOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	if _, err := h.service.VerifyOTP(r.Context(), req.Email, req.OTP); err != nil {
+		h.otpError(w, err)
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+func (h *AuthHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), req.Email, req.OTP, req.NewPassword); err != nil {
+		h.otpError(w, err)
+		return
+	}
+
+	response.Success(w, "Password reset successfully", "successfully")
+}
+
+func (h *AuthHandler) otpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidOTP):
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeInvalidOTP, "Invalid reset code", err.Error())
+	case errors.Is(err, ErrOTPExpired):
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeOTPExpired, "Reset code has expired", err.Error())
+	case errors.Is(err, ErrOTPAttempts):
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeOTPAttempts, "Too many wrong reset codes", err.Error())
+	case errors.Is(err, ErrPasswordPwned):
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+			{Field: "new_password", Message: err.Error()},
+		})
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to reset password", err.Error())
+	}
+}
+
+// MyInspirationsHandler returns just the logged in user's inspirations
+func (h *AuthHandler) MyInspirationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	inspirations, err := h.service.GetUserInspirations(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get inspirations", err.Error())
+		return
+	}
+	if inspirations == nil {
+		inspirations = []string{}
+	}
+
+	response.Success(w, inspirations, "successfully")
+}
+
+// ReplaceMyInspirationsHandler replaces the logged in user's inspirations
+func (h *AuthHandler) ReplaceMyInspirationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReplaceInspirationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	req.Normalize()
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	if err := h.service.ReplaceUserInspirations(r.Context(), userID, req.Inspirations); err != nil {
+		if errors.Is(err, ErrUnknownInspiration) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Validation failed", []validator.FieldError{
+				{Field: "inspiration", Message: err.Error()},
+			})
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to update inspirations", err.Error())
+		return
+	}
+
+	response.Success(w, req.Inspirations, "successfully")
+}
+
+// MeHandler returns the logged in user's details
+func (h *AuthHandler) MeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	user, err := h.service.GetUserDetails(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, "User not found", err.Error())
+		return
+	}
+
+	response.Success(w, user, "successfully")
+}
+
+// FeedTokenHandler mints a new feed token, revoking the previous one
+func (h *AuthHandler) FeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	token, err := h.service.RotateFeedToken(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create feed token", err.Error())
+		return
+	}
+
+	response.Success(w, token, "successfully")
+}
+
+func (h *AuthHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.GetAllUsers(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get users", err.Error())
+		return
+	}
+
+	if users == nil {
+		users = []User{}
+	}
+
+	response.Success(w, users, "successfully")
+}
+
+func (h *AuthHandler) ResendWelcomeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	h.resendWelcome(w, r, userID)
+}
+
+// AdminResendWelcomeHandler resends the welcome email to the user in the {id} URL param.
+func (h *AuthHandler) AdminResendWelcomeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || userID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid user id", "id must be a positive integer")
+		return
+	}
+
+	h.resendWelcome(w, r, userID)
+}
+
+func (h *AuthHandler) resendWelcome(w http.ResponseWriter, r *http.Request, userID int) {
+	err := h.service.ResendWelcomeEmail(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyRequests):
+			response.ErrorWithCode(w, http.StatusTooManyRequests, response.CodeTooManyRequests, "Too many requests", err.Error())
+		case errors.Is(err, ErrUserNotFound):
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeUserNotFound, "User not found", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to resend welcome email", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, "Welcome email queued", "successfully")
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// responseCode decodes the error code from a response envelope.
+func responseCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body response.APIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	return body.Code
+}
+
+func TestHandlerErrorCodes(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	hashed, err := util.HashPasswordBcrypt("password1")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	repo := &registerRepo{users: map[string]*User{
+		"taken@example.com": {ID: 1, Email: "taken@example.com", Password: hashed},
+	}}
+	h := NewHandler(NewAuthService(repo, &recordingMailer{}, &config.Config{}))
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "register existing email",
+			handler:    h.RegisterHandler,
+			body:       `{"email":"taken@example.com","password":"password1"}`,
+			wantStatus: http.StatusConflict,
+			wantCode:   response.CodeUserExists,
+		},
+		{
+			name:       "login wrong password",
+			handler:    h.LoginHandler,
+			body:       `{"email":"taken@example.com","password":"wrong-password1"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   response.CodeInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if code := responseCode(t, rec); code != tt.wantCode {
+				t.Errorf("expected code %q, got %q", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestCheckEmailHandler(t *testing.T) {
+	repo := &registerRepo{users: map[string]*User{"taken@example.com": {ID: 1, Email: "taken@example.com"}}}
+	h := NewHandler(NewAuthService(repo, &recordingMailer{}, &config.Config{}))
+
+	tests := []struct {
+		name          string
+		query         string
+		wantStatus    int
+		wantAvailable bool
+	}{
+		{"taken", "?email=taken@example.com", http.StatusOK, false},
+		{"taken with surrounding spaces", "?email=%20taken@example.com%20", http.StatusOK, false},
+		{"available", "?email=new@example.com", http.StatusOK, true},
+		{"invalid", "?email=not-an-email", http.StatusBadRequest, false},
+		{"missing", "", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.CheckEmailHandler(rec, httptest.NewRequest(http.MethodGet, "/auth/check-email"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if code := responseCode(t, rec); code != response.CodeValidationFailed {
+					t.Errorf("expected code %q, got %q", response.CodeValidationFailed, code)
+				}
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Available bool `json:"available"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if body.Data.Available != tt.wantAvailable {
+				t.Errorf("expected available=%v, got %v", tt.wantAvailable, body.Data.Available)
+			}
+		})
+	}
+}
+
+// inspirationsRepo keeps each user's inspirations in memory.
+type inspirationsRepo struct {
+	profileRepo
+	mine map[int][]string
+}
+
+func (r *inspirationsRepo) GetUserInspirations(ctx context.Context, userID int) ([]string, error) {
+	return r.mine[userID], nil
+}
+
+func (r *inspirationsRepo) UpdateUserInspirations(ctx context.Context, userID int, inspirations []string) error {
+	r.mine[userID] = inspirations
+	return nil
+}
+
+func TestMyInspirationsHandlers(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &inspirationsRepo{mine: map[int][]string{1: {"faith"}}}
+	h := NewHandler(NewAuthService(repo, &recordingMailer{}, &config.Config{}))
+
+	get := func(userID int) []string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		AuthMiddleware(http.HandlerFunc(h.MyInspirationsHandler)).ServeHTTP(rec, authedRequest(t, userID))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Data []string `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return body.Data
+	}
+	put := func(payload string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := authedRequest(t, 1)
+		req.Method = http.MethodPut
+		req.Body = io.NopCloser(strings.NewReader(payload))
+		rec := httptest.NewRecorder()
+		AuthMiddleware(http.HandlerFunc(h.ReplaceMyInspirationsHandler)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got := get(1); !reflect.DeepEqual(got, []string{"faith"}) {
+		t.Errorf("expected [faith], got %v", got)
+	}
+	if got := get(2); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty list for a user with none, got %v", got)
+	}
+
+	if rec := put(`{"inspiration":[" Hope ","faith","hope"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := get(1); !reflect.DeepEqual(got, []string{"hope", "faith"}) {
+		t.Errorf("expected [hope faith], got %v", got)
+	}
+
+	for _, payload := range []string{`{"inspiration":["joy"]}`, `{"inspiration":[" "]}`} {
+		rec := put(payload)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", payload, rec.Code)
+		}
+	}
+	if got := get(1); !reflect.DeepEqual(got, []string{"hope", "faith"}) {
+		t.Errorf("rejected updates changed inspirations to %v", got)
+	}
+}
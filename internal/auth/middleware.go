@@ -2,9 +2,14 @@ package auth
 
 import (
 	"context"
+	"math"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/taiwoajasa245/memory-verse-api/pkg/ratelimit"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
 )
@@ -14,6 +19,8 @@ type contextKey string
 const (
 	userContextKey   contextKey = "user"
 	userIDContextKey contextKey = "user_id"
+	// set on requests let through while in maintenance
+	maintenanceContextKey contextKey = "maintenance"
 )
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -57,3 +64,154 @@ func GetUserIDFromContext(r *http.Request) (int, bool) {
 	id, ok := r.Context().Value(userIDContextKey).(int)
 	return id, ok
 }
+
+// RequireCompletedProfile rejects users who haven't finished onboarding with a 403.
+// It must run after AuthMiddleware.
+func RequireCompletedProfile(repo Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserIDFromContext(r)
+			if !ok {
+				response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+				return
+			}
+
+			user, _, err := repo.GetUserWithProfile(r.Context(), userID)
+			if err != nil {
+				response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not found")
+				return
+			}
+
+			if !user.IsProfileCompleted {
+				response.ErrorWithCode(w, http.StatusForbidden, response.CodeProfileIncomplete, "Please complete your profile to continue", "profile incomplete")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole rejects users whose token doesn't carry the given role with a 403.
+// It must run after AuthMiddleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetUserFromContext(r)
+			if !ok {
+				response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+				return
+			}
+
+			if claims.Role != role {
+				response.ErrorWithCode(w, http.StatusForbidden, response.CodeForbidden, "Forbidden", "insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Throttle limits each client to requestsPerMinute, answering 429 with a
+// Retry-After header once the budget is spent. Clients are keyed by user ID
+// when running after AuthMiddleware, otherwise by remote IP, which is only
+// the client's own behind a proxy when middleware.RealIP runs first (see
+// TRUST_PROXY_HEADERS). Budgets are kept in memory per instance, so with
+// several instances a client can get up to that many times the limit.
+func Throttle(requestsPerMinute int) func(http.Handler) http.Handler {
+	limiter := ratelimit.NewTokenBucket(requestsPerMinute)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := limiter.Allow(throttleKey(r))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				response.ErrorWithCode(w, http.StatusTooManyRequests, response.CodeTooManyRequests, "Too many requests", ErrTooManyRequests.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Maintenance answers writes with a 503 while enabled so deploys and
+// migrations don't see half-applied changes. Reads still go through, as do
+// requests carrying a valid admin token and POSTs to the exempt paths (login,
+// so admins can get that token). Reads are marked so handlers can skip the
+// writes they make as a side effect, see InMaintenance.
+func Maintenance(enabled bool, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAdminRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), maintenanceContextKey, true)
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			case http.MethodPost:
+				if slices.Contains(exempt, r.URL.Path) {
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+
+			rejectForMaintenance(w)
+		})
+	}
+}
+
+// RejectInMaintenance 503s a read route whose whole point is a write (such as
+// unsubscribing by link), which Maintenance would otherwise let through.
+func RejectInMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if InMaintenance(r.Context()) {
+			rejectForMaintenance(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InMaintenance reports whether the request came through Maintenance while
+// it was enabled, so side-effect writes (impressions, tracking, delivery
+// records) should be skipped. Admin requests are never marked.
+func InMaintenance(ctx context.Context) bool {
+	on, _ := ctx.Value(maintenanceContextKey).(bool)
+	return on
+}
+
+func rejectForMaintenance(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "60")
+	response.ErrorWithCode(w, http.StatusServiceUnavailable, response.CodeMaintenance, "Service under maintenance", ErrMaintenance.Error())
+}
+
+// isAdminRequest reports whether the bearer token is valid and has the admin role.
+func isAdminRequest(r *http.Request) bool {
+	tokenStr, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	claims, err := util.ValidateJWT(tokenStr)
+	return err == nil && claims.Role == RoleAdmin
+}
+
+func throttleKey(r *http.Request) string {
+	if userID, ok := GetUserIDFromContext(r); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// stubRepo overrides only the Repository methods a test needs.
+type stubRepo struct {
+	Repository
+	users map[int]*User
+}
+
+func (s *stubRepo) GetUserWithProfile(ctx context.Context, userID int) (*User, *CompleteProfileRequest, error) {
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, nil, ErrUserNotFound
+	}
+	return user, &CompleteProfileRequest{}, nil
+}
+
+func authedRequest(t *testing.T, userID int) *http.Request {
+	return authedRequestWithRole(t, userID, RoleUser)
+}
+
+func authedRequestWithRole(t *testing.T, userID int, role string) *http.Request {
+	t.Helper()
+	token, err := util.GenerateJWT(userID, "user@example.com", role)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestRequireCompletedProfile(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &stubRepo{users: map[int]*User{
+		1: {ID: 1, IsProfileCompleted: true},
+		2: {ID: 2, IsProfileCompleted: false},
+	}}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := AuthMiddleware(RequireCompletedProfile(repo)(ok))
+
+	tests := []struct {
+		name     string
+		userID   int
+		want     int
+		wantCode string
+	}{
+		{"completed profile", 1, http.StatusOK, ""},
+		{"incomplete profile", 2, http.StatusForbidden, response.CodeProfileIncomplete},
+		{"unknown user", 3, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, authedRequest(t, tt.userID))
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if tt.wantCode != "" {
+				if code := responseCode(t, rec); code != tt.wantCode {
+					t.Errorf("expected code %q, got %q", tt.wantCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := AuthMiddleware(RequireRole(RoleAdmin)(ok))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, authedRequestWithRole(t, 1, RoleUser))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected regular user to get 403, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, authedRequestWithRole(t, 2, RoleAdmin))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected admin to get 200, got %d", rec.Code)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := AuthMiddleware(Throttle(2)(ok))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, authedRequest(t, 1))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, authedRequest(t, 1))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the budget is spent, got %d", rec.Code)
+	}
+	// Two per minute refills one token every 30 seconds
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+
+	// Another user on the same IP has their own budget
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, authedRequest(t, 2))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected a different user to be allowed, got %d", rec.Code)
+	}
+}
+
+func TestThrottleFallsBackToIP(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Throttle(1)(ok)
+
+	request := func(addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/translations", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request("10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("expected first request to be allowed, got %d", code)
+	}
+	if code := request("10.0.0.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the same IP on another port to be throttled, got %d", code)
+	}
+	if code := request("10.0.0.2:1234"); code != http.StatusOK {
+		t.Errorf("expected a different IP to be allowed, got %d", code)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Maintenance(true, "/auth/login")(ok)
+
+	withMethod := func(req *http.Request, method string) *http.Request {
+		req.Method = method
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		want     int
+		wantCode string
+	}{
+		{"read", httptest.NewRequest(http.MethodGet, "/dashboard", nil), http.StatusOK, ""},
+		{"anonymous write", httptest.NewRequest(http.MethodPost, "/auth/register-with-email", nil), http.StatusServiceUnavailable, response.CodeMaintenance},
+		{"exempt login", httptest.NewRequest(http.MethodPost, "/auth/login", nil), http.StatusOK, ""},
+		{"exempt path only for POST", httptest.NewRequest(http.MethodDelete, "/auth/login", nil), http.StatusServiceUnavailable, response.CodeMaintenance},
+		{"user write", withMethod(authedRequest(t, 1), http.MethodPatch), http.StatusServiceUnavailable, response.CodeMaintenance},
+		{"user delete", withMethod(authedRequest(t, 1), http.MethodDelete), http.StatusServiceUnavailable, response.CodeMaintenance},
+		{"admin write", withMethod(authedRequestWithRole(t, 2, RoleAdmin), http.MethodPost), http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rec.Code)
+			}
+			if tt.wantCode != "" {
+				if code := responseCode(t, rec); code != tt.wantCode {
+					t.Errorf("expected code %q, got %q", tt.wantCode, code)
+				}
+				if rec.Header().Get("Retry-After") == "" {
+					t.Error("expected a Retry-After header")
+				}
+			}
+		})
+	}
+
+	// Switched off, writes go straight through
+	rec := httptest.NewRecorder()
+	Maintenance(false)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected writes to pass when disabled, got %d", rec.Code)
+	}
+}
+
+func TestRejectInMaintenance(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	var marked bool
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		marked = InMaintenance(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	Maintenance(true)(RejectInMaintenance(ok)).ServeHTTP(rec, authedRequest(t, 1))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a write-by-GET to be rejected, got %d", rec.Code)
+	}
+
+	// Admins aren't marked, so they get through
+	rec = httptest.NewRecorder()
+	Maintenance(true)(RejectInMaintenance(ok)).ServeHTTP(rec, authedRequestWithRole(t, 2, RoleAdmin))
+	if rec.Code != http.StatusOK || marked {
+		t.Errorf("expected admin through unmarked, got %d (marked %v)", rec.Code, marked)
+	}
+
+	rec = httptest.NewRecorder()
+	Maintenance(true)(ok).ServeHTTP(rec, authedRequest(t, 1))
+	if rec.Code != http.StatusOK || !marked {
+		t.Errorf("expected a plain read through and marked, got %d (marked %v)", rec.Code, marked)
+	}
+
+	rec = httptest.NewRecorder()
+	Maintenance(false)(RejectInMaintenance(ok)).ServeHTTP(rec, authedRequest(t, 1))
+	if rec.Code != http.StatusOK || marked {
+		t.Errorf("expected nothing marked when disabled, got %d (marked %v)", rec.Code, marked)
+	}
+}
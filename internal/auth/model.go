@@ -1,39 +1,244 @@
 // User model definition
 package auth
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Verse paces; see PaceInterval
+const (
+	PaceDaily      = "daily"
+	PaceWeekly     = "weekly"
+	PaceMonthly    = "monthly"
+	PaceEveryNDays = "every_n_days" // every PaceDays days
+)
+
+// Delivery channels a verse or OTP can be sent on
+const (
+	ChannelEmail = "email"
+	ChannelWeb   = "web"
+	ChannelSMS   = "sms" // OTPs only
+)
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// CheckEmailRequest is the ?email= query of the signup availability check.
+type CheckEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type CompleteProfileRequest struct {
-	VersePace           string    `json:"verse_pace"`
-	BibleTranslation    string    `json:"bible_translation"`
-	EnableNotification  bool      `json:"enable_notification"`
-	Inspirations        []string  `json:"inspiration"`
-	IsEmailNotification bool      `json:"is_email_notification"`
-	IsWebNotification   bool      `json:"is_web_notification"`
-	SelectedTime        time.Time `json:"selected_time"`
-	UserName            string    `json:"user_name"`
+	VersePace           string      `json:"verse_pace" validate:"required"`
+	PaceDays            int         `json:"pace_days"` // only for every_n_days
+	BibleTranslation    string      `json:"bible_translation" validate:"required"`
+	EnableNotification  bool        `json:"enable_notification"`
+	Inspirations        []string    `json:"inspiration" validate:"required"`
+	IsEmailNotification bool        `json:"is_email_notification"`
+	IsWebNotification   bool        `json:"is_web_notification"`
+	SelectedTime        time.Time   `json:"selected_time"`
+	SelectedTimes       []time.Time `json:"selected_times"`
+	UserName            string      `json:"user_name" validate:"required"`
+	OTPChannel          string      `json:"otp_channel" validate:"oneof=email sms"`
+	PhoneNumber         string      `json:"phone_number" validate:"max=20"`
+}
+
+// Normalize trims free-text fields and puts pace and translation into their
+// canonical casing, so " Daily " and "kjv" are stored as "daily" and "KJV".
+func (req *CompleteProfileRequest) Normalize() {
+	req.UserName = strings.TrimSpace(req.UserName)
+	req.VersePace = strings.ToLower(strings.TrimSpace(req.VersePace))
+	req.BibleTranslation = strings.ToUpper(strings.TrimSpace(req.BibleTranslation))
+	req.OTPChannel = strings.ToLower(strings.TrimSpace(req.OTPChannel))
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	req.Inspirations = normalizeInspirations(req.Inspirations)
+}
+
+// UpdateProfileRequest is a partial profile update: nil fields are left
+// unchanged, present fields are validated as in CompleteProfileRequest.
+type UpdateProfileRequest struct {
+	Email               *string      `json:"email" validate:"email"`
+	VersePace           *string      `json:"verse_pace" validate:"oneof=daily weekly monthly every_n_days"`
+	PaceDays            *int         `json:"pace_days"`
+	BibleTranslation    *string      `json:"bible_translation" validate:"min=1"`
+	EnableNotification  *bool        `json:"enable_notification"`
+	Inspirations        *[]string    `json:"inspiration" validate:"min=1"`
+	IsEmailNotification *bool        `json:"is_email_notification"`
+	IsWebNotification   *bool        `json:"is_web_notification"`
+	SelectedTime        *time.Time   `json:"selected_time"`
+	SelectedTimes       *[]time.Time `json:"selected_times" validate:"min=1"`
+	UserName            *string      `json:"user_name" validate:"min=1"`
+	OTPChannel          *string      `json:"otp_channel" validate:"oneof=email sms"`
+	PhoneNumber         *string      `json:"phone_number" validate:"max=20"`
+	// VerseGoal pauses delivery after this many verses; 0 clears the goal.
+	// Setting it restarts the count.
+	VerseGoal *int `json:"verse_goal" validate:"min=0,max=1000"`
+}
+
+// Normalize applies CompleteProfileRequest's normalization to the fields
+// that are present.
+func (req *UpdateProfileRequest) Normalize() {
+	trim := func(s *string, fn func(string) string) {
+		if s != nil {
+			*s = fn(strings.TrimSpace(*s))
+		}
+	}
+	same := func(s string) string { return s }
+
+	trim(req.Email, same)
+	trim(req.UserName, same)
+	trim(req.VersePace, strings.ToLower)
+	trim(req.BibleTranslation, strings.ToUpper)
+	trim(req.OTPChannel, strings.ToLower)
+	trim(req.PhoneNumber, same)
+	if req.Inspirations != nil {
+		*req.Inspirations = normalizeInspirations(*req.Inspirations)
+	}
+}
+
+// ReplaceInspirationsRequest replaces the user's inspirations without
+// touching the rest of their profile.
+type ReplaceInspirationsRequest struct {
+	Inspirations []string `json:"inspiration" validate:"required"`
+}
+
+// Normalize applies the same cleanup as the profile requests.
+func (req *ReplaceInspirationsRequest) Normalize() {
+	req.Inspirations = normalizeInspirations(req.Inspirations)
+}
+
+// normalizeInspirations lowercases and trims inspirations to match catalogue
+// slugs, dropping blanks and duplicates.
+func normalizeInspirations(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+// Inspiration is an entry in the catalogue users pick their topics from.
+type Inspiration struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// FeedToken authenticates the verse RSS feed for feed readers.
+type FeedToken struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
+}
+
+type ForgetPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyOTPRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	OTP   string `json:"otp" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	OTP         string `json:"otp" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// ConfirmEmailChangeRequest carries the token from an email change link.
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EmailChange records a user's email being changed, for support to trace
+// hijacked accounts. The rate limit is counted in email_change_limits.
+type EmailChange struct {
+	UserID    int       `json:"user_id"`
+	OldEmail  string    `json:"old_email"`
+	NewEmail  string    `json:"new_email"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// PasswordReset is a pending OTP; only a hash of the code is stored.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	CodeHash  string
+	ExpiresAt time.Time
+	Attempts  int // wrong guesses so far
+	CreatedAt time.Time
 }
 
 type User struct {
 	ID                 int        `json:"id"`
 	UserName           string     `json:"user_name,omitempty"`
 	Email              string     `json:"email"`
+	Role               string     `json:"role,omitempty"`
 	Password           string     `json:"-"`
 	CreatedAt          time.Time  `json:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at"`
 	Token              string     `json:"token,omitempty"`
-	IsProfileCompleted bool       `json:"is_profile_completed,omitempty"`
+	IsProfileCompleted bool       `json:"is_profile_completed"`
 	VersePace          string     `json:"verse_pace,omitempty"`
+	PaceDays           int        `json:"pace_days,omitempty"`
 	LastVerseSentAt    *time.Time `json:"last_verse_sent_at,omitempty"`
 	IsSubscribed       bool       `json:"is_subscribed"`
+	SnoozedUntil       *time.Time `json:"snoozed_until,omitempty"`
+	IsSnoozed          bool       `json:"is_snoozed"`
+	CompletionPercent  int        `json:"completion_percent"`
+	Streak             int        `json:"streak"`
+	NextVerseAt        *time.Time `json:"next_verse_at"`
+	VerseGoal          int        `json:"verse_goal,omitempty"`
+	GoalStartedAt      *time.Time `json:"-"`
+	GoalReachedAt      *time.Time `json:"goal_reached_at,omitempty"`
+
+	// Notification preferences, loaded only for the scheduler. They are not
+	// serialised since elsewhere they would read as false; the profile
+	// carries the real values.
+	EnableNotification  bool `json:"-"`
+	IsEmailNotification bool `json:"-"`
+	IsWebNotification   bool `json:"-"`
+}
+
+// GoalPaused reports whether delivery is paused because the verse goal was reached.
+func (u User) GoalPaused() bool {
+	return u.VerseGoal > 0 && u.GoalReachedAt != nil
+}
+
+// DeliveryChannels returns the channels the user wants verses on.
+// EnableNotification is a master switch: when off, the result is empty.
+func (u User) DeliveryChannels() []string {
+	if !u.EnableNotification {
+		return nil
+	}
+
+	var channels []string
+	if u.IsEmailNotification {
+		channels = append(channels, ChannelEmail)
+	}
+	if u.IsWebNotification {
+		channels = append(channels, ChannelWeb)
+	}
+	return channels
 }
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A false boolean is meaningful to clients, so it must never be dropped.
+func TestUserMarshalsAllBooleans(t *testing.T) {
+	data, err := json.Marshal(User{})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	typ := reflect.TypeOf(User{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Type.Kind() != reflect.Bool || name == "-" {
+			continue
+		}
+		if v, ok := got[name]; !ok || v != false {
+			t.Errorf("expected %q to be present and false, got %v (present %v)", name, v, ok)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/mail"
+)
+
+// Notifier delivers one-time passwords to a user on a single channel.
+// destination is an email address or phone number depending on the channel.
+type Notifier interface {
+	SendOTP(ctx context.Context, destination, code string) error
+}
+
+// EmailNotifier sends OTPs as templated emails.
+type EmailNotifier struct {
+	mail   mail.Sender
+	expiry time.Duration
+}
+
+// NewEmailNotifier emails codes that lapse after expiry.
+func NewEmailNotifier(sender mail.Sender, expiry time.Duration) *EmailNotifier {
+	return &EmailNotifier{mail: sender, expiry: expiry}
+}
+
+func (n *EmailNotifier) SendOTP(ctx context.Context, destination, code string) error {
+	data := map[string]interface{}{
+		"Code":             code,
+		"ExpiresInMinutes": int(n.expiry.Minutes()),
+	}
+
+	return n.mail.SendHTML(destination, "Your Memory Verse reset code", "otp.html", data)
+}
+
+var ErrSMSNotConfigured = errors.New("sms delivery is not configured")
+
+// SMSProvider is the hook for an SMS gateway (Twilio, Termii, ...).
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+// SMSNotifier sends OTPs by text message. With no provider plugged in it
+// fails with ErrSMSNotConfigured.
+type SMSNotifier struct {
+	provider SMSProvider
+	expiry   time.Duration
+}
+
+// NewSMSNotifier texts codes that lapse after expiry.
+func NewSMSNotifier(provider SMSProvider, expiry time.Duration) *SMSNotifier {
+	return &SMSNotifier{provider: provider, expiry: expiry}
+}
+
+func (n *SMSNotifier) SendOTP(ctx context.Context, destination, code string) error {
+	if n.provider == nil {
+		return ErrSMSNotConfigured
+	}
+
+	body := fmt.Sprintf("Your Memory Verse reset code is %s. It expires in %d minutes.", code, int(n.expiry.Minutes()))
+	return n.provider.SendSMS(ctx, destination, body)
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+)
+
+// resetRepo keeps users, profiles and pending resets in memory.
+type resetRepo struct {
+	Repository
+	users    map[string]*User
+	profiles map[int]*CompleteProfileRequest
+	resets   map[int]*PasswordReset
+}
+
+func (r *resetRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, ok := r.users[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (r *resetRepo) GetUserWithProfile(ctx context.Context, userID int) (*User, *CompleteProfileRequest, error) {
+	for _, u := range r.users {
+		if u.ID == userID {
+			return u, r.profiles[userID], nil
+		}
+	}
+	return nil, nil, ErrUserNotFound
+}
+
+func (r *resetRepo) CreatePasswordReset(ctx context.Context, userID int, codeHash string, expiresAt time.Time) error {
+	r.resets[userID] = &PasswordReset{UserID: userID, CodeHash: codeHash, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r *resetRepo) CheckPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int) error {
+	return r.withPasswordReset(userID, match, maxAttempts, nil)
+}
+
+func (r *resetRepo) RedeemPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int, hashedPassword string) error {
+	return r.withPasswordReset(userID, match, maxAttempts, func() {
+		for _, u := range r.users {
+			if u.ID == userID {
+				u.Password = hashedPassword
+			}
+		}
+		delete(r.resets, userID)
+	})
+}
+
+// withPasswordReset mirrors the repository: wrong codes count attempts and
+// burn the code at maxAttempts.
+func (r *resetRepo) withPasswordReset(userID int, match func(codeHash string) bool, maxAttempts int, use func()) error {
+	reset, ok := r.resets[userID]
+	if !ok {
+		return ErrInvalidOTP
+	}
+	if time.Now().After(reset.ExpiresAt) {
+		return ErrOTPExpired
+	}
+	if !match(reset.CodeHash) {
+		reset.Attempts++
+		if reset.Attempts >= maxAttempts {
+			delete(r.resets, userID)
+			return ErrOTPAttempts
+		}
+		return ErrInvalidOTP
+	}
+	if use != nil {
+		use()
+	}
+	return nil
+}
+
+func (r *resetRepo) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	for userID, reset := range r.resets {
+		if reset.ExpiresAt.Before(before) {
+			delete(r.resets, userID)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type sentOTP struct {
+	destination string
+	code        string
+}
+
+// mockNotifier records OTPs, optionally failing every send with err.
+type mockNotifier struct {
+	sent []sentOTP
+	err  error
+}
+
+func (m *mockNotifier) SendOTP(ctx context.Context, destination, code string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentOTP{destination, code})
+	return nil
+}
+
+func newResetService(profile *CompleteProfileRequest) (*AuthService, *resetRepo, *mockNotifier, *mockNotifier) {
+	repo := &resetRepo{
+		users:    map[string]*User{"user@example.com": {ID: 1, Email: "user@example.com"}},
+		profiles: map[int]*CompleteProfileRequest{1: profile},
+		resets:   map[int]*PasswordReset{},
+	}
+	email, sms := &mockNotifier{}, &mockNotifier{}
+
+	service := NewAuthService(repo, nil, &config.Config{OTPLength: 6, OTPCharset: "numeric"})
+	service.notifiers = map[string]Notifier{ChannelEmail: email, ChannelSMS: sms}
+	return &service, repo, email, sms
+}
+
+func TestForgetPasswordRoutesByChannel(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   *CompleteProfileRequest
+		wantEmail string
+		wantSMS   string
+	}{
+		{"email channel", &CompleteProfileRequest{OTPChannel: ChannelEmail}, "user@example.com", ""},
+		{"no channel set", &CompleteProfileRequest{}, "user@example.com", ""},
+		{"sms channel", &CompleteProfileRequest{OTPChannel: ChannelSMS, PhoneNumber: "+2348000000000"}, "", "+2348000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, email, sms := newResetService(tt.profile)
+
+			if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+				t.Fatalf("ForgetPassword returned error: %v", err)
+			}
+
+			check := func(channel string, n *mockNotifier, want string) {
+				if want == "" {
+					if len(n.sent) != 0 {
+						t.Errorf("expected nothing on %s, got %v", channel, n.sent)
+					}
+					return
+				}
+				if len(n.sent) != 1 || n.sent[0].destination != want || len(n.sent[0].code) != 6 {
+					t.Errorf("expected one 6-character code on %s to %s, got %v", channel, want, n.sent)
+				}
+			}
+			check(ChannelEmail, email, tt.wantEmail)
+			check(ChannelSMS, sms, tt.wantSMS)
+		})
+	}
+}
+
+func TestForgetPasswordFallsBackToEmailWithoutSMSProvider(t *testing.T) {
+	service, _, email, _ := newResetService(&CompleteProfileRequest{OTPChannel: ChannelSMS, PhoneNumber: "+2348000000000"})
+	service.notifiers[ChannelSMS] = NewSMSNotifier(nil, 10*time.Minute)
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("expected the code to fall back to email, got %v", email.sent)
+	}
+}
+
+func TestForgetPasswordUnknownEmailSendsNothing(t *testing.T) {
+	service, _, email, sms := newResetService(&CompleteProfileRequest{})
+
+	if err := service.ForgetPassword(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("expected unknown emails to succeed silently, got %v", err)
+	}
+	if len(email.sent)+len(sms.sent) != 0 {
+		t.Error("expected no code to be sent for an unknown email")
+	}
+}
+
+func TestResetPasswordConsumesCode(t *testing.T) {
+	service, repo, email, _ := newResetService(&CompleteProfileRequest{})
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	code := email.sent[0].code
+
+	if err := service.ResetPassword(context.Background(), "user@example.com", "not-it", "new-password"); !errors.Is(err, ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP for a wrong code, got %v", err)
+	}
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "new-password"); err != nil {
+		t.Fatalf("ResetPassword returned error: %v", err)
+	}
+	if repo.users["user@example.com"].Password == "" {
+		t.Error("expected the password to be updated")
+	}
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "again-password"); !errors.Is(err, ErrInvalidOTP) {
+		t.Errorf("expected a used code to be rejected, got %v", err)
+	}
+}
+
+func TestWrongCodesBurnTheResetCode(t *testing.T) {
+	service, _, email, _ := newResetService(&CompleteProfileRequest{})
+	service.otpAttempts = 3
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	code := email.sent[0].code
+
+	for i := 1; i < 3; i++ {
+		if _, err := service.VerifyOTP(context.Background(), "user@example.com", "wrong"); !errors.Is(err, ErrInvalidOTP) {
+			t.Fatalf("attempt %d: expected ErrInvalidOTP, got %v", i, err)
+		}
+	}
+	if err := service.ResetPassword(context.Background(), "user@example.com", "wrong", "new-password"); !errors.Is(err, ErrOTPAttempts) {
+		t.Fatalf("expected ErrOTPAttempts on the last wrong guess, got %v", err)
+	}
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "new-password"); !errors.Is(err, ErrInvalidOTP) {
+		t.Errorf("expected the burned code to be rejected, got %v", err)
+	}
+}
+
+func TestOTPMailerBypassesQueue(t *testing.T) {
+	repo := &resetRepo{
+		users:    map[string]*User{"user@example.com": {ID: 1, Email: "user@example.com"}},
+		profiles: map[int]*CompleteProfileRequest{1: {}},
+		resets:   map[int]*PasswordReset{},
+	}
+	queue, direct := &recordingMailer{}, &recordingMailer{}
+
+	service := NewAuthService(repo, queue, &config.Config{OTPLength: 6, OTPCharset: "numeric"})
+	service.SetOTPMailer(direct)
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	if len(queue.templates) != 0 {
+		t.Errorf("expected nothing queued, got %v", queue.templates)
+	}
+	if len(direct.templates) != 1 || direct.templates[0] != "otp.html" {
+		t.Errorf("expected the code mailed directly, got %v", direct.templates)
+	}
+}
+
+func TestResetPasswordRejectsExpiredCode(t *testing.T) {
+	service, _, email, _ := newResetService(&CompleteProfileRequest{})
+	service.otpExpiry = time.Millisecond
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	code := email.sent[0].code
+	time.Sleep(5 * time.Millisecond)
+
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "new-password"); !errors.Is(err, ErrOTPExpired) {
+		t.Errorf("expected ErrOTPExpired once the code lapsed, got %v", err)
+	}
+}
+
+func TestOTPExpiryFollowsConfig(t *testing.T) {
+	tests := []struct {
+		minutes int
+		want    time.Duration
+	}{
+		{3, 3 * time.Minute},
+		{0, 10 * time.Minute},
+		{-5, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		service := NewAuthService(&resetRepo{}, nil, &config.Config{OTPExpiryMinutes: tt.minutes})
+		if service.otpExpiry != tt.want {
+			t.Errorf("OTPExpiryMinutes %d: expected %s, got %s", tt.minutes, tt.want, service.otpExpiry)
+		}
+	}
+}
+
+func TestResetPasswordRejectsPwnedPassword(t *testing.T) {
+	service, repo, email, _ := newResetService(&CompleteProfileRequest{})
+	service.cfg.CheckPwned = true
+	service.isPwned = func(ctx context.Context, password string) (bool, error) {
+		return password == "password1", nil
+	}
+
+	if err := service.ForgetPassword(context.Background(), "user@example.com"); err != nil {
+		t.Fatalf("ForgetPassword returned error: %v", err)
+	}
+	code := email.sent[0].code
+
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "password1"); !errors.Is(err, ErrPasswordPwned) {
+		t.Fatalf("expected ErrPasswordPwned, got %v", err)
+	}
+	if repo.users["user@example.com"].Password != "" {
+		t.Error("expected the password to be left alone")
+	}
+	// The code survives the rejection
+	if err := service.ResetPassword(context.Background(), "user@example.com", code, "another-password1"); err != nil {
+		t.Fatalf("ResetPassword returned error: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import "time"
+
+// MaxPaceDays is the longest gap an every_n_days pace may ask for.
+const MaxPaceDays = 90
+
+// PaceInterval is the time between verses for a pace. days is only read for
+// PaceEveryNDays. Both the dashboard and the scheduler use this, so a pace
+// means the same thing everywhere. ok is false for an unknown pace or an
+// out-of-range days.
+func PaceInterval(pace string, days int) (interval time.Duration, ok bool) {
+	const day = 24 * time.Hour
+	switch pace {
+	case PaceDaily:
+		return day, true
+	case PaceWeekly:
+		return 7 * day, true
+	case PaceMonthly:
+		return 30 * day, true
+	case PaceEveryNDays:
+		if days < 1 || days > MaxPaceDays {
+			return 0, false
+		}
+		return time.Duration(days) * day, true
+	}
+	return 0, false
+}
+
+// ValidatePace returns ErrInvalidVersePace unless pace is known and, for
+// PaceEveryNDays, days is between 1 and MaxPaceDays.
+func ValidatePace(pace string, days int) error {
+	if _, ok := PaceInterval(pace, days); !ok {
+		return ErrInvalidVersePace
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPaceInterval(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		pace   string
+		days   int
+		want   time.Duration
+		wantOK bool
+	}{
+		{PaceDaily, 0, day, true},
+		{PaceWeekly, 0, 7 * day, true},
+		{PaceMonthly, 0, 30 * day, true},
+		{PaceEveryNDays, 1, day, true},
+		{PaceEveryNDays, 3, 3 * day, true},
+		{PaceEveryNDays, MaxPaceDays, MaxPaceDays * day, true},
+		{PaceEveryNDays, 0, 0, false},
+		{PaceEveryNDays, MaxPaceDays + 1, 0, false},
+		{"fortnightly", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := PaceInterval(tt.pace, tt.days)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("PaceInterval(%q, %d) = %v, %v; want %v, %v", tt.pace, tt.days, got, ok, tt.want, tt.wantOK)
+		}
+		if err := ValidatePace(tt.pace, tt.days); (err == nil) != tt.wantOK || (err != nil && !errors.Is(err, ErrInvalidVersePace)) {
+			t.Errorf("ValidatePace(%q, %d) = %v", tt.pace, tt.days, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"unicode"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/validator"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+// ValidatePassword checks password strength and returns field errors in the
+// same shape as validator.Validate.
+func ValidatePassword(password string) []validator.FieldError {
+	fieldErr := func(msg string) []validator.FieldError {
+		return []validator.FieldError{{Field: "password", Message: "password " + msg}}
+	}
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fieldErr("must be between 8 and 72 characters")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fieldErr("must contain at least one letter and one number")
+	}
+
+	return nil
+}
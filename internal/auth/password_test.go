@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"too short", "abc12", false},
+		{"too long", strings.Repeat("a1", 37), false},
+		{"letters only", "password", false},
+		{"digits only", "12345678", false},
+		{"valid", "password1", true},
+		{"valid unicode", "ọrọ̀aṣínà9", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidatePassword(tt.password)
+			if tt.valid && len(errs) > 0 {
+				t.Errorf("expected %q to be valid, got %v", tt.password, errs)
+			}
+			if !tt.valid && (len(errs) != 1 || errs[0].Field != "password") {
+				t.Errorf("expected a password field error for %q, got %v", tt.password, errs)
+			}
+		})
+	}
+}
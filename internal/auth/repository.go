@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/taiwoajasa245/memory-verse-api/internal/database"
@@ -15,20 +16,51 @@ var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrInvalidVersePace   = errors.New("verse pace must be daily, weekly, monthly, or every_n_days with pace_days from 1 to 90")
+	ErrInvalidUserName    = errors.New("invalid user name")
+	ErrUserNameTaken      = errors.New("user name is already taken")
+	ErrTooManyRequests    = errors.New("too many requests, please try again later")
+	ErrInvalidOTP         = errors.New("invalid reset code")
+	ErrOTPExpired         = errors.New("reset code has expired")
+	ErrOTPAttempts        = errors.New("too many wrong reset codes, please request a new one")
+	ErrProfileIncomplete  = errors.New("profile has not been completed")
+	ErrUnknownInspiration = errors.New("unknown inspiration")
+	ErrUnknownTranslation = errors.New("no verses in translation")
+	ErrMaintenance        = errors.New("the service is under maintenance, please try again later")
+	ErrPasswordPwned      = errors.New("password has appeared in a data breach, please choose another")
+	ErrInvalidEmailLink   = errors.New("email confirmation link is invalid or has expired")
 )
 
 // Repository defines the methods the Auth module provides for DB operations.
 type Repository interface {
 	CreateUser(ctx context.Context, user User) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
-	UpdateUserProfile(ctx context.Context, userID int, req CompleteProfileRequest) error
-	MarkProfileCompleted(ctx context.Context, userID int) error
+	CompleteProfile(ctx context.Context, userID int, req CompleteProfileRequest) error
+	PatchUserProfile(ctx context.Context, userID int, req UpdateProfileRequest) error
+	SetFeedTokenHash(ctx context.Context, userID int, hash string) error
+	SavePendingEmailChange(ctx context.Context, userID int, email, tokenHash string, expiresAt time.Time) error
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (int, error)
+	GetUserIDByFeedTokenHash(ctx context.Context, hash string) (int, error)
+	IsUserNameTaken(ctx context.Context, userName string, excludeUserID int) (bool, error)
 	UpdateUserInspirations(ctx context.Context, userID int, inspirations []string) error
+	GetUserInspirations(ctx context.Context, userID int) ([]string, error)
+	GetInspirations(ctx context.Context) ([]Inspiration, error)
 	GetUserWithProfile(ctx context.Context, userID int) (*User, *CompleteProfileRequest, error)
 	GetAllUsers(ctx context.Context) ([]User, error)
 	GetAllUsersWithVersePace(ctx context.Context) ([]User, error)
 	UpdateLastVerseSentAt(ctx context.Context, userID int, t time.Time) error
 	UnsubscribeUser(ctx context.Context, userID int) error
+	SetSubscription(ctx context.Context, userID int, subscribed bool) error
+	GetUserDeliveryTimes(ctx context.Context, userID int) ([]time.Time, error)
+	GetDeliveryTimesForUsers(ctx context.Context, userIDs []int) (map[int][]time.Time, error)
+	SetSnoozedUntil(ctx context.Context, userID int, until *time.Time) error
+	MarkVerseGoalReached(ctx context.Context, userID int) (bool, error)
+	ResetVerseGoal(ctx context.Context, userID int) error
+	SetUserRole(ctx context.Context, email, role string) error
+	CreatePasswordReset(ctx context.Context, userID int, codeHash string, expiresAt time.Time) error
+	CheckPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int) error
+	RedeemPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int, hashedPassword string) error
+	DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error)
 }
 
 // repository implements Repository.
@@ -42,7 +74,7 @@ func NewRepository(dbService database.Service) Repository {
 }
 
 func (r *repository) GetAllUsers(ctx context.Context) ([]User, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, email FROM users`)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, email, role FROM users ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +83,7 @@ func (r *repository) GetAllUsers(ctx context.Context) ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.Email); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.Role); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -63,18 +95,7 @@ func (r *repository) CreateUser(ctx context.Context, user User) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	// Check if email exists
-	var exists bool
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
-	err := r.db.QueryRowContext(ctx, checkQuery, user.Email).Scan(&exists)
-	if err != nil {
-		return nil, err
-	}
-	if exists {
-		return nil, ErrUserAlreadyExists
-	}
-
-	// Now insert
+	// The unique index on email decides races between concurrent signups
 	query := `
 		INSERT INTO users (email, password)
 		VALUES ($1, $2)
@@ -82,10 +103,13 @@ func (r *repository) CreateUser(ctx context.Context, user User) (*User, error) {
 	`
 
 	usr := User{}
-	err = r.db.QueryRowContext(ctx, query, user.Email, user.Password).
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Password).
 		Scan(&usr.ID, &usr.Email, &usr.Password, &usr.CreatedAt, &usr.UpdatedAt)
 
 	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return nil, ErrUserAlreadyExists
+		}
 		return nil, err
 	}
 
@@ -95,9 +119,12 @@ func (r *repository) CreateUser(ctx context.Context, user User) (*User, error) {
 func (r *repository) GetUserWithProfile(ctx context.Context, userID int) (*User, *CompleteProfileRequest, error) {
 	query := `
 		SELECT 
-			u.id, u.email, u.password, u.created_at, u.updated_at, u.is_profile_completed, u.is_subscribed,
-			p.verse_pace, p.bible_translation, p.enable_notification,
-			p.is_email_notification, p.is_web_notification, p.selected_time, p.username
+			u.id, u.email, u.role, u.password, u.created_at, u.updated_at, u.is_profile_completed, u.is_subscribed,
+			u.snoozed_until, u.last_verse_sent_at,
+			p.verse_pace, p.pace_days, p.bible_translation, p.enable_notification,
+			p.is_email_notification, p.is_web_notification, p.selected_time, p.username,
+			p.otp_channel, p.phone_number,
+			COALESCE(p.verse_goal, 0), p.goal_started_at, p.goal_reached_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 		WHERE u.id = $1
@@ -111,41 +138,58 @@ func (r *repository) GetUserWithProfile(ctx context.Context, userID int) (*User,
 	// Handle nullable fields from the profile table
 	var (
 		versePace           sql.NullString
+		paceDays            sql.NullInt64
 		bibleTranslation    sql.NullString
 		enableNotification  sql.NullBool
 		isEmailNotification sql.NullBool
 		isWebNotification   sql.NullBool
 		selectedTime        sql.NullTime
 		userName            sql.NullString
+		otpChannel          sql.NullString
+		phoneNumber         sql.NullString
 	)
 
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(
 		&user.ID,
 		&user.Email,
+		&user.Role,
 		&user.Password,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.IsProfileCompleted,
 		&user.IsSubscribed,
+		&user.SnoozedUntil,
+		&user.LastVerseSentAt,
 		&versePace,
+		&paceDays,
 		&bibleTranslation,
 		&enableNotification,
 		&isEmailNotification,
 		&isWebNotification,
 		&selectedTime,
 		&userName,
+		&otpChannel,
+		&phoneNumber,
+		&user.VerseGoal,
+		&user.GoalStartedAt,
+		&user.GoalReachedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil, fmt.Errorf("user not found")
+			return nil, nil, ErrUserNotFound
 		}
 		return nil, nil, fmt.Errorf("failed to fetch user with profile: %w", err)
 	}
 
+	user.IsSnoozed = user.SnoozedUntil != nil && user.SnoozedUntil.After(time.Now())
+
 	// Map nullable fields only if valid
 	if versePace.Valid {
 		profile.VersePace = versePace.String
 	}
+	if paceDays.Valid {
+		profile.PaceDays = int(paceDays.Int64)
+	}
 	if bibleTranslation.Valid {
 		profile.BibleTranslation = bibleTranslation.String
 	}
@@ -164,6 +208,22 @@ func (r *repository) GetUserWithProfile(ctx context.Context, userID int) (*User,
 	if userName.Valid {
 		profile.UserName = userName.String
 	}
+	if otpChannel.Valid {
+		profile.OTPChannel = otpChannel.String
+	}
+	if phoneNumber.Valid {
+		profile.PhoneNumber = phoneNumber.String
+	}
+
+	profile.SelectedTimes, err = r.GetUserDeliveryTimes(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch delivery times: %w", err)
+	}
+
+	profile.Inspirations, err = r.GetUserInspirations(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch inspirations: %w", err)
+	}
 
 	return &user, &profile, nil
 }
@@ -173,9 +233,9 @@ func (r *repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 	defer cancel()
 
 	user := User{}
-	query := `SELECT id, email, password, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT id, email, role, password, created_at, updated_at FROM users WHERE email = $1`
 	err := r.db.QueryRowContext(ctx, query, email).
-		Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+		Scan(&user.ID, &user.Email, &user.Role, &user.Password, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -186,10 +246,48 @@ func (r *repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 	return &user, nil
 }
 
-func (r *repository) UpdateUserProfile(ctx context.Context, userID int, req CompleteProfileRequest) error {
+// CompleteProfile saves the profile, delivery times and inspirations and marks
+// the profile completed in one transaction, so a failure part way leaves the
+// user exactly as they were.
+func (r *repository) CompleteProfile(ctx context.Context, userID int, req CompleteProfileRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := upsertProfile(ctx, tx, userID, req); err != nil {
+		return err
+	}
+	if err := replaceDeliveryTimes(ctx, tx, userID, req.SelectedTimes); err != nil {
+		return err
+	}
+	if err := replaceInspirations(ctx, tx, userID, req.Inspirations); err != nil {
+		return err
+	}
+	if err := markProfileCompleted(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so statements can be
+// shared between standalone and transactional repository methods.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// userNameIndex is the unique index on LOWER(username). It backs the
+// IsUserNameTaken pre-check when two requests claim the same name at once.
+const userNameIndex = "idx_user_profiles_username_unique"
+
+// upsertProfile creates or replaces the user's profile row.
+func upsertProfile(ctx context.Context, q execer, userID int, req CompleteProfileRequest) error {
 	var exists bool
 	checkQuery := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`
-	err := r.db.QueryRowContext(ctx, checkQuery, userID).Scan(&exists)
+	err := q.QueryRowContext(ctx, checkQuery, userID).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -202,22 +300,26 @@ func (r *repository) UpdateUserProfile(ctx context.Context, userID int, req Comp
 		INSERT INTO user_profiles (
 			user_id, verse_pace, bible_translation,
 			enable_notification, is_email_notification,
-			is_web_notification, selected_time, username
+			is_web_notification, selected_time, username,
+			otp_channel, phone_number, pace_days
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, 0))
 		ON CONFLICT (user_id)
 		DO UPDATE SET
 			verse_pace = EXCLUDED.verse_pace,
+			pace_days = EXCLUDED.pace_days,
 			bible_translation = EXCLUDED.bible_translation,
 			enable_notification = EXCLUDED.enable_notification,
 			is_email_notification = EXCLUDED.is_email_notification,
 			is_web_notification = EXCLUDED.is_web_notification,
 			selected_time = EXCLUDED.selected_time,
 			updated_at = NOW(),
-			username = EXCLUDED.username
+			username = EXCLUDED.username,
+			otp_channel = EXCLUDED.otp_channel,
+			phone_number = EXCLUDED.phone_number
 	`
 
-	_, err = r.db.ExecContext(ctx, query,
+	_, err = q.ExecContext(ctx, query,
 		userID,
 		req.VersePace,
 		req.BibleTranslation,
@@ -226,17 +328,260 @@ func (r *repository) UpdateUserProfile(ctx context.Context, userID int, req Comp
 		req.IsWebNotification,
 		req.SelectedTime,
 		req.UserName,
+		req.OTPChannel,
+		req.PhoneNumber,
+		req.PaceDays,
 	)
+	if database.IsUniqueViolationOn(err, userNameIndex) {
+		return ErrUserNameTaken
+	}
 	return err
 }
 
-func (r *repository) MarkProfileCompleted(ctx context.Context, userID int) error {
+// PatchUserProfile updates only the profile columns present in req, and
+// replaces the delivery times and inspirations when they are present, all in
+// one transaction. The email only changes once the new address is confirmed
+// (see ConfirmEmailChange).
+func (r *repository) PatchUserProfile(ctx context.Context, userID int, req UpdateProfileRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if sets, args := profileUpdates(req); len(sets) > 0 {
+		args = append(args, userID)
+		query := fmt.Sprintf(`UPDATE user_profiles SET %s, updated_at = NOW() WHERE user_id = $%d`,
+			strings.Join(sets, ", "), len(args))
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if database.IsUniqueViolationOn(err, userNameIndex) {
+			return ErrUserNameTaken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update profile: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrProfileIncomplete
+		}
+	}
+
+	if req.SelectedTimes != nil {
+		if err := replaceDeliveryTimes(ctx, tx, userID, *req.SelectedTimes); err != nil {
+			return err
+		}
+	}
+	if req.Inspirations != nil {
+		if err := replaceInspirations(ctx, tx, userID, *req.Inspirations); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SavePendingEmailChange stores email as the user's unconfirmed new address,
+// replacing any earlier request so only the latest link works.
+func (r *repository) SavePendingEmailChange(ctx context.Context, userID int, email, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO pending_email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			new_email = EXCLUDED.new_email,
+			token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at,
+			created_at = NOW()
+	`, userID, email, tokenHash, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save pending email change: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange switches the user to the pending address the token was
+// sent to and returns the user's id. The change is recorded in
+// email_change_history and counted against the rate limit in the same
+// transaction; if the limit refuses it, the pending change is kept so the
+// link still works later.
+func (r *repository) ConfirmEmailChange(ctx context.Context, tokenHash string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userID int
+		email  string
+	)
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM pending_email_changes
+		WHERE token_hash = $1 AND expires_at > NOW()
+		RETURNING user_id, new_email
+	`, tokenHash).Scan(&userID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidEmailLink
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending email change: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO email_change_history (user_id, old_email, new_email)
+		SELECT id, email, $1 FROM users WHERE id = $2 AND email <> $1
+	`, email, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record email change: %w", err)
+	}
+	// The address may already be current if the user switched back meanwhile
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		if err := claimEmailChange(ctx, tx, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET email = $1, updated_at = NOW() WHERE id = $2`, email, userID)
+	if database.IsUniqueViolation(err) {
+		return 0, ErrUserAlreadyExists
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// claimEmailChange counts an email change against the user's
+// emailChangeInterval window in a single statement. A concurrent change waits
+// on the row lock and then sees this one's count, so two changes can't both
+// find room. Past the limit it returns EmailChangeTooSoonError and the caller
+// rolls back, undoing the increment.
+func claimEmailChange(ctx context.Context, tx *sql.Tx, userID int) error {
+	var (
+		count       int
+		windowStart time.Time
+	)
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO email_change_limits AS l (user_id, window_start, count)
+		VALUES ($1, NOW(), 1)
+		ON CONFLICT (user_id) DO UPDATE SET
+			count = CASE WHEN l.window_start <= NOW() - make_interval(secs => $2) THEN 1 ELSE l.count + 1 END,
+			window_start = CASE WHEN l.window_start <= NOW() - make_interval(secs => $2) THEN NOW() ELSE l.window_start END
+		RETURNING count, window_start
+	`, userID, emailChangeInterval.Seconds()).Scan(&count, &windowStart)
+	if err != nil {
+		return fmt.Errorf("failed to count email change: %w", err)
+	}
+	if count > 1 {
+		return &EmailChangeTooSoonError{NextAllowedAt: windowStart.Add(emailChangeInterval)}
+	}
+	return nil
+}
+
+// profileUpdates builds the SET clauses and their arguments for the
+// user_profiles columns present in req.
+func profileUpdates(req UpdateProfileRequest) ([]string, []any) {
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.VersePace != nil {
+		set("verse_pace", *req.VersePace)
+	}
+	if req.PaceDays != nil {
+		if *req.PaceDays == 0 {
+			set("pace_days", nil)
+		} else {
+			set("pace_days", *req.PaceDays)
+		}
+	}
+	if req.BibleTranslation != nil {
+		set("bible_translation", *req.BibleTranslation)
+	}
+	if req.EnableNotification != nil {
+		set("enable_notification", *req.EnableNotification)
+	}
+	if req.IsEmailNotification != nil {
+		set("is_email_notification", *req.IsEmailNotification)
+	}
+	if req.IsWebNotification != nil {
+		set("is_web_notification", *req.IsWebNotification)
+	}
+	if req.SelectedTime != nil {
+		set("selected_time", *req.SelectedTime)
+	}
+	if req.UserName != nil {
+		set("username", *req.UserName)
+	}
+	if req.OTPChannel != nil {
+		set("otp_channel", *req.OTPChannel)
+	}
+	if req.PhoneNumber != nil {
+		args = append(args, *req.PhoneNumber)
+		sets = append(sets, fmt.Sprintf("phone_number = NULLIF($%d, '')", len(args)))
+	}
+	if req.VerseGoal != nil {
+		set("verse_goal", *req.VerseGoal)
+		sets = append(sets, "goal_started_at = NOW()", "goal_reached_at = NULL")
+	}
+
+	return sets, args
+}
+
+// SetFeedTokenHash replaces the user's feed token, invalidating the old one.
+func (r *repository) SetFeedTokenHash(ctx context.Context, userID int, hash string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET feed_token_hash = $1, updated_at = NOW() WHERE id = $2`, hash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set feed token: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *repository) GetUserIDByFeedTokenHash(ctx context.Context, hash string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM users WHERE feed_token_hash = $1`, hash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up feed token: %w", err)
+	}
+	return userID, nil
+}
+
+// IsUserNameTaken reports whether another user already has userName, ignoring case.
+func (r *repository) IsUserNameTaken(ctx context.Context, userName string, excludeUserID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_profiles
+			WHERE LOWER(username) = LOWER($1) AND user_id <> $2
+		)
+	`
+	var taken bool
+	if err := r.db.QueryRowContext(ctx, query, userName, excludeUserID).Scan(&taken); err != nil {
+		return false, fmt.Errorf("failed to check user name: %w", err)
+	}
+	return taken, nil
+}
+
+func markProfileCompleted(ctx context.Context, q execer, userID int) error {
 	query := `
 		UPDATE users
 		SET is_profile_completed = TRUE, updated_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.db.ExecContext(ctx, query, userID)
+	_, err := q.ExecContext(ctx, query, userID)
 	return err
 }
 
@@ -247,8 +592,18 @@ func (r *repository) UpdateUserInspirations(ctx context.Context, userID int, ins
 	}
 	defer tx.Rollback()
 
+	if err := replaceInspirations(ctx, tx, userID, inspirations); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceInspirations swaps the user's inspirations for the given set. It
+// should run in a transaction.
+func replaceInspirations(ctx context.Context, q execer, userID int, inspirations []string) error {
 	// First, clear existing inspirations
-	_, err = tx.ExecContext(ctx, `DELETE FROM user_inspirations WHERE user_id = $1`, userID)
+	_, err := q.ExecContext(ctx, `DELETE FROM user_inspirations WHERE user_id = $1`, userID)
 	if err != nil {
 		return err
 	}
@@ -256,13 +611,51 @@ func (r *repository) UpdateUserInspirations(ctx context.Context, userID int, ins
 	// Insert new inspirations
 	query := `INSERT INTO user_inspirations (user_id, inspiration) VALUES ($1, $2)`
 	for _, inspiration := range inspirations {
-		_, err = tx.ExecContext(ctx, query, userID, inspiration)
+		_, err = q.ExecContext(ctx, query, userID, inspiration)
 		if err != nil {
 			return err
 		}
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// GetInspirations lists the inspiration catalogue in display order.
+func (r *repository) GetInspirations(ctx context.Context) ([]Inspiration, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT slug, name FROM inspirations ORDER BY sort_order, slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspirations []Inspiration
+	for rows.Next() {
+		var i Inspiration
+		if err := rows.Scan(&i.Slug, &i.Name); err != nil {
+			return nil, err
+		}
+		inspirations = append(inspirations, i)
+	}
+	return inspirations, rows.Err()
+}
+
+// GetUserInspirations returns the inspiration slugs the user picked.
+func (r *repository) GetUserInspirations(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT inspiration FROM user_inspirations WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspirations []string
+	for rows.Next() {
+		var inspiration string
+		if err := rows.Scan(&inspiration); err != nil {
+			return nil, err
+		}
+		inspirations = append(inspirations, inspiration)
+	}
+	return inspirations, rows.Err()
 }
 
 func (r *repository) GetAllUsersWithVersePace(ctx context.Context) ([]User, error) {
@@ -272,8 +665,17 @@ func (r *repository) GetAllUsersWithVersePace(ctx context.Context) ([]User, erro
 			u.email, 
 			COALESCE(p.username, '') AS username, 
 			COALESCE(p.verse_pace, '') AS verse_pace, 
+			COALESCE(p.pace_days, 0) AS pace_days,
 			u.last_verse_sent_at,
-			u.is_subscribed
+			u.is_subscribed,
+			u.is_profile_completed,
+			u.snoozed_until,
+			COALESCE(p.enable_notification, FALSE),
+			COALESCE(p.is_email_notification, FALSE),
+			COALESCE(p.is_web_notification, FALSE),
+			COALESCE(p.verse_goal, 0),
+			p.goal_started_at,
+			p.goal_reached_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 	`)
@@ -285,7 +687,9 @@ func (r *repository) GetAllUsersWithVersePace(ctx context.Context) ([]User, erro
 	var users []User
 	for rows.Next() {
 		var u User
-		err := rows.Scan(&u.ID, &u.Email, &u.UserName, &u.VersePace, &u.LastVerseSentAt, &u.IsSubscribed)
+		err := rows.Scan(&u.ID, &u.Email, &u.UserName, &u.VersePace, &u.PaceDays, &u.LastVerseSentAt, &u.IsSubscribed, &u.IsProfileCompleted, &u.SnoozedUntil,
+			&u.EnableNotification, &u.IsEmailNotification, &u.IsWebNotification,
+			&u.VerseGoal, &u.GoalStartedAt, &u.GoalReachedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -314,3 +718,291 @@ func (r *repository) UnsubscribeUser(ctx context.Context, userID int) error {
 	`, userID)
 	return err
 }
+
+func (r *repository) SetSubscription(ctx context.Context, userID int, subscribed bool) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET is_subscribed = $1, updated_at = NOW()
+		WHERE id = $2
+	`, subscribed, userID)
+	return err
+}
+
+// replaceDeliveryTimes swaps the user's delivery slots for the given times. It
+// should run in a transaction.
+func replaceDeliveryTimes(ctx context.Context, q execer, userID int, times []time.Time) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM user_delivery_times WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_delivery_times (user_id, delivery_time)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, delivery_time) DO NOTHING
+	`
+	for _, t := range times {
+		_, err = q.ExecContext(ctx, query, userID, t.UTC().Format("15:04:05"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetUserDeliveryTimes returns the user's delivery slots as UTC times of day
+// on the zero date, ordered from earliest to latest.
+func (r *repository) GetUserDeliveryTimes(ctx context.Context, userID int) ([]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT delivery_time::text
+		FROM user_delivery_times
+		WHERE user_id = $1
+		ORDER BY delivery_time
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		t, err := parseDeliveryTime(raw)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+
+	return times, rows.Err()
+}
+
+// GetDeliveryTimesForUsers loads the delivery slots of many users in one
+// query, keyed by user id, each ordered like GetUserDeliveryTimes. Users with
+// no slots are absent from the map.
+func (r *repository) GetDeliveryTimesForUsers(ctx context.Context, userIDs []int) (map[int][]time.Time, error) {
+	times := make(map[int][]time.Time, len(userIDs))
+	if len(userIDs) == 0 {
+		return times, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, delivery_time::text
+		FROM user_delivery_times
+		WHERE user_id = ANY($1)
+		ORDER BY user_id, delivery_time
+	`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			userID int
+			raw    string
+		)
+		if err := rows.Scan(&userID, &raw); err != nil {
+			return nil, err
+		}
+		t, err := parseDeliveryTime(raw)
+		if err != nil {
+			return nil, err
+		}
+		times[userID] = append(times[userID], t)
+	}
+
+	return times, rows.Err()
+}
+
+// parseDeliveryTime reads a delivery_time column cast to text.
+func parseDeliveryTime(raw string) (time.Time, error) {
+	return time.Parse("15:04:05", raw)
+}
+
+// SetSnoozedUntil pauses delivery until the given time, or resumes it when until is nil.
+func (r *repository) SetSnoozedUntil(ctx context.Context, userID int, until *time.Time) error {
+	var value interface{}
+	if until != nil {
+		value = until.UTC()
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET snoozed_until = $1, updated_at = NOW()
+		WHERE id = $2
+	`, value, userID)
+	return err
+}
+
+// MarkVerseGoalReached pauses delivery for a user whose verse goal is met.
+// It reports false when the goal was already marked, so callers celebrate once.
+func (r *repository) MarkVerseGoalReached(ctx context.Context, userID int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE user_profiles
+		SET goal_reached_at = NOW()
+		WHERE user_id = $1 AND verse_goal > 0 AND goal_reached_at IS NULL
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ResetVerseGoal restarts the count towards the verse goal and resumes delivery.
+func (r *repository) ResetVerseGoal(ctx context.Context, userID int) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE user_profiles
+		SET goal_started_at = NOW(), goal_reached_at = NULL, updated_at = NOW()
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrProfileIncomplete
+	}
+	return nil
+}
+
+func (r *repository) SetUserRole(ctx context.Context, email, role string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET role = $1, updated_at = NOW()
+		WHERE email = $2
+	`, role, email)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// CreatePasswordReset stores a new reset code for the user, replacing any
+// earlier one so only the latest code works.
+func (r *repository) CreatePasswordReset(ctx context.Context, userID int, codeHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO password_resets (user_id, code_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, codeHash, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckPasswordReset compares a code against the user's pending reset
+// without using it up. See withPasswordReset for how wrong codes count.
+func (r *repository) CheckPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int) error {
+	return r.withPasswordReset(ctx, userID, match, maxAttempts, nil)
+}
+
+// RedeemPasswordReset sets the new password and deletes the reset code in
+// the same transaction that checks it, so a code can't be used twice.
+func (r *repository) RedeemPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int, hashedPassword string) error {
+	return r.withPasswordReset(ctx, userID, match, maxAttempts, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET password = $1, updated_at = NOW()
+			WHERE id = $2
+		`, hashedPassword, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// withPasswordReset locks the user's pending reset and checks it with match.
+// A wrong code counts as a failed attempt, and the code is deleted once
+// maxAttempts is reached. On a match, use (if any) runs before commit.
+func (r *repository) withPasswordReset(ctx context.Context, userID int, match func(codeHash string) bool, maxAttempts int, use func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pr PasswordReset
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, code_hash, expires_at, attempts, created_at
+		FROM password_resets
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`, userID).Scan(&pr.ID, &pr.UserID, &pr.CodeHash, &pr.ExpiresAt, &pr.Attempts, &pr.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidOTP
+		}
+		return err
+	}
+
+	if time.Now().After(pr.ExpiresAt) {
+		return ErrOTPExpired
+	}
+
+	if !match(pr.CodeHash) {
+		pr.Attempts++
+		burned := pr.Attempts >= maxAttempts
+		if burned {
+			_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE password_resets SET attempts = $1 WHERE id = $2`, pr.Attempts, pr.ID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if burned {
+			return ErrOTPAttempts
+		}
+		return ErrInvalidOTP
+	}
+
+	if use != nil {
+		if err := use(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteExpiredPasswordResets removes reset codes that expired before the
+// given time and returns how many were removed.
+func (r *repository) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM password_resets WHERE expires_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,460 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolationDriver fails every statement the way Postgres rejects a
+// duplicate key, so repository error mapping can be tested without a database.
+type uniqueViolationDriver struct{}
+
+func (uniqueViolationDriver) Open(string) (driver.Conn, error) { return uniqueViolationConn{}, nil }
+
+type uniqueViolationConn struct{}
+
+func (uniqueViolationConn) Prepare(string) (driver.Stmt, error) { return uniqueViolationStmt{}, nil }
+func (uniqueViolationConn) Close() error                        { return nil }
+func (uniqueViolationConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type uniqueViolationStmt struct{}
+
+func (uniqueViolationStmt) Close() error  { return nil }
+func (uniqueViolationStmt) NumInput() int { return -1 }
+func (uniqueViolationStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, &pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_unique"}
+}
+func (uniqueViolationStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, &pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_unique"}
+}
+
+func init() {
+	sql.Register("uniqueviolation", uniqueViolationDriver{})
+}
+
+func TestCreateUserMapsUniqueViolation(t *testing.T) {
+	db, err := sql.Open("uniqueviolation", "")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	defer db.Close()
+
+	repo := &repository{db: db}
+	_, err = repo.CreateUser(context.Background(), User{Email: "dup@example.com", Password: "hash"})
+	if !errors.Is(err, ErrUserAlreadyExists) {
+		t.Fatalf("expected ErrUserAlreadyExists, got %v", err)
+	}
+}
+
+// noRowsDriver answers every query with an empty result, like a lookup of a
+// user that doesn't exist.
+type noRowsDriver struct{}
+
+func (noRowsDriver) Open(string) (driver.Conn, error) { return noRowsConn{}, nil }
+
+type noRowsConn struct{}
+
+func (noRowsConn) Prepare(string) (driver.Stmt, error) { return noRowsStmt{}, nil }
+func (noRowsConn) Close() error                        { return nil }
+func (noRowsConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type noRowsStmt struct{}
+
+func (noRowsStmt) Close() error  { return nil }
+func (noRowsStmt) NumInput() int { return -1 }
+func (noRowsStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (noRowsStmt) Query([]driver.Value) (driver.Rows, error) { return noRows{}, nil }
+
+type noRows struct{}
+
+func (noRows) Columns() []string         { return nil }
+func (noRows) Close() error              { return nil }
+func (noRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("norows", noRowsDriver{})
+}
+
+func TestResendWelcomeEmailUnknownUserThroughRepository(t *testing.T) {
+	db, err := sql.Open("norows", "")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	defer db.Close()
+
+	mailer := &recordingMailer{}
+	service := NewAuthService(&repository{db: db}, mailer, nil)
+
+	if err := service.ResendWelcomeEmail(context.Background(), 42); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if len(mailer.to) > 0 {
+		t.Errorf("expected no mail for an unknown user, got %v", mailer.to)
+	}
+}
+
+// txRecorder is a fake database that records statements per transaction and
+// fails the first statement containing failOn.
+type txRecorder struct {
+	mu         sync.Mutex
+	failOn     string
+	changes    int // email changes in the window, answered by the limit upsert
+	statements []string
+	committed  []string
+	rolledBack bool
+	outsideTx  []string
+}
+
+var (
+	txRecordersMu sync.Mutex
+	txRecorders   = map[string]*txRecorder{}
+)
+
+type txRecorderDriver struct{}
+
+func (txRecorderDriver) Open(name string) (driver.Conn, error) {
+	txRecordersMu.Lock()
+	defer txRecordersMu.Unlock()
+	return &txRecorderConn{rec: txRecorders[name]}, nil
+}
+
+type txRecorderConn struct {
+	rec  *txRecorder
+	inTx bool
+}
+
+func (c *txRecorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &txRecorderStmt{conn: c, query: query}, nil
+}
+func (c *txRecorderConn) Close() error { return nil }
+func (c *txRecorderConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+// Commit and Rollback make the connection its own driver.Tx.
+func (c *txRecorderConn) Commit() error {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+	c.inTx = false
+	c.rec.committed = append(c.rec.committed, c.rec.statements...)
+	c.rec.statements = nil
+	return nil
+}
+
+func (c *txRecorderConn) Rollback() error {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+	c.inTx = false
+	c.rec.rolledBack = true
+	c.rec.statements = nil
+	return nil
+}
+
+type txRecorderStmt struct {
+	conn  *txRecorderConn
+	query string
+}
+
+func (*txRecorderStmt) Close() error  { return nil }
+func (*txRecorderStmt) NumInput() int { return -1 }
+
+func (s *txRecorderStmt) record() error {
+	rec := s.conn.rec
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !s.conn.inTx {
+		rec.outsideTx = append(rec.outsideTx, s.query)
+	}
+	if rec.failOn != "" && strings.Contains(s.query, rec.failOn) {
+		return errors.New("injected failure")
+	}
+	rec.statements = append(rec.statements, s.query)
+	return nil
+}
+
+func (s *txRecorderStmt) Exec([]driver.Value) (driver.Result, error) {
+	if err := s.record(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// Query answers the user existence check, the pending email change claim and
+// the email change limit upsert.
+func (s *txRecorderStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.record(); err != nil {
+		return nil, err
+	}
+	if strings.Contains(s.query, "pending_email_changes") {
+		return &pendingRows{userID: 1, email: "new@example.com"}, nil
+	}
+	if strings.Contains(s.query, "email_change_limits") {
+		return &limitRows{count: int64(max(s.conn.rec.changes, 1)), windowStart: time.Now()}, nil
+	}
+	return &boolRows{value: true}, nil
+}
+
+type limitRows struct {
+	count       int64
+	windowStart time.Time
+	done        bool
+}
+
+func (*limitRows) Columns() []string { return []string{"count", "window_start"} }
+func (*limitRows) Close() error      { return nil }
+func (r *limitRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = r.count, r.windowStart
+	return nil
+}
+
+type pendingRows struct {
+	userID int64
+	email  string
+	done   bool
+}
+
+func (*pendingRows) Columns() []string { return []string{"user_id", "new_email"} }
+func (*pendingRows) Close() error      { return nil }
+func (r *pendingRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = r.userID, r.email
+	return nil
+}
+
+type boolRows struct {
+	value bool
+	done  bool
+}
+
+func (*boolRows) Columns() []string { return []string{"exists"} }
+func (*boolRows) Close() error      { return nil }
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func init() {
+	sql.Register("txrecorder", txRecorderDriver{})
+}
+
+func TestCompleteProfileIsAtomic(t *testing.T) {
+	req := CompleteProfileRequest{
+		VersePace:        PaceDaily,
+		BibleTranslation: "KJV",
+		Inspirations:     []string{"hope", "faith"},
+		SelectedTimes:    []time.Time{time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)},
+		UserName:         "taiwo",
+	}
+
+	steps := []string{
+		"INSERT INTO user_profiles",
+		"INSERT INTO user_delivery_times",
+		"INSERT INTO user_inspirations",
+		"SET is_profile_completed",
+	}
+
+	for _, failOn := range append(steps, "") {
+		name := "no failure"
+		if failOn != "" {
+			name = "fails on " + failOn
+		}
+		t.Run(name, func(t *testing.T) {
+			rec := &txRecorder{failOn: failOn}
+			txRecordersMu.Lock()
+			txRecorders[t.Name()] = rec
+			txRecordersMu.Unlock()
+
+			db, err := sql.Open("txrecorder", t.Name())
+			if err != nil {
+				t.Fatalf("failed to open test db: %v", err)
+			}
+			defer db.Close()
+
+			err = (&repository{db: db}).CompleteProfile(context.Background(), 1, req)
+
+			if len(rec.outsideTx) > 0 {
+				t.Errorf("statements ran outside the transaction: %v", rec.outsideTx)
+			}
+			if failOn != "" {
+				if err == nil {
+					t.Fatal("expected the injected failure to be returned")
+				}
+				if !rec.rolledBack || len(rec.committed) > 0 {
+					t.Errorf("expected a rollback with nothing committed, committed %v", rec.committed)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, step := range steps {
+				found := false
+				for _, stmt := range rec.committed {
+					found = found || strings.Contains(stmt, step)
+				}
+				if !found {
+					t.Errorf("expected %q to be committed", step)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchUserProfileIsAtomic(t *testing.T) {
+	name := "taiwo"
+	req := UpdateProfileRequest{
+		UserName:      &name,
+		SelectedTimes: &[]time.Time{time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)},
+		Inspirations:  &[]string{"hope"},
+	}
+
+	steps := []string{
+		"UPDATE user_profiles",
+		"INSERT INTO user_delivery_times",
+		"INSERT INTO user_inspirations",
+	}
+
+	for _, failOn := range append(steps, "") {
+		name := "no failure"
+		if failOn != "" {
+			name = "fails on " + failOn
+		}
+		t.Run(name, func(t *testing.T) {
+			rec := &txRecorder{failOn: failOn}
+			txRecordersMu.Lock()
+			txRecorders[t.Name()] = rec
+			txRecordersMu.Unlock()
+
+			db, err := sql.Open("txrecorder", t.Name())
+			if err != nil {
+				t.Fatalf("failed to open test db: %v", err)
+			}
+			defer db.Close()
+
+			err = (&repository{db: db}).PatchUserProfile(context.Background(), 1, req)
+
+			if len(rec.outsideTx) > 0 {
+				t.Errorf("statements ran outside the transaction: %v", rec.outsideTx)
+			}
+			if failOn != "" {
+				if err == nil {
+					t.Fatal("expected the injected failure to be returned")
+				}
+				if !rec.rolledBack || len(rec.committed) > 0 {
+					t.Errorf("expected a rollback with nothing committed, committed %v", rec.committed)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, step := range steps {
+				found := false
+				for _, stmt := range rec.committed {
+					found = found || strings.Contains(stmt, step)
+				}
+				if !found {
+					t.Errorf("expected %q to be committed", step)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChangeRecordsEmailChange(t *testing.T) {
+	for _, failOn := range []string{"", "UPDATE users SET email"} {
+		name := "no failure"
+		if failOn != "" {
+			name = "fails on email update"
+		}
+		t.Run(name, func(t *testing.T) {
+			rec := &txRecorder{failOn: failOn}
+			txRecordersMu.Lock()
+			txRecorders[t.Name()] = rec
+			txRecordersMu.Unlock()
+
+			db, err := sql.Open("txrecorder", t.Name())
+			if err != nil {
+				t.Fatalf("failed to open test db: %v", err)
+			}
+			defer db.Close()
+
+			userID, err := (&repository{db: db}).ConfirmEmailChange(context.Background(), "hash")
+
+			if len(rec.outsideTx) > 0 {
+				t.Errorf("statements ran outside the transaction: %v", rec.outsideTx)
+			}
+			if failOn != "" {
+				if err == nil {
+					t.Fatal("expected the injected failure to be returned")
+				}
+				if !rec.rolledBack || len(rec.committed) > 0 {
+					t.Errorf("expected the claim and history row to be rolled back, committed %v", rec.committed)
+				}
+				return
+			}
+
+			if err != nil || userID != 1 {
+				t.Fatalf("expected user 1, got %d (err %v)", userID, err)
+			}
+			if len(rec.committed) != 4 ||
+				!strings.Contains(rec.committed[0], "DELETE FROM pending_email_changes") ||
+				!strings.Contains(rec.committed[1], "INSERT INTO email_change_history") ||
+				!strings.Contains(rec.committed[2], "INSERT INTO email_change_limits") ||
+				!strings.Contains(rec.committed[3], "UPDATE users SET email") {
+				t.Errorf("expected the claim, history row, limit and email update in one transaction, got %v", rec.committed)
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChangeRejectsChangeOverLimit(t *testing.T) {
+	rec := &txRecorder{changes: 2}
+	txRecordersMu.Lock()
+	txRecorders[t.Name()] = rec
+	txRecordersMu.Unlock()
+
+	db, err := sql.Open("txrecorder", t.Name())
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	defer db.Close()
+
+	_, err = (&repository{db: db}).ConfirmEmailChange(context.Background(), "hash")
+
+	var tooSoon *EmailChangeTooSoonError
+	if !errors.As(err, &tooSoon) {
+		t.Fatalf("expected EmailChangeTooSoonError, got %v", err)
+	}
+	if until := time.Until(tooSoon.NextAllowedAt); until <= 0 || until > emailChangeInterval {
+		t.Errorf("expected the next change within the interval, got %v", tooSoon.NextAllowedAt)
+	}
+	if !rec.rolledBack || len(rec.committed) > 0 {
+		t.Errorf("expected the change, its count and the claimed link to be rolled back, committed %v", rec.committed)
+	}
+}
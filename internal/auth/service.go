@@ -3,21 +3,116 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/taiwoajasa245/memory-verse-api/internal/mail"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/ratelimit"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/validator"
 )
 
 type AuthService struct {
 	repo Repository
-	mail *mail.Mailer
+	mail mail.Sender
+	cfg  *config.Config
+
+	// secretMail sends emails carrying codes or links that must not be
+	// stored in the outbox
+	secretMail mail.Sender
+
+	// notifiers deliver reset OTPs, keyed by channel
+	notifiers map[string]Notifier
+
+	// otpExpiry is how long a password reset code stays valid
+	otpExpiry time.Duration
+
+	// otpAttempts is how many wrong guesses burn a reset code
+	otpAttempts int
+
+	// welcomeLimiter caps on-demand welcome resends per user
+	welcomeLimiter *ratelimit.Limiter
+
+	// isPwned looks passwords up in known breaches when CheckPwned is on
+	isPwned func(ctx context.Context, password string) (bool, error)
+
+	// dailyVerse adds the verse of the day to welcome emails when set
+	dailyVerse DailyVerseSource
+
+	// translations, when set, limits profiles to translations with verses
+	translations TranslationSource
+}
+
+// DailyVerse is the verse of the day as shown in emails.
+type DailyVerse struct {
+	Reference   string
+	Text        string
+	Translation string
+}
+
+// DailyVerseSource supplies the verse of the day. It returns nil when there
+// are no verses yet. The memory verse service implements it; auth can't
+// import that package directly.
+type DailyVerseSource interface {
+	DailyVerse(ctx context.Context) (*DailyVerse, error)
+}
+
+// SetDailyVerseSource makes welcome emails include the verse of the day.
+func (h *AuthService) SetDailyVerseSource(src DailyVerseSource) {
+	h.dailyVerse = src
+}
+
+// TranslationSource lists the Bible translations verses exist in. The memory
+// verse service implements it from a cache, since every profile save asks.
+type TranslationSource interface {
+	AvailableTranslations(ctx context.Context) ([]string, error)
 }
 
-func NewAuthService(repo Repository, mail *mail.Mailer) AuthService {
+// SetOTPMailer sends reset code and email confirmation emails through sender
+// rather than the mailer passed to NewAuthService. Pass a direct mailer so
+// live codes and links are never stored in the outbox, where admins can list
+// them.
+func (h *AuthService) SetOTPMailer(sender mail.Sender) {
+	if sender == nil {
+		return
+	}
+	h.secretMail = sender
+	h.notifiers[ChannelEmail] = NewEmailNotifier(sender, h.otpExpiry)
+}
+
+// SetTranslationSource makes profile saves reject translations with no verses.
+func (h *AuthService) SetTranslationSource(src TranslationSource) {
+	h.translations = src
+}
+
+// NewAuthService builds the auth service. A nil cfg uses the built-in
+// defaults, with optional emails such as the welcome turned off.
+func NewAuthService(repo Repository, mail mail.Sender, cfg *config.Config) AuthService {
+	if cfg == nil {
+		cfg = &config.Config{AppBaseURL: config.DefaultAppBaseURL}
+	}
+	otpExpiry := cfg.OTPExpiry()
+	otpAttempts := cfg.OTPAttempts()
+
 	return AuthService{
-		repo: repo,
-		mail: mail,
+		repo:           repo,
+		mail:           mail,
+		cfg:            cfg,
+		secretMail:     mail,
+		welcomeLimiter: ratelimit.New(3, time.Hour),
+		isPwned:        util.IsPasswordCompromised,
+		otpExpiry:      otpExpiry,
+		otpAttempts:    otpAttempts,
+		notifiers: map[string]Notifier{
+			ChannelEmail: NewEmailNotifier(mail, otpExpiry),
+			ChannelSMS:   NewSMSNotifier(nil, otpExpiry),
+		},
 	}
 }
 
@@ -25,6 +120,9 @@ func (h *AuthService) Register(ctx context.Context, email, password string) (*Us
 	if email == "" || password == "" {
 		return &User{}, errors.New("invalid email and password")
 	}
+	if err := h.checkPwned(ctx, password); err != nil {
+		return &User{}, err
+	}
 
 	hashed, err := util.HashPasswordBcrypt(password)
 	if err != nil {
@@ -44,21 +142,91 @@ func (h *AuthService) Register(ctx context.Context, email, password string) (*Us
 		return &User{}, err
 	}
 
+	// Queue the welcome mail; the outbox dispatcher delivers it
+	if h.cfg.SendWelcome {
+		if err := h.sendWelcome(ctx, email); err != nil {
+			log.Printf("failed to queue welcome email: %v", err)
+		}
+	}
+
+	return logInUser, nil
+}
+
+// IsEmailAvailable reports whether no account uses the email yet.
+func (h *AuthService) IsEmailAvailable(ctx context.Context, email string) (bool, error) {
+	_, err := h.repo.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, ErrUserNotFound):
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// ValidateRegistration runs RegisterHandler's field checks plus an email
+// availability lookup, without creating anything.
+func (h *AuthService) ValidateRegistration(ctx context.Context, req RegisterRequest) ([]validator.FieldError, error) {
+	errs := validator.Validate(req)
+	if req.Password != "" {
+		errs = append(errs, ValidatePassword(req.Password)...)
+	}
+
+	// Only look up addresses that passed the format check
+	for _, e := range errs {
+		if e.Field == "email" {
+			return errs, nil
+		}
+	}
+
+	_, err := h.repo.GetUserByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		errs = append(errs, validator.FieldError{Field: "email", Message: "email is already registered"})
+	case !errors.Is(err, ErrUserNotFound):
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// ResendWelcomeEmail queues the welcome email again for a user whose original
+// one never arrived. Resends are rate limited per user; only requests that
+// would actually send count, so a failed lookup doesn't use up the quota.
+// The limiter is in process memory, so each instance has its own quota.
+func (h *AuthService) ResendWelcomeEmail(ctx context.Context, userID int) error {
+	user, _, err := h.repo.GetUserWithProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if ok, _ := h.welcomeLimiter.Allow(strconv.Itoa(userID)); !ok {
+		return ErrTooManyRequests
+	}
+
+	return h.sendWelcome(ctx, user.Email)
+}
+
+func (h *AuthService) sendWelcome(ctx context.Context, email string) error {
 	data := map[string]interface{}{
-		"Name":         user.Email,
-		"DashboardURL": "https://memoryverse.app/dashboard",
+		"Name":         email,
+		"DashboardURL": h.cfg.AppURL("/dashboard"),
 	}
 
-	// Send welcome mail asynchronously
-	go func() {
-		if err := h.mail.SendHTML(email, "🎉 Welcome to Memory Verse", "welcome.html", data); err != nil {
-			log.Printf("failed to send welcome email: %v", err)
-		} else {
-			log.Println("Email sent successfully")
+	// The verse section is left out when there is no verse to show
+	if h.dailyVerse != nil {
+		verse, err := h.dailyVerse.DailyVerse(ctx)
+		if err != nil {
+			log.Printf("could not load verse of the day for welcome email: %v", err)
+		} else if verse != nil {
+			data["VerseReference"] = verse.Reference
+			data["VerseText"] = verse.Text
+			data["VerseTranslation"] = verse.Translation
 		}
-	}()
+	}
 
-	return logInUser, nil
+	return h.mail.SendHTML(email, "🎉 Welcome to Memory Verse", "welcome.html", data)
 }
 
 func (h *AuthService) Login(ctx context.Context, email, password string) (*User, error) {
@@ -77,7 +245,7 @@ func (h *AuthService) Login(ctx context.Context, email, password string) (*User,
 		return nil, ErrInvalidCredentials
 	}
 
-	token, err := util.GenerateJWT(user.ID, user.Email)
+	token, err := util.GenerateJWT(user.ID, user.Email, user.Role)
 	if err != nil {
 		return &User{}, err
 	}
@@ -89,31 +257,485 @@ func (h *AuthService) Login(ctx context.Context, email, password string) (*User,
 }
 
 func (h *AuthService) CompleteUserProfile(ctx context.Context, userID int, req CompleteProfileRequest) error {
+	req.Normalize()
+
+	// A single selected_time is treated as a one-slot list for older clients
+	if len(req.SelectedTimes) == 0 && !req.SelectedTime.IsZero() {
+		req.SelectedTimes = []time.Time{req.SelectedTime}
+	}
+	if req.SelectedTime.IsZero() && len(req.SelectedTimes) > 0 {
+		req.SelectedTime = req.SelectedTimes[0]
+	}
 
 	if req.VersePace == "" ||
 		req.BibleTranslation == "" ||
 		len(req.Inspirations) == 0 ||
 		req.UserName == "" ||
-		req.SelectedTime.IsZero() {
+		len(req.SelectedTimes) == 0 {
 		return errors.New("incomplete profile data")
 	}
 
-	err := h.repo.UpdateUserProfile(ctx, userID, req)
+	if err := ValidatePace(req.VersePace, req.PaceDays); err != nil {
+		return err
+	}
+	if req.VersePace != PaceEveryNDays {
+		req.PaceDays = 0
+	}
+
+	if req.OTPChannel == "" {
+		req.OTPChannel = ChannelEmail
+	}
+	if req.OTPChannel == ChannelSMS && req.PhoneNumber == "" {
+		return ErrPhoneNumberRequired
+	}
+
+	if len(ValidateUserName(req.UserName)) > 0 {
+		return ErrInvalidUserName
+	}
+
+	if err := h.validateInspirations(ctx, req.Inspirations); err != nil {
+		return err
+	}
+	if err := h.validateTranslation(ctx, req.BibleTranslation); err != nil {
+		return err
+	}
+
+	taken, err := h.repo.IsUserNameTaken(ctx, req.UserName, userID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrUserNameTaken
+	}
+
+	// Profile, delivery times, inspirations and the completed flag are saved
+	// together or not at all
+	if err := h.repo.CompleteProfile(ctx, userID, req); err != nil {
+		log.Printf("failed to complete profile for %d: %v", userID, err)
+		return err
+	}
+
+	return nil
+}
+
+// emailChangeInterval is the minimum time between email changes. It is
+// enforced by the repository in the transaction that changes the email.
+const emailChangeInterval = 24 * time.Hour
+
+// EmailChangeTooSoonError rejects an email change made within
+// emailChangeInterval of the last one. It matches ErrTooManyRequests.
+type EmailChangeTooSoonError struct {
+	NextAllowedAt time.Time
+}
+
+func (e *EmailChangeTooSoonError) Error() string {
+	return fmt.Sprintf("email was changed recently, try again after %s", e.NextAllowedAt.UTC().Format(time.RFC3339))
+}
+
+func (e *EmailChangeTooSoonError) Unwrap() error { return ErrTooManyRequests }
+
+// ListInspirations returns the catalogue of inspirations users can pick.
+func (h *AuthService) ListInspirations(ctx context.Context) ([]Inspiration, error) {
+	return h.repo.GetInspirations(ctx)
+}
+
+// GetUserInspirations returns the inspirations the user picked.
+func (h *AuthService) GetUserInspirations(ctx context.Context, userID int) ([]string, error) {
+	return h.repo.GetUserInspirations(ctx, userID)
+}
+
+// ReplaceUserInspirations swaps the user's inspirations for values after
+// checking them against the catalogue.
+func (h *AuthService) ReplaceUserInspirations(ctx context.Context, userID int, values []string) error {
+	if err := h.validateInspirations(ctx, values); err != nil {
+		return err
+	}
+	return h.repo.UpdateUserInspirations(ctx, userID, values)
+}
+
+// validateInspirations rejects values that aren't in the catalogue, naming
+// each unknown one.
+func (h *AuthService) validateInspirations(ctx context.Context, values []string) error {
+	catalogue, err := h.repo.GetInspirations(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(catalogue))
+	for _, i := range catalogue {
+		known[i.Slug] = true
+	}
+
+	var unknown []string
+	for _, v := range values {
+		if !known[v] {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownInspiration, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// validateTranslation rejects a translation the verse catalogue doesn't have.
+// Without a source, or before any verses are loaded, anything is accepted.
+func (h *AuthService) validateTranslation(ctx context.Context, translation string) error {
+	if h.translations == nil {
+		return nil
+	}
+	available, err := h.translations.AvailableTranslations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(available) == 0 || slices.Contains(available, translation) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownTranslation, translation)
+}
+
+// resolvePace validates a pace or pace_days update against whichever half
+// is on file, and clears pace_days when the pace no longer uses it.
+func (h *AuthService) resolvePace(ctx context.Context, userID int, req *UpdateProfileRequest) error {
+	var pace string
+	var days int
+	// pace_days on file only matters when the pace is, or stays, every_n_days
+	if req.VersePace == nil || (*req.VersePace == PaceEveryNDays && req.PaceDays == nil) {
+		_, profile, err := h.repo.GetUserWithProfile(ctx, userID)
+		if err != nil {
+			return err
+		}
+		pace, days = profile.VersePace, profile.PaceDays
+	}
+	if req.VersePace != nil {
+		pace = *req.VersePace
+	}
+	if req.PaceDays != nil {
+		days = *req.PaceDays
+	}
+
+	if err := ValidatePace(pace, days); err != nil {
+		return err
+	}
+	if pace != PaceEveryNDays {
+		days = 0
+	}
+	req.PaceDays = &days
+	return nil
+}
+
+// UpdateProfile applies a partial profile update. Only fields present in req
+// are validated and saved; the profile must already have been completed. A new
+// email isn't applied: a confirmation link is mailed to it instead, and
+// UpdateProfile reports whether one was sent.
+func (h *AuthService) UpdateProfile(ctx context.Context, userID int, req UpdateProfileRequest) (bool, error) {
+	req.Normalize()
+
+	// A single selected_time is treated as a one-slot list for older clients
+	if req.SelectedTimes == nil && req.SelectedTime != nil {
+		req.SelectedTimes = &[]time.Time{*req.SelectedTime}
+	}
+	if req.SelectedTimes != nil && len(*req.SelectedTimes) > 0 {
+		req.SelectedTime = &(*req.SelectedTimes)[0]
+	}
+
+	if req.VersePace != nil || req.PaceDays != nil {
+		if err := h.resolvePace(ctx, userID, &req); err != nil {
+			return false, err
+		}
+	}
+	if (req.BibleTranslation != nil && *req.BibleTranslation == "") ||
+		(req.Inspirations != nil && len(*req.Inspirations) == 0) ||
+		(req.SelectedTimes != nil && len(*req.SelectedTimes) == 0) {
+		return false, errors.New("incomplete profile data")
+	}
+
+	if req.Inspirations != nil {
+		if err := h.validateInspirations(ctx, *req.Inspirations); err != nil {
+			return false, err
+		}
+	}
+	if req.BibleTranslation != nil {
+		if err := h.validateTranslation(ctx, *req.BibleTranslation); err != nil {
+			return false, err
+		}
+	}
+
+	// Switching to sms needs a phone number, either in this request or on file
+	if req.OTPChannel != nil && *req.OTPChannel == ChannelSMS {
+		phone := ""
+		if req.PhoneNumber != nil {
+			phone = *req.PhoneNumber
+		} else {
+			_, profile, err := h.repo.GetUserWithProfile(ctx, userID)
+			if err != nil {
+				return false, err
+			}
+			phone = profile.PhoneNumber
+		}
+		if phone == "" {
+			return false, ErrPhoneNumberRequired
+		}
+	}
+
+	if req.UserName != nil {
+		if len(ValidateUserName(*req.UserName)) > 0 {
+			return false, ErrInvalidUserName
+		}
+		taken, err := h.repo.IsUserNameTaken(ctx, *req.UserName, userID)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, ErrUserNameTaken
+		}
+	}
+
+	// The email only changes once the link mailed to it is followed, so a typo
+	// can't lock the user out of their account
+	changeEmail := false
+	if req.Email != nil {
+		existing, err := h.repo.GetUserByEmail(ctx, *req.Email)
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			return false, err
+		}
+		if existing != nil && existing.ID != userID {
+			return false, ErrUserAlreadyExists
+		}
+		changeEmail = existing == nil
+	}
+	email := req.Email
+	req.Email = nil
+
+	if err := h.repo.PatchUserProfile(ctx, userID, req); err != nil {
+		return false, err
+	}
+
+	if changeEmail {
+		if err := h.requestEmailChange(ctx, userID, *email); err != nil {
+			return false, err
+		}
+	}
+
+	return changeEmail, nil
+}
+
+// emailConfirmationExpiry is how long an email change link stays valid
+const emailConfirmationExpiry = 24 * time.Hour
+
+// requestEmailChange saves email as the user's pending address and mails it
+// a confirmation link. Only the token's hash is stored.
+func (h *AuthService) requestEmailChange(ctx context.Context, userID int, email string) error {
+	token, err := util.GenerateConfirmationToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(emailConfirmationExpiry)
+	if err := h.repo.SavePendingEmailChange(ctx, userID, email, util.HashConfirmationToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"ConfirmURL":     h.cfg.AppURL("/confirm-email?token=" + url.QueryEscape(token)),
+		"ExpiresInHours": int(emailConfirmationExpiry.Hours()),
+	}
+	return h.secretMail.SendHTML(email, "Confirm your new Memory Verse email", "confirm_email.html", data)
+}
+
+// ConfirmEmailChange switches the user to the address the confirmation token
+// was mailed to. The limit on email changes is enforced here, when the change
+// actually happens.
+func (h *AuthService) ConfirmEmailChange(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidEmailLink
+	}
+	_, err := h.repo.ConfirmEmailChange(ctx, util.HashConfirmationToken(token))
+	return err
+}
+
+var ErrPhoneNumberRequired = errors.New("phone number is required for sms codes")
+
+// ForgetPassword sends a reset OTP on the user's chosen channel. Unknown
+// emails succeed silently so the endpoint can't be used to probe accounts.
+func (h *AuthService) ForgetPassword(ctx context.Context, email string) error {
+	user, err := h.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	_, profile, err := h.repo.GetUserWithProfile(ctx, user.ID)
 	if err != nil {
 		return err
 	}
 
-	err = h.repo.UpdateUserInspirations(ctx, userID, req.Inspirations)
+	code, err := util.GenerateOTPFromCharset(h.cfg.OTPLength, h.cfg.OTPCharacters())
 	if err != nil {
-		log.Println(err.Error())
 		return err
 	}
 
-	err = h.repo.MarkProfileCompleted(ctx, userID)
+	codeHash, err := util.HashPasswordBcrypt(code)
 	if err != nil {
 		return err
 	}
 
+	if err := h.repo.CreatePasswordReset(ctx, user.ID, codeHash, time.Now().Add(h.otpExpiry)); err != nil {
+		return err
+	}
+
+	if profile.OTPChannel == ChannelSMS && profile.PhoneNumber != "" {
+		err := h.notifiers[ChannelSMS].SendOTP(ctx, profile.PhoneNumber, code)
+		if !errors.Is(err, ErrSMSNotConfigured) {
+			return err
+		}
+		log.Printf("SMS not configured, sending reset code for user %d by email", user.ID)
+	}
+
+	return h.notifiers[ChannelEmail].SendOTP(ctx, user.Email, code)
+}
+
+// VerifyOTP checks a reset code without consuming it and returns the user it
+// belongs to. Wrong codes count towards burning it.
+func (h *AuthService) VerifyOTP(ctx context.Context, email, code string) (*User, error) {
+	user, err := h.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidOTP
+		}
+		return nil, err
+	}
+
+	if err := h.repo.CheckPasswordReset(ctx, user.ID, otpMatcher(code), h.otpAttempts); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// otpMatcher reports whether a stored code hash is for code.
+func otpMatcher(code string) func(codeHash string) bool {
+	return func(codeHash string) bool {
+		return util.ComparePasswordBcrypt(codeHash, code) == nil
+	}
+}
+
+// checkPwned rejects passwords seen in known breaches when
+// CHECK_PWNED_PASSWORDS is on. A failed lookup lets the password through,
+// so an outage at the API never blocks signups.
+func (h *AuthService) checkPwned(ctx context.Context, password string) error {
+	if h.cfg == nil || !h.cfg.CheckPwned || h.isPwned == nil {
+		return nil
+	}
+	pwned, err := h.isPwned(ctx, password)
+	if err != nil {
+		log.Printf("pwned password check failed, allowing password: %v", err)
+		return nil
+	}
+	if pwned {
+		return ErrPasswordPwned
+	}
 	return nil
 }
 
+// ResetPassword sets a new password once the reset code checks out. The code
+// is single use, and checking and using it happen in one transaction.
+func (h *AuthService) ResetPassword(ctx context.Context, email, code, newPassword string) error {
+	user, err := h.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidOTP
+		}
+		return err
+	}
+
+	// Checked before the code so a breached password doesn't use up a guess
+	if err := h.checkPwned(ctx, newPassword); err != nil {
+		return err
+	}
+
+	hashed, err := util.HashPasswordBcrypt(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return h.repo.RedeemPasswordReset(ctx, user.ID, otpMatcher(code), h.otpAttempts, hashed)
+}
+
+// GetUserDetails returns the user with their profile completion filled in.
+func (h *AuthService) GetUserDetails(ctx context.Context, userID int) (*User, error) {
+	user, profile, err := h.repo.GetUserWithProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.CompletionPercent = ComputeProfileCompletion(user, profile)
+	return user, nil
+}
+
+// RotateFeedToken mints a new verse feed token for the user, replacing any
+// previous one. Only its hash is stored, so the token is returned just once.
+func (h *AuthService) RotateFeedToken(ctx context.Context, userID int) (*FeedToken, error) {
+	token, err := util.GenerateFeedToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.SetFeedTokenHash(ctx, userID, util.HashFeedToken(token)); err != nil {
+		return nil, err
+	}
+
+	feedURL := fmt.Sprintf("%s/memory-verse-api/v1/feed.xml?token=%s",
+		strings.TrimRight(h.cfg.ApiBaseURL, "/"), url.QueryEscape(token))
+
+	return &FeedToken{Token: token, FeedURL: feedURL}, nil
+}
+
+// ComputeProfileCompletion reports how much of the encouraged profile is
+// filled in, from 0 to 100, for the onboarding progress bar.
+func ComputeProfileCompletion(user *User, profile *CompleteProfileRequest) int {
+	if profile == nil {
+		profile = &CompleteProfileRequest{}
+	}
+	if user == nil {
+		user = &User{}
+	}
+
+	fields := []bool{
+		profile.BibleTranslation != "",
+		profile.VersePace != "",
+		len(profile.Inspirations) > 0,
+		len(profile.SelectedTimes) > 0 || !profile.SelectedTime.IsZero(),
+		profile.UserName != "" || user.UserName != "",
+		profile.EnableNotification || user.EnableNotification,
+	}
+
+	set := 0
+	for _, ok := range fields {
+		if ok {
+			set++
+		}
+	}
+
+	return (set*100 + len(fields)/2) / len(fields)
+}
+
+// BootstrapAdmin promotes the configured email to admin so the first admin
+// can be created without direct database access.
+func (h *AuthService) BootstrapAdmin(ctx context.Context, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	err := h.repo.SetUserRole(ctx, email, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Bootstrapped admin: %s", email)
+	return nil
+}
+
+func (h *AuthService) GetAllUsers(ctx context.Context) ([]User, error) {
+	return h.repo.GetAllUsers(ctx)
+}
@@ -0,0 +1,718 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+)
+
+// profileRepo records the profile written by CompleteUserProfile.
+type profileRepo struct {
+	Repository
+	saved *CompleteProfileRequest
+	taken map[string]bool
+}
+
+func (p *profileRepo) IsUserNameTaken(ctx context.Context, userName string, excludeUserID int) (bool, error) {
+	return p.taken[userName], nil
+}
+
+// PatchUserProfile applies the present fields to the saved profile, as the
+// dynamic UPDATE does.
+func (p *profileRepo) PatchUserProfile(ctx context.Context, userID int, req UpdateProfileRequest) error {
+	if p.saved == nil {
+		return ErrProfileIncomplete
+	}
+	if req.VersePace != nil {
+		p.saved.VersePace = *req.VersePace
+	}
+	if req.BibleTranslation != nil {
+		p.saved.BibleTranslation = *req.BibleTranslation
+	}
+	if req.UserName != nil {
+		p.saved.UserName = *req.UserName
+	}
+	if req.SelectedTime != nil {
+		p.saved.SelectedTime = *req.SelectedTime
+	}
+	if req.PaceDays != nil {
+		p.saved.PaceDays = *req.PaceDays
+	}
+	return nil
+}
+
+func (p *profileRepo) GetUserWithProfile(ctx context.Context, userID int) (*User, *CompleteProfileRequest, error) {
+	if p.saved == nil {
+		return &User{ID: userID}, &CompleteProfileRequest{}, nil
+	}
+	profile := *p.saved
+	return &User{ID: userID, IsProfileCompleted: true}, &profile, nil
+}
+
+func (p *profileRepo) UpdateUserInspirations(ctx context.Context, userID int, inspirations []string) error {
+	return nil
+}
+
+func (p *profileRepo) CompleteProfile(ctx context.Context, userID int, req CompleteProfileRequest) error {
+	p.saved = &req
+	return nil
+}
+
+func (p *profileRepo) GetInspirations(ctx context.Context) ([]Inspiration, error) {
+	return []Inspiration{{Slug: "faith", Name: "Faith"}, {Slug: "hope", Name: "Hope"}}, nil
+}
+
+func profileRequest(pace string) CompleteProfileRequest {
+	return CompleteProfileRequest{
+		VersePace:        pace,
+		BibleTranslation: " kjv ",
+		Inspirations:     []string{"hope"},
+		SelectedTime:     time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC),
+		UserName:         "  Taiwo ",
+	}
+}
+
+func TestCompleteUserProfileNormalizesFields(t *testing.T) {
+	for _, pace := range []string{" Daily ", "WEEKLY", "\tdaily\n"} {
+		repo := &profileRepo{}
+		service := NewAuthService(repo, nil, nil)
+
+		if err := service.CompleteUserProfile(context.Background(), 1, profileRequest(pace)); err != nil {
+			t.Fatalf("pace %q: unexpected error: %v", pace, err)
+		}
+
+		saved := repo.saved
+		if saved.VersePace != PaceDaily && saved.VersePace != PaceWeekly {
+			t.Errorf("pace %q: expected canonical pace, got %q", pace, saved.VersePace)
+		}
+		if saved.BibleTranslation != "KJV" {
+			t.Errorf("expected translation KJV, got %q", saved.BibleTranslation)
+		}
+		if saved.UserName != "Taiwo" {
+			t.Errorf("expected trimmed user name, got %q", saved.UserName)
+		}
+	}
+}
+
+func TestCompleteUserProfileRejectsUnknownPace(t *testing.T) {
+	repo := &profileRepo{}
+	service := NewAuthService(repo, nil, nil)
+
+	err := service.CompleteUserProfile(context.Background(), 1, profileRequest("fortnightly"))
+	if !errors.Is(err, ErrInvalidVersePace) {
+		t.Fatalf("expected ErrInvalidVersePace, got %v", err)
+	}
+	if repo.saved != nil {
+		t.Error("expected nothing to be persisted for an invalid pace")
+	}
+}
+
+func TestProfilePaceDays(t *testing.T) {
+	complete := func(pace string, days int) (*profileRepo, error) {
+		repo := &profileRepo{}
+		req := profileRequest(pace)
+		req.PaceDays = days
+		svc := NewAuthService(repo, nil, nil)
+		return repo, svc.CompleteUserProfile(context.Background(), 1, req)
+	}
+
+	if _, err := complete(PaceEveryNDays, 0); !errors.Is(err, ErrInvalidVersePace) {
+		t.Errorf("expected every_n_days without pace_days to fail, got %v", err)
+	}
+	if repo, err := complete(PaceEveryNDays, 3); err != nil || repo.saved.PaceDays != 3 {
+		t.Errorf("expected pace_days 3 to be saved, got %v", err)
+	}
+	if repo, err := complete(PaceMonthly, 5); err != nil || repo.saved.PaceDays != 0 {
+		t.Errorf("expected pace_days to be dropped for monthly, got %v", err)
+	}
+
+	repo, _ := complete(PaceEveryNDays, 3)
+	svc := AuthService{repo: repo}
+	days := 10
+	if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{PaceDays: &days}); err != nil || repo.saved.PaceDays != 10 {
+		t.Errorf("expected pace_days alone to update an every_n_days profile, got %v", err)
+	}
+	weekly := PaceWeekly
+	if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{VersePace: &weekly}); err != nil || repo.saved.PaceDays != 0 {
+		t.Errorf("expected switching to weekly to clear pace_days, got %v (pace_days %d)", err, repo.saved.PaceDays)
+	}
+	if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{PaceDays: &days}); err != nil || repo.saved.PaceDays != 0 {
+		t.Errorf("expected pace_days to be ignored on a weekly profile, got %v (pace_days %d)", err, repo.saved.PaceDays)
+	}
+	everyN, tooMany := PaceEveryNDays, MaxPaceDays+1
+	if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{VersePace: &everyN, PaceDays: &tooMany}); !errors.Is(err, ErrInvalidVersePace) {
+		t.Errorf("expected pace_days over %d to fail, got %v", MaxPaceDays, err)
+	}
+}
+
+func TestCompleteUserProfileRejectsTakenUserName(t *testing.T) {
+	repo := &profileRepo{taken: map[string]bool{"Taiwo": true}}
+
+	svc := NewAuthService(repo, nil, &config.Config{})
+	err := svc.CompleteUserProfile(context.Background(), 1, profileRequest("daily"))
+	if !errors.Is(err, ErrUserNameTaken) {
+		t.Fatalf("expected ErrUserNameTaken, got %v", err)
+	}
+	if repo.saved != nil {
+		t.Fatal("expected nothing to be saved")
+	}
+}
+
+func TestProfileInspirationsMustBeInCatalogue(t *testing.T) {
+	tests := []struct {
+		name         string
+		inspirations []string
+		wantErr      bool
+		wantSaved    []string
+	}{
+		{"known values", []string{"hope", "faith"}, false, []string{"hope", "faith"}},
+		{"normalized and deduplicated", []string{" Hope", "HOPE", "faith "}, false, []string{"hope", "faith"}},
+		{"typo", []string{"hope", "faiht"}, true, nil},
+		{"arbitrary value", []string{"football"}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &profileRepo{}
+			service := NewAuthService(repo, nil, nil)
+
+			req := profileRequest("daily")
+			req.Inspirations = tt.inspirations
+			err := service.CompleteUserProfile(context.Background(), 1, req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownInspiration) {
+					t.Fatalf("expected ErrUnknownInspiration, got %v", err)
+				}
+				if repo.saved != nil {
+					t.Error("expected nothing to be persisted for unknown inspirations")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(repo.saved.Inspirations, tt.wantSaved) {
+				t.Errorf("expected inspirations %v, got %v", tt.wantSaved, repo.saved.Inspirations)
+			}
+		})
+	}
+
+	t.Run("profile update", func(t *testing.T) {
+		repo := &profileRepo{}
+		service := NewAuthService(repo, nil, nil)
+		if err := service.CompleteUserProfile(context.Background(), 1, profileRequest("daily")); err != nil {
+			t.Fatalf("CompleteUserProfile returned error: %v", err)
+		}
+
+		_, err := service.UpdateProfile(context.Background(), 1, UpdateProfileRequest{Inspirations: &[]string{"faith", "hoep"}})
+		if !errors.Is(err, ErrUnknownInspiration) || !strings.Contains(err.Error(), "hoep") {
+			t.Errorf("expected ErrUnknownInspiration naming hoep, got %v", err)
+		}
+		if _, err := service.UpdateProfile(context.Background(), 1, UpdateProfileRequest{Inspirations: &[]string{"Faith"}}); err != nil {
+			t.Errorf("expected a known inspiration to be accepted, got %v", err)
+		}
+	})
+}
+
+// recordingMailer captures queued emails instead of sending them.
+func TestUpdateProfileChangesOnlyProvidedFields(t *testing.T) {
+	repo := &profileRepo{}
+	svc := AuthService{repo: repo}
+
+	if err := svc.CompleteUserProfile(context.Background(), 1, profileRequest("daily")); err != nil {
+		t.Fatalf("CompleteUserProfile returned error: %v", err)
+	}
+	before := *repo.saved
+
+	name := " Grace "
+	if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{UserName: &name}); err != nil {
+		t.Fatalf("UpdateProfile returned error: %v", err)
+	}
+
+	if repo.saved.UserName != "Grace" {
+		t.Errorf("expected user name Grace, got %q", repo.saved.UserName)
+	}
+	if repo.saved.VersePace != before.VersePace ||
+		repo.saved.BibleTranslation != before.BibleTranslation ||
+		!repo.saved.SelectedTime.Equal(before.SelectedTime) {
+		t.Errorf("unrelated fields changed: before %+v, after %+v", before, *repo.saved)
+	}
+}
+
+func TestUpdateProfileValidatesPresentFields(t *testing.T) {
+	svc := AuthService{repo: &profileRepo{saved: &CompleteProfileRequest{}}}
+
+	pace := "fortnightly"
+	_, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{VersePace: &pace})
+	if !errors.Is(err, ErrInvalidVersePace) {
+		t.Fatalf("expected ErrInvalidVersePace, got %v", err)
+	}
+}
+
+func TestProfileUpdatesOnlySetsProvidedColumns(t *testing.T) {
+	translation := "NIV"
+	enabled := false
+
+	sets, args := profileUpdates(UpdateProfileRequest{BibleTranslation: &translation, EnableNotification: &enabled})
+
+	want := []string{"bible_translation = $1", "enable_notification = $2"}
+	if len(sets) != len(want) || sets[0] != want[0] || sets[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, sets)
+	}
+	if len(args) != 2 || args[0] != "NIV" || args[1] != false {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+// staticDailyVerse is a DailyVerseSource with a fixed answer.
+type staticDailyVerse struct{ verse *DailyVerse }
+
+func (s staticDailyVerse) DailyVerse(ctx context.Context) (*DailyVerse, error) {
+	return s.verse, nil
+}
+
+func TestWelcomeEmailIncludesVerseOfTheDay(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	tests := []struct {
+		name  string
+		verse *DailyVerse
+	}{
+		{"verse available", &DailyVerse{Reference: "John 3:16", Text: "For God so loved the world", Translation: "KJV"}},
+		{"no verses yet", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := &recordingMailer{}
+			cfg := &config.Config{SendWelcome: true, AppBaseURL: "https://memoryverse.app"}
+			service := NewAuthService(&registerRepo{users: map[string]*User{}}, mailer, cfg)
+			service.SetDailyVerseSource(staticDailyVerse{verse: tt.verse})
+
+			if _, err := service.Register(context.Background(), "new@example.com", "password1"); err != nil {
+				t.Fatalf("Register returned error: %v", err)
+			}
+			if len(mailer.data) != 1 {
+				t.Fatalf("expected one welcome email, got %d", len(mailer.data))
+			}
+			data := mailer.data[0].(map[string]interface{})
+
+			if tt.verse == nil {
+				for _, key := range []string{"VerseReference", "VerseText", "VerseTranslation"} {
+					if _, ok := data[key]; ok {
+						t.Errorf("expected %s to be omitted, got %v", key, data[key])
+					}
+				}
+				return
+			}
+			if data["VerseReference"] != "John 3:16" || data["VerseText"] != "For God so loved the world" || data["VerseTranslation"] != "KJV" {
+				t.Errorf("unexpected verse fields in %v", data)
+			}
+		})
+	}
+}
+
+type recordingMailer struct {
+	templates []string
+	to        []string
+	data      []interface{}
+}
+
+func (m *recordingMailer) SendHTML(to, subject, templateName string, data interface{}) error {
+	return m.SendHTMLWithHeaders(to, subject, templateName, data, nil)
+}
+
+func (m *recordingMailer) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	m.to = append(m.to, to)
+	m.templates = append(m.templates, templateName)
+	m.data = append(m.data, data)
+	return nil
+}
+
+func TestResendWelcomeEmailQueuesTemplateAndRateLimits(t *testing.T) {
+	repo := &stubRepo{users: map[int]*User{1: {ID: 1, Email: "new@example.com"}}}
+	mailer := &recordingMailer{}
+	service := NewAuthService(repo, mailer, &config.Config{AppBaseURL: "https://memoryverse.app"})
+
+	for i := 0; i < 3; i++ {
+		if err := service.ResendWelcomeEmail(context.Background(), 1); err != nil {
+			t.Fatalf("resend %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if len(mailer.templates) != 3 || mailer.templates[0] != "welcome.html" || mailer.to[0] != "new@example.com" {
+		t.Fatalf("expected welcome.html queued to new@example.com, got %v to %v", mailer.templates, mailer.to)
+	}
+
+	if err := service.ResendWelcomeEmail(context.Background(), 1); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("expected ErrTooManyRequests after the limit, got %v", err)
+	}
+	if len(mailer.templates) != 3 {
+		t.Errorf("expected rate-limited resend not to queue mail, got %d sends", len(mailer.templates))
+	}
+}
+
+func TestNewAuthServiceWithoutConfigUsesDefaults(t *testing.T) {
+	repo := &stubRepo{users: map[int]*User{1: {ID: 1, Email: "new@example.com"}}}
+	mailer := &recordingMailer{}
+	service := NewAuthService(repo, mailer, nil)
+
+	if err := service.ResendWelcomeEmail(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := mailer.data[0].(map[string]interface{})
+	if got := data["DashboardURL"]; got != config.DefaultAppBaseURL+"/dashboard" {
+		t.Errorf("expected the default dashboard link, got %v", got)
+	}
+}
+
+// registerRepo stores created users so Register can log them straight in.
+type registerRepo struct {
+	Repository
+	users map[string]*User
+}
+
+func (r *registerRepo) CreateUser(ctx context.Context, user User) (*User, error) {
+	if _, ok := r.users[user.Email]; ok {
+		return nil, ErrUserAlreadyExists
+	}
+	user.ID = len(r.users) + 1
+	r.users[user.Email] = &user
+	return &user, nil
+}
+
+func (r *registerRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, ok := r.users[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func TestValidateRegistration(t *testing.T) {
+	repo := &registerRepo{users: map[string]*User{"taken@example.com": {ID: 1, Email: "taken@example.com"}}}
+	service := NewAuthService(repo, &recordingMailer{}, &config.Config{})
+
+	tests := []struct {
+		name      string
+		req       RegisterRequest
+		wantField string
+	}{
+		{"taken email", RegisterRequest{Email: "taken@example.com", Password: "password1"}, "email"},
+		{"weak password", RegisterRequest{Email: "new@example.com", Password: "password"}, "password"},
+		{"valid", RegisterRequest{Email: "new@example.com", Password: "password1"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := service.ValidateRegistration(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantField == "" {
+				if len(errs) > 0 {
+					t.Errorf("expected no field errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Field != tt.wantField {
+				t.Errorf("expected one %s error, got %v", tt.wantField, errs)
+			}
+		})
+	}
+
+	if len(repo.users) != 1 {
+		t.Errorf("validation must not create users, have %d", len(repo.users))
+	}
+}
+
+func TestRegisterWelcomeEmailToggle(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	for _, send := range []bool{true, false} {
+		mailer := &recordingMailer{}
+		cfg := &config.Config{SendWelcome: send, AppBaseURL: "https://staging.memoryverse.app/"}
+		service := NewAuthService(&registerRepo{users: map[string]*User{}}, mailer, cfg)
+
+		if _, err := service.Register(context.Background(), "new@example.com", "password"); err != nil {
+			t.Fatalf("send=%v: Register returned error: %v", send, err)
+		}
+
+		if !send {
+			if len(mailer.templates) != 0 {
+				t.Errorf("expected no email with SEND_WELCOME_EMAIL off, got %v", mailer.templates)
+			}
+			continue
+		}
+
+		if len(mailer.templates) != 1 || mailer.templates[0] != "welcome.html" {
+			t.Fatalf("expected welcome.html to be queued, got %v", mailer.templates)
+		}
+		data := mailer.data[0].(map[string]interface{})
+		if got := data["DashboardURL"]; got != "https://staging.memoryverse.app/dashboard" {
+			t.Errorf("expected DashboardURL from the configured app URL, got %v", got)
+		}
+	}
+}
+
+func TestRegisterRejectsPwnedPasswords(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	lookupFailed := errors.New("connection refused")
+	tests := []struct {
+		name    string
+		check   bool
+		pwned   bool
+		lookup  error
+		wantErr error
+	}{
+		{"breached password", true, true, nil, ErrPasswordPwned},
+		{"clean password", true, false, nil, nil},
+		{"api unreachable fails open", true, false, lookupFailed, nil},
+		{"check disabled", false, true, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &registerRepo{users: map[string]*User{}}
+			service := NewAuthService(repo, &recordingMailer{}, &config.Config{CheckPwned: tt.check})
+			service.isPwned = func(ctx context.Context, password string) (bool, error) {
+				return tt.pwned, tt.lookup
+			}
+
+			_, err := service.Register(context.Background(), "new@example.com", "password1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if created := len(repo.users) == 1; created != (tt.wantErr == nil) {
+				t.Errorf("expected user created = %v", tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestResendWelcomeEmailUnknownUser(t *testing.T) {
+	service := NewAuthService(&stubRepo{users: map[int]*User{}}, &recordingMailer{}, &config.Config{})
+
+	// Failed lookups don't use up the quota, so they never turn into a 429
+	for i := 0; i < 5; i++ {
+		if err := service.ResendWelcomeEmail(context.Background(), 99); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("attempt %d: expected ErrUserNotFound, got %v", i+1, err)
+		}
+	}
+}
+
+func TestComputeProfileCompletion(t *testing.T) {
+	full := profileRequest("daily")
+	full.EnableNotification = true
+
+	tests := []struct {
+		name    string
+		profile *CompleteProfileRequest
+		want    int
+	}{
+		{"no profile", nil, 0},
+		{"empty profile", &CompleteProfileRequest{}, 0},
+		{"partial profile", &CompleteProfileRequest{VersePace: "daily", BibleTranslation: "KJV", UserName: "taiwo"}, 50},
+		{"one field", &CompleteProfileRequest{Inspirations: []string{"hope"}}, 17},
+		{"full profile", &full, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeProfileCompletion(&User{ID: 1}, tt.profile); got != tt.want {
+				t.Errorf("expected %d%%, got %d%%", tt.want, got)
+			}
+		})
+	}
+}
+
+// emailChangeRepo keeps pending email changes and records and limits
+// confirmed ones the way the repository does.
+type emailChangeRepo struct {
+	Repository
+	email   string
+	pending map[string]string // token hash -> new email
+	history []EmailChange
+}
+
+func (r *emailChangeRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if email == r.email {
+		return &User{ID: 1, Email: r.email}, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *emailChangeRepo) PatchUserProfile(ctx context.Context, userID int, req UpdateProfileRequest) error {
+	if req.Email != nil {
+		return errors.New("email must not be patched before it is confirmed")
+	}
+	return nil
+}
+
+func (r *emailChangeRepo) SavePendingEmailChange(ctx context.Context, userID int, email, tokenHash string, expiresAt time.Time) error {
+	r.pending = map[string]string{tokenHash: email}
+	return nil
+}
+
+func (r *emailChangeRepo) ConfirmEmailChange(ctx context.Context, tokenHash string) (int, error) {
+	email, ok := r.pending[tokenHash]
+	if !ok {
+		return 0, ErrInvalidEmailLink
+	}
+	if email != r.email {
+		if len(r.history) > 0 {
+			if next := r.history[0].ChangedAt.Add(emailChangeInterval); time.Now().Before(next) {
+				return 0, &EmailChangeTooSoonError{NextAllowedAt: next}
+			}
+		}
+		change := EmailChange{UserID: 1, OldEmail: r.email, NewEmail: email, ChangedAt: time.Now()}
+		r.history = append([]EmailChange{change}, r.history...)
+		r.email = email
+	}
+	delete(r.pending, tokenHash)
+	return 1, nil
+}
+
+// confirmToken pulls the token out of the link in the last mail sent.
+func confirmToken(t *testing.T, mailer *recordingMailer) string {
+	t.Helper()
+	if len(mailer.data) == 0 {
+		t.Fatal("expected a confirmation email")
+	}
+	link, err := url.Parse(mailer.data[len(mailer.data)-1].(map[string]interface{})["ConfirmURL"].(string))
+	if err != nil {
+		t.Fatalf("bad confirmation link: %v", err)
+	}
+	return link.Query().Get("token")
+}
+
+func TestUpdateProfileConfirmsNewEmailBeforeSwitching(t *testing.T) {
+	repo := &emailChangeRepo{email: "first@example.com"}
+	outbox, direct := &recordingMailer{}, &recordingMailer{}
+	svc := NewAuthService(repo, outbox, &config.Config{AppBaseURL: "https://memoryverse.app"})
+	svc.SetOTPMailer(direct)
+
+	email := "second@example.com"
+	sent, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{Email: &email})
+	if err != nil || !sent {
+		t.Fatalf("expected a confirmation to be sent, got %v (err %v)", sent, err)
+	}
+	if repo.email != "first@example.com" {
+		t.Errorf("email changed before confirmation: %q", repo.email)
+	}
+	if len(outbox.to) > 0 {
+		t.Errorf("confirmation link went through the outbox: %v", outbox.to)
+	}
+	if len(direct.to) != 1 || direct.to[0] != email || direct.templates[0] != "confirm_email.html" {
+		t.Fatalf("expected confirm_email.html sent to %s, got %v %v", email, direct.to, direct.templates)
+	}
+	link := direct.data[0].(map[string]interface{})["ConfirmURL"].(string)
+	if !strings.HasPrefix(link, "https://memoryverse.app/confirm-email?token=") {
+		t.Errorf("unexpected confirmation link %q", link)
+	}
+
+	if err := svc.ConfirmEmailChange(context.Background(), "wrong"); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected ErrInvalidEmailLink for a wrong token, got %v", err)
+	}
+	if err := svc.ConfirmEmailChange(context.Background(), confirmToken(t, direct)); err != nil {
+		t.Fatalf("confirm returned error: %v", err)
+	}
+	if repo.email != email {
+		t.Errorf("expected email %q after confirming, got %q", email, repo.email)
+	}
+
+	sent, err = svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{Email: &email})
+	if err != nil || sent || len(direct.to) != 1 {
+		t.Errorf("re-sending the current email should not send a confirmation, got %v (err %v)", sent, err)
+	}
+}
+
+func TestUpdateProfileRateLimitsEmailChanges(t *testing.T) {
+	repo := &emailChangeRepo{email: "first@example.com"}
+	mailer := &recordingMailer{}
+	svc := NewAuthService(repo, mailer, &config.Config{AppBaseURL: "https://memoryverse.app"})
+	changeTo := func(email string) error {
+		if _, err := svc.UpdateProfile(context.Background(), 1, UpdateProfileRequest{Email: &email}); err != nil {
+			return err
+		}
+		return svc.ConfirmEmailChange(context.Background(), confirmToken(t, mailer))
+	}
+
+	if err := changeTo("second@example.com"); err != nil {
+		t.Fatalf("first change returned error: %v", err)
+	}
+
+	err := changeTo("third@example.com")
+	var tooSoon *EmailChangeTooSoonError
+	if !errors.As(err, &tooSoon) || !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("expected EmailChangeTooSoonError, got %v", err)
+	}
+	if want := repo.history[0].ChangedAt.Add(emailChangeInterval); !tooSoon.NextAllowedAt.Equal(want) {
+		t.Errorf("expected next change at %v, got %v", want, tooSoon.NextAllowedAt)
+	}
+	if repo.email != "second@example.com" {
+		t.Errorf("rejected change was saved: %q", repo.email)
+	}
+
+	repo.history[0].ChangedAt = time.Now().Add(-emailChangeInterval - time.Minute)
+	if err := svc.ConfirmEmailChange(context.Background(), confirmToken(t, mailer)); err != nil {
+		t.Fatalf("confirming after the interval returned error: %v", err)
+	}
+	if len(repo.history) != 2 || repo.history[0].OldEmail != "second@example.com" {
+		t.Errorf("unexpected history: %+v", repo.history)
+	}
+}
+
+// staticTranslations is a TranslationSource that counts lookups.
+type staticTranslations struct {
+	available []string
+	calls     int
+}
+
+func (s *staticTranslations) AvailableTranslations(ctx context.Context) ([]string, error) {
+	s.calls++
+	return s.available, nil
+}
+
+func TestProfileTranslationMustHaveVerses(t *testing.T) {
+	repo := &profileRepo{}
+	source := &staticTranslations{available: []string{"KJV", "NIV"}}
+	service := NewAuthService(repo, nil, nil)
+	service.SetTranslationSource(source)
+
+	req := profileRequest("daily")
+	req.BibleTranslation = "esv"
+	err := service.CompleteUserProfile(context.Background(), 1, req)
+	if !errors.Is(err, ErrUnknownTranslation) || repo.saved != nil {
+		t.Fatalf("expected ErrUnknownTranslation and nothing saved, got %v", err)
+	}
+
+	if err := service.CompleteUserProfile(context.Background(), 1, profileRequest("daily")); err != nil {
+		t.Fatalf("expected KJV to be accepted, got %v", err)
+	}
+
+	esv, niv := "esv", "niv"
+	if _, err := service.UpdateProfile(context.Background(), 1, UpdateProfileRequest{BibleTranslation: &esv}); !errors.Is(err, ErrUnknownTranslation) {
+		t.Errorf("expected ErrUnknownTranslation on update, got %v", err)
+	}
+	if _, err := service.UpdateProfile(context.Background(), 1, UpdateProfileRequest{BibleTranslation: &niv}); err != nil {
+		t.Errorf("expected NIV to be accepted on update, got %v", err)
+	}
+	if source.calls != 4 {
+		t.Errorf("expected every save to consult the source, got %d calls", source.calls)
+	}
+
+	// With no verses loaded yet any translation is accepted
+	source.available = nil
+	if _, err := service.UpdateProfile(context.Background(), 1, UpdateProfileRequest{BibleTranslation: &esv}); err != nil {
+		t.Errorf("expected an empty catalogue to accept anything, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/validator"
+)
+
+const (
+	minUserNameLength = 2
+	maxUserNameLength = 30
+)
+
+// userNamePattern allows letters, digits, and . _ - so names stay safe in
+// email subjects and URLs.
+var userNamePattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N}._-]+$`)
+
+var reservedUserNames = map[string]bool{
+	"admin":   true,
+	"support": true,
+	"system":  true,
+}
+
+// ValidateUserName checks an already-trimmed user name and returns field
+// errors in the same shape as validator.Validate.
+func ValidateUserName(name string) []validator.FieldError {
+	fieldErr := func(msg string) []validator.FieldError {
+		return []validator.FieldError{{Field: "user_name", Message: "user_name " + msg}}
+	}
+
+	if n := utf8.RuneCountInString(name); n < minUserNameLength || n > maxUserNameLength {
+		return fieldErr("must be between 2 and 30 characters")
+	}
+	if !userNamePattern.MatchString(name) {
+		return fieldErr("may only contain letters, numbers, '.', '_' and '-'")
+	}
+	if reservedUserNames[strings.ToLower(name)] {
+		return fieldErr("is reserved")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUserName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"too short", "a", false},
+		{"too long", strings.Repeat("a", 31), false},
+		{"reserved", "Admin", false},
+		{"reserved support", "support", false},
+		{"invalid characters", "taiwo<script>", false},
+		{"contains space", "taiwo ajasa", false},
+		{"valid", "taiwo_ajasa", true},
+		{"valid at max length", strings.Repeat("a", 30), true},
+		{"valid unicode", "Adébáyọ̀", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateUserName(tt.input)
+			if tt.valid && len(errs) > 0 {
+				t.Errorf("expected %q to be valid, got %v", tt.input, errs)
+			}
+			if !tt.valid {
+				if len(errs) != 1 || errs[0].Field != "user_name" {
+					t.Errorf("expected a user_name field error for %q, got %v", tt.input, errs)
+				}
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/tracing"
 )
 
 // Service represents a service that interacts with a database.
@@ -27,16 +28,28 @@ type Service interface {
 	Close() error
 
 	DB() *sql.DB
+
+	// ReadDB returns the read replica for queries that tolerate lag, or
+	// the primary when no replica is configured.
+	ReadDB() *sql.DB
 }
 
 type service struct {
-	db *sql.DB
+	db     *sql.DB
+	readDB *sql.DB // nil without a replica
 }
 
 func (s *service) DB() *sql.DB {
 	return s.db
 }
 
+func (s *service) ReadDB() *sql.DB {
+	if s.readDB != nil {
+		return s.readDB
+	}
+	return s.db
+}
+
 var (
 	database   string
 	dbInstance *service
@@ -47,17 +60,8 @@ var (
 func New(cfg *config.Config) Service {
 	once.Do(func() {
 		database = cfg.DBName
-		connStr := fmt.Sprintf(
-			"postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s",
-			cfg.DBUser,
-			cfg.DBPassword,
-			cfg.DBHost,
-			cfg.DBPort,
-			cfg.DBName,
-			cfg.DBSchema,
-		)
-
-		db, err := sql.Open("pgx", connStr)
+
+		db, err := tracing.OpenDB("pgx", connString(cfg, cfg.DBHost))
 		if err != nil {
 			log.Fatalf("Failed to connect to database: %v", err)
 		}
@@ -66,13 +70,48 @@ func New(cfg *config.Config) Service {
 			log.Fatalf("Database not reachable: %v", err)
 		}
 
-		dbInstance = &service{db: db}
+		dbInstance = &service{db: db, readDB: openReplica(cfg)}
 		log.Println("Database connected successfully")
 	})
 
 	return dbInstance
 }
 
+// connString builds the Postgres URL for host from the shared settings.
+func connString(cfg *config.Config, host string) string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s",
+		cfg.DBUser,
+		cfg.DBPassword,
+		host,
+		cfg.DBPort,
+		cfg.DBName,
+		cfg.DBSchema,
+	)
+}
+
+// openReplica connects to the DB_READ_HOST replica. It returns nil, so reads
+// go to the primary, when none is configured or it can't be reached.
+func openReplica(cfg *config.Config) *sql.DB {
+	if cfg.DBReadHost == "" {
+		return nil
+	}
+
+	db, err := tracing.OpenDB("pgx", connString(cfg, cfg.DBReadHost))
+	if err != nil {
+		log.Printf("Failed to connect to read replica, reading from the primary: %v", err)
+		return nil
+	}
+	if err := db.Ping(); err != nil {
+		log.Printf("Read replica not reachable, reading from the primary: %v", err)
+		db.Close()
+		return nil
+	}
+
+	log.Println("Read replica connected successfully")
+	return db
+}
+
 // func New() Service {
 // 	// Reuse Connection
 // 	if dbInstance != nil {
@@ -146,5 +185,10 @@ func (s *service) Health() map[string]string {
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
 	log.Printf("Disconnected from database: %s", database)
+	if s.readDB != nil {
+		if err := s.readDB.Close(); err != nil {
+			log.Printf("Failed to close read replica: %v", err)
+		}
+	}
 	return s.db.Close()
 }
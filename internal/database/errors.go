@@ -0,0 +1,27 @@
+// Postgres error helpers
+
+package database
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the SQLSTATE Postgres returns when an insert or update
+// breaks a unique constraint or index.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err (or anything it wraps) is a Postgres
+// unique-violation error.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// IsUniqueViolationOn reports whether err is a unique violation of the named
+// constraint or index, so callers can tell one unique column from another.
+func IsUniqueViolationOn(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == constraint
+}
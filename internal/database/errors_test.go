@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505"}, true},
+		{"wrapped unique violation", fmt.Errorf("insert user: %w", &pgconn.PgError{Code: "23505"}), true},
+		{"other postgres error", &pgconn.PgError{Code: "23503"}, false},
+		{"plain error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolationOn(t *testing.T) {
+	violation := &pgconn.PgError{Code: "23505", ConstraintName: "idx_user_profiles_username_unique"}
+
+	if !IsUniqueViolationOn(fmt.Errorf("update profile: %w", violation), "idx_user_profiles_username_unique") {
+		t.Error("expected the named index to match")
+	}
+	if IsUniqueViolationOn(violation, "users_email_key") {
+		t.Error("expected a different constraint not to match")
+	}
+	if IsUniqueViolationOn(&pgconn.PgError{Code: "23503", ConstraintName: "idx_user_profiles_username_unique"}, "idx_user_profiles_username_unique") {
+		t.Error("expected a non-unique error not to match")
+	}
+}
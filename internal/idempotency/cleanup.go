@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartCleanupJob purges expired idempotency keys every interval until ctx
+// is cancelled.
+func StartCleanupJob(ctx context.Context, repo Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Idempotency key cleanup started (%s interval)\n", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Idempotency key cleanup stopped gracefully")
+			return
+		case <-ticker.C:
+			runCleanup(ctx, repo, time.Now())
+		}
+	}
+}
+
+func runCleanup(ctx context.Context, repo Repository, now time.Time) {
+	n, err := repo.DeleteExpired(ctx, now.Add(-KeyTTL))
+	if err != nil {
+		log.Printf("Failed to purge expired idempotency keys: %v", err)
+		return
+	}
+
+	log.Printf("Purged %d expired idempotency keys", n)
+}
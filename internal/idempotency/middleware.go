@@ -0,0 +1,129 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+)
+
+// HeaderKey is the request header clients use to make a POST safe to retry.
+const HeaderKey = "Idempotency-Key"
+
+const maxKeyLength = 255
+
+// Middleware replays the stored response when an authenticated user repeats a
+// request with the same Idempotency-Key, instead of running the handler again.
+// The key is claimed before the handler runs, so a concurrent repeat gets a
+// 409, and reusing it for a different method, path or body gets a 422.
+// Requests without the header are passed through unchanged. It must run after
+// auth.AuthMiddleware.
+func Middleware(repo Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxKeyLength {
+				response.Error(w, http.StatusBadRequest, "Invalid Idempotency-Key", "key must be at most 255 characters")
+				return
+			}
+
+			userID, ok := auth.GetUserIDFromContext(r)
+			if !ok {
+				response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			stored, err := repo.Claim(r.Context(), userID, key, requestHash(r, body))
+			switch {
+			case errors.Is(err, ErrKeyReused):
+				response.Error(w, http.StatusUnprocessableEntity, "Invalid Idempotency-Key", err.Error())
+				return
+			case errors.Is(err, ErrInProgress):
+				response.Error(w, http.StatusConflict, "Request in progress", err.Error())
+				return
+			case err != nil:
+				log.Printf("idempotency claim failed: %v", err)
+				response.Error(w, http.StatusInternalServerError, "Failed to process request", err.Error())
+				return
+			case stored != nil:
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+				return
+			}
+
+			// Give the key back after a failure, including a panic, so the
+			// request can be retried
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := repo.Release(context.WithoutCancel(r.Context()), userID, key); err != nil {
+					log.Printf("failed to release idempotency key: %v", err)
+				}
+			}()
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Only successful results are replayed; failures may be retried for
+			// real. A success keeps its claim even if storing it fails, since
+			// running it again would repeat the write.
+			if rec.status >= 200 && rec.status < 300 {
+				completed = true
+				resp := StoredResponse{StatusCode: rec.status, Body: rec.body.Bytes()}
+				if err := repo.Complete(r.Context(), userID, key, resp); err != nil {
+					log.Printf("failed to store idempotency key: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// requestHash identifies a request by method, path and body, so a key can't
+// be replayed for a different request.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,201 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// memoryRepo claims keys under a mutex, like the unique key does in SQL.
+type memoryRepo struct {
+	mu   sync.Mutex
+	keys map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	hash string
+	resp *StoredResponse
+}
+
+func (m *memoryRepo) Claim(ctx context.Context, userID int, key, requestHash string) (*StoredResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := fmt.Sprintf("%d:%s", userID, key)
+	entry, ok := m.keys[id]
+	if !ok {
+		m.keys[id] = &memoryEntry{hash: requestHash}
+		return nil, nil
+	}
+	if entry.hash != requestHash {
+		return nil, ErrKeyReused
+	}
+	if entry.resp == nil {
+		return nil, ErrInProgress
+	}
+	return entry.resp, nil
+}
+
+func (m *memoryRepo) Complete(ctx context.Context, userID int, key string, resp StoredResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[fmt.Sprintf("%d:%s", userID, key)].resp = &resp
+	return nil
+}
+
+func (m *memoryRepo) Release(ctx context.Context, userID int, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := fmt.Sprintf("%d:%s", userID, key)
+	if entry, ok := m.keys[id]; ok && entry.resp == nil {
+		delete(m.keys, id)
+	}
+	return nil
+}
+
+func (m *memoryRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newKeyRequest(t *testing.T, path, body, key string) *http.Request {
+	t.Helper()
+	token, err := util.GenerateJWT(7, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(HeaderKey, key)
+	return req
+}
+
+func TestMiddlewareReplaysRepeatedKey(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	token, err := util.GenerateJWT(7, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	created := 0
+	createNote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		created++
+		response.Success(w, map[string]int{"id": created}, "successfully")
+	})
+
+	repo := &memoryRepo{keys: map[string]*memoryEntry{}}
+	handler := auth.AuthMiddleware(Middleware(repo)(createNote))
+
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/save-note", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set(HeaderKey, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("abc-123")
+	second := send("abc-123")
+
+	if created != 1 {
+		t.Fatalf("expected a single note to be created, got %d", created)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("expected replayed body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected replayed response to be marked")
+	}
+
+	send("")
+	if created != 2 {
+		t.Errorf("expected a request without a key to create a new note, got %d notes", created)
+	}
+}
+
+func TestMiddlewareRejectsConcurrentRepeat(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &memoryRepo{keys: map[string]*memoryEntry{}}
+	var inner *httptest.ResponseRecorder
+	var handler http.Handler
+	slowNote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The same key arrives while this request is still running
+		inner = httptest.NewRecorder()
+		handler.ServeHTTP(inner, newKeyRequest(t, "/save-note", `{}`, "abc-123"))
+		response.Success(w, "saved", "successfully")
+	})
+	handler = auth.AuthMiddleware(Middleware(repo)(slowNote))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newKeyRequest(t, "/save-note", `{}`, "abc-123"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to succeed, got %d", rec.Code)
+	}
+	if inner.Code != http.StatusConflict {
+		t.Errorf("expected 409 for the concurrent repeat, got %d", inner.Code)
+	}
+}
+
+func TestMiddlewareBindsKeyToRequest(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &memoryRepo{keys: map[string]*memoryEntry{}}
+	saveNote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Success(w, "saved", "successfully")
+	})
+	handler := auth.AuthMiddleware(Middleware(repo)(saveNote))
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"first use", "/save-note", `{"note":"a"}`, http.StatusOK},
+		{"different body", "/save-note", `{"note":"b"}`, http.StatusUnprocessableEntity},
+		{"different path", "/other", `{"note":"a"}`, http.StatusUnprocessableEntity},
+		{"same request", "/save-note", `{"note":"a"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newKeyRequest(t, tt.path, tt.body, "abc-123"))
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestMiddlewareReleasesKeyAfterFailure(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &memoryRepo{keys: map[string]*memoryEntry{}}
+	calls := 0
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			response.Error(w, http.StatusInternalServerError, "Failed", "boom")
+			return
+		}
+		response.Success(w, "saved", "successfully")
+	})
+	handler := auth.AuthMiddleware(Middleware(repo)(flaky))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newKeyRequest(t, "/save-note", `{}`, "abc-123"))
+	}
+	if calls != 2 {
+		t.Errorf("expected the retry after a failure to run, got %d calls", calls)
+	}
+}
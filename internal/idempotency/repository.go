@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/database"
+)
+
+// KeyTTL is how long a processed key is replayed before it can be reused.
+const KeyTTL = 24 * time.Hour
+
+var (
+	// ErrInProgress means another request holding the key hasn't finished.
+	ErrInProgress = errors.New("a request with this idempotency key is still being processed")
+	// ErrKeyReused means the key was first used for a different request.
+	ErrKeyReused = errors.New("idempotency key was already used for a different request")
+)
+
+// StoredResponse is the response recorded for a processed idempotency key.
+type StoredResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Repository persists idempotency keys per user.
+type Repository interface {
+	// Claim takes the key for a request identified by requestHash. It returns
+	// nil when the caller now owns the key, the stored response when the same
+	// request already finished, or ErrInProgress / ErrKeyReused.
+	Claim(ctx context.Context, userID int, key, requestHash string) (*StoredResponse, error)
+	// Complete stores the response for a claimed key so repeats replay it.
+	Complete(ctx context.Context, userID int, key string, resp StoredResponse) error
+	// Release gives up a claimed key so the request can be retried for real.
+	Release(ctx context.Context, userID int, key string) error
+	// DeleteExpired removes keys created before the given time.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(dbService database.Service) Repository {
+	return &repository{db: dbService.DB()}
+}
+
+func (r *repository) Claim(ctx context.Context, userID int, key, requestHash string) (*StoredResponse, error) {
+	// The insert is the claim, so two concurrent requests can't both win. An
+	// expired key is taken over; a live one is left untouched.
+	var claimed bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			body = NULL,
+			created_at = NOW()
+		WHERE idempotency_keys.created_at <= $4
+		RETURNING TRUE
+	`, userID, key, requestHash, time.Now().Add(-KeyTTL)).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var (
+		storedHash string
+		status     sql.NullInt64
+		body       []byte
+	)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT request_hash, status_code, body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, userID, key).Scan(&storedHash, &status, &body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Released between the two statements
+			return nil, ErrInProgress
+		}
+		return nil, err
+	}
+
+	if storedHash != requestHash {
+		return nil, ErrKeyReused
+	}
+	if !status.Valid {
+		return nil, ErrInProgress
+	}
+	return &StoredResponse{StatusCode: int(status.Int64), Body: body}, nil
+}
+
+func (r *repository) Complete(ctx context.Context, userID int, key string, resp StoredResponse) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, body = $4
+		WHERE user_id = $1 AND key = $2
+	`, userID, key, resp.StatusCode, resp.Body)
+	return err
+}
+
+func (r *repository) Release(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND status_code IS NULL
+	`, userID, key)
+	return err
+}
+
+func (r *repository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -2,56 +2,201 @@ package mail
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/smtp"
+	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/tracing"
 )
 
+// Sender is implemented by anything that can render and send templated emails.
+type Sender interface {
+	SendHTML(to, subject, templateName string, data interface{}) error
+	SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error
+}
+
 type Mailer struct {
 	FromName string
 	From     string
+	ReplyTo  string
 	Password string
 	Host     string
 	Port     string
-	auth     smtp.Auth
+	// Timeout bounds dialing and the whole SMTP conversation for one attempt.
+	Timeout time.Duration
+	// MaxRetries is how many extra attempts are made after a transient failure.
+	MaxRetries int
+	// RequireTLS aborts delivery when the server cannot upgrade to TLS.
+	RequireTLS bool
+	// PreviewLength caps verse text in emails via the preview template
+	// function; 0 leaves it untruncated.
+	PreviewLength int
+	auth          smtp.Auth
 }
 
-func NewMail(from, fromName, password, host, port string) *Mailer {
+func NewMail(from, fromName, replyTo, password, host, port string) *Mailer {
 	auth := smtp.PlainAuth("", from, password, host)
 	return &Mailer{
 		FromName: fromName,
 		From:     from,
+		ReplyTo:  replyTo,
 		Password: password,
 		Host:     host,
 		Port:     port,
+		Timeout:  10 * time.Second,
 		auth:     auth,
 	}
 }
 
 func (m *Mailer) SendHTML(to, subject, templateName string, data interface{}) error {
-	// Parse your HTML template
-	tmpl, err := template.ParseFiles(fmt.Sprintf("internal/mail/templates/%s", templateName))
+	return m.SendHTMLWithHeaders(to, subject, templateName, data, nil)
+}
+
+// SendHTMLWithHeaders renders and sends an HTML template, adding the given
+// extra headers (e.g. List-Unsubscribe) to the message.
+//
+// Sender carries no context, so each send is traced as its own root span.
+func (m *Mailer) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	_, span := tracing.Tracer().Start(context.Background(), "mail send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("mail.template", templateName)),
+	)
+	defer span.End()
+	fail := func(err error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	html, err := m.render(templateName, data)
+	if err != nil {
+		fail(err)
+		return err
+	}
+
+	msg, err := m.buildMessage(to, subject, headers, html)
+	if err != nil {
+		fail(err)
+		return err
+	}
+
+	if err := m.deliver([]string{to}, msg); err != nil {
+		fail(err)
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+
+}
+
+// templateDir is where email templates are loaded from, relative to the working directory.
+var templateDir = "internal/mail/templates"
+
+// render executes the named template with the mailer's template functions.
+func (m *Mailer) render(templateName string, data interface{}) ([]byte, error) {
+	tmpl, err := template.New(templateName).
+		Funcs(m.templateFuncs()).
+		ParseFiles(filepath.Join(templateDir, templateName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return html.Bytes(), nil
+}
+
+// templateFuncs lets templates shorten long verses so the email isn't
+// clipped, linking to the dashboard for the full text:
+//
+//	{{preview .Verse}}{{if isTruncated .Verse}} <a href="...">Read full verse</a>{{end}}
+func (m *Mailer) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"preview": func(s string) string {
+			text, _ := truncateText(s, m.PreviewLength)
+			return text
+		},
+		"isTruncated": func(s string) bool {
+			_, truncated := truncateText(s, m.PreviewLength)
+			return truncated
+		},
+	}
+}
+
+// truncateText shortens s to at most max runes plus an ellipsis, breaking at
+// a word boundary where possible. max <= 0 means no limit.
+func truncateText(s string, max int) (string, bool) {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s, false
+	}
+
+	cut := string(runes[:max])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " \t\n,;:.") + "…", true
+}
+
+// buildMessage assembles the headers and HTML body into a raw RFC 5322 message.
+func (m *Mailer) buildMessage(to, subject string, headers map[string]string, html []byte) ([]byte, error) {
+	messageID, err := m.newMessageID()
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to generate message id: %w", err)
 	}
 
 	var body bytes.Buffer
 	body.WriteString("MIME-Version: 1.0\r\n")
 	body.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	body.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	body.WriteString(fmt.Sprintf("Message-ID: %s\r\n", messageID))
 	body.WriteString(fmt.Sprintf("From: %s <%s>\r\n", m.FromName, m.From))
+	if m.ReplyTo != "" {
+		body.WriteString(fmt.Sprintf("Reply-To: %s\r\n", m.ReplyTo))
+	}
 	body.WriteString(fmt.Sprintf("To: %s\r\n", to))
-	body.WriteString(fmt.Sprintf("Subject: %s\r\n\r\n", subject))
 
-	// Render the HTML body
-	if err := tmpl.Execute(&body, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	// Extra headers are written in a stable order
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		body.WriteString(fmt.Sprintf("%s: %s\r\n", k, headers[k]))
 	}
 
-	addr := fmt.Sprintf("%s:%s", m.Host, m.Port)
-	if err := smtp.SendMail(addr, m.auth, m.From, []string{to}, body.Bytes()); err != nil {
-		return fmt.Errorf("failed to send mail: %w", err)
+	body.WriteString(fmt.Sprintf("Subject: %s\r\n\r\n", subject))
+	body.Write(html)
+
+	return body.Bytes(), nil
+}
+
+// newMessageID returns a unique Message-ID scoped to the sender's domain.
+func (m *Mailer) newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
 
-	return nil
+	domain := m.Host
+	if at := strings.LastIndex(m.From, "@"); at != -1 {
+		domain = m.From[at+1:]
+	}
 
+	return fmt.Sprintf("<%x.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain), nil
 }
@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMessageHeaders(t *testing.T) {
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "support@memoryverse.app", "secret", "smtp.example.com", "587")
+
+	msg, err := m.buildMessage("user@example.com", "Hello", nil, []byte("<p>hi</p>"))
+	if err != nil {
+		t.Fatalf("buildMessage returned error: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if got := parsed.Header.Get("From"); got != "Memory Verse <noreply@memoryverse.app>" {
+		t.Errorf("unexpected From header: %q", got)
+	}
+	if got := parsed.Header.Get("Reply-To"); got != "support@memoryverse.app" {
+		t.Errorf("unexpected Reply-To header: %q", got)
+	}
+
+	date, err := parsed.Header.Date()
+	if err != nil {
+		t.Fatalf("invalid Date header: %v", err)
+	}
+	if time.Since(date) > time.Minute {
+		t.Errorf("Date header is not current: %v", date)
+	}
+
+	messageID := parsed.Header.Get("Message-ID")
+	if !regexp.MustCompile(`^<[0-9a-f]+\.[0-9a-f]{32}@memoryverse\.app>$`).MatchString(messageID) {
+		t.Errorf("unexpected Message-ID header: %q", messageID)
+	}
+}
+
+func TestBuildMessageOmitsEmptyReplyTo(t *testing.T) {
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "secret", "smtp.example.com", "587")
+
+	msg, err := m.buildMessage("user@example.com", "Hello", nil, []byte("<p>hi</p>"))
+	if err != nil {
+		t.Fatalf("buildMessage returned error: %v", err)
+	}
+
+	if strings.Contains(string(msg), "Reply-To:") {
+		t.Errorf("expected no Reply-To header, got message:\n%s", msg)
+	}
+}
+
+func TestBuildMessageExtraHeaders(t *testing.T) {
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "secret", "smtp.example.com", "587")
+
+	msg, err := m.buildMessage("user@example.com", "Hello", map[string]string{
+		"List-Unsubscribe":      "<https://api.memoryverse.app/unsubscribe?token=abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}, []byte("<p>hi</p>"))
+	if err != nil {
+		t.Fatalf("buildMessage returned error: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<https://api.memoryverse.app/unsubscribe?token=abc>" {
+		t.Errorf("unexpected List-Unsubscribe header: %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected List-Unsubscribe-Post header: %q", got)
+	}
+}
+
+func TestRenderTruncatesLongVerse(t *testing.T) {
+	templateDir = "templates"
+	t.Cleanup(func() { templateDir = "internal/mail/templates" })
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "secret", "smtp.example.com", "587")
+	m.PreviewLength = 40
+
+	verse := "In the beginning was the Word, and the Word was with God, and the Word was God."
+	data := map[string]interface{}{
+		"UserName":       "Taiwo",
+		"Verse":          verse,
+		"Reference":      "John 1:1",
+		"Pace":           "daily",
+		"DashboardURL":   "https://memoryverse.app/dashboard",
+		"UnsubscribeURL": "https://memoryverse.app/unsubscribe",
+		"AppURL":         "https://memoryverse.app",
+	}
+
+	html, err := m.render("verse.html", data)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	body := string(html)
+
+	if strings.Contains(body, verse) {
+		t.Error("expected the long verse to be truncated")
+	}
+	if !strings.Contains(body, "“In the beginning was the Word, and the…”") {
+		t.Errorf("expected a word-boundary preview with an ellipsis, got:\n%s", body)
+	}
+	if !strings.Contains(body, `<a href="https://memoryverse.app/dashboard" class="read-more">Read full verse</a>`) {
+		t.Error("expected a read full verse link to the dashboard")
+	}
+
+	data["Verse"] = "Jesus wept."
+	html, err = m.render("verse.html", data)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if !strings.Contains(string(html), "“Jesus wept.”") || strings.Contains(string(html), "Read full verse") {
+		t.Error("expected a short verse to be shown in full without a read more link")
+	}
+}
@@ -0,0 +1,130 @@
+package mail
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// deliver sends msg, retrying with a fresh connection when an attempt fails
+// for a transient reason (dropped connection, timeout, 4xx reply).
+func (m *Mailer) deliver(to []string, msg []byte) error {
+	var err error
+	for attempt := 0; attempt <= m.MaxRetries; attempt++ {
+		if attempt > 0 {
+			// Simple linear backoff between attempts
+			time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+			log.Printf("retrying mail send (attempt %d): %v", attempt+1, err)
+		}
+
+		err = m.sendOnce(to, msg)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// sendOnce dials the SMTP server and performs a single delivery. Unlike
+// smtp.SendMail, every step is bounded by the configured timeout.
+func (m *Mailer) sendOnce(to []string, msg []byte) error {
+	addr := net.JoinHostPort(m.Host, m.Port)
+
+	conn, err := net.DialTimeout("tcp", addr, m.Timeout)
+	if err != nil {
+		return err
+	}
+	if m.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(m.Timeout)); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := m.startTLS(c); err != nil {
+		return err
+	}
+
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(m.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.Mail(m.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// The server has accepted the message; a failed QUIT must not trigger a
+	// retry or the user gets it twice
+	if err := c.Quit(); err != nil {
+		log.Printf("smtp QUIT failed after the message was accepted: %v", err)
+	}
+	return nil
+}
+
+// ErrTLSRequired is returned when RequireTLS is set and the server does not offer STARTTLS.
+var ErrTLSRequired = errors.New("smtp server does not support STARTTLS")
+
+// startTLS upgrades the connection when the server offers STARTTLS. With
+// RequireTLS set, a server without STARTTLS is rejected before any credentials are sent.
+func (m *Mailer) startTLS(c *smtp.Client) error {
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		if m.RequireTLS {
+			return ErrTLSRequired
+		}
+		return nil
+	}
+	return c.StartTLS(m.tlsConfig())
+}
+
+// isTransient reports whether a failed send is worth retrying: network
+// failures and 4xx replies. Anything else, such as a 5xx rejection or a TLS
+// certificate problem, is returned to the caller immediately.
+func isTransient(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// The server hung up mid-conversation
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (m *Mailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.Host}
+}
@@ -0,0 +1,234 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal plaintext SMTP server for exercising the transport.
+type fakeSMTPServer struct {
+	ln         net.Listener
+	dropFirst  int // number of connections to close before greeting
+	extensions []string
+	dropOnQuit bool // hang up instead of answering QUIT; guarded by mu
+
+	mu        sync.Mutex
+	conns     int
+	delivered []string
+}
+
+func newFakeSMTPServer(t *testing.T, dropFirst int, extensions ...string) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &fakeSMTPServer{ln: ln, dropFirst: dropFirst, extensions: extensions}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) hostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return host, port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns++
+		drop := s.conns <= s.dropFirst
+		s.mu.Unlock()
+
+		if drop {
+			conn.Close()
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake.smtp ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			lines := append([]string{"fake.smtp"}, s.extensions...)
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				reply("250" + sep + l)
+			}
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, body.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			s.mu.Lock()
+			drop := s.dropOnQuit
+			s.mu.Unlock()
+			if !drop {
+				reply("221 bye")
+			}
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) deliveredCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.delivered)
+}
+
+func TestDeliverRetriesDroppedConnection(t *testing.T) {
+	srv := newFakeSMTPServer(t, 1)
+	host, port := srv.hostPort()
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "", host, port)
+	m.Timeout = 2 * time.Second
+	m.MaxRetries = 2
+
+	if err := m.deliver([]string{"user@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err != nil {
+		t.Fatalf("expected delivery to succeed on retry, got %v", err)
+	}
+
+	if got := srv.deliveredCount(); got != 1 {
+		t.Errorf("expected 1 delivered message, got %d", got)
+	}
+}
+
+func TestDeliverGivesUpAfterMaxRetries(t *testing.T) {
+	srv := newFakeSMTPServer(t, 5)
+	host, port := srv.hostPort()
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "", host, port)
+	m.Timeout = 2 * time.Second
+	m.MaxRetries = 1
+
+	if err := m.deliver([]string{"user@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err == nil {
+		t.Fatal("expected delivery to fail once retries are exhausted")
+	}
+
+	if got := srv.deliveredCount(); got != 0 {
+		t.Errorf("expected no delivered messages, got %d", got)
+	}
+}
+
+func TestDeliverRejectsPlaintextServerWhenTLSRequired(t *testing.T) {
+	srv := newFakeSMTPServer(t, 0, "AUTH PLAIN")
+	host, port := srv.hostPort()
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "secret", host, port)
+	m.Timeout = 2 * time.Second
+	m.MaxRetries = 2
+	m.RequireTLS = true
+
+	err := m.deliver([]string{"user@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n"))
+	if !errors.Is(err, ErrTLSRequired) {
+		t.Fatalf("expected ErrTLSRequired, got %v", err)
+	}
+
+	if got := srv.deliveredCount(); got != 0 {
+		t.Errorf("expected no delivered messages, got %d", got)
+	}
+}
+
+func TestDeliverAllowsPlaintextServerByDefault(t *testing.T) {
+	srv := newFakeSMTPServer(t, 0)
+	host, port := srv.hostPort()
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "", host, port)
+	m.Timeout = 2 * time.Second
+
+	if err := m.deliver([]string{"user@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err != nil {
+		t.Fatalf("expected delivery to succeed, got %v", err)
+	}
+}
+
+func TestDeliverDoesNotResendWhenQuitFails(t *testing.T) {
+	srv := newFakeSMTPServer(t, 0)
+	srv.mu.Lock()
+	srv.dropOnQuit = true
+	srv.mu.Unlock()
+	host, port := srv.hostPort()
+
+	m := NewMail("noreply@memoryverse.app", "Memory Verse", "", "", host, port)
+	m.Timeout = 2 * time.Second
+	m.MaxRetries = 2
+
+	if err := m.deliver([]string{"user@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err != nil {
+		t.Fatalf("expected an accepted message to count as delivered, got %v", err)
+	}
+	if got := srv.deliveredCount(); got != 1 {
+		t.Errorf("expected 1 delivered message, got %d", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"4xx reply", &textproto.Error{Code: 421, Msg: "try later"}, true},
+		{"5xx reply", &textproto.Error{Code: 550, Msg: "no such user"}, false},
+		{"dropped connection", io.EOF, true},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"tls required", ErrTLSRequired, false},
+		{"other error", errors.New("x509: certificate signed by unknown authority"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package memoryverse
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartInactivityJob sends comeback reminders to inactive users every
+// interval until ctx is cancelled. It does nothing when INACTIVITY_REMINDER_DAYS
+// is 0, the default.
+func (s *MemoryVerseService) StartInactivityJob(ctx context.Context, interval time.Duration) {
+	if s.inactivityDays() <= 0 || interval <= 0 {
+		log.Println("Inactivity reminders disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Inactivity reminder job started (%s interval, after %d days)\n", interval, s.inactivityDays())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Inactivity reminder job stopped gracefully")
+			return
+		case <-ticker.C:
+			s.runInactivityReminders(ctx, time.Now())
+		}
+	}
+}
+
+func (s *MemoryVerseService) inactivityDays() int {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.InactivityDays
+}
+
+// runInactivityReminders emails comeback.html to every user who is due one.
+func (s *MemoryVerseService) runInactivityReminders(ctx context.Context, now time.Time) {
+	days := s.inactivityDays()
+	users, err := s.repo.GetInactiveUsers(ctx, now.AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Failed to fetch inactive users: %v", err)
+		return
+	}
+
+	sent := 0
+	for _, user := range users {
+		if !comebackDue(user, now) {
+			continue
+		}
+
+		unsubscribeURL, headers := s.unsubscribeLinks(user.ID)
+		data := map[string]interface{}{
+			"UserName":       user.UserName,
+			"Days":           days,
+			"DashboardURL":   s.cfg.AppURL("/dashboard"),
+			"UnsubscribeURL": unsubscribeURL,
+			"AppURL":         s.cfg.AppURL(""),
+		}
+		if err := s.mail.SendHTMLWithHeaders(user.Email, "Your verses are waiting for you", "comeback.html", data, headers); err != nil {
+			log.Printf("Failed to send comeback.html to %s: %v", user.Email, err)
+			continue
+		}
+		if err := s.repo.MarkReminderSent(ctx, user.ID, now); err != nil {
+			log.Printf("Could not record comeback reminder for %d: %v", user.ID, err)
+		}
+		sent++
+	}
+
+	log.Printf("Sent %d comeback reminders to %d inactive users", sent, len(users))
+}
+
+// comebackDue reports whether an inactive user should get a reminder now:
+// they aren't snoozed and haven't had one since they were last active, so
+// each stretch of inactivity gets one reminder.
+func comebackDue(user InactiveUser, now time.Time) bool {
+	if user.SnoozedUntil != nil && user.SnoozedUntil.After(now) {
+		return false
+	}
+	return user.LastReminderAt == nil || user.LastReminderAt.Before(user.LastActiveAt)
+}
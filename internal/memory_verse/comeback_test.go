@@ -0,0 +1,57 @@
+package memoryverse
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+)
+
+func TestRunInactivityRemindersTargetsOnlyEligibleUsers(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	now := time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	at := func(t time.Time) *time.Time { return &t }
+
+	repo := &fakeVerseRepo{inactive: []InactiveUser{
+		{ID: 1, Email: "lapsed@example.com", UserName: "ada", LastActiveAt: daysAgo(20)},
+		{ID: 2, Email: "active@example.com", LastActiveAt: daysAgo(3)},
+		{ID: 3, Email: "snoozed@example.com", LastActiveAt: daysAgo(20), SnoozedUntil: at(now.Add(time.Hour))},
+		{ID: 4, Email: "reminded@example.com", LastActiveAt: daysAgo(30), LastReminderAt: at(daysAgo(10))},
+		// Came back after their last reminder, then lapsed again
+		{ID: 5, Email: "relapsed@example.com", LastActiveAt: daysAgo(15), LastReminderAt: at(daysAgo(40))},
+		{ID: 6, Email: "snooze-over@example.com", LastActiveAt: daysAgo(20), SnoozedUntil: at(daysAgo(1))},
+	}}
+	mailer := &fakeMailer{}
+	s := &MemoryVerseService{
+		repo: repo,
+		mail: mailer,
+		cfg:  &config.Config{ApiBaseURL: "https://api.memoryverse.app", InactivityDays: 14},
+	}
+
+	s.runInactivityReminders(context.Background(), now)
+
+	var got []string
+	for _, m := range mailer.sent {
+		if m.Template != "comeback.html" {
+			t.Errorf("unexpected template %s", m.Template)
+		}
+		if m.Headers["List-Unsubscribe"] == "" {
+			t.Errorf("expected unsubscribe headers on the reminder to %s", m.To)
+		}
+		got = append(got, m.To)
+	}
+	want := []string{"lapsed@example.com", "relapsed@example.com", "snooze-over@example.com"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected reminders to %v, got %v", want, got)
+	}
+
+	// A day later nobody is reminded twice; only the lapsed snooze is due
+	s.runInactivityReminders(context.Background(), now.Add(24*time.Hour))
+	if len(mailer.sent) != len(want)+1 || mailer.sent[len(want)].To != "snoozed@example.com" {
+		t.Errorf("expected one more reminder, to snoozed@example.com, got %+v", mailer.sent[len(want):])
+	}
+}
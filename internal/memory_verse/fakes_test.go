@@ -0,0 +1,1155 @@
+package memoryverse
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
+)
+
+// fakeAuthRepo overrides only the auth.Repository methods the scheduler uses;
+// calling anything else panics on the nil embedded interface.
+type fakeAuthRepo struct {
+	auth.Repository
+
+	mu       sync.Mutex
+	users    []auth.User
+	profiles map[int]*auth.CompleteProfileRequest
+	slots    map[int][]time.Time
+	lastSent map[int]time.Time
+	reached  map[int]bool   // users paused at their verse goal
+	feeds    map[string]int // feed token hash -> user ID
+
+	deliveryTimeBatches int // GetDeliveryTimesForUsers calls
+}
+
+func (f *fakeAuthRepo) GetUserIDByFeedTokenHash(ctx context.Context, hash string) (int, error) {
+	userID, ok := f.feeds[hash]
+	if !ok {
+		return 0, auth.ErrUserNotFound
+	}
+	return userID, nil
+}
+
+func (f *fakeAuthRepo) GetAllUsersWithVersePace(ctx context.Context) ([]auth.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeAuthRepo) GetUserDeliveryTimes(ctx context.Context, userID int) ([]time.Time, error) {
+	return f.slots[userID], nil
+}
+
+func (f *fakeAuthRepo) GetDeliveryTimesForUsers(ctx context.Context, userIDs []int) (map[int][]time.Time, error) {
+	f.deliveryTimeBatches++
+	times := make(map[int][]time.Time, len(userIDs))
+	for _, id := range userIDs {
+		if slots, ok := f.slots[id]; ok {
+			times[id] = slots
+		}
+	}
+	return times, nil
+}
+
+func (f *fakeAuthRepo) GetUserWithProfile(ctx context.Context, userID int) (*auth.User, *auth.CompleteProfileRequest, error) {
+	for _, u := range f.users {
+		if u.ID == userID {
+			u := u
+			return &u, f.profiles[userID], nil
+		}
+	}
+	return nil, nil, auth.ErrUserNotFound
+}
+
+func (f *fakeAuthRepo) UpdateLastVerseSentAt(ctx context.Context, userID int, t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastSent == nil {
+		f.lastSent = map[int]time.Time{}
+	}
+	f.lastSent[userID] = t
+	return nil
+}
+
+func (f *fakeAuthRepo) MarkVerseGoalReached(ctx context.Context, userID int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reached[userID] {
+		return false, nil
+	}
+	if f.reached == nil {
+		f.reached = map[int]bool{}
+	}
+	f.reached[userID] = true
+	return true, nil
+}
+
+// fakeVerseRepo serves a fixed pool of verses and records deliveries.
+type fakeVerseRepo struct {
+	MemoryVerseRepo
+
+	mu         sync.Mutex
+	verses     []Verse
+	delivered  map[int][]int
+	claimed    map[int]int         // userID -> verse recorded by ClaimDeliveredVerse
+	favourites map[int][]int       // userID -> favourited verse IDs
+	positions  map[int]map[int]int // userID -> verseID -> favourites position
+	history    map[int][]VerseHistory
+
+	collections []Collection
+	members     map[int][]int // collectionID -> verse IDs
+
+	prompts    map[int][]string             // verseID -> reflection prompts
+	guests     map[string]map[int]time.Time // guestID -> verseID -> served at
+	sends      map[string]int               // tracking token -> user ID
+	variants   map[string]string            // tracking token -> subject variant
+	opens      map[string]int               // tracking token -> open count
+	clicks     map[string][]string          // tracking token -> clicked destinations
+	notes      map[int][]UserNotes          // userID -> saved notes
+	attached   map[int][]NoteAttachment     // noteID -> attachments
+	commentary map[int]string               // verseID -> commentary
+	shares     map[string]*SharedFavourites // token hash -> favourites share
+	daily      map[string]int               // YYYY-MM-DD -> verse of the day ID
+	shown      map[int][]int                // userID -> verse IDs shown on the dashboard
+	inactive   []InactiveUser               // subscribed users and when they were last active
+	raw        map[int]string               // verseID -> raw_verse, when cleaning changed the text
+	reports    []VerseReport                // in filing order
+
+	// profileTranslations and inspirations stand in for user_profiles and
+	// user_inspirations in coverage reports
+	profileTranslations map[int]string
+	inspirations        map[int][]string
+
+	// lastSort is the sort passed to the last list call
+	lastSort string
+
+	// call counters for cache tests
+	popularCalls     int
+	translationCalls int
+	recentCalls      int
+	streakCalls      int
+}
+
+func (f *fakeVerseRepo) GetRandomVerse(ctx context.Context, userID int, translation string) (*Verse, error) {
+	if len(f.verses) == 0 {
+		return nil, ErrNotFound
+	}
+	v := f.verses[0]
+	return &v, nil
+}
+
+// GetRandomGuestVerse returns the first matching verse so tests are deterministic.
+func (f *fakeVerseRepo) GetRandomGuestVerse(ctx context.Context, guestID, translation string, since time.Time) (*Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, v := range f.verses {
+		if translation != "" && v.Translation != translation {
+			continue
+		}
+		if served, ok := f.guests[guestID][v.ID]; ok && !served.Before(since) {
+			continue
+		}
+		v := v
+		return &v, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeVerseRepo) SaveGuestVerse(ctx context.Context, guestID string, verseID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.guests == nil {
+		f.guests = map[string]map[int]time.Time{}
+	}
+	if f.guests[guestID] == nil {
+		f.guests[guestID] = map[int]time.Time{}
+	}
+	f.guests[guestID][verseID] = time.Now()
+	return nil
+}
+
+func (f *fakeVerseRepo) GetVersesByIDs(ctx context.Context, userID int, ids []int) ([]Verse, error) {
+	var verses []Verse
+	for _, id := range ids {
+		for _, v := range f.verses {
+			if v.ID == id {
+				verses = append(verses, v)
+			}
+		}
+	}
+	return verses, nil
+}
+
+func (f *fakeVerseRepo) GetWeeklyVerses(ctx context.Context, userID int, count int) ([]Verse, error) {
+	if count > len(f.verses) {
+		count = len(f.verses)
+	}
+	return f.verses[:count], nil
+}
+
+func (f *fakeVerseRepo) GetLastDeliveredVerse(ctx context.Context, userID int) (*VerseHistory, error) {
+	history := f.history[userID]
+	if len(history) == 0 {
+		return nil, ErrNotFound
+	}
+	last := history[len(history)-1]
+	return &last, nil
+}
+
+// SaveDeliveredVerse mirrors the per-day unique guard; every fake delivery
+// happens "today".
+func (f *fakeVerseRepo) SaveDeliveredVerse(ctx context.Context, userID, verseID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.delivered == nil {
+		f.delivered = map[int][]int{}
+	}
+	for _, id := range f.delivered[userID] {
+		if id == verseID {
+			return nil
+		}
+	}
+	f.delivered[userID] = append(f.delivered[userID], verseID)
+	return nil
+}
+
+// ClaimDeliveredVerse treats earlier claims as newer than after, so the
+// first claim wins and later ones get its verse.
+func (f *fakeVerseRepo) ClaimDeliveredVerse(ctx context.Context, userID, verseID int, after *time.Time) (*Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id, ok := f.claimed[userID]; ok {
+		for _, v := range f.verses {
+			if v.ID == id {
+				return &v, nil
+			}
+		}
+		return &Verse{ID: id}, nil
+	}
+	if f.claimed == nil {
+		f.claimed = map[int]int{}
+	}
+	if f.delivered == nil {
+		f.delivered = map[int][]int{}
+	}
+	f.claimed[userID] = verseID
+	f.delivered[userID] = append(f.delivered[userID], verseID)
+	return nil, nil
+}
+
+func (f *fakeVerseRepo) CreateVerse(ctx context.Context, verse NewVerse) (*Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.insertVerseLocked(verse), nil
+}
+
+func (f *fakeVerseRepo) BulkInsertVerses(ctx context.Context, verses []NewVerse) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range verses {
+		f.insertVerseLocked(v)
+	}
+	return len(verses), nil
+}
+
+func (f *fakeVerseRepo) insertVerseLocked(verse NewVerse) *Verse {
+	v := Verse{ID: len(f.verses) + 1, Reference: verse.Reference, Verse: verse.Verse, Translation: verse.Translation, CreatedAt: time.Now()}
+	f.verses = append(f.verses, v)
+	if verse.RawVerse != verse.Verse {
+		if f.raw == nil {
+			f.raw = map[int]string{}
+		}
+		f.raw[v.ID] = verse.RawVerse
+	}
+	return &v
+}
+
+func (f *fakeVerseRepo) GetPopularVerses(ctx context.Context, limit int) ([]Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.popularCalls++
+
+	counts := map[int]int{}
+	for _, ids := range f.favourites {
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+
+	var popular []Verse
+	for _, v := range f.verses {
+		if counts[v.ID] > 0 {
+			v.FavouriteCount = counts[v.ID]
+			popular = append(popular, v)
+		}
+	}
+	sort.SliceStable(popular, func(i, j int) bool {
+		return popular[i].FavouriteCount > popular[j].FavouriteCount
+	})
+	if len(popular) > limit {
+		popular = popular[:limit]
+	}
+	return popular, nil
+}
+
+// ToggleFavouriteVerse is atomic under the mutex, as the locked toggle is in
+// the real repo.
+func (f *fakeVerseRepo) ToggleFavouriteVerse(ctx context.Context, userID, verseID, limit int) (*FavouriteState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.favourites == nil {
+		f.favourites = map[int][]int{}
+	}
+	favs := f.favourites[userID]
+	for i, id := range favs {
+		if id == verseID {
+			f.favourites[userID] = append(favs[:i:i], favs[i+1:]...)
+			return &FavouriteState{VerseID: verseID, FavouriteCount: f.favouriteCount(verseID)}, nil
+		}
+	}
+	if limit > 0 && len(favs) >= limit {
+		return nil, &LimitExceededError{Resource: "favourites", Limit: limit, Count: len(favs)}
+	}
+	f.favourites[userID] = append(favs, verseID)
+	return &FavouriteState{VerseID: verseID, IsFavourite: true, FavouriteCount: f.favouriteCount(verseID)}, nil
+}
+
+// favouriteCount counts the users who favourited verseID; f.mu must be held.
+func (f *fakeVerseRepo) favouriteCount(verseID int) int {
+	n := 0
+	for _, ids := range f.favourites {
+		for _, id := range ids {
+			if id == verseID {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// BulkToggleFavourites mirrors the real repo: unknown ids fail the whole batch.
+func (f *fakeVerseRepo) BulkToggleFavourites(ctx context.Context, userID int, add, remove []int, limit int) ([]FavouriteState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	known := map[int]bool{}
+	for _, v := range f.verses {
+		known[v.ID] = true
+	}
+	for _, id := range append(append([]int{}, add...), remove...) {
+		if !known[id] {
+			return nil, ErrNotFound
+		}
+	}
+
+	if f.favourites == nil {
+		f.favourites = map[int][]int{}
+	}
+	favs := map[int]bool{}
+	for _, id := range f.favourites[userID] {
+		favs[id] = true
+	}
+	for _, id := range add {
+		favs[id] = true
+	}
+	for _, id := range remove {
+		delete(favs, id)
+	}
+	if count := len(f.favourites[userID]); limit > 0 && len(favs) > count && len(favs) > limit {
+		return nil, &LimitExceededError{Resource: "favourites", Limit: limit, Count: count}
+	}
+
+	f.favourites[userID] = nil
+	for id := range favs {
+		f.favourites[userID] = append(f.favourites[userID], id)
+	}
+	sort.Ints(f.favourites[userID])
+
+	var states []FavouriteState
+	for _, id := range append(append([]int{}, add...), remove...) {
+		states = append(states, FavouriteState{VerseID: id, IsFavourite: favs[id], FavouriteCount: f.favouriteCount(id)})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].VerseID < states[j].VerseID })
+	return states, nil
+}
+
+func (f *fakeVerseRepo) CreateCollection(ctx context.Context, userID int, name string) (*Collection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.collections {
+		if c.UserID == userID && strings.EqualFold(c.Name, name) {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	c := Collection{ID: len(f.collections) + 1, UserID: userID, Name: name}
+	f.collections = append(f.collections, c)
+	return &c, nil
+}
+
+// ownedCollection mirrors the repo's scoping: other users' collections don't exist.
+func (f *fakeVerseRepo) ownedCollection(userID, collectionID int) error {
+	for _, c := range f.collections {
+		if c.ID == collectionID && c.UserID == userID {
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (f *fakeVerseRepo) AddVerseToCollection(ctx context.Context, userID, collectionID, verseID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.ownedCollection(userID, collectionID); err != nil {
+		return err
+	}
+	if f.members == nil {
+		f.members = map[int][]int{}
+	}
+	for _, id := range f.members[collectionID] {
+		if id == verseID {
+			return nil
+		}
+	}
+	for _, v := range f.verses {
+		if v.ID == verseID {
+			f.members[collectionID] = append(f.members[collectionID], verseID)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (f *fakeVerseRepo) RemoveVerseFromCollection(ctx context.Context, userID, collectionID, verseID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.ownedCollection(userID, collectionID); err != nil {
+		return err
+	}
+	ids := f.members[collectionID][:0]
+	for _, id := range f.members[collectionID] {
+		if id != verseID {
+			ids = append(ids, id)
+		}
+	}
+	f.members[collectionID] = ids
+	return nil
+}
+
+func (f *fakeVerseRepo) GetCollectionVerses(ctx context.Context, userID, collectionID int) ([]Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.ownedCollection(userID, collectionID); err != nil {
+		return nil, err
+	}
+	var verses []Verse
+	for _, id := range f.members[collectionID] {
+		for _, v := range f.verses {
+			if v.ID == id {
+				verses = append(verses, v)
+			}
+		}
+	}
+	return verses, nil
+}
+
+func (f *fakeVerseRepo) StreamUserVerseHistory(ctx context.Context, userID int, rng HistoryRange, fn func(VerseHistory) error) error {
+	for _, h := range f.history[userID] {
+		if rng.From != nil && h.DeliveredAt.Before(*rng.From) {
+			continue
+		}
+		if rng.To != nil && !h.DeliveredAt.Before(*rng.To) {
+			continue
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeVerseRepo) GetTranslations(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.translationCalls++
+
+	seen := map[string]bool{}
+	var translations []string
+	for _, v := range f.verses {
+		if !seen[v.Translation] {
+			seen[v.Translation] = true
+			translations = append(translations, v.Translation)
+		}
+	}
+	sort.Strings(translations)
+	return translations, nil
+}
+
+func (f *fakeVerseRepo) GetUserNotes(ctx context.Context, userID int, sort string) ([]UserNotes, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastSort = sort
+
+	var notes []UserNotes
+	for _, n := range f.notes[userID] {
+		n.Attachments = f.attached[n.ID]
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+// SearchUserNotes stands in for the full-text search: a note matches when its
+// content contains query, and ranks by how often it does.
+func (f *fakeVerseRepo) SearchUserNotes(ctx context.Context, userID int, query string, limit, offset int) ([]NoteSearchResult, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query = strings.ToLower(query)
+	var matches []NoteSearchResult
+	for _, n := range f.notes[userID] {
+		if hits := strings.Count(strings.ToLower(n.Content), query); hits > 0 {
+			n.Attachments = f.attached[n.ID]
+			matches = append(matches, NoteSearchResult{UserNotes: n, Rank: float64(hits), Snippet: n.Content})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Rank > matches[j].Rank })
+
+	total := len(matches)
+	if offset >= total {
+		return nil, total, nil
+	}
+	return matches[offset:min(offset+limit, total)], total, nil
+}
+
+// ownsNote mirrors the repository: another user's note is ErrNotFound.
+// Callers hold f.mu.
+func (f *fakeVerseRepo) ownsNote(userID, noteID int) error {
+	for _, n := range f.notes[userID] {
+		if n.ID == noteID {
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (f *fakeVerseRepo) AttachNoteFile(ctx context.Context, userID, noteID int, url, contentType string, limit int) (*NoteAttachment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ownsNote(userID, noteID); err != nil {
+		return nil, err
+	}
+
+	if f.attached == nil {
+		f.attached = map[int][]NoteAttachment{}
+	}
+	for i, a := range f.attached[noteID] {
+		if a.URL == url {
+			f.attached[noteID][i].ContentType = contentType
+			a.ContentType = contentType
+			return &a, nil
+		}
+	}
+	if count := len(f.attached[noteID]); count >= limit {
+		return nil, &LimitExceededError{Resource: "attachments", Limit: limit, Count: count}
+	}
+	id := 1
+	for _, as := range f.attached {
+		for _, a := range as {
+			id = max(id, a.ID+1)
+		}
+	}
+	a := NoteAttachment{ID: id, NoteID: noteID, URL: url, ContentType: contentType, CreatedAt: time.Now()}
+	f.attached[noteID] = append(f.attached[noteID], a)
+	return &a, nil
+}
+
+func (f *fakeVerseRepo) GetInactiveUsers(ctx context.Context, since time.Time) ([]InactiveUser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []InactiveUser
+	for _, u := range f.inactive {
+		if u.LastActiveAt.Before(since) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeVerseRepo) MarkReminderSent(ctx context.Context, userID int, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.inactive {
+		if f.inactive[i].ID == userID {
+			f.inactive[i].LastReminderAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeVerseRepo) DetachNoteFile(ctx context.Context, userID, noteID, attachmentID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ownsNote(userID, noteID); err != nil {
+		return err
+	}
+	for i, a := range f.attached[noteID] {
+		if a.ID == attachmentID {
+			f.attached[noteID] = append(f.attached[noteID][:i], f.attached[noteID][i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// favouritedAt is when the fake says the user's nth favourite was saved.
+var favouritedAt = time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+
+// GetUserFavouriteVerses pages newest first whatever the sort; the n-th
+// favourite in f.favourites has ID n+1 and was saved n minutes after
+// favouritedAt.
+func (f *fakeVerseRepo) GetUserFavouriteVerses(ctx context.Context, userID int, sort string, after *pagination.Cursor, limit int) ([]FavouriteVerse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastSort = sort
+
+	var favourites []FavouriteVerse
+	ids := f.favourites[userID]
+	for i := len(ids) - 1; i >= 0; i-- {
+		fav := FavouriteVerse{ID: i + 1, UserID: userID, VerseID: ids[i], CreatedAt: favouritedAt.Add(time.Duration(i) * time.Minute)}
+		if after != nil && !fav.CreatedAt.Before(after.LastCreatedAt) {
+			continue
+		}
+		if len(favourites) == limit {
+			break
+		}
+		favourites = append(favourites, fav)
+	}
+	if sort == SortPosition {
+		positions := f.positions[userID]
+		slices.SortStableFunc(favourites, func(a, b FavouriteVerse) int {
+			return positions[a.VerseID] - positions[b.VerseID]
+		})
+	}
+	return favourites, nil
+}
+
+func (f *fakeVerseRepo) ReorderFavourites(ctx context.Context, userID int, verseIDs []int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	favourited := map[int]bool{}
+	for _, id := range f.favourites[userID] {
+		favourited[id] = true
+	}
+	if err := checkFavouriteOrder(favourited, verseIDs); err != nil {
+		return err
+	}
+
+	if f.positions == nil {
+		f.positions = map[int]map[int]int{}
+	}
+	f.positions[userID] = map[int]int{}
+	for i, id := range verseIDs {
+		f.positions[userID][id] = i + 1
+	}
+	return nil
+}
+
+// GetUserVerseHistoryPage pages f.history, which tests keep newest first.
+func (f *fakeVerseRepo) GetUserVerseHistoryPage(ctx context.Context, userID int, after *pagination.Cursor, limit int) ([]VerseHistory, error) {
+	var histories []VerseHistory
+	for _, h := range f.history[userID] {
+		if after != nil && (h.DeliveredAt.After(after.LastCreatedAt) ||
+			h.DeliveredAt.Equal(after.LastCreatedAt) && h.VerseID >= after.LastID) {
+			continue
+		}
+		if len(histories) == limit {
+			break
+		}
+		histories = append(histories, h)
+	}
+	return histories, nil
+}
+
+func (f *fakeVerseRepo) GetVersePrompts(ctx context.Context, verseID int) ([]string, error) {
+	return f.prompts[verseID], nil
+}
+
+func (f *fakeVerseRepo) GetVerseCommentary(ctx context.Context, verseID int) (string, error) {
+	for _, v := range f.verses {
+		if v.ID == verseID {
+			return f.commentary[verseID], nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+func (f *fakeVerseRepo) SetVerseCommentary(ctx context.Context, verseID int, commentary string) error {
+	for _, v := range f.verses {
+		if v.ID == verseID {
+			if f.commentary == nil {
+				f.commentary = map[int]string{}
+			}
+			f.commentary[verseID] = commentary
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// CreateVerseReport mirrors the partial unique index: one open report per
+// user and verse.
+func (f *fakeVerseRepo) CreateVerseReport(ctx context.Context, userID, verseID int, reason string) (*VerseReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !slices.ContainsFunc(f.verses, func(v Verse) bool { return v.ID == verseID }) {
+		return nil, ErrNotFound
+	}
+	for _, rep := range f.reports {
+		if rep.UserID == userID && rep.VerseID == verseID && rep.Status == ReportStatusOpen {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	rep := VerseReport{
+		ID:        len(f.reports) + 1,
+		VerseID:   verseID,
+		UserID:    userID,
+		Reason:    reason,
+		Status:    ReportStatusOpen,
+		CreatedAt: time.Now().Add(time.Duration(len(f.reports)) * time.Second),
+	}
+	f.reports = append(f.reports, rep)
+	return &rep, nil
+}
+
+// GetVerseReports lists reports newest first, filtered by status when given.
+func (f *fakeVerseRepo) GetVerseReports(ctx context.Context, status string, limit, offset int) ([]VerseReport, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matches []VerseReport
+	for i := len(f.reports) - 1; i >= 0; i-- {
+		if status == "" || f.reports[i].Status == status {
+			matches = append(matches, f.reports[i])
+		}
+	}
+
+	total := len(matches)
+	if offset >= total {
+		return nil, total, nil
+	}
+	return matches[offset:min(offset+limit, total)], total, nil
+}
+
+// ResolveVerseReport keeps the first resolved_at, as the COALESCE does.
+func (f *fakeVerseRepo) ResolveVerseReport(ctx context.Context, reportID int) (*VerseReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.reports {
+		if rep := &f.reports[i]; rep.ID == reportID {
+			if rep.ResolvedAt == nil {
+				now := time.Now()
+				rep.ResolvedAt = &now
+			}
+			rep.Status = ReportStatusResolved
+			resolved := *rep
+			return &resolved, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateFavouriteShare copies the user's favourites, newest first.
+func (f *fakeVerseRepo) CreateFavouriteShare(ctx context.Context, userID int, tokenHash, displayName string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	shared := &SharedFavourites{DisplayName: displayName, SharedAt: time.Now(), ExpiresAt: expiresAt}
+	ids := f.favourites[userID]
+	for i := len(ids) - 1; i >= 0; i-- {
+		for _, v := range f.verses {
+			if v.ID == ids[i] {
+				v.IsFavourite = true
+				shared.Verses = append(shared.Verses, v)
+			}
+		}
+	}
+
+	if f.shares == nil {
+		f.shares = map[string]*SharedFavourites{}
+	}
+	f.shares[tokenHash] = shared
+	return nil
+}
+
+func (f *fakeVerseRepo) GetFavouriteShare(ctx context.Context, tokenHash string) (*SharedFavourites, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	shared, ok := f.shares[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *shared
+	return &copied, nil
+}
+
+func (f *fakeVerseRepo) SaveDailyVerse(ctx context.Context, day string, verseID int) (*Verse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.daily == nil {
+		f.daily = map[string]int{}
+	}
+	if _, ok := f.daily[day]; !ok {
+		f.daily[day] = verseID
+	}
+	for _, v := range f.verses {
+		if v.ID == f.daily[day] {
+			return &v, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeVerseRepo) GetDailyVerseArchive(ctx context.Context, rng HistoryRange, limit, offset int) ([]PublicDailyVerse, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var days []PublicDailyVerse
+	for day, verseID := range f.daily {
+		t, _ := time.Parse(time.DateOnly, day)
+		if t.Before(*rng.From) || !t.Before(*rng.To) {
+			continue
+		}
+		for _, v := range f.verses {
+			if v.ID == verseID {
+				days = append(days, PublicDailyVerse{Reference: v.Reference, Verse: v.Verse, Translation: v.Translation, Date: day})
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date > days[j].Date })
+
+	total := len(days)
+	if offset > total {
+		offset = total
+	}
+	days = days[offset:]
+	if len(days) > limit {
+		days = days[:limit]
+	}
+	return days, total, nil
+}
+
+func (f *fakeVerseRepo) CountDailyVerseStreak(ctx context.Context, day string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.streakCalls++
+
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return 0, err
+	}
+	var streak int
+	for ; ; t = t.AddDate(0, 0, -1) {
+		if _, ok := f.daily[t.Format(time.DateOnly)]; !ok {
+			return streak, nil
+		}
+		streak++
+	}
+}
+
+func (f *fakeVerseRepo) GetFavouriteSummary(ctx context.Context, userID int) ([]TranslationFavourites, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, id := range f.favourites[userID] {
+		for _, v := range f.verses {
+			if v.ID == id {
+				counts[v.Translation]++
+			}
+		}
+	}
+
+	var groups []TranslationFavourites
+	for translation, n := range counts {
+		groups = append(groups, TranslationFavourites{Translation: translation, Count: n})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Translation < groups[j].Translation
+	})
+	return groups, nil
+}
+
+// GetRelatedVerses matches on chapterOf, keeping f.verses order.
+func (f *fakeVerseRepo) GetRelatedVerses(ctx context.Context, userID, verseID, limit int) ([]Verse, error) {
+	var current *Verse
+	for i := range f.verses {
+		if f.verses[i].ID == verseID {
+			current = &f.verses[i]
+		}
+	}
+	if current == nil {
+		return nil, ErrNotFound
+	}
+
+	chapter, ok := chapterOf(current.Reference)
+	if !ok {
+		return nil, nil
+	}
+	var related []Verse
+	for _, v := range f.verses {
+		if v.ID == verseID || v.Translation != current.Translation {
+			continue
+		}
+		if c, ok := chapterOf(v.Reference); ok && c == chapter && len(related) < limit {
+			related = append(related, v)
+		}
+	}
+	return related, nil
+}
+
+func (f *fakeVerseRepo) RecordVerseImpression(ctx context.Context, userID, verseID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.shown == nil {
+		f.shown = map[int][]int{}
+	}
+	f.shown[userID] = append(f.shown[userID], verseID)
+	return nil
+}
+
+// CountDeliveredVersesSince ignores since: every fake delivery happens "today".
+func (f *fakeVerseRepo) CountDeliveredVersesSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.delivered[userID]), nil
+}
+
+// impressionsFor reads f.shown under the lock, for tests racing the
+// background impression write.
+func (f *fakeVerseRepo) impressionsFor(userID int) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.shown[userID]...)
+}
+
+func (f *fakeVerseRepo) CreateEmailSend(ctx context.Context, token string, userID, verseID int, subjectVariant string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.sends == nil {
+		f.sends = map[string]int{}
+		f.variants = map[string]string{}
+	}
+	f.sends[token] = userID
+	f.variants[token] = subjectVariant
+	return nil
+}
+
+func (f *fakeVerseRepo) RecordEmailOpen(ctx context.Context, token string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.sends[token]; !ok {
+		return false, nil
+	}
+	if f.opens == nil {
+		f.opens = map[string]int{}
+	}
+	f.opens[token]++
+	return true, nil
+}
+
+func (f *fakeVerseRepo) RecordEmailClick(ctx context.Context, token, destination string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.sends[token]; !ok {
+		return false, nil
+	}
+	if f.clicks == nil {
+		f.clicks = map[string][]string{}
+	}
+	f.clicks[token] = append(f.clicks[token], destination)
+	return true, nil
+}
+
+func (f *fakeVerseRepo) GetEmailOpenStats(ctx context.Context) (*EmailOpenStats, error) {
+	stats := &EmailOpenStats{Sent: len(f.sends), Opened: len(f.opens)}
+	for _, n := range f.opens {
+		stats.TotalOpens += n
+	}
+	return stats, nil
+}
+
+func (f *fakeVerseRepo) GetRecentVerses(ctx context.Context, limit, offset int) ([]Verse, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recentCalls++
+
+	verses := append([]Verse(nil), f.verses...)
+	sort.SliceStable(verses, func(i, j int) bool { return verses[i].CreatedAt.After(verses[j].CreatedAt) })
+	if offset >= len(verses) {
+		return nil, len(verses), nil
+	}
+	end := offset + limit
+	if end > len(verses) {
+		end = len(verses)
+	}
+	return verses[offset:end], len(verses), nil
+}
+
+func (f *fakeVerseRepo) GetFavouritedVerseIDs(ctx context.Context, userID int, verseIDs []int) (map[int]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	favourited := map[int]bool{}
+	for _, id := range f.favourites[userID] {
+		favourited[id] = true
+	}
+	return favourited, nil
+}
+
+func (f *fakeVerseRepo) IsVerseFavourited(ctx context.Context, userID, verseID int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range f.favourites[userID] {
+		if id == verseID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeVerseRepo) SaveUserNote(ctx context.Context, userID int, verseRef, content string, dedupeWindow time.Duration, limit int) (*UserNotes, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for _, n := range f.notes[userID] {
+		if n.VerseReference == verseRef && n.Content == content && now.Sub(n.CreatedAt) < dedupeWindow {
+			return &n, nil
+		}
+	}
+
+	if count := len(f.notes[userID]); limit > 0 && count >= limit {
+		return nil, &LimitExceededError{Resource: "notes", Limit: limit, Count: count}
+	}
+	if f.notes == nil {
+		f.notes = map[int][]UserNotes{}
+	}
+	note := UserNotes{ID: len(f.notes[userID]) + 1, VerseReference: verseRef, Content: content, CreatedAt: now, UpdatedAt: now}
+	f.notes[userID] = append(f.notes[userID], note)
+	return &note, nil
+}
+
+func (f *fakeVerseRepo) GetTranslationCoverage(ctx context.Context) ([]TranslationCoverage, error) {
+	counts := map[string]*TranslationCoverage{}
+	entry := func(translation string) *TranslationCoverage {
+		if counts[translation] == nil {
+			counts[translation] = &TranslationCoverage{Translation: translation}
+		}
+		return counts[translation]
+	}
+	for _, v := range f.verses {
+		entry(v.Translation).VerseCount++
+	}
+	for _, t := range f.profileTranslations {
+		entry(t).UserCount++
+	}
+
+	var coverage []TranslationCoverage
+	for _, c := range counts {
+		coverage = append(coverage, *c)
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Translation < coverage[j].Translation })
+	return coverage, nil
+}
+
+func (f *fakeVerseRepo) GetTopicCoverage(ctx context.Context) ([]TopicCoverage, error) {
+	counts := map[string]int{}
+	for _, topics := range f.inspirations {
+		for _, t := range topics {
+			counts[t]++
+		}
+	}
+
+	var coverage []TopicCoverage
+	for t, n := range counts {
+		coverage = append(coverage, TopicCoverage{Topic: t, UserCount: n})
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].UserCount != coverage[j].UserCount {
+			return coverage[i].UserCount > coverage[j].UserCount
+		}
+		return coverage[i].Topic < coverage[j].Topic
+	})
+	return coverage, nil
+}
+
+func (f *fakeVerseRepo) GetAllUserVerseHistory(ctx context.Context, userID int) ([]VerseHistory, error) {
+	return f.history[userID], nil
+}
+
+type sentMail struct {
+	To       string
+	Subject  string
+	Template string
+	Data     interface{}
+	Headers  map[string]string
+}
+
+// fakeMailer records every email instead of sending it.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+	// err, when set, fails every send without recording it
+	err error
+}
+
+func (f *fakeMailer) SendHTML(to, subject, templateName string, data interface{}) error {
+	return f.SendHTMLWithHeaders(to, subject, templateName, data, nil)
+}
+
+func (f *fakeMailer) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentMail{To: to, Subject: subject, Template: templateName, Data: data, Headers: headers})
+	return nil
+}
+
+func (f *fakeMailer) templatesFor(to string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var templates []string
+	for _, m := range f.sent {
+		if m.To == to {
+			templates = append(templates, m.Template)
+		}
+	}
+	return templates
+}
@@ -0,0 +1,104 @@
+package memoryverse
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// feedItemLimit caps how many recent deliveries the RSS feed lists.
+const feedItemLimit = 50
+
+var ErrInvalidFeedToken = errors.New("invalid feed token")
+
+// RSSFeed is an RSS 2.0 document of a user's delivered verses.
+type RSSFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []RSSItem `xml:"item"`
+}
+
+type RSSItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        RSSGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type RSSGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// GetFeedService builds the RSS feed of recent verses for the user owning
+// the feed token.
+func (s *MemoryVerseService) GetFeedService(ctx context.Context, token string) (*RSSFeed, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidFeedToken
+	}
+
+	userID, err := s.authRepo.GetUserIDByFeedTokenHash(ctx, util.HashFeedToken(token))
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, ErrInvalidFeedToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	histories, err := s.repo.GetAllUserVerseHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user verse history: %w", err)
+	}
+	if len(histories) > feedItemLimit {
+		histories = histories[:feedItemLimit]
+	}
+
+	return buildFeed(histories, s.cfg.AppURL("/dashboard")), nil
+}
+
+// buildFeed turns delivered verses into RSS items; encoding/xml escapes the
+// verse text when the feed is written.
+func buildFeed(histories []VerseHistory, link string) *RSSFeed {
+	items := make([]RSSItem, 0, len(histories))
+	for _, h := range histories {
+		title := h.Verse.Reference
+		if h.Verse.Translation != "" {
+			title = fmt.Sprintf("%s (%s)", h.Verse.Reference, h.Verse.Translation)
+		}
+
+		items = append(items, RSSItem{
+			Title:       title,
+			Link:        link,
+			Description: h.Verse.Verse,
+			GUID: RSSGUID{
+				Value: fmt.Sprintf("memoryverse-%d-%d", h.VerseID, h.DeliveredAt.Unix()),
+			},
+			PubDate: h.DeliveredAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return &RSSFeed{
+		Version: "2.0",
+		Channel: RSSChannel{
+			Title:       "My Memoryverse",
+			Link:        link,
+			Description: "Verses delivered to you by Memoryverse",
+			Items:       items,
+		},
+	}
+}
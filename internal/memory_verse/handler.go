@@ -1,11 +1,23 @@
 package memoryverse
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/validator"
 )
 
 type MemoryVerseHandler struct {
@@ -29,6 +41,10 @@ func (h *MemoryVerseHandler) GetDashboardVerseHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	if verse != nil {
+		h.service.RecordImpression(r.Context(), userID, verse.ID)
+	}
+
 	if notes == nil {
 		notes = []UserNotes{}
 	}
@@ -41,9 +57,32 @@ func (h *MemoryVerseHandler) GetDashboardVerseHandler(w http.ResponseWriter, r *
 		"verse":         verse,
 		"notes":         notes,
 		"verse_history": histories,
+		"goal_progress": h.service.GetGoalProgress(r.Context(), *user),
 	}, "successfully")
 }
 
+// GetUpcomingHandler previews the user's next delivery without sending or
+// recording anything.
+func (h *MemoryVerseHandler) GetUpcomingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	upcoming, err := h.service.UpcomingService(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "No verse available", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get upcoming verse", err.Error())
+		return
+	}
+
+	response.Success(w, upcoming, "successfully")
+}
+
 func (h *MemoryVerseHandler) UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserIDFromContext(r)
 	if !ok {
@@ -60,41 +99,297 @@ func (h *MemoryVerseHandler) UnsubscribeHandler(w http.ResponseWriter, r *http.R
 	response.Success(w, "Ok", "successfully")
 }
 
-func (h *MemoryVerseHandler) ToggleFavouriteVerseHandler(w http.ResponseWriter, r *http.Request) {
+func (h *MemoryVerseHandler) SnoozeHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserIDFromContext(r)
 	if !ok {
 		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
 		return
 	}
 
-	var req AddToFavouriteRequest
+	var req SnoozeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
 		return
 	}
 
-	if req.VerseID == 0 {
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	err := h.service.SnoozeService(r.Context(), userID, req.Until)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSnooze) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid snooze date", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to snooze", err.Error())
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"snoozed_until": req.Until,
+	}, "successfully")
+}
+
+func (h *MemoryVerseHandler) UnsnoozeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	err := h.service.UnsnoozeService(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to unsnooze", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+// ResetVerseGoalHandler restarts the count towards the verse goal, resuming
+// delivery if it was paused there.
+func (h *MemoryVerseHandler) ResetVerseGoalHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	err := h.service.ResetVerseGoalService(r.Context(), userID)
+	if errors.Is(err, auth.ErrProfileIncomplete) {
+		response.ErrorWithCode(w, http.StatusForbidden, response.CodeProfileIncomplete, "Please complete your profile to continue", err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to reset verse goal", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+func (h *MemoryVerseHandler) OneClickUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
 		response.Error(w, http.StatusBadRequest, "Missing required fields", map[string]string{
-			"verse_id": "verse_id is required",
+			"token": "token is required",
 		})
 		return
 	}
 
+	err := h.service.OneClickUnsubscribeService(r.Context(), token)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid unsubscribe link", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+func (h *MemoryVerseHandler) ToggleFavouriteVerseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req AddToFavouriteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
 	verseId := AddToFavouriteRequest{
 		VerseID: req.VerseID,
 	}
 
-	ok, err := h.service.ToggleFavouriteVerseService(r.Context(), userID, verseId.VerseID)
+	state, err := h.service.ToggleFavouriteVerseService(r.Context(), userID, verseId.VerseID)
 	if err != nil {
+		var limitErr *LimitExceededError
+		if errors.As(err, &limitErr) {
+			limitExceeded(w, limitErr)
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to save favourite", err.Error())
 		return
 	}
 
-	response.Success(w, map[string]bool{
-		"is_saved": ok,
+	response.Success(w, map[string]interface{}{
+		"is_saved":        state.IsFavourite,
+		"favourite_count": state.FavouriteCount,
 	}, "successfully")
 }
 
+// GetFavouriteSummaryHandler counts the user's favourites per translation
+func (h *MemoryVerseHandler) GetFavouriteSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	summary, err := h.service.GetFavouriteSummaryService(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get favourite summary", err.Error())
+		return
+	}
+
+	response.Success(w, summary, "successfully")
+}
+
+func (h *MemoryVerseHandler) BulkFavouritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req BulkFavouritesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	states, err := h.service.BulkToggleFavouritesService(r.Context(), userID, req)
+	if err != nil {
+		var limitErr *LimitExceededError
+		switch {
+		case errors.As(err, &limitErr):
+			limitExceeded(w, limitErr)
+		case errors.Is(err, ErrInvalidBulkFavourites):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid favourites", err.Error())
+		case errors.Is(err, ErrNotFound):
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Verse not found", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to update favourites", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, states, "successfully")
+}
+
+// ReorderFavouritesHandler saves the user's own order for their favourites
+func (h *MemoryVerseHandler) ReorderFavouritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req ReorderFavouritesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	if err := h.service.ReorderFavouritesService(r.Context(), userID, req.OrderedIDs); err != nil {
+		if errors.Is(err, ErrInvalidFavouriteOrder) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid favourites order", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to reorder favourites", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+// limitExceeded reports a per-user cap with the user's current count.
+func limitExceeded(w http.ResponseWriter, err *LimitExceededError) {
+	response.ErrorWithCode(w, http.StatusConflict, response.CodeLimitExceeded, err.Error(), map[string]interface{}{
+		"resource": err.Resource,
+		"limit":    err.Limit,
+		"count":    err.Count,
+	})
+}
+
+// GetVersesByIDsHandler returns full verse data for up to MaxBatchVerseIDs
+// ids, in request order. Ids that don't match a verse are left out.
+func (h *MemoryVerseHandler) GetVersesByIDsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req BatchVersesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	verses, err := h.service.GetVersesByIDsService(r.Context(), userID, req.IDs)
+	if err != nil {
+		if errors.Is(err, ErrInvalidBatchIDs) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid verse ids", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get verses", err.Error())
+		return
+	}
+
+	if verses == nil {
+		verses = []Verse{}
+	}
+
+	response.Success(w, verses, "successfully")
+}
+
+// GetRelatedVersesHandler suggests verses from the same chapter to read next
+func (h *MemoryVerseHandler) GetRelatedVersesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	verseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "id must be a positive integer")
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	if r.URL.Query().Get("limit") == "" {
+		limit = defaultRelatedLimit
+	}
+
+	verses, err := h.service.GetRelatedVersesService(r.Context(), userID, verseID, limit)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Verse not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get related verses", err.Error())
+		return
+	}
+
+	response.Success(w, verses, "successfully")
+}
+
 func (h *MemoryVerseHandler) GetUserFavouriteVersesHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserIDFromContext(r)
 	if !ok {
@@ -102,8 +397,19 @@ func (h *MemoryVerseHandler) GetUserFavouriteVersesHandler(w http.ResponseWriter
 		return
 	}
 
-	favourites, err := h.service.GetUserFavouriteVersesService(r.Context(), userID)
+	after, err := parseCursor(r)
+	if err != nil {
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid cursor", err.Error())
+		return
+	}
+	limit, _ := parsePagination(r)
+
+	favourites, page, err := h.service.GetUserFavouriteVersesService(r.Context(), userID, r.URL.Query().Get("sort"), after, limit)
 	if err != nil {
+		if errors.Is(err, ErrInvalidSort) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid sort", err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to get user favourite verses", err.Error())
 		return
 	}
@@ -112,5 +418,1019 @@ func (h *MemoryVerseHandler) GetUserFavouriteVersesHandler(w http.ResponseWriter
 		favourites = []FavouriteVerse{}
 	}
 
-	response.Success(w, favourites, "successfully")
+	response.SuccessWithCursor(w, favourites, page, "successfully")
+}
+
+func (h *MemoryVerseHandler) GetUserNotesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	notes, err := h.service.GetUserNotesService(r.Context(), userID, r.URL.Query().Get("sort"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidSort) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid sort", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get user notes", err.Error())
+		return
+	}
+
+	if notes == nil {
+		notes = []UserNotes{}
+	}
+
+	response.Success(w, notes, "successfully")
+}
+
+// AttachNoteFileHandler links an already uploaded image to one of the user's notes
+func (h *MemoryVerseHandler) AttachNoteFileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	noteID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || noteID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid note id", "id must be a positive integer")
+		return
+	}
+
+	var req AttachNoteFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	attachment, err := h.service.AttachNoteFileService(r.Context(), userID, noteID, req)
+	if err != nil {
+		var limitErr *LimitExceededError
+		switch {
+		case errors.As(err, &limitErr):
+			limitExceeded(w, limitErr)
+		case errors.Is(err, ErrInvalidAttachment):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid attachment", err.Error())
+		case errors.Is(err, ErrNotFound):
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Note not found", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to attach file", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, attachment, "successfully")
+}
+
+func (h *MemoryVerseHandler) DetachNoteFileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	noteID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || noteID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid note id", "id must be a positive integer")
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(chi.URLParam(r, "attachmentID"))
+	if err != nil || attachmentID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid attachment id", "attachment id must be a positive integer")
+		return
+	}
+
+	if err := h.service.DetachNoteFileService(r.Context(), userID, noteID, attachmentID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Attachment not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to detach file", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+func (h *MemoryVerseHandler) CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req CreateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	collection, err := h.service.CreateCollectionService(r.Context(), userID, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidCollectionName):
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid collection name", err.Error())
+		case errors.Is(err, ErrAlreadyExists):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeAlreadyExists, "You already have a collection with this name", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to create collection", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, collection, "successfully")
+}
+
+func (h *MemoryVerseHandler) GetUserCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	collections, err := h.service.GetUserCollectionsService(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get collections", err.Error())
+		return
+	}
+
+	if collections == nil {
+		collections = []Collection{}
+	}
+
+	response.Success(w, collections, "successfully")
+}
+
+func (h *MemoryVerseHandler) GetCollectionVersesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	collectionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || collectionID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid collection id", "id must be a positive integer")
+		return
+	}
+
+	verses, err := h.service.GetCollectionVersesService(r.Context(), userID, collectionID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Collection not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get collection verses", err.Error())
+		return
+	}
+
+	if verses == nil {
+		verses = []Verse{}
+	}
+
+	response.Success(w, verses, "successfully")
+}
+
+func (h *MemoryVerseHandler) AddVerseToCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	collectionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || collectionID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid collection id", "id must be a positive integer")
+		return
+	}
+
+	var req CollectionVerseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	err = h.service.AddVerseToCollectionService(r.Context(), userID, collectionID, req.VerseID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Collection or verse not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to add verse to collection", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+func (h *MemoryVerseHandler) RemoveVerseFromCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	collectionID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || collectionID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid collection id", "id must be a positive integer")
+		return
+	}
+
+	verseID, err := strconv.Atoi(chi.URLParam(r, "verseID"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "verse id must be a positive integer")
+		return
+	}
+
+	err = h.service.RemoveVerseFromCollectionService(r.Context(), userID, collectionID, verseID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Collection not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to remove verse from collection", err.Error())
+		return
+	}
+
+	response.Success(w, "Ok", "successfully")
+}
+
+// ExportVerseHistoryHandler streams the user's verse history as CSV,
+// optionally bounded by from/to dates (YYYY-MM-DD, inclusive).
+// GetVerseHistoryHandler pages through the user's delivered verses, newest
+// first, using the cursor from the previous page's meta.
+func (h *MemoryVerseHandler) GetVerseHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	after, err := parseCursor(r)
+	if err != nil {
+		response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid cursor", err.Error())
+		return
+	}
+	limit, _ := parsePagination(r)
+
+	histories, page, err := h.service.GetVerseHistoryService(r.Context(), userID, after, limit)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get verse history", err.Error())
+		return
+	}
+
+	if histories == nil {
+		histories = []VerseHistory{}
+	}
+
+	response.SuccessWithCursor(w, histories, page, "successfully")
+}
+
+func (h *MemoryVerseHandler) ExportVerseHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	rng, errs := parseHistoryRange(r)
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid date range", errs)
+		return
+	}
+
+	// Long histories can outlast the server's WriteTimeout, so lift it for this
+	// response only; errors mean the writer doesn't support deadlines
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="verse-history.csv"`)
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"reference", "verse", "translation", "delivered_at"}); err != nil {
+		return
+	}
+
+	rows := 0
+	err := h.service.StreamVerseHistoryService(r.Context(), userID, rng, func(vh VerseHistory) error {
+		if err := cw.Write([]string{
+			csvCell(vh.Verse.Reference),
+			csvCell(vh.Verse.Verse),
+			csvCell(vh.Verse.Translation),
+			vh.DeliveredAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+
+		// Push rows to the client in batches instead of buffering the whole export
+		rows++
+		if rows%100 == 0 {
+			cw.Flush()
+			_ = rc.Flush()
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		// Headers are already sent, so the client just gets a truncated file
+		log.Printf("error exporting verse history for user %d: %v", userID, err)
+	}
+
+	cw.Flush()
+}
+
+// csvCell defuses text a spreadsheet would run as a formula. Cells starting
+// with = + - @, a tab or a carriage return get a leading apostrophe, which
+// spreadsheets treat as "show as text".
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// FeedHandler serves the RSS feed for the user identified by ?token=
+func (h *MemoryVerseHandler) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.service.GetFeedService(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidFeedToken) {
+			response.ErrorWithCode(w, http.StatusUnauthorized, response.CodeInvalidFeedToken, "Unauthorized", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get feed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("error writing feed: %v", err)
+	}
+}
+
+// ShareFavouritesHandler snapshots the user's favourites into a public link
+func (h *MemoryVerseHandler) ShareFavouritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	link, err := h.service.CreateFavouriteShareService(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to share favourites", err.Error())
+		return
+	}
+
+	response.Success(w, link, "successfully")
+}
+
+// SharedFavouritesHandler serves a favourites share to anyone with its token
+func (h *MemoryVerseHandler) SharedFavouritesHandler(w http.ResponseWriter, r *http.Request) {
+	shared, err := h.service.GetSharedFavouritesService(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrShareNotFound):
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Share not found", err.Error())
+		case errors.Is(err, ErrShareExpired):
+			response.ErrorWithCode(w, http.StatusGone, response.CodeShareExpired, "Share has expired", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to get shared favourites", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, shared, "successfully")
+}
+
+func (h *MemoryVerseHandler) GetPopularVersesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _ := parsePagination(r)
+	if r.URL.Query().Get("limit") == "" {
+		limit = defaultPopularLimit
+	}
+
+	verses, err := h.service.GetPopularVersesService(r.Context(), limit)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get popular verses", err.Error())
+		return
+	}
+
+	if verses == nil {
+		verses = []Verse{}
+	}
+
+	response.Success(w, verses, "successfully")
+}
+
+// GetRecentVersesHandler lists the most recently added verses, newest first
+func (h *MemoryVerseHandler) GetRecentVersesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	page, err := h.service.GetRecentVersesService(r.Context(), userID, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get recent verses", err.Error())
+		return
+	}
+
+	response.SuccessWithMeta(w, page.Verses, response.NewMeta(page.Total, limit, offset), "successfully")
+}
+
+// GuestIDHeader carries a visitor's stable guest id.
+const GuestIDHeader = "X-Guest-ID"
+
+// GetDailyVerseHandler serves a verse to visitors without an account. A
+// stable X-Guest-ID header or guest_id cookie avoids repeats.
+func (h *MemoryVerseHandler) GetDailyVerseHandler(w http.ResponseWriter, r *http.Request) {
+	guestID := r.Header.Get(GuestIDHeader)
+	if guestID == "" {
+		if c, err := r.Cookie("guest_id"); err == nil {
+			guestID = c.Value
+		}
+	}
+
+	verse, err := h.service.GetGuestVerseService(r.Context(), guestID, r.URL.Query().Get("translation"))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNoVerses, "No verse found", "no verses for this translation")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get daily verse", err.Error())
+		return
+	}
+
+	response.Success(w, verse, "successfully")
+}
+
+// publicDailyVerseMaxAge lets browsers and CDNs serve the widget for up to an
+// hour, never past the verse's refresh.
+const publicDailyVerseMaxAge = time.Hour
+
+// publicDailyVerseCacheAge is the max-age in seconds for a verse refreshing
+// at next: the usual hour, cut short so caches drop it at midnight.
+func publicDailyVerseCacheAge(now time.Time, next *time.Time) int {
+	age := publicDailyVerseMaxAge
+	if next != nil {
+		age = min(age, next.Sub(now))
+	}
+	// Round down so a cached copy never outlives the verse
+	return max(int(age/time.Second), 0)
+}
+
+// PublicDailyVerseHandler serves the verse of the day as bare JSON for
+// third-party widgets. It is public and cacheable, unlike the dashboard.
+func (h *MemoryVerseHandler) PublicDailyVerseHandler(w http.ResponseWriter, r *http.Request) {
+	verse, err := h.service.PublicDailyVerseService(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNoVerses, "No verse found", "no verses yet")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get daily verse", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(publicDailyVerseCacheAge(time.Now(), verse.NextRefreshAt)))
+	if err := json.NewEncoder(w).Encode(verse); err != nil {
+		log.Println("Error writing daily verse:", err)
+	}
+}
+
+// GetDailyVerseArchiveHandler lists past verses of the day. Without from/to
+// it covers the last maxDailyArchiveDays days.
+func (h *MemoryVerseHandler) GetDailyVerseArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	rng, errs := parseArchiveRange(r, time.Now())
+	if len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	limit, offset := parsePagination(r)
+
+	days, total, err := h.service.GetDailyVerseArchiveService(r.Context(), rng, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get daily verse archive", err.Error())
+		return
+	}
+
+	response.SuccessWithMeta(w, days, response.NewMeta(total, limit, offset), "successfully")
+}
+
+func (h *MemoryVerseHandler) GetTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	translations, err := h.service.GetTranslationsService(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get translations", err.Error())
+		return
+	}
+
+	if translations == nil {
+		translations = []string{}
+	}
+
+	response.Success(w, translations, "successfully")
+}
+
+func (h *MemoryVerseHandler) SaveUserNoteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	var req SaveNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	note, err := h.service.SaveUserNoteService(r.Context(), userID, req)
+	if err != nil {
+		var limitErr *LimitExceededError
+		if errors.As(err, &limitErr) {
+			limitExceeded(w, limitErr)
+			return
+		}
+		if errors.Is(err, util.ErrInvalidReference) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid verse reference", []validator.FieldError{
+				{Field: "verse_reference", Message: err.Error()},
+			})
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to save note", err.Error())
+		return
+	}
+
+	response.Success(w, note, "successfully")
+}
+
+func (h *MemoryVerseHandler) SearchUserNotesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		response.Error(w, http.StatusBadRequest, "Missing required fields", map[string]string{
+			"q": "q is required",
+		})
+		return
+	}
+
+	limit, offset := parsePagination(r)
+
+	results, total, err := h.service.SearchUserNotesService(r.Context(), userID, query, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to search notes", err.Error())
+		return
+	}
+
+	if results == nil {
+		results = []NoteSearchResult{}
+	}
+
+	response.SuccessWithMeta(w, results, response.NewMeta(total, limit, offset), "successfully")
+}
+
+func (h *MemoryVerseHandler) ReportVerseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized", "user not logged in")
+		return
+	}
+
+	verseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "id must be a positive integer")
+		return
+	}
+
+	var req ReportVerseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	report, err := h.service.ReportVerseService(r.Context(), userID, verseID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Verse not found", err.Error())
+		case errors.Is(err, ErrAlreadyExists):
+			response.ErrorWithCode(w, http.StatusConflict, response.CodeAlreadyExists, "You already have an open report for this verse", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to report verse", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, report, "successfully")
+}
+
+func (h *MemoryVerseHandler) GetVerseReportsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != ReportStatusOpen && status != ReportStatusResolved {
+		response.Error(w, http.StatusBadRequest, "Invalid status", "status must be open or resolved")
+		return
+	}
+
+	limit, offset := parsePagination(r)
+
+	reports, total, err := h.service.GetVerseReportsService(r.Context(), status, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get verse reports", err.Error())
+		return
+	}
+
+	if reports == nil {
+		reports = []VerseReport{}
+	}
+
+	response.SuccessWithMeta(w, reports, response.NewMeta(total, limit, offset), "successfully")
+}
+
+func (h *MemoryVerseHandler) ResolveVerseReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || reportID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid report id", "id must be a positive integer")
+		return
+	}
+
+	report, err := h.service.ResolveVerseReportService(r.Context(), reportID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Report not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to resolve report", err.Error())
+		return
+	}
+
+	response.Success(w, report, "successfully")
+}
+
+// transparentGIF is a 1x1 transparent GIF served as the open-tracking pixel.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// TrackOpenHandler records an email open and always returns the pixel, so
+// mail clients never show a broken image.
+func (h *MemoryVerseHandler) TrackOpenHandler(w http.ResponseWriter, r *http.Request) {
+	h.service.RecordEmailOpenService(r.Context(), chi.URLParam(r, "token"))
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+	w.Header().Set("Content-Length", strconv.Itoa(len(transparentGIF)))
+	_, _ = w.Write(transparentGIF)
+}
+
+// TrackClickHandler records an email link click and redirects to ?to=
+func (h *MemoryVerseHandler) TrackClickHandler(w http.ResponseWriter, r *http.Request) {
+	dest, err := h.service.TrackClickService(r.Context(), chi.URLParam(r, "token"), r.URL.Query().Get("to"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid redirect", err.Error())
+		return
+	}
+
+	http.Redirect(w, r, dest, http.StatusFound)
+}
+
+// GetMetricsHandler reports delivery metrics for admins
+func (h *MemoryVerseHandler) GetMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	opens, err := h.service.GetEmailOpenStatsService(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get metrics", err.Error())
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"email_opens": opens,
+	}, "successfully")
+}
+
+// GetSchedulerStatusHandler reports the verse scheduler's last run, as JSON or,
+// with ?format=prometheus, as Prometheus gauges
+func (h *MemoryVerseHandler) GetSchedulerStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status := h.service.SchedulerStatus()
+
+	if r.URL.Query().Get("format") == "prometheus" {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		status.writePrometheus(w)
+		return
+	}
+
+	response.Success(w, status, "successfully")
+}
+
+// GetSubjectVariantMetricsHandler reports open rates per verse email subject variant
+func (h *MemoryVerseHandler) GetSubjectVariantMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.GetSubjectVariantStatsService(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get A/B metrics", err.Error())
+		return
+	}
+
+	response.Success(w, stats, "successfully")
+}
+
+// GetImpressionMetricsHandler summarises dashboard verse views over ?days=
+func (h *MemoryVerseHandler) GetImpressionMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultImpressionDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxImpressionDays {
+			response.Error(w, http.StatusBadRequest, "Invalid days", "days must be between 1 and "+strconv.Itoa(maxImpressionDays))
+			return
+		}
+		days = n
+	}
+
+	stats, err := h.service.GetImpressionStatsService(r.Context(), days)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get impression metrics", err.Error())
+		return
+	}
+
+	response.Success(w, stats, "successfully")
+}
+
+// GetVerseCoverageHandler reports content gaps for admins
+func (h *MemoryVerseHandler) GetVerseCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	coverage, err := h.service.GetVerseCoverageService(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get verse coverage", err.Error())
+		return
+	}
+
+	response.Success(w, coverage, "successfully")
+}
+
+// SetVersePromptsHandler replaces the reflection prompts for a verse
+func (h *MemoryVerseHandler) SetVersePromptsHandler(w http.ResponseWriter, r *http.Request) {
+	verseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "id must be a positive integer")
+		return
+	}
+
+	var req SetVersePromptsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	prompts, err := h.service.SetVersePromptsService(r.Context(), verseID, req.Prompts)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Verse not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to set verse prompts", err.Error())
+		return
+	}
+
+	response.Success(w, prompts, "successfully")
+}
+
+// GetVerseCommentaryHandler returns the study commentary for a verse
+func (h *MemoryVerseHandler) GetVerseCommentaryHandler(w http.ResponseWriter, r *http.Request) {
+	verseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "id must be a positive integer")
+		return
+	}
+
+	commentary, err := h.service.GetVerseCommentaryService(r.Context(), verseID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Commentary not found", "no commentary for this verse")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get verse commentary", err.Error())
+		return
+	}
+
+	response.Success(w, commentary, "successfully")
+}
+
+// SetVerseCommentaryHandler replaces the study commentary for a verse
+func (h *MemoryVerseHandler) SetVerseCommentaryHandler(w http.ResponseWriter, r *http.Request) {
+	verseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || verseID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid verse id", "id must be a positive integer")
+		return
+	}
+
+	var req SetVerseCommentaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	commentary, err := h.service.SetVerseCommentaryService(r.Context(), verseID, req.Commentary)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.ErrorWithCode(w, http.StatusNotFound, response.CodeNotFound, "Verse not found", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to set verse commentary", err.Error())
+		return
+	}
+
+	response.Success(w, commentary, "successfully")
+}
+
+// CreateVerseHandler adds one verse to the catalogue
+func (h *MemoryVerseHandler) CreateVerseHandler(w http.ResponseWriter, r *http.Request) {
+	var req NewVerse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	verse, err := h.service.CreateVerseService(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidVerse) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid verse", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to add verse", err.Error())
+		return
+	}
+
+	response.Success(w, verse, "successfully")
+}
+
+// ImportVersesHandler adds a batch of verses, all or nothing
+func (h *MemoryVerseHandler) ImportVersesHandler(w http.ResponseWriter, r *http.Request) {
+	var req ImportVersesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid JSON body", err.Error())
+		return
+	}
+	if errs := validator.Validate(req); len(errs) > 0 {
+		response.Error(w, http.StatusBadRequest, "Validation failed", errs)
+		return
+	}
+
+	imported, err := h.service.ImportVersesService(r.Context(), req.Verses)
+	if err != nil {
+		if errors.Is(err, ErrInvalidVerse) {
+			response.ErrorWithCode(w, http.StatusBadRequest, response.CodeValidationFailed, "Invalid verse", err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to import verses", err.Error())
+		return
+	}
+
+	response.Success(w, map[string]int{"imported": imported}, "successfully")
+}
+
+// parseHistoryRange reads the optional from/to date filters. The to date is
+// inclusive, so it is turned into an exclusive bound at the start of the next day.
+func parseHistoryRange(r *http.Request) (HistoryRange, map[string]string) {
+	var rng HistoryRange
+	errs := map[string]string{}
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			errs["from"] = "from must be a date in YYYY-MM-DD format"
+		} else {
+			rng.From = &from
+		}
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			errs["to"] = "to must be a date in YYYY-MM-DD format"
+		} else {
+			to = to.AddDate(0, 0, 1)
+			rng.To = &to
+		}
+	}
+
+	if rng.From != nil && rng.To != nil && !rng.From.Before(*rng.To) {
+		errs["to"] = "to must not be before from"
+	}
+
+	return rng, errs
+}
+
+// parseArchiveRange reads from/to like parseHistoryRange, defaulting to the
+// archive window ending today and rejecting spans over maxDailyArchiveDays.
+func parseArchiveRange(r *http.Request, now time.Time) (HistoryRange, map[string]string) {
+	rng, errs := parseHistoryRange(r)
+	if len(errs) > 0 {
+		return rng, errs
+	}
+
+	if rng.To == nil {
+		to := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+		rng.To = &to
+	}
+	if rng.From == nil {
+		from := rng.To.AddDate(0, 0, -maxDailyArchiveDays)
+		rng.From = &from
+	}
+
+	if rng.To.Sub(*rng.From) > maxDailyArchiveDays*24*time.Hour {
+		errs["from"] = "range must span at most " + strconv.Itoa(maxDailyArchiveDays) + " days"
+	}
+	return rng, errs
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	defaultPopularLimit = 10
+)
+
+// parseCursor reads the ?cursor= of a keyset-paged list, nil for the first
+// page.
+func parseCursor(r *http.Request) (*pagination.Cursor, error) {
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" {
+		return nil, nil
+	}
+	cursor, err := pagination.DecodeCursor(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// parsePagination reads limit/offset query params, falling back to sane defaults.
+func parsePagination(r *http.Request) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
 }
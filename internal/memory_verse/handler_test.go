@@ -0,0 +1,1344 @@
+package memoryverse
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/cache"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// serveAuthed runs handler behind the real auth middleware as the given user.
+func serveAuthed(t *testing.T, handler http.HandlerFunc, userID int, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	token, err := util.GenerateJWT(userID, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	auth.AuthMiddleware(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestExportVerseHistoryHandlerWritesCSV(t *testing.T) {
+	delivered := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	repo := &fakeVerseRepo{history: map[int][]VerseHistory{
+		7: {
+			{VerseID: 1, DeliveredAt: delivered, Verse: Verse{ID: 1, Reference: "John 3:16", Verse: "For God so loved the world, that he gave", Translation: "KJV"}},
+			{VerseID: 2, DeliveredAt: delivered.AddDate(0, 1, 0), Verse: Verse{ID: 2, Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"}},
+		},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	rec := serveAuthed(t, h.ExportVerseHistoryHandler, 7, "/auth/me/history.csv?to=2025-03-31")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected Content-Type: %q", got)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("response is not valid CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one row within range, got %v", records)
+	}
+
+	want := [][]string{
+		{"reference", "verse", "translation", "delivered_at"},
+		{"John 3:16", "For God so loved the world, that he gave", "KJV", "2025-03-10T08:00:00Z"},
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d field %d: expected %q, got %q", i, j, want[i][j], records[i][j])
+			}
+		}
+	}
+}
+
+func TestExportVerseHistoryHandlerDefusesFormulas(t *testing.T) {
+	delivered := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	repo := &fakeVerseRepo{history: map[int][]VerseHistory{
+		7: {{VerseID: 1, DeliveredAt: delivered, Verse: Verse{
+			ID:          1,
+			Reference:   `=HYPERLINK("https://evil.example","John 3:16")`,
+			Verse:       "-1+1",
+			Translation: "@SUM(A1)",
+		}}},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	rec := serveAuthed(t, h.ExportVerseHistoryHandler, 7, "/auth/me/history.csv")
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("response is not valid CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one row, got %v", records)
+	}
+	want := []string{`'=HYPERLINK("https://evil.example","John 3:16")`, "'-1+1", "'@SUM(A1)", "2025-03-10T08:00:00Z"}
+	if !reflect.DeepEqual(records[1], want) {
+		t.Errorf("expected %q, got %q", want, records[1])
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"John 3:16": "John 3:16",
+		"=1+1":      "'=1+1",
+		"+1":        "'+1",
+		"-1":        "'-1",
+		"@A1":       "'@A1",
+		"\tx":       "'\tx",
+		"a=b":       "a=b",
+		"":          "",
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestExportVerseHistoryHandlerRejectsBadDates(t *testing.T) {
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: &fakeVerseRepo{}})
+
+	rec := serveAuthed(t, h.ExportVerseHistoryHandler, 7, "/auth/me/history.csv?from=last-week")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid from date, got %d", rec.Code)
+	}
+}
+
+func TestGetVersesByIDsHandler(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repo := &fakeVerseRepo{verses: []Verse{
+		{ID: 1, Reference: "John 3:16"},
+		{ID: 2, Reference: "Psalm 23:1"},
+		{ID: 3, Reference: "Romans 8:28"},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		token, err := util.GenerateJWT(7, "user@example.com", auth.RoleUser)
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/verses/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		auth.AuthMiddleware(http.HandlerFunc(h.GetVersesByIDsHandler)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"ids": [3, 99, 1, 3, 42]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data []Verse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].ID != 3 || resp.Data[1].ID != 1 {
+		t.Errorf("expected verses 3 and 1 in request order, got %+v", resp.Data)
+	}
+
+	if rec := post(`{"ids": [1, -2]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-positive id, got %d", rec.Code)
+	}
+
+	ids := make([]string, MaxBatchVerseIDs+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	if rec := post(`{"ids": [` + strings.Join(ids, ",") + `]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 above the id cap, got %d", rec.Code)
+	}
+}
+
+func TestFeedHandler(t *testing.T) {
+	delivered := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	repo := &fakeVerseRepo{history: map[int][]VerseHistory{
+		7: {
+			{VerseID: 2, DeliveredAt: delivered.AddDate(0, 0, 1), Verse: Verse{ID: 2, Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"}},
+			{VerseID: 1, DeliveredAt: delivered, Verse: Verse{ID: 1, Reference: "Proverbs 3:5", Verse: `Trust <in> the "Lord" & lean not`, Translation: "KJV"}},
+		},
+	}}
+	authRepo := &fakeAuthRepo{feeds: map[string]int{util.HashFeedToken("feed-token"): 7}}
+	cfg := &config.Config{AppBaseURL: "https://memoryverse.app"}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo, authRepo: authRepo, cfg: cfg})
+
+	t.Run("valid token returns rss", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.FeedHandler(rec, httptest.NewRequest(http.MethodGet, "/feed.xml?token=feed-token", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/rss+xml; charset=utf-8" {
+			t.Errorf("unexpected Content-Type: %q", got)
+		}
+
+		body := rec.Body.String()
+		if !strings.Contains(body, "Trust &lt;in&gt; the &#34;Lord&#34; &amp; lean not") {
+			t.Errorf("verse text not escaped: %s", body)
+		}
+
+		var feed RSSFeed
+		if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+			t.Fatalf("response is not valid XML: %v", err)
+		}
+		if feed.Version != "2.0" || feed.Channel.Link != "https://memoryverse.app/dashboard" {
+			t.Errorf("unexpected channel: %+v", feed)
+		}
+		if len(feed.Channel.Items) != 2 {
+			t.Fatalf("expected 2 items, got %d", len(feed.Channel.Items))
+		}
+		item := feed.Channel.Items[1]
+		if item.Title != "Proverbs 3:5 (KJV)" || item.Description != `Trust <in> the "Lord" & lean not` {
+			t.Errorf("unexpected item: %+v", item)
+		}
+		if item.PubDate != "Mon, 10 Mar 2025 08:00:00 +0000" {
+			t.Errorf("unexpected pubDate %q", item.PubDate)
+		}
+	})
+
+	for _, target := range []string{"/feed.xml", "/feed.xml?token=wrong"} {
+		t.Run("rejects "+target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.FeedHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), `"code":"`+response.CodeInvalidFeedToken+`"`) {
+				t.Errorf("expected %s code, got %s", response.CodeInvalidFeedToken, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestListSortParam(t *testing.T) {
+	repo := &fakeVerseRepo{}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		target   string
+		wantCode int
+		wantSort string
+	}{
+		{"notes default", h.GetUserNotesHandler, "/notes", http.StatusOK, SortCreatedDesc},
+		{"notes created_desc", h.GetUserNotesHandler, "/notes?sort=created_desc", http.StatusOK, SortCreatedDesc},
+		{"notes created_asc", h.GetUserNotesHandler, "/notes?sort=created_asc", http.StatusOK, SortCreatedAsc},
+		{"notes reference", h.GetUserNotesHandler, "/notes?sort=reference", http.StatusOK, SortReference},
+		{"notes updated_desc", h.GetUserNotesHandler, "/notes?sort=updated_desc", http.StatusOK, SortUpdatedDesc},
+		{"notes injection", h.GetUserNotesHandler, "/notes?sort=created_at%3B%20DROP%20TABLE%20user_notes", http.StatusBadRequest, ""},
+		{"favourites default", h.GetUserFavouriteVersesHandler, "/get-favourite-verses", http.StatusOK, SortPosition},
+		{"favourites position", h.GetUserFavouriteVersesHandler, "/get-favourite-verses?sort=position", http.StatusOK, SortPosition},
+		{"favourites created_desc", h.GetUserFavouriteVersesHandler, "/get-favourite-verses?sort=created_desc", http.StatusOK, SortCreatedDesc},
+		{"favourites created_asc", h.GetUserFavouriteVersesHandler, "/get-favourite-verses?sort=created_asc", http.StatusOK, SortCreatedAsc},
+		{"favourites reference", h.GetUserFavouriteVersesHandler, "/get-favourite-verses?sort=reference", http.StatusOK, SortReference},
+		{"favourites have no updated_at", h.GetUserFavouriteVersesHandler, "/get-favourite-verses?sort=updated_desc", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.lastSort = ""
+
+			rec := serveAuthed(t, tt.handler, 1, tt.target)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if repo.lastSort != tt.wantSort {
+				t.Errorf("expected repo sort %q, got %q", tt.wantSort, repo.lastSort)
+			}
+		})
+	}
+}
+
+func TestTrackOpenHandler(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	repo := &fakeVerseRepo{sends: map[string]int{token: 7}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	router := chi.NewRouter()
+	router.Get("/track/open/{token}.gif", h.TrackOpenHandler)
+
+	open := func(tok string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/track/open/"+tok+".gif", nil))
+		return rec
+	}
+
+	t.Run("records repeat opens", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rec := open(token)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/gif" {
+				t.Fatalf("expected gif, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			if _, err := gif.Decode(rec.Body); err != nil {
+				t.Fatalf("pixel is not a valid GIF: %v", err)
+			}
+		}
+		if repo.opens[token] != 2 {
+			t.Errorf("expected 2 opens, got %d", repo.opens[token])
+		}
+	})
+
+	t.Run("unknown token still returns pixel", func(t *testing.T) {
+		for _, tok := range []string{"ffffffffffffffffffffffffffffffff", "not-a-token"} {
+			rec := open(tok)
+			if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+				t.Errorf("%s: expected pixel, got %d", tok, rec.Code)
+			}
+		}
+		if len(repo.opens) != 1 {
+			t.Errorf("unknown tokens must not be recorded, got %v", repo.opens)
+		}
+	})
+
+	stats, err := h.service.GetEmailOpenStatsService(context.Background())
+	if err != nil {
+		t.Fatalf("GetEmailOpenStatsService returned error: %v", err)
+	}
+	if stats.Sent != 1 || stats.Opened != 1 || stats.TotalOpens != 2 || stats.OpenRatePct != 100 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTrackClickHandler(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	repo := &fakeVerseRepo{sends: map[string]int{token: 7}}
+	cfg := &config.Config{AppBaseURL: "https://memoryverse.app", ApiBaseURL: "https://api.memoryverse.app"}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo, cfg: cfg})
+
+	router := chi.NewRouter()
+	router.Get("/track/click/{token}", h.TrackClickHandler)
+
+	click := func(tok, to string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		target := "/track/click/" + tok + "?to=" + url.QueryEscape(to)
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("records click and redirects", func(t *testing.T) {
+		rec := click(token, "https://memoryverse.app/dashboard")
+		if rec.Code != http.StatusFound {
+			t.Fatalf("expected 302, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Location"); got != "https://memoryverse.app/dashboard" {
+			t.Errorf("unexpected Location %q", got)
+		}
+		if got := repo.clicks[token]; len(got) != 1 || got[0] != "https://memoryverse.app/dashboard" {
+			t.Errorf("expected one recorded click, got %v", got)
+		}
+	})
+
+	t.Run("query is not recorded", func(t *testing.T) {
+		rec := click(token, "https://memoryverse.app/unsubscribe?token=secret-unsubscribe-token")
+		if got := rec.Header().Get("Location"); got != "https://memoryverse.app/unsubscribe?token=secret-unsubscribe-token" {
+			t.Errorf("expected the redirect to keep the token, got Location %q", got)
+		}
+		got := repo.clicks[token]
+		if len(got) != 2 || got[1] != "https://memoryverse.app/unsubscribe" {
+			t.Errorf("expected the click recorded without its query, got %v", got)
+		}
+		repo.clicks[token] = got[:1]
+	})
+
+	t.Run("unknown token still redirects", func(t *testing.T) {
+		rec := click("ffffffffffffffffffffffffffffffff", "https://memoryverse.app/unsubscribe?token=abc")
+		if rec.Code != http.StatusFound {
+			t.Fatalf("expected 302, got %d", rec.Code)
+		}
+		if len(repo.clicks) != 1 {
+			t.Errorf("unknown tokens must not be recorded, got %v", repo.clicks)
+		}
+	})
+
+	t.Run("rejects off-allowlist destination", func(t *testing.T) {
+		for _, to := range []string{
+			"https://evil.example.com/dashboard",
+			"//evil.example.com",
+			"javascript:alert(1)",
+			"https://memoryverse.app.evil.example.com/",
+			"",
+		} {
+			rec := click(token, to)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%q: expected 400, got %d", to, rec.Code)
+			}
+			if loc := rec.Header().Get("Location"); loc != "" {
+				t.Errorf("%q: must not redirect, got Location %q", to, loc)
+			}
+		}
+		if got := repo.clicks[token]; len(got) != 1 {
+			t.Errorf("rejected clicks must not be recorded, got %v", got)
+		}
+	})
+}
+
+func TestSaveUserNoteHandlerReportsLimit(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{notes: map[int][]UserNotes{7: {{ID: 1}}}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo, cfg: &config.Config{MaxNotes: 1}})
+
+	token, err := util.GenerateJWT(7, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader(`{"verse_reference": "John 3:16", "content": "note"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	auth.AuthMiddleware(http.HandlerFunc(h.SaveUserNoteHandler)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp response.APIResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if resp.Code != response.CodeLimitExceeded {
+		t.Errorf("expected code %s, got %q", response.CodeLimitExceeded, resp.Code)
+	}
+	if data, _ := resp.Errors.(map[string]interface{}); data["count"] != float64(1) || data["limit"] != float64(1) {
+		t.Errorf("expected limit and count in the error, got %v", resp.Errors)
+	}
+}
+
+func TestSearchUserNotesHandler(t *testing.T) {
+	repo := &fakeVerseRepo{notes: map[int][]UserNotes{
+		7: {
+			{ID: 1, VerseReference: "1 John 4:8", Content: "God is love"},
+			{ID: 2, VerseReference: "Psalm 23:1", Content: "The Lord is my shepherd"},
+			{ID: 3, VerseReference: "1 Corinthians 13:4", Content: "Love is patient, love is kind"},
+		},
+		8: {{ID: 4, VerseReference: "John 3:16", Content: "love love love"}},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	t.Run("missing query", func(t *testing.T) {
+		rec := serveAuthed(t, h.SearchUserNotesHandler, 7, "/notes/search?q=%20")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("ranked own notes", func(t *testing.T) {
+		rec := serveAuthed(t, h.SearchUserNotesHandler, 7, "/notes/search?q=love")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data []NoteSearchResult `json:"data"`
+			Meta response.MetaInfo  `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		var ids []int
+		for _, res := range resp.Data {
+			ids = append(ids, res.ID)
+		}
+		if !reflect.DeepEqual(ids, []int{3, 1}) {
+			t.Errorf("expected notes [3 1] by rank, got %v", ids)
+		}
+		if resp.Meta.Total != 2 {
+			t.Errorf("expected total 2, got %d", resp.Meta.Total)
+		}
+	})
+
+	t.Run("paged", func(t *testing.T) {
+		rec := serveAuthed(t, h.SearchUserNotesHandler, 7, "/notes/search?q=love&limit=1&offset=1")
+		var resp struct {
+			Data []NoteSearchResult `json:"data"`
+			Meta response.MetaInfo  `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].ID != 1 {
+			t.Errorf("expected only note 1 on the second page, got %+v", resp.Data)
+		}
+		if resp.Meta.Total != 2 || resp.Meta.Page != 2 || resp.Meta.HasMore {
+			t.Errorf("unexpected meta: %+v", resp.Meta)
+		}
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		rec := serveAuthed(t, h.SearchUserNotesHandler, 7, "/notes/search?q=grace")
+		if body := rec.Body.String(); !strings.Contains(body, `"data":[]`) {
+			t.Errorf("expected an empty list, got %s", body)
+		}
+	})
+}
+
+func TestPublicDailyVerseHandler(t *testing.T) {
+	today := time.Now().UTC()
+	daysAgo := func(n int) string { return today.AddDate(0, 0, -n).Format(time.DateOnly) }
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16", Verse: "For God so loved the world", Translation: "KJV"},
+			{ID: 2, Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"},
+		},
+		// Yesterday and the day before, then a gap; today is added on first load
+		daily: map[string]int{daysAgo(1): 2, daysAgo(2): 2, daysAgo(4): 1},
+	}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo, verseOfDay: cache.New[Verse](24 * time.Hour), dailyStreak: cache.New[int](24 * time.Hour)})
+
+	get := func() (*httptest.ResponseRecorder, PublicDailyVerse) {
+		rec := httptest.NewRecorder()
+		h.PublicDailyVerseHandler(rec, httptest.NewRequest(http.MethodGet, "/public/daily-verse.json", nil))
+		var verse PublicDailyVerse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&verse); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+		}
+		return rec, verse
+	}
+
+	rec, first := get()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	midnight := time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, time.UTC)
+	var maxAge int
+	if _, err := fmt.Sscanf(rec.Header().Get("Cache-Control"), "public, max-age=%d", &maxAge); err != nil ||
+		maxAge <= 0 || maxAge > 3600 || time.Now().Add(time.Duration(maxAge)*time.Second).After(midnight) {
+		t.Errorf("unexpected Cache-Control: %q", rec.Header().Get("Cache-Control"))
+	}
+	if first.NextRefreshAt == nil || !first.NextRefreshAt.Equal(midnight) {
+		t.Errorf("expected next_refresh_at %s, got %v", midnight, first.NextRefreshAt)
+	}
+	if first.Streak != 3 {
+		t.Errorf("expected a streak of 3 days, got %d", first.Streak)
+	}
+	want := PublicDailyVerse{
+		Reference:   "John 3:16",
+		Verse:       "For God so loved the world",
+		Translation: "KJV",
+		Date:        today.Format(time.DateOnly),
+	}
+	if got := (PublicDailyVerse{Reference: first.Reference, Verse: first.Verse, Translation: first.Translation, Date: first.Date}); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// Later requests the same day keep the verse even if a new pick would differ
+	repo.verses = repo.verses[1:]
+	if _, again := get(); again.Reference != first.Reference || again.Date != first.Date {
+		t.Errorf("verse changed within the day: %+v then %+v", first, again)
+	}
+
+	if got := repo.daily[want.Date]; got != 1 {
+		t.Errorf("expected the verse of the day to be archived, got verse %d", got)
+	}
+
+	// The streak is counted once and then served from the day's cache
+	if _, again := get(); again.Streak != 3 || repo.streakCalls != 1 {
+		t.Errorf("expected the cached streak of 3 from one count, got %d after %d counts", again.Streak, repo.streakCalls)
+	}
+}
+
+func TestPublicDailyVerseCacheAge(t *testing.T) {
+	next := time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"mid-day", time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC), 3600},
+		{"last hour", time.Date(2025, 3, 12, 23, 30, 0, 0, time.UTC), 1800},
+		{"last half second", time.Date(2025, 3, 12, 23, 59, 59, 5e8, time.UTC), 0},
+	}
+	for _, tt := range tests {
+		if got := publicDailyVerseCacheAge(tt.now, &next); got != tt.want {
+			t.Errorf("%s: expected max-age %d, got %d", tt.name, tt.want, got)
+		}
+	}
+	if got := publicDailyVerseCacheAge(time.Now(), nil); got != 3600 {
+		t.Errorf("expected the usual hour without a refresh time, got %d", got)
+	}
+}
+
+func TestPublicDailyVerseHandlerWithoutVerses(t *testing.T) {
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: &fakeVerseRepo{}, verseOfDay: cache.New[Verse](24 * time.Hour), dailyStreak: cache.New[int](24 * time.Hour)})
+
+	rec := httptest.NewRecorder()
+	h.PublicDailyVerseHandler(rec, httptest.NewRequest(http.MethodGet, "/public/daily-verse.json", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("errors should not be cached, got Cache-Control %q", got)
+	}
+}
+
+// walkPages follows next_cursor through a keyset-paged list and returns every
+// page's data, failing if the walk doesn't end.
+func walkPages[T any](t *testing.T, handler http.HandlerFunc, path string) [][]T {
+	t.Helper()
+	var pages [][]T
+	target := path
+	for i := 0; i < 10; i++ {
+		rec := serveAuthed(t, handler, 7, target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 for %s, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data []T                   `json:"data"`
+			Meta pagination.CursorPage `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		pages = append(pages, resp.Data)
+		if !resp.Meta.HasMore {
+			if resp.Meta.NextCursor != "" {
+				t.Errorf("last page should have no next cursor, got %q", resp.Meta.NextCursor)
+			}
+			return pages
+		}
+		target = path + "&cursor=" + url.QueryEscape(resp.Meta.NextCursor)
+	}
+	t.Fatal("pagination did not terminate")
+	return nil
+}
+
+func TestGetUserFavouriteVersesHandlerPagesByCursor(t *testing.T) {
+	repo := &fakeVerseRepo{favourites: map[int][]int{7: {10, 11, 12, 13, 14}}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	pages := walkPages[FavouriteVerse](t, h.GetUserFavouriteVersesHandler, "/get-favourite-verses?limit=2")
+
+	if len(pages) != 3 || len(pages[2]) != 1 {
+		t.Fatalf("expected pages of 2, 2 and 1, got %v", pages)
+	}
+	var got []int
+	for _, page := range pages {
+		for _, fav := range page {
+			got = append(got, fav.VerseID)
+		}
+	}
+	if want := []int{14, 13, 12, 11, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected favourites %v newest first, got %v", want, got)
+	}
+
+	// A favourite saved mid-walk doesn't shift the later pages
+	first := serveAuthed(t, h.GetUserFavouriteVersesHandler, 7, "/get-favourite-verses?limit=2")
+	var resp struct {
+		Meta pagination.CursorPage `json:"meta"`
+	}
+	if err := json.NewDecoder(first.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	repo.favourites[7] = append(repo.favourites[7], 15)
+	rec := serveAuthed(t, h.GetUserFavouriteVersesHandler, 7, "/get-favourite-verses?limit=2&cursor="+url.QueryEscape(resp.Meta.NextCursor))
+	var second struct {
+		Data []FavouriteVerse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&second); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if len(second.Data) != 2 || second.Data[0].VerseID != 12 || second.Data[1].VerseID != 11 {
+		t.Errorf("expected verses 12 and 11 on the second page, got %+v", second.Data)
+	}
+
+	if rec := serveAuthed(t, h.GetUserFavouriteVersesHandler, 7, "/get-favourite-verses?cursor=tampered"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a tampered cursor, got %d", rec.Code)
+	}
+}
+
+func TestGetVerseHistoryHandlerPagesByCursor(t *testing.T) {
+	day := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	var history []VerseHistory
+	for i := 0; i < 5; i++ {
+		history = append(history, VerseHistory{VerseID: 5 - i, DeliveredAt: day.AddDate(0, 0, -i)})
+	}
+	repo := &fakeVerseRepo{history: map[int][]VerseHistory{7: history}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	pages := walkPages[VerseHistory](t, h.GetVerseHistoryHandler, "/auth/me/history?limit=3")
+
+	if len(pages) != 2 || len(pages[0]) != 3 || len(pages[1]) != 2 {
+		t.Fatalf("expected pages of 3 and 2, got %v", pages)
+	}
+	if pages[1][0].VerseID != 2 || pages[1][1].VerseID != 1 {
+		t.Errorf("expected the oldest deliveries on the last page, got %+v", pages[1])
+	}
+
+	if rec := serveAuthed(t, h.GetVerseHistoryHandler, 7, "/auth/me/history?cursor=e30"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty cursor object, got %d", rec.Code)
+	}
+}
+
+func TestVerseCommentaryHandlers(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses:     []Verse{{ID: 1, Reference: "John 3:16"}, {ID: 2, Reference: "Psalm 23:1"}},
+		commentary: map[int]string{1: "God's love is shown in the giving of his Son."},
+	}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	router := chi.NewRouter()
+	router.Get("/verse/{id}/commentary", h.GetVerseCommentaryHandler)
+	router.Put("/admin/verses/{id}/commentary", h.SetVerseCommentaryHandler)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	commentaryOf := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var resp struct {
+			Data VerseCommentary `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp.Data.Commentary
+	}
+
+	t.Run("present", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/verse/1/commentary", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if got := commentaryOf(t, rec); got != repo.commentary[1] {
+			t.Errorf("unexpected commentary %q", got)
+		}
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		for _, target := range []string{"/verse/2/commentary", "/verse/99/commentary"} {
+			if rec := serve(http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", target, rec.Code)
+			}
+		}
+	})
+
+	t.Run("admin update", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/admin/verses/2/commentary", `{"commentary":"  The Lord provides.  "}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		rec = serve(http.MethodGet, "/verse/2/commentary", "")
+		if got := commentaryOf(t, rec); got != "The Lord provides." {
+			t.Errorf("expected trimmed commentary, got %q", got)
+		}
+
+		if rec := serve(http.MethodPut, "/admin/verses/2/commentary", `{"commentary":""}`); rec.Code != http.StatusOK {
+			t.Fatalf("clearing: expected 200, got %d", rec.Code)
+		}
+		if rec := serve(http.MethodGet, "/verse/2/commentary", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("cleared commentary: expected 404, got %d", rec.Code)
+		}
+
+		if rec := serve(http.MethodPut, "/admin/verses/99/commentary", `{"commentary":"x"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("unknown verse: expected 404, got %d", rec.Code)
+		}
+	})
+}
+
+func TestVerseReportHandlers(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{verses: []Verse{{ID: 1, Reference: "John 3:16"}, {ID: 2, Reference: "Psalm 23:1"}}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	router := chi.NewRouter()
+	router.With(auth.AuthMiddleware).Post("/verse/{id}/report", h.ReportVerseHandler)
+	router.Get("/admin/verse-reports", h.GetVerseReportsHandler)
+	router.Patch("/admin/verse-reports/{id}", h.ResolveVerseReportHandler)
+
+	serve := func(method, target, body string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != 0 {
+			token, err := util.GenerateJWT(userID, "user@example.com", auth.RoleUser)
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	report := func(t *testing.T, rec *httptest.ResponseRecorder) VerseReport {
+		t.Helper()
+		var resp struct {
+			Data VerseReport `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp.Data
+	}
+	list := func(t *testing.T, query string) ([]VerseReport, response.MetaInfo) {
+		t.Helper()
+		rec := serve(http.MethodGet, "/admin/verse-reports"+query, "", 0)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data []VerseReport     `json:"data"`
+			Meta response.MetaInfo `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp.Data, resp.Meta
+	}
+
+	t.Run("file", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/verse/1/report", `{"reason":"  Typo in verse 16  "}`, 7)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if got := report(t, rec); got.UserID != 7 || got.VerseID != 1 || got.Reason != "Typo in verse 16" || got.Status != ReportStatusOpen {
+			t.Errorf("unexpected report %+v", got)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		tests := []struct {
+			name, target, body string
+			userID, want       int
+		}{
+			{"anonymous", "/verse/1/report", `{"reason":"typo"}`, 0, http.StatusUnauthorized},
+			{"bad id", "/verse/abc/report", `{"reason":"typo"}`, 7, http.StatusBadRequest},
+			{"missing reason", "/verse/1/report", `{}`, 7, http.StatusBadRequest},
+			{"unknown verse", "/verse/99/report", `{"reason":"typo"}`, 7, http.StatusNotFound},
+			{"already open", "/verse/1/report", `{"reason":"again"}`, 7, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			if rec := serve(http.MethodPost, tt.target, tt.body, tt.userID); rec.Code != tt.want {
+				t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("triage", func(t *testing.T) {
+		if rec := serve(http.MethodPost, "/verse/2/report", `{"reason":"wrong translation"}`, 8); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+
+		reports, meta := list(t, "")
+		if len(reports) != 2 || reports[0].VerseID != 2 || meta.Total != 2 {
+			t.Fatalf("expected both reports newest first, got %+v (total %d)", reports, meta.Total)
+		}
+		if rec := serve(http.MethodGet, "/admin/verse-reports?status=closed", "", 0); rec.Code != http.StatusBadRequest {
+			t.Errorf("unknown status: expected 400, got %d", rec.Code)
+		}
+
+		rec := serve(http.MethodPatch, "/admin/verse-reports/1", "", 0)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		resolved := report(t, rec)
+		if resolved.Status != ReportStatusResolved || resolved.ResolvedAt == nil {
+			t.Fatalf("expected a resolved report, got %+v", resolved)
+		}
+		again := report(t, serve(http.MethodPatch, "/admin/verse-reports/1", "", 0))
+		if again.ResolvedAt == nil || !again.ResolvedAt.Equal(*resolved.ResolvedAt) {
+			t.Errorf("expected resolving twice to keep the first resolved_at, got %v", again.ResolvedAt)
+		}
+		if rec := serve(http.MethodPatch, "/admin/verse-reports/99", "", 0); rec.Code != http.StatusNotFound {
+			t.Errorf("unknown report: expected 404, got %d", rec.Code)
+		}
+
+		open, meta := list(t, "?status=open")
+		if len(open) != 1 || open[0].ID != 2 || meta.Total != 1 {
+			t.Errorf("expected only report 2 open, got %+v", open)
+		}
+
+		// With the first report resolved, the user may report the verse again
+		if rec := serve(http.MethodPost, "/verse/1/report", `{"reason":"still a typo"}`, 7); rec.Code != http.StatusOK {
+			t.Errorf("expected a new report after resolution, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestGetDailyVerseArchiveHandler(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16", Verse: "For God so loved the world", Translation: "KJV"},
+			{ID: 2, Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"},
+		},
+		daily: map[string]int{"2025-03-09": 2, "2025-03-10": 1, "2025-03-11": 2, "2025-03-12": 1},
+	}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	archive := func(query string) (*httptest.ResponseRecorder, []PublicDailyVerse, *response.MetaInfo) {
+		rec := httptest.NewRecorder()
+		h.GetDailyVerseArchiveHandler(rec, httptest.NewRequest(http.MethodGet, "/daily-archive?"+query, nil))
+		var resp struct {
+			Data []PublicDailyVerse `json:"data"`
+			Meta *response.MetaInfo `json:"meta"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+		}
+		return rec, resp.Data, resp.Meta
+	}
+
+	t.Run("range is inclusive and newest first", func(t *testing.T) {
+		rec, days, meta := archive("from=2025-03-10&to=2025-03-11")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		want := []PublicDailyVerse{
+			{Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV", Date: "2025-03-11"},
+			{Reference: "John 3:16", Verse: "For God so loved the world", Translation: "KJV", Date: "2025-03-10"},
+		}
+		if !reflect.DeepEqual(days, want) {
+			t.Errorf("expected %+v, got %+v", want, days)
+		}
+		if meta == nil || meta.Total != 2 {
+			t.Errorf("expected total 2, got %+v", meta)
+		}
+	})
+
+	t.Run("paginates", func(t *testing.T) {
+		_, days, meta := archive("from=2025-03-01&to=2025-03-31&limit=3&offset=3")
+		if len(days) != 1 || days[0].Date != "2025-03-09" || meta.Total != 4 || meta.HasMore {
+			t.Errorf("unexpected last page %+v meta %+v", days, meta)
+		}
+	})
+
+	t.Run("range size is capped", func(t *testing.T) {
+		// Both ends count, so this is exactly 90 days
+		if rec, _, _ := archive("from=2025-01-01&to=2025-03-31"); rec.Code != http.StatusOK {
+			t.Errorf("expected 90 days to be allowed, got %d: %s", rec.Code, rec.Body.String())
+		}
+		rec, _, _ := archive("from=2024-12-31&to=2025-03-31")
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at most 90 days") {
+			t.Errorf("expected 400 for 91 days, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("defaults to the window ending today", func(t *testing.T) {
+		now := time.Date(2025, 3, 12, 15, 0, 0, 0, time.UTC)
+		rng, errs := parseArchiveRange(httptest.NewRequest(http.MethodGet, "/daily-archive", nil), now)
+		if len(errs) > 0 {
+			t.Fatalf("unexpected errors %v", errs)
+		}
+		if want := time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC); !rng.To.Equal(want) {
+			t.Errorf("expected to %s, got %s", want, rng.To)
+		}
+		if got := rng.To.Sub(*rng.From); got != maxDailyArchiveDays*24*time.Hour {
+			t.Errorf("expected a %d day window, got %s", maxDailyArchiveDays, got)
+		}
+	})
+}
+
+func TestGetFavouriteSummaryHandler(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16", Translation: "KJV"},
+			{ID: 2, Reference: "Psalm 23:1", Translation: "KJV"},
+			{ID: 3, Reference: "John 3:16", Translation: "NIV"},
+			{ID: 4, Reference: "Romans 8:28", Translation: "ESV"},
+		},
+		favourites: map[int][]int{7: {1, 3, 2}},
+	}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	summary := func(userID int) FavouriteSummary {
+		rec := serveAuthed(t, h.GetFavouriteSummaryHandler, userID, "/favourites/summary")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data FavouriteSummary `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		return resp.Data
+	}
+
+	got := summary(7)
+	want := FavouriteSummary{Total: 3, Translations: []TranslationFavourites{
+		{Translation: "KJV", Count: 2},
+		{Translation: "NIV", Count: 1},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if empty := summary(8); empty.Total != 0 || empty.Translations == nil || len(empty.Translations) != 0 {
+		t.Errorf("expected an empty summary, got %+v", empty)
+	}
+}
+
+func TestReorderFavouritesHandler(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{favourites: map[int][]int{7: {10, 11, 12}, 8: {20}}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	token, err := util.GenerateJWT(7, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	reorder := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/favourites/reorder", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		auth.AuthMiddleware(http.HandlerFunc(h.ReorderFavouritesHandler)).ServeHTTP(rec, req)
+		return rec
+	}
+	listed := func() []int {
+		rec := serveAuthed(t, h.GetUserFavouriteVersesHandler, 7, "/get-favourite-verses")
+		var resp struct {
+			Data []FavouriteVerse `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		var ids []int
+		for _, fav := range resp.Data {
+			ids = append(ids, fav.VerseID)
+		}
+		return ids
+	}
+
+	if got, want := listed(), []int{12, 11, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected unordered favourites %v newest first, got %v", want, got)
+	}
+
+	if rec := reorder(`{"ordered_ids": [11, 10, 12]}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := listed(), []int{11, 10, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected favourites in the saved order %v, got %v", want, got)
+	}
+
+	for name, body := range map[string]string{
+		"incomplete": `{"ordered_ids": [12, 11]}`,
+		"foreign":    `{"ordered_ids": [12, 11, 20]}`,
+		"extra":      `{"ordered_ids": [12, 11, 10, 20]}`,
+		"duplicate":  `{"ordered_ids": [12, 11, 11]}`,
+		"empty":      `{"ordered_ids": []}`,
+	} {
+		rec := reorder(body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if got, want := listed(), []int{11, 10, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected rejected orders to leave %v, got %v", want, got)
+	}
+}
+
+func TestNoteAttachmentHandlers(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{notes: map[int][]UserNotes{
+		7: {{ID: 1, VerseReference: "John 3:16", Content: "mine"}},
+		8: {{ID: 2, VerseReference: "Psalm 23:1", Content: "someone else's"}},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	router := chi.NewRouter()
+	router.Use(auth.AuthMiddleware)
+	router.Get("/notes", h.GetUserNotesHandler)
+	router.Post("/notes/{id}/attachments", h.AttachNoteFileHandler)
+	router.Delete("/notes/{id}/attachments/{attachmentID}", h.DetachNoteFileHandler)
+
+	serve := func(userID int, method, target, body string) *httptest.ResponseRecorder {
+		token, err := util.GenerateJWT(userID, "user@example.com", auth.RoleUser)
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	attachmentsOf := func(userID int) []NoteAttachment {
+		var resp struct {
+			Data []UserNotes `json:"data"`
+		}
+		if err := json.NewDecoder(serve(userID, http.MethodGet, "/notes", "").Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		if len(resp.Data) != 1 {
+			t.Fatalf("expected one note, got %+v", resp.Data)
+		}
+		return resp.Data[0].Attachments
+	}
+
+	rec := serve(7, http.MethodPost, "/notes/1/attachments", `{"url": "https://cdn.example.com/a.jpg", "content_type": "image/jpeg"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var attached struct {
+		Data NoteAttachment `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&attached); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if got := attachmentsOf(7); len(got) != 1 || got[0].URL != "https://cdn.example.com/a.jpg" || got[0].ContentType != "image/jpeg" {
+		t.Errorf("expected the attachment listed with the note, got %+v", got)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"not an image": `{"url": "https://cdn.example.com/a.pdf", "content_type": "application/pdf"}`,
+			"relative url": `{"url": "/uploads/a.png", "content_type": "image/png"}`,
+			"other scheme": `{"url": "javascript:alert(1)", "content_type": "image/png"}`,
+			"missing type": `{"url": "https://cdn.example.com/a.png"}`,
+		} {
+			if rec := serve(7, http.MethodPost, "/notes/1/attachments", body); rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", name, rec.Code)
+			}
+		}
+	})
+
+	t.Run("other users' notes", func(t *testing.T) {
+		if rec := serve(8, http.MethodPost, "/notes/1/attachments", `{"url": "https://cdn.example.com/b.png", "content_type": "image/png"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 attaching to another user's note, got %d", rec.Code)
+		}
+		target := fmt.Sprintf("/notes/1/attachments/%d", attached.Data.ID)
+		if rec := serve(8, http.MethodDelete, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 detaching from another user's note, got %d", rec.Code)
+		}
+		if got := attachmentsOf(7); len(got) != 1 {
+			t.Errorf("expected the attachment to survive, got %+v", got)
+		}
+	})
+
+	t.Run("cap", func(t *testing.T) {
+		for i := len(repo.attached[1]); i < maxAttachmentsPerNote; i++ {
+			body := fmt.Sprintf(`{"url": "https://cdn.example.com/%d.png", "content_type": "image/png"}`, i)
+			if rec := serve(7, http.MethodPost, "/notes/1/attachments", body); rec.Code != http.StatusOK {
+				t.Fatalf("attachment %d: expected 200, got %d", i, rec.Code)
+			}
+		}
+		rec := serve(7, http.MethodPost, "/notes/1/attachments", `{"url": "https://cdn.example.com/extra.png", "content_type": "image/png"}`)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("expected 409 past the cap, got %d", rec.Code)
+		}
+	})
+
+	t.Run("detach", func(t *testing.T) {
+		target := fmt.Sprintf("/notes/1/attachments/%d", attached.Data.ID)
+		if rec := serve(7, http.MethodDelete, target, ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		for _, a := range attachmentsOf(7) {
+			if a.ID == attached.Data.ID {
+				t.Errorf("expected attachment %d to be gone", a.ID)
+			}
+		}
+		if rec := serve(7, http.MethodDelete, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 detaching twice, got %d", rec.Code)
+		}
+	})
+}
+
+func TestSaveUserNoteHandlerIgnoresDoubleSubmit(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	token, err := util.GenerateJWT(7, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	save := func(body string) UserNotes {
+		req := httptest.NewRequest(http.MethodPost, "/save-note", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		auth.AuthMiddleware(http.HandlerFunc(h.SaveUserNoteHandler)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data UserNotes `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		return resp.Data
+	}
+
+	first := save(`{"verse_reference": "John 3:16", "content": "God so loved"}`)
+	second := save(`{"verse_reference": "John 3:16", "content": "God so loved"}`)
+	if second.ID != first.ID {
+		t.Errorf("expected the repeat to return note %d, got %d", first.ID, second.ID)
+	}
+
+	// Different content is a new note, even straight away
+	if other := save(`{"verse_reference": "John 3:16", "content": "and gave his Son"}`); other.ID == first.ID {
+		t.Error("expected a different note to be saved separately")
+	}
+
+	if got := len(repo.notes[7]); got != 2 {
+		t.Errorf("expected 2 notes stored, got %d", got)
+	}
+}
+
+func TestSaveUserNoteHandlerNormalisesReference(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	repo := &fakeVerseRepo{}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	token, err := util.GenerateJWT(7, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	save := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/save-note", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		auth.AuthMiddleware(http.HandlerFunc(h.SaveUserNoteHandler)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := save(`{"verse_reference": "Jhn 3 16", "content": "God so loved"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// The canonical spelling is the same verse, so this is a double submit
+	if rec := save(`{"verse_reference": "John 3:16", "content": "God so loved"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := repo.notes[7]; len(got) != 1 || got[0].VerseReference != "John 3:16" {
+		t.Errorf("expected one note on John 3:16, got %+v", got)
+	}
+
+	rec = save(`{"verse_reference": "Hezekiah 3:16", "content": "not a book"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `unknown book \"Hezekiah\"`) {
+		t.Errorf("expected the error to name the unknown book, got %s", rec.Body.String())
+	}
+}
+
+func TestGetRelatedVersesHandler(t *testing.T) {
+	repo := &fakeVerseRepo{verses: []Verse{
+		{ID: 1, Reference: "John 3:16", Translation: "KJV"},
+		{ID: 2, Reference: "John 3:17", Translation: "KJV"},
+		{ID: 3, Reference: "John 3:17", Translation: "NIV"},
+		{ID: 4, Reference: "John 31:1", Translation: "KJV"},
+		{ID: 5, Reference: "1 John 3:1", Translation: "KJV"},
+		{ID: 6, Reference: "John 4:1", Translation: "KJV"},
+		{ID: 7, Reference: "John 3", Translation: "KJV"},
+	}}
+	h := NewMemoryVerseHandler(MemoryVerseService{repo: repo})
+
+	router := chi.NewRouter()
+	router.Get("/verse/{id}/related", h.GetRelatedVersesHandler)
+	related := func(target string) (*httptest.ResponseRecorder, []int) {
+		rec := serveAuthed(t, router.ServeHTTP, 9, target)
+		var resp struct {
+			Data []Verse `json:"data"`
+		}
+		var ids []int
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			for _, v := range resp.Data {
+				ids = append(ids, v.ID)
+			}
+		}
+		return rec, ids
+	}
+
+	rec, ids := related("/verse/1/related")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if want := []int{2, 7}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected same-chapter verses %v, got %v", want, ids)
+	}
+
+	if _, ids := related("/verse/1/related?limit=1"); len(ids) != 1 {
+		t.Errorf("expected limit to apply, got %v", ids)
+	}
+	if rec, _ := related("/verse/99/related"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown verse, got %d", rec.Code)
+	}
+}
+
+func TestGetDashboardVerseHandlerRecordsImpression(t *testing.T) {
+	s, repo := newDashboardService(nil)
+	h := NewMemoryVerseHandler(*s)
+
+	rec := serveAuthed(t, h.GetDashboardVerseHandler, 1, "/dashboard")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The impression is written in the background after the response.
+	deadline := time.Now().Add(time.Second)
+	for len(repo.impressionsFor(1)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := repo.impressionsFor(1); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected one impression of verse 1, got %v", got)
+	}
+}
+
+func TestGetDashboardVerseHandlerWritesNothingInMaintenance(t *testing.T) {
+	s, repo := newDashboardService(nil)
+	h := NewMemoryVerseHandler(*s)
+
+	rec := serveAuthed(t, auth.Maintenance(true)(http.HandlerFunc(h.GetDashboardVerseHandler)).ServeHTTP, 1, "/dashboard")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected reads to work in maintenance, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Give a stray background impression write time to land
+	time.Sleep(20 * time.Millisecond)
+	if got := repo.impressionsFor(1); len(got) != 0 {
+		t.Errorf("expected no impressions in maintenance, got %v", got)
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if got := repo.delivered[1]; len(got) != 0 {
+		t.Errorf("expected no delivery recorded in maintenance, got %v", got)
+	}
+}
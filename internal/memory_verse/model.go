@@ -9,6 +9,45 @@ type Verse struct {
 	Translation string    `json:"translation"`
 	CreatedAt   time.Time `json:"created_at"`
 	IsFavourite bool      `json:"is_favourite"`
+	// FavouriteCount is only populated by the popular verses query
+	FavouriteCount int `json:"favourite_count,omitempty"`
+	// Prompt is a reflection question, only set on the dashboard verse
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// RecentVersesPage is one page of the newest verses and the catalogue size.
+type RecentVersesPage struct {
+	Verses []Verse
+	Total  int
+}
+
+// PublicDailyVerse is the embeddable verse-of-the-day widget payload. Date is
+// the UTC day it belongs to, as YYYY-MM-DD. NextRefreshAt and Streak are only
+// set for today's verse, not in the archive.
+type PublicDailyVerse struct {
+	Reference     string     `json:"reference"`
+	Verse         string     `json:"verse"`
+	Translation   string     `json:"translation"`
+	Date          string     `json:"date"`
+	NextRefreshAt *time.Time `json:"next_refresh_at,omitempty"` // next UTC midnight
+	Streak        int        `json:"streak,omitempty"`          // days in a row with a verse of the day
+}
+
+// FavouriteShareLink is returned once when a favourites share is created;
+// the token can't be recovered later.
+type FavouriteShareLink struct {
+	Token     string    `json:"token"`
+	ShareURL  string    `json:"share_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SharedFavourites is the public view of a favourites share. It carries the
+// sharer's user name and nothing else about them.
+type SharedFavourites struct {
+	DisplayName string    `json:"display_name"`
+	Verses      []Verse   `json:"verses"`
+	SharedAt    time.Time `json:"shared_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
 
 type VerseHistory struct {
@@ -18,12 +57,46 @@ type VerseHistory struct {
 	Verse       Verse     `json:"verse"`
 }
 
+// HistoryRange bounds verse history by delivery time; a nil bound is open-ended.
+type HistoryRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
 type UserNotes struct {
-	ID             int       `json:"id"`
-	VerseReference string    `json:"verse_reference"`
-	Content        string    `json:"content"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             int              `json:"id"`
+	VerseReference string           `json:"verse_reference"`
+	Content        string           `json:"content"`
+	Attachments    []NoteAttachment `json:"attachments,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// InactiveUser is a subscribed user who hasn't opened the dashboard since
+// LastActiveAt (their sign-up time if they never have).
+type InactiveUser struct {
+	ID             int
+	Email          string
+	UserName       string
+	LastActiveAt   time.Time
+	LastReminderAt *time.Time
+	SnoozedUntil   *time.Time
+}
+
+// NoteAttachment is a previously uploaded file, such as a photo of a
+// handwritten note, linked to a note by URL.
+type NoteAttachment struct {
+	ID          int       `json:"id"`
+	NoteID      int       `json:"note_id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type NoteSearchResult struct {
+	UserNotes
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
 
 type FavouriteVerse struct {
@@ -34,6 +107,207 @@ type FavouriteVerse struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// FavouriteSummary breaks a user's favourites down by translation so the UI
+// can nudge them towards ones they rarely save from.
+type FavouriteSummary struct {
+	Total        int                     `json:"total"`
+	Translations []TranslationFavourites `json:"translations"`
+}
+
+type TranslationFavourites struct {
+	Translation string `json:"translation"`
+	Count       int    `json:"count"`
+}
+
+// List orderings accepted in ?sort= by the favourites and notes endpoints
+const (
+	SortCreatedDesc = "created_desc"
+	SortCreatedAsc  = "created_asc"
+	SortReference   = "reference"
+	SortUpdatedDesc = "updated_desc"
+	SortPosition    = "position" // the user's own favourites order
+)
+
+// ReorderFavouritesRequest lists every favourited verse id in the user's
+// chosen order.
+type ReorderFavouritesRequest struct {
+	OrderedIDs []int `json:"ordered_ids" validate:"required"`
+}
+
 type AddToFavouriteRequest struct {
-	VerseID int `json:"verse_id"`
+	VerseID int `json:"verse_id" validate:"required"`
+}
+
+// MaxBatchVerseIDs caps how many verses one batch request can fetch
+const MaxBatchVerseIDs = 100
+
+type BatchVersesRequest struct {
+	IDs []int `json:"ids" validate:"required,max=100"`
+}
+
+// BulkFavouritesRequest applies many favourite changes at once. An id present
+// in both lists ends up removed.
+type BulkFavouritesRequest struct {
+	Add    []int `json:"add" validate:"max=100"`
+	Remove []int `json:"remove" validate:"max=100"`
+}
+
+type FavouriteState struct {
+	VerseID     int  `json:"verse_id"`
+	IsFavourite bool `json:"is_favourite"`
+	// FavouriteCount is how many users have favourited the verse after the change
+	FavouriteCount int `json:"favourite_count"`
+}
+
+// Collection is a user's named group of verses for study.
+type Collection struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AttachNoteFileRequest struct {
+	URL         string `json:"url" validate:"required,max=2048"`
+	ContentType string `json:"content_type" validate:"required,max=100"`
+}
+
+type CreateCollectionRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CollectionVerseRequest struct {
+	VerseID int `json:"verse_id" validate:"required"`
+}
+
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+type VerseReport struct {
+	ID         int        `json:"id"`
+	VerseID    int        `json:"verse_id"`
+	UserID     int        `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+type ReportVerseRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// NewVerse is a verse as an admin adds or imports it. The text is cleaned
+// with sanitizeVerseText before it is stored; RawVerse keeps what arrived.
+type NewVerse struct {
+	Reference   string `json:"reference" validate:"required,max=100"`
+	Verse       string `json:"verse" validate:"required,max=5000"`
+	Translation string `json:"translation" validate:"required,max=20"`
+	RawVerse    string `json:"-"`
+}
+
+// ImportVersesRequest adds a batch of verses at once.
+type ImportVersesRequest struct {
+	Verses []NewVerse `json:"verses" validate:"required,min=1,max=500"`
+}
+
+// SetVersePromptsRequest replaces a verse's reflection prompts; an empty
+// list clears them so the generic prompt is used.
+type SetVersePromptsRequest struct {
+	Prompts []string `json:"prompts" validate:"max=20"`
+}
+
+// VerseCommentary is the study commentary for a verse. It is kept off Verse
+// so the everyday verse payload stays small.
+type VerseCommentary struct {
+	VerseID    int    `json:"verse_id"`
+	Commentary string `json:"commentary"`
+}
+
+// SetVerseCommentaryRequest replaces a verse's commentary; an empty string
+// removes it.
+type SetVerseCommentaryRequest struct {
+	Commentary string `json:"commentary" validate:"max=20000"`
+}
+
+type SaveNoteRequest struct {
+	VerseReference string `json:"verse_reference" validate:"required"`
+	Content        string `json:"content" validate:"required"`
+}
+
+type SnoozeRequest struct {
+	Until time.Time `json:"until" validate:"required"`
+}
+
+// GoalProgress counts verses delivered towards the user's verse goal.
+type GoalProgress struct {
+	Current   int        `json:"current"`
+	Target    int        `json:"target"`
+	ReachedAt *time.Time `json:"reached_at,omitempty"`
+}
+
+// UpcomingDelivery previews the user's next verse delivery. When deliveries
+// are paused, PausedReason says why and there is no send time or verse.
+type UpcomingDelivery struct {
+	NextSendAt   *time.Time `json:"next_send_at"`
+	Pace         string     `json:"pace"`
+	Verses       []Verse    `json:"verses"`
+	PausedReason string     `json:"paused_reason,omitempty"` // unsubscribed or goal_reached
+}
+
+// EmailOpenStats summarises tracking pixel loads for sent verse emails.
+type EmailOpenStats struct {
+	Sent        int     `json:"sent"`
+	Opened      int     `json:"opened"`
+	TotalOpens  int     `json:"total_opens"`
+	OpenRatePct float64 `json:"open_rate_pct"`
+}
+
+// SubjectVariantStats compares opens for one verse email subject variant.
+type SubjectVariantStats struct {
+	Variant     string  `json:"variant"`
+	Sent        int     `json:"sent"`
+	Opened      int     `json:"opened"`
+	OpenRatePct float64 `json:"open_rate_pct"`
+}
+
+// ImpressionStats summarises dashboard verse views since a point in time.
+type ImpressionStats struct {
+	Since       time.Time          `json:"since"`
+	Total       int                `json:"total"`
+	UniqueUsers int                `json:"unique_users"`
+	TopVerses   []VerseImpressions `json:"top_verses"`
+}
+
+// VerseImpressions counts dashboard views of one verse.
+type VerseImpressions struct {
+	VerseID   int    `json:"verse_id"`
+	Reference string `json:"reference"`
+	Views     int    `json:"views"`
+}
+
+// TranslationCoverage compares the verses held for a translation with the
+// users who have chosen it.
+type TranslationCoverage struct {
+	Translation string `json:"translation"`
+	VerseCount  int    `json:"verse_count"`
+	UserCount   int    `json:"user_count"`
+	// Missing is set when users have chosen the translation but there are no
+	// verses for it, which leaves their dashboard empty
+	Missing bool `json:"missing"`
+}
+
+// TopicCoverage counts the users who selected an inspiration topic.
+type TopicCoverage struct {
+	Topic     string `json:"topic"`
+	UserCount int    `json:"user_count"`
+}
+
+// VerseCoverage is the admin content-gap report.
+type VerseCoverage struct {
+	Translations        []TranslationCoverage `json:"translations"`
+	Topics              []TopicCoverage       `json:"topics"`
+	MissingTranslations []string              `json:"missing_translations"`
 }
@@ -4,8 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
 
 	"github.com/taiwoajasa245/memory-verse-api/internal/database"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
 )
 
 var (
@@ -16,40 +21,110 @@ var (
 
 type MemoryVerseRepo interface {
 	GetRandomVerse(ctx context.Context, userID int, translation string) (*Verse, error)
+	GetVersesByIDs(ctx context.Context, userID int, ids []int) ([]Verse, error)
+	GetRandomGuestVerse(ctx context.Context, guestID, translation string, since time.Time) (*Verse, error)
+	SaveGuestVerse(ctx context.Context, guestID string, verseID int) error
+	GetWeeklyVerses(ctx context.Context, userID int, count int) ([]Verse, error)
 	GetLastDeliveredVerse(ctx context.Context, userID int) (*VerseHistory, error)
 	SaveDeliveredVerse(ctx context.Context, userID, verseID int) error
-	SaveUserNote(ctx context.Context, userID int, verseRef, content string) error
-	GetUserNotes(ctx context.Context, userID int) ([]UserNotes, error)
+	ClaimDeliveredVerse(ctx context.Context, userID, verseID int, after *time.Time) (*Verse, error)
+	SaveUserNote(ctx context.Context, userID int, verseRef, content string, dedupeWindow time.Duration, limit int) (*UserNotes, error)
+	GetUserNotes(ctx context.Context, userID int, sort string) ([]UserNotes, error)
+	SearchUserNotes(ctx context.Context, userID int, query string, limit, offset int) ([]NoteSearchResult, int, error)
+	AttachNoteFile(ctx context.Context, userID, noteID int, url, contentType string, limit int) (*NoteAttachment, error)
+	DetachNoteFile(ctx context.Context, userID, noteID, attachmentID int) error
+	GetInactiveUsers(ctx context.Context, since time.Time) ([]InactiveUser, error)
+	MarkReminderSent(ctx context.Context, userID int, at time.Time) error
 	GetAllUserVerseHistory(ctx context.Context, userID int) ([]VerseHistory, error)
-	ToggleFavouriteVerse(ctx context.Context, userID, verseID int) (bool, error)
-	GetUserFavouriteVerses(ctx context.Context, userID int) ([]FavouriteVerse, error)
+	GetUserVerseHistoryPage(ctx context.Context, userID int, after *pagination.Cursor, limit int) ([]VerseHistory, error)
+	StreamUserVerseHistory(ctx context.Context, userID int, rng HistoryRange, fn func(VerseHistory) error) error
+	ToggleFavouriteVerse(ctx context.Context, userID, verseID, limit int) (*FavouriteState, error)
+	BulkToggleFavourites(ctx context.Context, userID int, add, remove []int, limit int) ([]FavouriteState, error)
+	ReorderFavourites(ctx context.Context, userID int, verseIDs []int) error
+	GetUserFavouriteVerses(ctx context.Context, userID int, sort string, after *pagination.Cursor, limit int) ([]FavouriteVerse, error)
 	IsVerseFavourited(ctx context.Context, userID, verseID int) (bool, error)
+	CreateCollection(ctx context.Context, userID int, name string) (*Collection, error)
+	GetUserCollections(ctx context.Context, userID int) ([]Collection, error)
+	AddVerseToCollection(ctx context.Context, userID, collectionID, verseID int) error
+	RemoveVerseFromCollection(ctx context.Context, userID, collectionID, verseID int) error
+	GetCollectionVerses(ctx context.Context, userID, collectionID int) ([]Verse, error)
+	GetPopularVerses(ctx context.Context, limit int) ([]Verse, error)
+	GetRecentVerses(ctx context.Context, limit, offset int) ([]Verse, int, error)
+	GetFavouritedVerseIDs(ctx context.Context, userID int, verseIDs []int) (map[int]bool, error)
+	GetTranslations(ctx context.Context) ([]string, error)
+	CreateVerseReport(ctx context.Context, userID, verseID int, reason string) (*VerseReport, error)
+	GetVerseReports(ctx context.Context, status string, limit, offset int) ([]VerseReport, int, error)
+	ResolveVerseReport(ctx context.Context, reportID int) (*VerseReport, error)
+	GetVersePrompts(ctx context.Context, verseID int) ([]string, error)
+	CreateEmailSend(ctx context.Context, token string, userID, verseID int, subjectVariant string) error
+	GetSubjectVariantStats(ctx context.Context) ([]SubjectVariantStats, error)
+	RecordEmailOpen(ctx context.Context, token string) (bool, error)
+	RecordEmailClick(ctx context.Context, token, destination string) (bool, error)
+	GetEmailOpenStats(ctx context.Context) (*EmailOpenStats, error)
+	GetTranslationCoverage(ctx context.Context) ([]TranslationCoverage, error)
+	GetTopicCoverage(ctx context.Context) ([]TopicCoverage, error)
+	SetVersePrompts(ctx context.Context, verseID int, prompts []string) error
+	GetVerseCommentary(ctx context.Context, verseID int) (string, error)
+	SetVerseCommentary(ctx context.Context, verseID int, commentary string) error
+	CreateVerse(ctx context.Context, verse NewVerse) (*Verse, error)
+	BulkInsertVerses(ctx context.Context, verses []NewVerse) (int, error)
+	CreateFavouriteShare(ctx context.Context, userID int, tokenHash, displayName string, expiresAt time.Time) error
+	GetFavouriteShare(ctx context.Context, tokenHash string) (*SharedFavourites, error)
+	SaveDailyVerse(ctx context.Context, day string, verseID int) (*Verse, error)
+	GetFavouriteSummary(ctx context.Context, userID int) ([]TranslationFavourites, error)
+	GetRelatedVerses(ctx context.Context, userID, verseID, limit int) ([]Verse, error)
+	RecordVerseImpression(ctx context.Context, userID, verseID int) error
+	CountDeliveredVersesSince(ctx context.Context, userID int, since time.Time) (int, error)
+	GetImpressionStats(ctx context.Context, since time.Time, top int) (*ImpressionStats, error)
+	GetDailyVerseArchive(ctx context.Context, rng HistoryRange, limit, offset int) ([]PublicDailyVerse, int, error)
+	CountDailyVerseStreak(ctx context.Context, day string) (int, error)
 }
 
 type repository struct {
 	db *sql.DB
+	// readDB serves read-heavy queries (history, favourites, search, stats)
+	// that can tolerate replica lag. Writes and reads feeding writes stay
+	// on db.
+	readDB *sql.DB
 }
 
 func NewMemoryVerseRepo(dbService database.Service) MemoryVerseRepo {
-	return &repository{db: dbService.DB()}
+	return &repository{db: dbService.DB(), readDB: dbService.ReadDB()}
 }
 
+// reader returns the read replica, or the primary when there is none.
+func (r *repository) reader() *sql.DB {
+	if r.readDB != nil {
+		return r.readDB
+	}
+	return r.db
+}
+
+// randFloat picks where in the candidate set a random verse is taken from.
+// Tests replace it to make selection deterministic.
+var randFloat = rand.Float64
+
+// GetRandomVerse picks a random verse in the translation. Rather than
+// ORDER BY RANDOM(), which sorts every candidate, it skips a random number of
+// rows in id order. The count and the pick run in one statement so they see
+// the same snapshot, and the (translation, id) index serves both.
 func (r *repository) GetRandomVerse(ctx context.Context, userID int, translation string) (*Verse, error) {
 	query := `
-		SELECT 
+		SELECT
 			mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
 			EXISTS (
-				SELECT 1 FROM favourite_verses fv 
+				SELECT 1 FROM favourite_verses fv
 				WHERE fv.user_id = $1 AND fv.verse_id = mv.id
 			) AS is_favourite
 		FROM memory_verses mv
 		WHERE mv.translation = $2
-		ORDER BY RANDOM()
+		ORDER BY mv.id
+		OFFSET FLOOR($3 * (SELECT COUNT(*) FROM memory_verses WHERE translation = $2))
 		LIMIT 1
 	`
 
 	var v Verse
-	err := r.db.QueryRowContext(ctx, query, userID, translation).Scan(
+	err := r.db.QueryRowContext(ctx, query, userID, translation, randFloat()).Scan(
 		&v.ID,
 		&v.Reference,
 		&v.Verse,
@@ -66,6 +141,186 @@ func (r *repository) GetRandomVerse(ctx context.Context, userID int, translation
 	return &v, nil
 }
 
+// GetRandomGuestVerse picks a random verse for a signed-out visitor, in the
+// given translation when one is set, skipping verses served to guestID since
+// the given time. An empty guestID skips nothing.
+func (r *repository) GetRandomGuestVerse(ctx context.Context, guestID, translation string, since time.Time) (*Verse, error) {
+	query := `
+		WITH candidates AS (
+			SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+			FROM memory_verses mv
+			WHERE ($1 = '' OR mv.translation = $1)
+			  AND NOT EXISTS (
+				SELECT 1 FROM guest_history gh
+				WHERE gh.guest_id = $2 AND gh.verse_id = mv.id AND gh.served_at >= $3
+			  )
+		)
+		SELECT id, reference, verse, translation, created_at
+		FROM candidates
+		ORDER BY id
+		OFFSET FLOOR($4 * (SELECT COUNT(*) FROM candidates))
+		LIMIT 1
+	`
+
+	var v Verse
+	err := r.db.QueryRowContext(ctx, query, translation, guestID, since.UTC(), randFloat()).Scan(
+		&v.ID,
+		&v.Reference,
+		&v.Verse,
+		&v.Translation,
+		&v.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrInternalServer
+	}
+	return &v, nil
+}
+
+// SaveGuestVerse records that a verse was served to a guest.
+func (r *repository) SaveGuestVerse(ctx context.Context, guestID string, verseID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO guest_history (guest_id, verse_id)
+		VALUES ($1, $2)
+		ON CONFLICT (guest_id, verse_id) DO UPDATE SET served_at = NOW()
+	`, guestID, verseID)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// GetVersesByIDs returns the verses matching ids in the order the ids were
+// given. Unknown ids are skipped rather than treated as an error.
+func (r *repository) GetVersesByIDs(ctx context.Context, userID int, ids []int) ([]Verse, error) {
+	query := `
+		SELECT
+			mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+			EXISTS (
+				SELECT 1 FROM favourite_verses fv
+				WHERE fv.user_id = $1 AND fv.verse_id = mv.id
+			) AS is_favourite
+		FROM memory_verses mv
+		WHERE mv.id = ANY($2::int[])
+		ORDER BY array_position($2::int[], mv.id)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, ids)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.IsFavourite); err != nil {
+			return nil, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return verses, nil
+}
+
+// GetRelatedVerses lists other verses in the same book and chapter as
+// verseID and in its translation, in verse order. A verse whose reference
+// can't be parsed has no related verses.
+func (r *repository) GetRelatedVerses(ctx context.Context, userID, verseID, limit int) ([]Verse, error) {
+	var reference, translation string
+	err := r.db.QueryRowContext(ctx, `SELECT reference, translation FROM memory_verses WHERE id = $1`, verseID).
+		Scan(&reference, &translation)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrInternalServer
+	}
+
+	chapter, ok := chapterOf(reference)
+	if !ok {
+		return nil, nil
+	}
+
+	query := `
+		SELECT
+			mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+			EXISTS (
+				SELECT 1 FROM favourite_verses fv
+				WHERE fv.user_id = $1 AND fv.verse_id = mv.id
+			) AS is_favourite
+		FROM memory_verses mv
+		WHERE mv.id <> $2 AND mv.translation = $3
+			AND (mv.reference = $4 OR LEFT(mv.reference, LENGTH($4) + 1) = $4 || ':')
+		ORDER BY NULLIF(SUBSTRING(mv.reference FROM ':(\d+)'), '')::int NULLS FIRST, mv.id
+		LIMIT $5
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, verseID, translation, chapter, limit)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.IsFavourite); err != nil {
+			return nil, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return verses, nil
+}
+
+// GetWeeklyVerses picks count random verses in the user's translation for a weekly digest.
+func (r *repository) GetWeeklyVerses(ctx context.Context, userID int, count int) ([]Verse, error) {
+	query := `
+		SELECT
+			mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+			EXISTS (
+				SELECT 1 FROM favourite_verses fv
+				WHERE fv.user_id = $1 AND fv.verse_id = mv.id
+			) AS is_favourite
+		FROM memory_verses mv
+		JOIN user_profiles p ON p.user_id = $1 AND p.bible_translation = mv.translation
+		ORDER BY RANDOM()
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, count)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.IsFavourite); err != nil {
+			return nil, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return verses, nil
+}
+
 func (r *repository) GetLastDeliveredVerse(ctx context.Context, userID int) (*VerseHistory, error) {
 	query := `
 		SELECT uh.user_id, uh.verse_id, uh.delivered_at,
@@ -99,10 +354,13 @@ func (r *repository) GetLastDeliveredVerse(ctx context.Context, userID int) (*Ve
 	return &h, nil
 }
 
+// SaveDeliveredVerse records a delivery. Recording the same verse for the
+// same user again on the same day is a no-op.
 func (r *repository) SaveDeliveredVerse(ctx context.Context, userID, verseID int) error {
 	query := `
 		INSERT INTO user_verse_history (user_id, verse_id)
 		VALUES ($1, $2)
+		ON CONFLICT (user_id, verse_id, delivery_date) DO NOTHING
 	`
 	_, err := r.db.ExecContext(ctx, query, userID, verseID)
 	if err != nil {
@@ -111,25 +369,174 @@ func (r *repository) SaveDeliveredVerse(ctx context.Context, userID, verseID int
 	return nil
 }
 
-func (r *repository) SaveUserNote(ctx context.Context, userID int, verseRef, content string) error {
-	query := `
+// ClaimDeliveredVerse records verseID as the user's new delivery unless
+// another request recorded one after `after` (nil when the user had none)
+// first; then that verse is returned instead and nothing is written. The
+// user row is locked so concurrent dashboard loads, each holding a different
+// random pick, settle on one verse.
+func (r *repository) ClaimDeliveredVerse(ctx context.Context, userID, verseID int, after *time.Time) (*Verse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	var since *time.Time
+	if after != nil {
+		t := after.UTC()
+		since = &t
+	}
+
+	var v Verse
+	err = tx.QueryRowContext(ctx, `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM user_verse_history h
+		JOIN memory_verses mv ON mv.id = h.verse_id
+		WHERE h.user_id = $1 AND ($2::timestamp IS NULL OR h.delivered_at > $2::timestamp)
+		ORDER BY h.delivered_at DESC
+		LIMIT 1
+	`, userID, since).Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt)
+	if err == nil {
+		return &v, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInternalServer
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_verse_history (user_id, verse_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, verse_id, delivery_date) DO NOTHING
+	`, userID, verseID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return nil, nil
+}
+
+// CountDeliveredVersesSince counts the verses delivered to the user at or after since.
+func (r *repository) CountDeliveredVersesSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM user_verse_history WHERE user_id = $1 AND delivered_at >= $2
+	`, userID, since).Scan(&count)
+	if err != nil {
+		return 0, ErrInternalServer
+	}
+	return count, nil
+}
+
+// SaveUserNote inserts a note, unless an identical one was saved within
+// dedupeWindow, in which case that note is returned instead. A new note is
+// refused with LimitExceededError once the user has limit notes; a limit of 0
+// means no cap. Saves for one user are serialised with an advisory lock so two
+// simultaneous requests can't both miss each other or both take the last slot.
+func (r *repository) SaveUserNote(ctx context.Context, userID int, verseRef, content string, dedupeWindow time.Duration, limit int) (*UserNotes, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('user_notes'), $1)`, userID); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	var note UserNotes
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, verse_reference, content, created_at, updated_at
+		FROM user_notes
+		WHERE user_id = $1 AND verse_reference = $2 AND content = $3
+			AND created_at > NOW() - make_interval(secs => $4)
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID, verseRef, content, dedupeWindow.Seconds()).
+		Scan(&note.ID, &note.VerseReference, &note.Content, &note.CreatedAt, &note.UpdatedAt)
+	if err == nil {
+		return &note, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInternalServer
+	}
+
+	if limit > 0 {
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_notes WHERE user_id = $1`, userID).Scan(&count)
+		if err != nil {
+			return nil, ErrInternalServer
+		}
+		if count >= limit {
+			return nil, &LimitExceededError{Resource: "notes", Limit: limit, Count: count}
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO user_notes (user_id, verse_reference, content)
 		VALUES ($1, $2, $3)
-	`
-	_, err := r.db.ExecContext(ctx, query, userID, verseRef, content)
+		RETURNING id, verse_reference, content, created_at, updated_at
+	`, userID, verseRef, content).
+		Scan(&note.ID, &note.VerseReference, &note.Content, &note.CreatedAt, &note.UpdatedAt)
 	if err != nil {
-		return ErrInternalServer
+		return nil, ErrInternalServer
 	}
-	return nil
+
+	if err := tx.Commit(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return &note, nil
 }
 
-func (r *repository) GetUserNotes(ctx context.Context, userID int) ([]UserNotes, error) {
+// noteSortOrders and favouriteSortOrders are the only ORDER BY clauses a
+// ?sort= value can select; user input never reaches the SQL directly.
+var (
+	noteSortOrders = map[string]string{
+		SortCreatedDesc: "created_at DESC",
+		SortCreatedAsc:  "created_at ASC",
+		SortReference:   "verse_reference ASC, created_at DESC",
+		SortUpdatedDesc: "updated_at DESC",
+	}
+	// Favourites end on fv.id so every row has a unique position for keyset
+	// paging
+	favouriteSortOrders = map[string]string{
+		SortCreatedDesc: "fv.created_at DESC, fv.id DESC",
+		SortCreatedAsc:  "fv.created_at ASC, fv.id ASC",
+		SortReference:   "mv.reference ASC, fv.created_at DESC, fv.id DESC",
+		SortPosition:    "fv.position ASC, fv.created_at DESC, fv.id DESC",
+	}
+	// favouriteKeysets select the rows after the cursor ($2 created_at, $3 id)
+	// in each favouriteSortOrders order. The reference and position orders
+	// look up the cursor row's reference and position, since the cursor
+	// doesn't carry them.
+	favouriteKeysets = map[string]string{
+		SortCreatedDesc: "(fv.created_at, fv.id) < ($2, $3)",
+		SortCreatedAsc:  "(fv.created_at, fv.id) > ($2, $3)",
+		SortReference: `(mv.reference > cur.reference
+			OR (mv.reference = cur.reference AND (fv.created_at, fv.id) < ($2, $3)))`,
+		SortPosition: `(fv.position > cur.position
+			OR (fv.position = cur.position AND (fv.created_at, fv.id) < ($2, $3)))`,
+	}
+)
+
+// GetUserNotes lists the user's notes in the given sort order, newest first
+// when sort is empty or unknown.
+func (r *repository) GetUserNotes(ctx context.Context, userID int, sort string) ([]UserNotes, error) {
+	orderBy, ok := noteSortOrders[sort]
+	if !ok {
+		orderBy = noteSortOrders[SortCreatedDesc]
+	}
+
 	query := `
 		SELECT id, verse_reference, content, created_at, updated_at
 		FROM user_notes
 		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`
+		ORDER BY ` + orderBy
 
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
@@ -146,128 +553,1555 @@ func (r *repository) GetUserNotes(ctx context.Context, userID int) ([]UserNotes,
 		notes = append(notes, note)
 	}
 
+	ids := make([]int, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+	attachments, err := r.getNoteAttachments(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		notes[i].Attachments = attachments[notes[i].ID]
+	}
+
 	return notes, nil
 }
 
-func (r *repository) GetAllUserVerseHistory(ctx context.Context, userID int) ([]VerseHistory, error) {
-	query := `
-		SELECT uh.verse_id, uh.delivered_at,
-		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
-		FROM user_verse_history uh
-		JOIN memory_verses mv ON mv.id = uh.verse_id
-		WHERE uh.user_id = $1
-		ORDER BY uh.delivered_at DESC
-	`
+// getNoteAttachments loads the attachments of the given notes from db, keyed
+// by note id, oldest first.
+func (r *repository) getNoteAttachments(ctx context.Context, db *sql.DB, noteIDs []int) (map[int][]NoteAttachment, error) {
+	attachments := map[int][]NoteAttachment{}
+	if len(noteIDs) == 0 {
+		return attachments, nil
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, note_id, url, content_type, created_at
+		FROM attachments
+		WHERE note_id = ANY($1)
+		ORDER BY created_at, id
+	`, noteIDs)
 	if err != nil {
 		return nil, ErrInternalServer
 	}
 	defer rows.Close()
 
-	var histories []VerseHistory
 	for rows.Next() {
-		var h VerseHistory
-		if err := rows.Scan(
-			// &h.UserID,
-			&h.VerseID,
-			&h.DeliveredAt,
-			&h.Verse.ID,
-			&h.Verse.Reference,
-			&h.Verse.Verse,
-			&h.Verse.Translation,
-			&h.Verse.CreatedAt,
-		); err != nil {
+		var a NoteAttachment
+		if err := rows.Scan(&a.ID, &a.NoteID, &a.URL, &a.ContentType, &a.CreatedAt); err != nil {
 			return nil, ErrInternalServer
 		}
-		histories = append(histories, h)
+		attachments[a.NoteID] = append(attachments[a.NoteID], a)
 	}
-
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, ErrInternalServer
 	}
-
-	return histories, nil
+	return attachments, nil
 }
 
-func (r *repository) ToggleFavouriteVerse(ctx context.Context, userID, verseID int) (bool, error) {
-	queryCheck := `
-		SELECT EXISTS (
-			SELECT 1 FROM favourite_verses WHERE user_id = $1 AND verse_id = $2
-		)
-	`
-
-	var exists bool
-	err := r.db.QueryRowContext(ctx, queryCheck, userID, verseID).Scan(&exists)
+// AttachNoteFile links url to the note, refusing with LimitExceededError once
+// the note has limit attachments. Attaching the same url again updates its
+// content type and returns the existing attachment, even at the limit. The
+// note row is locked while counting, so concurrent attaches can't both take
+// the last slot.
+func (r *repository) AttachNoteFile(ctx context.Context, userID, noteID int, url, contentType string, limit int) (*NoteAttachment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return false, ErrNotFound
+		return nil, ErrInternalServer
 	}
+	defer tx.Rollback()
 
-	if exists {
-
-		_, err = r.db.ExecContext(ctx, `
-			DELETE FROM favourite_verses WHERE user_id = $1 AND verse_id = $2
-		`, userID, verseID)
-		if err != nil {
-			return false, ErrInternalServer
-		}
-		return false, nil
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM attachments WHERE note_id = n.id AND url <> $3)
+		FROM user_notes n
+		WHERE n.id = $1 AND n.user_id = $2
+		FOR UPDATE
+	`, noteID, userID, url).Scan(&count)
+	// Other users' notes are treated as missing so their ids aren't leaked
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	if count >= limit {
+		return nil, &LimitExceededError{Resource: "attachments", Limit: limit, Count: count}
 	}
 
-	// Otherwise, add it
-	_, err = r.db.ExecContext(ctx, `
-		INSERT INTO favourite_verses (user_id, verse_id)
-		VALUES ($1, $2)
-	`, userID, verseID)
+	var a NoteAttachment
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO attachments (note_id, url, content_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (note_id, url) DO UPDATE SET content_type = EXCLUDED.content_type
+		RETURNING id, note_id, url, content_type, created_at
+	`, noteID, url, contentType).Scan(&a.ID, &a.NoteID, &a.URL, &a.ContentType, &a.CreatedAt)
 	if err != nil {
-		return false, ErrInternalServer
+		return nil, ErrInternalServer
 	}
 
-	return true, nil // now favourited
+	if err := tx.Commit(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return &a, nil
 }
 
-func (r *repository) GetUserFavouriteVerses(ctx context.Context, userID int) ([]FavouriteVerse, error) {
-	query := `
-		SELECT fv.id, fv.user_id, fv.verse_id, fv.created_at,
-		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
-		FROM favourite_verses fv
-		JOIN memory_verses mv ON mv.id = fv.verse_id
-		WHERE fv.user_id = $1
-		ORDER BY fv.created_at DESC
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID)
+// DetachNoteFile returns ErrNotFound unless the attachment is on a note the
+// user owns.
+func (r *repository) DetachNoteFile(ctx context.Context, userID, noteID, attachmentID int) error {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM attachments a
+		USING user_notes n
+		WHERE a.id = $1 AND a.note_id = $2 AND n.id = a.note_id AND n.user_id = $3
+	`, attachmentID, noteID, userID)
 	if err != nil {
-		return nil, err
+		return ErrInternalServer
 	}
-	defer rows.Close()
-
-	var favourites []FavouriteVerse
-	for rows.Next() {
-		var fav FavouriteVerse
-		err := rows.Scan(
-			&fav.ID, &fav.UserID, &fav.VerseID, &fav.CreatedAt,
-			&fav.Verse.ID, &fav.Verse.Reference, &fav.Verse.Verse,
-			&fav.Verse.Translation, &fav.Verse.CreatedAt,
+	n, err := res.RowsAffected()
+	if err != nil {
+		return ErrInternalServer
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SearchUserNotes runs a ranked full-text search over the user's own notes.
+func (r *repository) SearchUserNotes(ctx context.Context, userID int, query string, limit, offset int) ([]NoteSearchResult, int, error) {
+	q := `
+		WITH search AS (
+			SELECT plainto_tsquery('english', $2) AS tsq
 		)
-		if err != nil {
+		SELECT n.id, n.verse_reference, n.content, n.created_at, n.updated_at,
+		       ts_rank(to_tsvector('english', coalesce(n.content, '') || ' ' || coalesce(n.verse_reference, '')), search.tsq) AS rank,
+		       ts_headline('english', n.content, search.tsq, 'MaxWords=20, MinWords=5') AS snippet,
+		       COUNT(*) OVER() AS total
+		FROM user_notes n, search
+		WHERE n.user_id = $1
+		  AND to_tsvector('english', coalesce(n.content, '') || ' ' || coalesce(n.verse_reference, '')) @@ search.tsq
+		ORDER BY rank DESC, n.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.reader().QueryContext(ctx, q, userID, query, limit, offset)
+	if err != nil {
+		return nil, 0, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var (
+		results []NoteSearchResult
+		total   int
+	)
+	for rows.Next() {
+		var res NoteSearchResult
+		if err := rows.Scan(
+			&res.ID, &res.VerseReference, &res.Content, &res.CreatedAt, &res.UpdatedAt,
+			&res.Rank, &res.Snippet, &total,
+		); err != nil {
+			return nil, 0, ErrInternalServer
+		}
+		results = append(results, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, ErrInternalServer
+	}
+
+	ids := make([]int, len(results))
+	for i, res := range results {
+		ids[i] = res.ID
+	}
+	attachments, err := r.getNoteAttachments(ctx, r.reader(), ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Attachments = attachments[results[i].ID]
+	}
+
+	return results, total, nil
+}
+
+func (r *repository) GetAllUserVerseHistory(ctx context.Context, userID int) ([]VerseHistory, error) {
+	query := `
+		SELECT uh.verse_id, uh.delivered_at,
+		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM user_verse_history uh
+		JOIN memory_verses mv ON mv.id = uh.verse_id
+		WHERE uh.user_id = $1
+		ORDER BY uh.delivered_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var histories []VerseHistory
+	for rows.Next() {
+		var h VerseHistory
+		if err := rows.Scan(
+			// &h.UserID,
+			&h.VerseID,
+			&h.DeliveredAt,
+			&h.Verse.ID,
+			&h.Verse.Reference,
+			&h.Verse.Verse,
+			&h.Verse.Translation,
+			&h.Verse.CreatedAt,
+		); err != nil {
 			return nil, ErrInternalServer
 		}
-		favourites = append(favourites, fav)
+		histories = append(histories, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
 	}
 
-	return favourites, nil
+	return histories, nil
 }
 
-func (r *repository) IsVerseFavourited(ctx context.Context, userID, verseID int) (bool, error) {
+// GetUserVerseHistoryPage lists up to limit deliveries after the cursor
+// (from the start when nil), newest first. A verse is delivered at most once
+// a day, so (delivered_at, verse_id) identifies a row.
+func (r *repository) GetUserVerseHistoryPage(ctx context.Context, userID int, after *pagination.Cursor, limit int) ([]VerseHistory, error) {
+	var afterAt *time.Time
+	var afterID *int
+	if after != nil {
+		afterAt, afterID = &after.LastCreatedAt, &after.LastID
+	}
+
+	// delivered_at is a TIMESTAMP holding UTC, so the cursor is bound as one
+	// too; as a timestamptz it would be shifted by the session time zone
 	query := `
-		SELECT EXISTS (
-			SELECT 1 FROM favourite_verses WHERE user_id = $1 AND verse_id = $2
+		SELECT uh.verse_id, uh.delivered_at,
+		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM user_verse_history uh
+		JOIN memory_verses mv ON mv.id = uh.verse_id
+		WHERE uh.user_id = $1
+		  AND ($2::timestamp IS NULL OR (uh.delivered_at, uh.verse_id) < ($2, $3::int))
+		ORDER BY uh.delivered_at DESC, uh.verse_id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.reader().QueryContext(ctx, query, userID, afterAt, afterID, limit)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var histories []VerseHistory
+	for rows.Next() {
+		var h VerseHistory
+		if err := rows.Scan(
+			&h.VerseID,
+			&h.DeliveredAt,
+			&h.Verse.ID,
+			&h.Verse.Reference,
+			&h.Verse.Verse,
+			&h.Verse.Translation,
+			&h.Verse.CreatedAt,
+		); err != nil {
+			return nil, ErrInternalServer
+		}
+		histories = append(histories, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return histories, nil
+}
+
+// StreamUserVerseHistory calls fn for each delivered verse in rng, oldest first,
+// without holding the whole history in memory.
+func (r *repository) StreamUserVerseHistory(ctx context.Context, userID int, rng HistoryRange, fn func(VerseHistory) error) error {
+	query := `
+		SELECT uh.verse_id, uh.delivered_at,
+		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM user_verse_history uh
+		JOIN memory_verses mv ON mv.id = uh.verse_id
+		WHERE uh.user_id = $1
+		  AND ($2::timestamp IS NULL OR uh.delivered_at >= $2)
+		  AND ($3::timestamp IS NULL OR uh.delivered_at < $3)
+		ORDER BY uh.delivered_at
+	`
+
+	rows, err := r.reader().QueryContext(ctx, query, userID, rng.From, rng.To)
+	if err != nil {
+		return ErrInternalServer
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h VerseHistory
+		if err := rows.Scan(
+			&h.VerseID,
+			&h.DeliveredAt,
+			&h.Verse.ID,
+			&h.Verse.Reference,
+			&h.Verse.Verse,
+			&h.Verse.Translation,
+			&h.Verse.CreatedAt,
+		); err != nil {
+			return ErrInternalServer
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return ErrInternalServer
+	}
+
+	return nil
+}
+
+// ToggleFavouriteVerse flips the favourite in a single statement so
+// concurrent toggles can't both insert or both delete. The unique index on
+// (user_id, verse_id) settles racing inserts, and lockFavourites keeps the
+// toggle from landing in the middle of a reorder or past the cap. When nothing
+// was removed the row exists afterwards, whether this call inserted it or a
+// concurrent one won the insert. An add is refused with LimitExceededError
+// once the user has limit favourites; a limit of 0 means no cap.
+func (r *repository) ToggleFavouriteVerse(ctx context.Context, userID, verseID, limit int) (*FavouriteState, error) {
+	// The final SELECT sees the table as it was before the CTEs ran, so the
+	// new count is the old one adjusted by what this statement changed
+	query := `
+		WITH removed AS (
+			DELETE FROM favourite_verses
+			WHERE user_id = $1 AND verse_id = $2
+			RETURNING verse_id
+		), added AS (
+			INSERT INTO favourite_verses (user_id, verse_id)
+			SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM removed)
+			ON CONFLICT (user_id, verse_id) DO NOTHING
+			RETURNING verse_id
 		)
+		SELECT
+			NOT EXISTS (SELECT 1 FROM removed),
+			(SELECT COUNT(*) FROM favourite_verses WHERE verse_id = $2)
+				+ (SELECT COUNT(*) FROM added)
+				- (SELECT COUNT(*) FROM removed)
 	`
-	var exists bool
-	err := r.db.QueryRowContext(ctx, query, userID, verseID).Scan(&exists)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return false, ErrInternalServer
+		return nil, ErrInternalServer
 	}
-	return exists, err
+	defer tx.Rollback()
+
+	if err := lockFavourites(ctx, tx, userID); err != nil {
+		return nil, ErrInternalServer
+	}
+	if err := checkFavouriteLimit(ctx, tx, userID, []int{verseID}, nil, limit); err != nil {
+		return nil, err
+	}
+
+	state := FavouriteState{VerseID: verseID}
+	if err := tx.QueryRowContext(ctx, query, userID, verseID).Scan(&state.IsFavourite, &state.FavouriteCount); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return &state, nil
+}
+
+// lockFavourites serialises changes to one user's favourites until tx ends.
+// Row locks can't do this: SELECT ... FOR UPDATE only locks rows that already
+// exist and doesn't stop another transaction inserting a new favourite.
+func lockFavourites(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('favourite_verses'), $1)`, userID)
+	return err
+}
+
+// checkFavouriteLimit fails with LimitExceededError if adding add and removing
+// remove would take the user past limit favourites. Adds of verses already
+// favourited don't count. It must run after lockFavourites, so the count
+// can't change before the caller writes.
+func checkFavouriteLimit(ctx context.Context, tx *sql.Tx, userID int, add, remove []int, limit int) error {
+	if limit <= 0 || len(add) == 0 {
+		return nil
+	}
+
+	var count, present, removing int
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE verse_id = ANY($2::int[])),
+			COUNT(*) FILTER (WHERE verse_id = ANY($3::int[]))
+		FROM favourite_verses
+		WHERE user_id = $1
+	`, userID, add, remove).Scan(&count, &present, &removing)
+	if err != nil {
+		return ErrInternalServer
+	}
+	if net := len(add) - present - removing; net > 0 && count+net > limit {
+		return &LimitExceededError{Resource: "favourites", Limit: limit, Count: count}
+	}
+	return nil
+}
+
+// ReorderFavourites stores verseIDs' order as the user's favourites order.
+// verseIDs must be exactly the user's favourites, or ErrInvalidFavouriteOrder
+// is returned and nothing changes.
+func (r *repository) ReorderFavourites(ctx context.Context, userID int, verseIDs []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	// Holds off adds and removes until the new order is committed, so the
+	// check below still describes the favourites the UPDATE writes
+	if err := lockFavourites(ctx, tx, userID); err != nil {
+		return ErrInternalServer
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT verse_id FROM favourite_verses WHERE user_id = $1`, userID)
+	if err != nil {
+		return ErrInternalServer
+	}
+	favourited := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return ErrInternalServer
+		}
+		favourited[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ErrInternalServer
+	}
+
+	if err := checkFavouriteOrder(favourited, verseIDs); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE favourite_verses fv
+		SET position = o.position
+		FROM unnest($2::int[]) WITH ORDINALITY AS o(verse_id, position)
+		WHERE fv.user_id = $1 AND fv.verse_id = o.verse_id
+	`, userID, verseIDs)
+	if err != nil {
+		return ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// BulkToggleFavourites adds and removes favourites in a single transaction and
+// returns the resulting state of every verse touched, ordered by verse id.
+// add and remove are expected to be disjoint; nothing is applied if any id is
+// not a known verse, or if the batch would take the user past limit
+// favourites (0 means no cap).
+func (r *repository) BulkToggleFavourites(ctx context.Context, userID int, add, remove []int, limit int) ([]FavouriteState, error) {
+	ids := append(append([]int{}, add...), remove...)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM memory_verses WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	known := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, ErrInternalServer
+		}
+		known[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if !known[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: unknown verse ids %v", ErrNotFound, missing)
+	}
+
+	if err := lockFavourites(ctx, tx, userID); err != nil {
+		return nil, ErrInternalServer
+	}
+	if err := checkFavouriteLimit(ctx, tx, userID, add, remove, limit); err != nil {
+		return nil, err
+	}
+
+	// The unique index on (user_id, verse_id) makes a repeat add a no-op
+	insert := `
+		INSERT INTO favourite_verses (user_id, verse_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, verse_id) DO NOTHING
+	`
+	for _, id := range add {
+		if _, err := tx.ExecContext(ctx, insert, userID, id); err != nil {
+			return nil, ErrInternalServer
+		}
+	}
+
+	if len(remove) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM favourite_verses WHERE user_id = $1 AND verse_id = ANY($2)
+		`, userID, remove)
+		if err != nil {
+			return nil, ErrInternalServer
+		}
+	}
+
+	// Counted inside the transaction so they include this batch
+	counts, err := favouriteCounts(ctx, tx, ids)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	states := make([]FavouriteState, 0, len(ids))
+	for _, id := range add {
+		states = append(states, FavouriteState{VerseID: id, IsFavourite: true, FavouriteCount: counts[id]})
+	}
+	for _, id := range remove {
+		states = append(states, FavouriteState{VerseID: id, IsFavourite: false, FavouriteCount: counts[id]})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].VerseID < states[j].VerseID })
+
+	return states, nil
+}
+
+// favouriteCounts returns how many users have favourited each verse.
+func favouriteCounts(ctx context.Context, tx *sql.Tx, verseIDs []int) (map[int]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT verse_id, COUNT(*) FROM favourite_verses
+		WHERE verse_id = ANY($1)
+		GROUP BY verse_id
+	`, verseIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int, len(verseIDs))
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+// GetUserFavouriteVerses lists up to limit of the user's favourites after
+// the cursor (from the start when nil), in the given sort order or newest
+// first when sort is empty or unknown.
+func (r *repository) GetUserFavouriteVerses(ctx context.Context, userID int, sort string, after *pagination.Cursor, limit int) ([]FavouriteVerse, error) {
+	orderBy, ok := favouriteSortOrders[sort]
+	if !ok {
+		sort = SortPosition
+		orderBy = favouriteSortOrders[sort]
+	}
+
+	from := "FROM favourite_verses fv JOIN memory_verses mv ON mv.id = fv.verse_id"
+	where := "WHERE fv.user_id = $1"
+	args := []interface{}{userID}
+	if after != nil {
+		where += " AND " + favouriteKeysets[sort]
+		args = append(args, after.LastCreatedAt, after.LastID)
+		if sort == SortReference || sort == SortPosition {
+			from += `
+				CROSS JOIN (
+					SELECT m.reference, f.position FROM favourite_verses f
+					JOIN memory_verses m ON m.id = f.verse_id
+					WHERE f.id = $3 AND f.user_id = $1
+				) cur`
+		}
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT fv.id, fv.user_id, fv.verse_id, fv.created_at,
+		       mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		%s
+		%s
+		ORDER BY %s
+		LIMIT $%d`, from, where, orderBy, len(args))
+	rows, err := r.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var favourites []FavouriteVerse
+	for rows.Next() {
+		var fav FavouriteVerse
+		err := rows.Scan(
+			&fav.ID, &fav.UserID, &fav.VerseID, &fav.CreatedAt,
+			&fav.Verse.ID, &fav.Verse.Reference, &fav.Verse.Verse,
+			&fav.Verse.Translation, &fav.Verse.CreatedAt,
+		)
+		if err != nil {
+			return nil, ErrInternalServer
+		}
+		favourites = append(favourites, fav)
+	}
+
+	return favourites, rows.Err()
+}
+
+func (r *repository) IsVerseFavourited(ctx context.Context, userID, verseID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM favourite_verses WHERE user_id = $1 AND verse_id = $2
+		)
+	`
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, userID, verseID).Scan(&exists)
+	if err != nil {
+		return false, ErrInternalServer
+	}
+	return exists, err
+}
+
+// CreateCollection returns ErrAlreadyExists if the user already has a
+// collection with this name, ignoring case.
+func (r *repository) CreateCollection(ctx context.Context, userID int, name string) (*Collection, error) {
+	query := `
+		INSERT INTO collections (user_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, LOWER(name)) DO NOTHING
+		RETURNING id, user_id, name, created_at
+	`
+
+	var c Collection
+	err := r.db.QueryRowContext(ctx, query, userID, name).Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, ErrInternalServer
+	}
+	return &c, nil
+}
+
+func (r *repository) GetUserCollections(ctx context.Context, userID int) ([]Collection, error) {
+	query := `
+		SELECT id, user_id, name, created_at
+		FROM collections
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt); err != nil {
+			return nil, ErrInternalServer
+		}
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return collections, nil
+}
+
+// ownsCollection reports whether collectionID belongs to userID. Collections
+// of other users are treated as missing so their ids aren't leaked.
+func (r *repository) ownsCollection(ctx context.Context, userID, collectionID int) error {
+	var owned bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM collections WHERE id = $1 AND user_id = $2)
+	`, collectionID, userID).Scan(&owned)
+	if err != nil {
+		return ErrInternalServer
+	}
+	if !owned {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddVerseToCollection is a no-op if the verse is already in the collection.
+// It returns ErrNotFound for an unknown verse or a collection the user doesn't own.
+func (r *repository) AddVerseToCollection(ctx context.Context, userID, collectionID, verseID int) error {
+	if err := r.ownsCollection(ctx, userID, collectionID); err != nil {
+		return err
+	}
+
+	var verseExists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM memory_verses WHERE id = $1)`, verseID).Scan(&verseExists)
+	if err != nil {
+		return ErrInternalServer
+	}
+	if !verseExists {
+		return ErrNotFound
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO collection_verses (collection_id, verse_id)
+		VALUES ($1, $2)
+		ON CONFLICT (collection_id, verse_id) DO NOTHING
+	`, collectionID, verseID)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+func (r *repository) RemoveVerseFromCollection(ctx context.Context, userID, collectionID, verseID int) error {
+	if err := r.ownsCollection(ctx, userID, collectionID); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM collection_verses WHERE collection_id = $1 AND verse_id = $2
+	`, collectionID, verseID)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// GetCollectionVerses lists a collection's verses in the order they were added.
+func (r *repository) GetCollectionVerses(ctx context.Context, userID, collectionID int) ([]Verse, error) {
+	if err := r.ownsCollection(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+			EXISTS (
+				SELECT 1 FROM favourite_verses fv
+				WHERE fv.user_id = $2 AND fv.verse_id = mv.id
+			) AS is_favourite
+		FROM collection_verses cv
+		JOIN memory_verses mv ON mv.id = cv.verse_id
+		WHERE cv.collection_id = $1
+		ORDER BY cv.added_at, mv.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, collectionID, userID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.IsFavourite); err != nil {
+			return nil, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return verses, nil
+}
+
+// GetPopularVerses returns the most favourited verses across all users.
+func (r *repository) GetPopularVerses(ctx context.Context, limit int) ([]Verse, error) {
+	query := `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+		       COUNT(*) AS favourite_count
+		FROM favourite_verses fv
+		JOIN memory_verses mv ON mv.id = fv.verse_id
+		GROUP BY mv.id
+		ORDER BY favourite_count DESC, mv.id
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.FavouriteCount); err != nil {
+			return nil, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+
+	return verses, rows.Err()
+}
+
+// GetRecentVerses pages through the catalogue newest first and returns the
+// total number of verses.
+func (r *repository) GetRecentVerses(ctx context.Context, limit, offset int) ([]Verse, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM memory_verses`).Scan(&total); err != nil {
+		return nil, 0, ErrInternalServer
+	}
+
+	query := `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM memory_verses mv
+		ORDER BY mv.created_at DESC, mv.id DESC
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var verses []Verse
+	for rows.Next() {
+		var v Verse
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt); err != nil {
+			return nil, 0, ErrInternalServer
+		}
+		verses = append(verses, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ErrInternalServer
+	}
+
+	return verses, total, nil
+}
+
+// GetFavouritedVerseIDs reports which of verseIDs the user has favourited.
+func (r *repository) GetFavouritedVerseIDs(ctx context.Context, userID int, verseIDs []int) (map[int]bool, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT verse_id FROM favourite_verses
+		WHERE user_id = $1 AND verse_id = ANY($2::int[])
+	`, userID, verseIDs)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	favourited := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, ErrInternalServer
+		}
+		favourited[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return favourited, nil
+}
+
+// GetFavouriteSummary counts the user's favourites per translation, largest
+// first.
+func (r *repository) GetFavouriteSummary(ctx context.Context, userID int) ([]TranslationFavourites, error) {
+	rows, err := r.reader().QueryContext(ctx, `
+		SELECT mv.translation, COUNT(*)
+		FROM favourite_verses fv
+		JOIN memory_verses mv ON mv.id = fv.verse_id
+		WHERE fv.user_id = $1
+		GROUP BY mv.translation
+		ORDER BY 2 DESC, 1
+	`, userID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var groups []TranslationFavourites
+	for rows.Next() {
+		var g TranslationFavourites
+		if err := rows.Scan(&g.Translation, &g.Count); err != nil {
+			return nil, ErrInternalServer
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return groups, nil
+}
+
+// GetTranslations lists the distinct translations in the verse catalogue.
+func (r *repository) GetTranslations(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT translation FROM memory_verses ORDER BY translation`)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var translations []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, ErrInternalServer
+		}
+		translations = append(translations, t)
+	}
+
+	return translations, rows.Err()
+}
+
+// CreateVerseReport files a report against a verse. It returns ErrNotFound for
+// an unknown verse and ErrAlreadyExists if the user already has an open report for it.
+func (r *repository) CreateVerseReport(ctx context.Context, userID, verseID int, reason string) (*VerseReport, error) {
+	var verseExists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM memory_verses WHERE id = $1)`, verseID).Scan(&verseExists)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	if !verseExists {
+		return nil, ErrNotFound
+	}
+
+	query := `
+		INSERT INTO verse_reports (verse_id, user_id, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, verse_id) WHERE status = 'open' DO NOTHING
+		RETURNING id, verse_id, user_id, reason, status, created_at, resolved_at
+	`
+
+	var rep VerseReport
+	err = r.db.QueryRowContext(ctx, query, verseID, userID, reason).Scan(
+		&rep.ID, &rep.VerseID, &rep.UserID, &rep.Reason, &rep.Status, &rep.CreatedAt, &rep.ResolvedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, ErrInternalServer
+	}
+	return &rep, nil
+}
+
+// GetVerseReports lists reports newest first, optionally filtered by status.
+func (r *repository) GetVerseReports(ctx context.Context, status string, limit, offset int) ([]VerseReport, int, error) {
+	query := `
+		SELECT id, verse_id, user_id, reason, status, created_at, resolved_at,
+		       COUNT(*) OVER() AS total
+		FROM verse_reports
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var (
+		reports []VerseReport
+		total   int
+	)
+	for rows.Next() {
+		var rep VerseReport
+		if err := rows.Scan(&rep.ID, &rep.VerseID, &rep.UserID, &rep.Reason, &rep.Status, &rep.CreatedAt, &rep.ResolvedAt, &total); err != nil {
+			return nil, 0, ErrInternalServer
+		}
+		reports = append(reports, rep)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, ErrInternalServer
+	}
+
+	return reports, total, nil
+}
+
+func (r *repository) ResolveVerseReport(ctx context.Context, reportID int) (*VerseReport, error) {
+	query := `
+		UPDATE verse_reports
+		SET status = 'resolved', resolved_at = COALESCE(resolved_at, NOW())
+		WHERE id = $1
+		RETURNING id, verse_id, user_id, reason, status, created_at, resolved_at
+	`
+
+	var rep VerseReport
+	err := r.db.QueryRowContext(ctx, query, reportID).Scan(
+		&rep.ID, &rep.VerseID, &rep.UserID, &rep.Reason, &rep.Status, &rep.CreatedAt, &rep.ResolvedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrInternalServer
+	}
+	return &rep, nil
+}
+
+// GetVersePrompts returns a verse's reflection prompts in the order they were set.
+func (r *repository) GetVersePrompts(ctx context.Context, verseID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT prompt FROM verse_prompts WHERE verse_id = $1 ORDER BY id`, verseID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var prompts []string
+	for rows.Next() {
+		var prompt string
+		if err := rows.Scan(&prompt); err != nil {
+			return nil, ErrInternalServer
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return prompts, nil
+}
+
+// SetVersePrompts replaces all prompts for a verse in one transaction.
+func (r *repository) SetVersePrompts(ctx context.Context, verseID int, prompts []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	var verseExists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM memory_verses WHERE id = $1)`, verseID).Scan(&verseExists); err != nil {
+		return ErrInternalServer
+	}
+	if !verseExists {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM verse_prompts WHERE verse_id = $1`, verseID); err != nil {
+		return ErrInternalServer
+	}
+	for _, prompt := range prompts {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO verse_prompts (verse_id, prompt) VALUES ($1, $2)`, verseID, prompt); err != nil {
+			return ErrInternalServer
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// GetVerseCommentary returns a verse's commentary, or "" when it has none.
+func (r *repository) GetVerseCommentary(ctx context.Context, verseID int) (string, error) {
+	var commentary string
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(commentary, '') FROM memory_verses WHERE id = $1`, verseID).Scan(&commentary)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", ErrInternalServer
+	}
+	return commentary, nil
+}
+
+// SetVerseCommentary replaces a verse's commentary; "" clears it.
+func (r *repository) SetVerseCommentary(ctx context.Context, verseID int, commentary string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE memory_verses SET commentary = NULLIF($2, '') WHERE id = $1`, verseID, commentary)
+	if err != nil {
+		return ErrInternalServer
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return ErrInternalServer
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// insertVerseQuery stores a cleaned verse; raw_verse is only kept when
+// cleaning changed the text.
+const insertVerseQuery = `
+	INSERT INTO memory_verses (reference, verse, translation, raw_verse)
+	VALUES ($1, $2, $3, NULLIF($4, $2))
+	RETURNING id, created_at`
+
+// CreateVerse adds one verse to the catalogue.
+func (r *repository) CreateVerse(ctx context.Context, verse NewVerse) (*Verse, error) {
+	v := Verse{Reference: verse.Reference, Verse: verse.Verse, Translation: verse.Translation}
+	err := r.db.QueryRowContext(ctx, insertVerseQuery, verse.Reference, verse.Verse, verse.Translation, verse.RawVerse).Scan(&v.ID, &v.CreatedAt)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	return &v, nil
+}
+
+// BulkInsertVerses adds verses in one transaction, so a failed import adds
+// none of them.
+func (r *repository) BulkInsertVerses(ctx context.Context, verses []NewVerse) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertVerseQuery)
+	if err != nil {
+		return 0, ErrInternalServer
+	}
+	defer stmt.Close()
+
+	for _, v := range verses {
+		var id int
+		var createdAt time.Time
+		if err := stmt.QueryRowContext(ctx, v.Reference, v.Verse, v.Translation, v.RawVerse).Scan(&id, &createdAt); err != nil {
+			return 0, ErrInternalServer
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, ErrInternalServer
+	}
+	return len(verses), nil
+}
+
+// CreateFavouriteShare stores a share and copies the user's favourites into
+// it, newest first, in one transaction.
+func (r *repository) CreateFavouriteShare(ctx context.Context, userID int, tokenHash, displayName string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ErrInternalServer
+	}
+	defer tx.Rollback()
+
+	var shareID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO favourite_shares (token_hash, user_id, display_name, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, tokenHash, userID, displayName, expiresAt).Scan(&shareID)
+	if err != nil {
+		return ErrInternalServer
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO favourite_share_verses (share_id, verse_id, position)
+		SELECT $1, verse_id, ROW_NUMBER() OVER (ORDER BY position, created_at DESC, id DESC)
+		FROM favourite_verses
+		WHERE user_id = $2
+	`, shareID, userID)
+	if err != nil {
+		return ErrInternalServer
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// GetFavouriteShare loads a share and its verses by token hash, expired or
+// not; the caller decides what expiry means.
+func (r *repository) GetFavouriteShare(ctx context.Context, tokenHash string) (*SharedFavourites, error) {
+	var (
+		shareID int
+		shared  SharedFavourites
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, display_name, created_at, expires_at FROM favourite_shares WHERE token_hash = $1
+	`, tokenHash).Scan(&shareID, &shared.DisplayName, &shared.SharedAt, &shared.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrInternalServer
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM favourite_share_verses sv
+		JOIN memory_verses mv ON mv.id = sv.verse_id
+		WHERE sv.share_id = $1
+		ORDER BY sv.position
+	`, shareID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := Verse{IsFavourite: true}
+		if err := rows.Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt); err != nil {
+			return nil, ErrInternalServer
+		}
+		shared.Verses = append(shared.Verses, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+
+	return &shared, nil
+}
+
+// SaveDailyVerse records verseID as the verse for day (YYYY-MM-DD) unless
+// one is already set, and returns whichever verse the day ends up with.
+func (r *repository) SaveDailyVerse(ctx context.Context, day string, verseID int) (*Verse, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO daily_verses (day, verse_id) VALUES ($1, $2)
+		ON CONFLICT (day) DO NOTHING
+	`, day, verseID)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+
+	// A separate statement, so it sees a row a concurrent request committed
+	// while the insert waited; one snapshot for both would miss it
+	var v Verse
+	err = r.db.QueryRowContext(ctx, `
+		SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at
+		FROM daily_verses dv
+		JOIN memory_verses mv ON mv.id = dv.verse_id
+		WHERE dv.day = $1
+	`, day).Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrInternalServer
+	}
+	return &v, nil
+}
+
+// GetDailyVerseArchive lists past verses of the day within rng, newest
+// first, with the total in range.
+func (r *repository) GetDailyVerseArchive(ctx context.Context, rng HistoryRange, limit, offset int) ([]PublicDailyVerse, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT TO_CHAR(dv.day, 'YYYY-MM-DD'), mv.reference, mv.verse, mv.translation, COUNT(*) OVER ()
+		FROM daily_verses dv
+		JOIN memory_verses mv ON mv.id = dv.verse_id
+		WHERE dv.day >= $1 AND dv.day < $2
+		ORDER BY dv.day DESC
+		LIMIT $3 OFFSET $4
+	`, rng.From, rng.To, limit, offset)
+	if err != nil {
+		return nil, 0, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var (
+		days  []PublicDailyVerse
+		total int
+	)
+	for rows.Next() {
+		var d PublicDailyVerse
+		if err := rows.Scan(&d.Date, &d.Reference, &d.Verse, &d.Translation, &total); err != nil {
+			return nil, 0, ErrInternalServer
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ErrInternalServer
+	}
+
+	return days, total, nil
+}
+
+// CountDailyVerseStreak counts the consecutive days, ending with day, that
+// have a verse of the day. Within an unbroken run each day plus its rank
+// (newest first) is the day after the run's end, so rows still on that
+// anchor make up the streak.
+func (r *repository) CountDailyVerseStreak(ctx context.Context, day string) (int, error) {
+	var streak int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM (
+			SELECT dv.day + (ROW_NUMBER() OVER (ORDER BY dv.day DESC))::int AS anchor
+			FROM daily_verses dv
+			WHERE dv.day <= $1::date
+		) runs
+		WHERE runs.anchor = $1::date + 1
+	`, day).Scan(&streak)
+	if err != nil {
+		return 0, ErrInternalServer
+	}
+	return streak, nil
+}
+
+// CreateEmailSend registers a tracking token for an email sent to the user.
+func (r *repository) CreateEmailSend(ctx context.Context, token string, userID, verseID int, subjectVariant string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_sends (token, user_id, verse_id, subject_variant) VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''))
+	`, token, userID, verseID, subjectVariant)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// RecordEmailOpen stores an open for a known token and reports whether the
+// token was known; unknown tokens record nothing.
+func (r *repository) RecordEmailOpen(ctx context.Context, token string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_opens (token)
+		SELECT token FROM email_sends WHERE token = $1
+	`, token)
+	if err != nil {
+		return false, ErrInternalServer
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, ErrInternalServer
+	}
+	return n > 0, nil
+}
+
+// RecordEmailClick stores a tracked link click for a known token and reports
+// whether the token was known.
+func (r *repository) RecordEmailClick(ctx context.Context, token, destination string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_clicks (token, destination)
+		SELECT token, $2 FROM email_sends WHERE token = $1
+	`, token, destination)
+	if err != nil {
+		return false, ErrInternalServer
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, ErrInternalServer
+	}
+	return n > 0, nil
+}
+
+func (r *repository) GetEmailOpenStats(ctx context.Context) (*EmailOpenStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM email_sends),
+			(SELECT COUNT(DISTINCT token) FROM email_opens),
+			(SELECT COUNT(*) FROM email_opens)
+	`
+
+	var stats EmailOpenStats
+	if err := r.reader().QueryRowContext(ctx, query).Scan(&stats.Sent, &stats.Opened, &stats.TotalOpens); err != nil {
+		return nil, ErrInternalServer
+	}
+	return &stats, nil
+}
+
+// GetSubjectVariantStats counts sends and opened sends per subject variant.
+// Emails sent without a variant, like weekly digests, are left out.
+func (r *repository) GetSubjectVariantStats(ctx context.Context) ([]SubjectVariantStats, error) {
+	rows, err := r.reader().QueryContext(ctx, `
+		SELECT s.subject_variant, COUNT(*), COUNT(o.token)
+		FROM email_sends s
+		LEFT JOIN (SELECT DISTINCT token FROM email_opens) o ON o.token = s.token
+		WHERE s.subject_variant IS NOT NULL
+		GROUP BY s.subject_variant
+		ORDER BY s.subject_variant
+	`)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var stats []SubjectVariantStats
+	for rows.Next() {
+		var v SubjectVariantStats
+		if err := rows.Scan(&v.Variant, &v.Sent, &v.Opened); err != nil {
+			return nil, ErrInternalServer
+		}
+		stats = append(stats, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return stats, nil
+}
+
+func (r *repository) RecordVerseImpression(ctx context.Context, userID, verseID int) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO verse_impressions (user_id, verse_id) VALUES ($1, $2)`, userID, verseID)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
+}
+
+// GetImpressionStats counts dashboard views since the given time and lists
+// the top most viewed verses.
+func (r *repository) GetImpressionStats(ctx context.Context, since time.Time, top int) (*ImpressionStats, error) {
+	stats := ImpressionStats{Since: since}
+	err := r.reader().QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT user_id) FROM verse_impressions WHERE viewed_at >= $1
+	`, since).Scan(&stats.Total, &stats.UniqueUsers)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+
+	rows, err := r.reader().QueryContext(ctx, `
+		SELECT vi.verse_id, mv.reference, COUNT(*)
+		FROM verse_impressions vi
+		JOIN memory_verses mv ON mv.id = vi.verse_id
+		WHERE vi.viewed_at >= $1
+		GROUP BY vi.verse_id, mv.reference
+		ORDER BY 3 DESC, 1
+		LIMIT $2
+	`, since, top)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v VerseImpressions
+		if err := rows.Scan(&v.VerseID, &v.Reference, &v.Views); err != nil {
+			return nil, ErrInternalServer
+		}
+		stats.TopVerses = append(stats.TopVerses, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return &stats, nil
+}
+
+// GetTranslationCoverage counts verses and users per translation, including
+// translations that only appear on one side.
+func (r *repository) GetTranslationCoverage(ctx context.Context) ([]TranslationCoverage, error) {
+	query := `
+		WITH verses AS (
+			SELECT translation, COUNT(*) AS n FROM memory_verses GROUP BY translation
+		), users AS (
+			SELECT bible_translation AS translation, COUNT(*) AS n
+			FROM user_profiles
+			WHERE bible_translation <> ''
+			GROUP BY bible_translation
+		)
+		SELECT COALESCE(v.translation, u.translation), COALESCE(v.n, 0), COALESCE(u.n, 0)
+		FROM verses v
+		FULL OUTER JOIN users u ON u.translation = v.translation
+		ORDER BY 1
+	`
+
+	rows, err := r.reader().QueryContext(ctx, query)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var coverage []TranslationCoverage
+	for rows.Next() {
+		var c TranslationCoverage
+		if err := rows.Scan(&c.Translation, &c.VerseCount, &c.UserCount); err != nil {
+			return nil, ErrInternalServer
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage, rows.Err()
+}
+
+// GetTopicCoverage counts users per selected inspiration, most popular first.
+func (r *repository) GetTopicCoverage(ctx context.Context) ([]TopicCoverage, error) {
+	rows, err := r.reader().QueryContext(ctx, `
+		SELECT inspiration, COUNT(DISTINCT user_id)
+		FROM user_inspirations
+		GROUP BY inspiration
+		ORDER BY 2 DESC, 1
+	`)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var coverage []TopicCoverage
+	for rows.Next() {
+		var c TopicCoverage
+		if err := rows.Scan(&c.Topic, &c.UserCount); err != nil {
+			return nil, ErrInternalServer
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage, rows.Err()
+}
+
+// GetInactiveUsers lists subscribed users whose latest activity is before
+// since. Activity is a dashboard impression or opening or clicking through a
+// verse email, so people who only read by email aren't counted as gone;
+// sign-up stands in when there is none.
+func (r *repository) GetInactiveUsers(ctx context.Context, since time.Time) ([]InactiveUser, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(p.username, ''), a.last_active_at,
+		       u.last_reminder_at, u.snoozed_until
+		FROM users u
+		LEFT JOIN user_profiles p ON p.user_id = u.id
+		CROSS JOIN LATERAL (
+			SELECT GREATEST(
+				u.created_at,
+				(SELECT MAX(vi.viewed_at) FROM verse_impressions vi WHERE vi.user_id = u.id),
+				(SELECT MAX(eo.opened_at)
+				 FROM email_sends es JOIN email_opens eo ON eo.token = es.token
+				 WHERE es.user_id = u.id),
+				(SELECT MAX(ec.clicked_at)
+				 FROM email_sends es JOIN email_clicks ec ON ec.token = es.token
+				 WHERE es.user_id = u.id)
+			) AS last_active_at
+		) a
+		WHERE u.is_subscribed = TRUE AND a.last_active_at < $1
+		ORDER BY u.id
+	`, since)
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	defer rows.Close()
+
+	var users []InactiveUser
+	for rows.Next() {
+		var u InactiveUser
+		if err := rows.Scan(&u.ID, &u.Email, &u.UserName, &u.LastActiveAt, &u.LastReminderAt, &u.SnoozedUntil); err != nil {
+			return nil, ErrInternalServer
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrInternalServer
+	}
+	return users, nil
+}
+
+func (r *repository) MarkReminderSent(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET last_reminder_at = $2 WHERE id = $1`, userID, at)
+	if err != nil {
+		return ErrInternalServer
+	}
+	return nil
 }
@@ -0,0 +1,848 @@
+package memoryverse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
+)
+
+// versePoolDriver answers random-verse queries from an in-memory pool the way
+// Postgres would, skipping FLOOR(frac * count) rows in id order. The DSN names
+// the pool so each test gets its own.
+type versePoolDriver struct{}
+
+var (
+	versePoolsMu sync.Mutex
+	versePools   = map[string]*versePool{}
+)
+
+type versePool struct {
+	verses  []Verse // in id order
+	queries []string
+}
+
+func (versePoolDriver) Open(name string) (driver.Conn, error) {
+	versePoolsMu.Lock()
+	defer versePoolsMu.Unlock()
+	return versePoolConn{pool: versePools[name]}, nil
+}
+
+type versePoolConn struct{ pool *versePool }
+
+func (c versePoolConn) Prepare(query string) (driver.Stmt, error) {
+	return versePoolStmt{pool: c.pool, query: query}, nil
+}
+func (versePoolConn) Close() error              { return nil }
+func (versePoolConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type versePoolStmt struct {
+	pool  *versePool
+	query string
+}
+
+func (versePoolStmt) Close() error  { return nil }
+func (versePoolStmt) NumInput() int { return -1 }
+func (versePoolStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+// Query expects GetRandomVerse's arguments: user ID, translation, fraction.
+func (s versePoolStmt) Query(args []driver.Value) (driver.Rows, error) {
+	versePoolsMu.Lock()
+	s.pool.queries = append(s.pool.queries, s.query)
+	versePoolsMu.Unlock()
+
+	translation, frac := args[1].(string), args[2].(float64)
+	var candidates []Verse
+	for _, v := range s.pool.verses {
+		if v.Translation == translation {
+			candidates = append(candidates, v)
+		}
+	}
+
+	rows := &versePoolRows{}
+	if offset := int(math.Floor(frac * float64(len(candidates)))); offset < len(candidates) {
+		rows.verse = &candidates[offset]
+	}
+	return rows, nil
+}
+
+type versePoolRows struct {
+	verse *Verse
+	done  bool
+}
+
+func (*versePoolRows) Columns() []string {
+	return []string{"id", "reference", "verse", "translation", "created_at", "is_favourite"}
+}
+func (*versePoolRows) Close() error { return nil }
+func (r *versePoolRows) Next(dest []driver.Value) error {
+	if r.verse == nil || r.done {
+		return io.EOF
+	}
+	r.done = true
+	v := r.verse
+	dest[0], dest[1], dest[2], dest[3], dest[4], dest[5] = int64(v.ID), v.Reference, v.Verse, v.Translation, v.CreatedAt, false
+	return nil
+}
+
+func init() {
+	sql.Register("versepool", versePoolDriver{})
+}
+
+func newVersePoolRepo(t *testing.T, verses []Verse) (*repository, *versePool) {
+	t.Helper()
+	pool := &versePool{verses: verses}
+	versePoolsMu.Lock()
+	versePools[t.Name()] = pool
+	versePoolsMu.Unlock()
+
+	db, err := sql.Open("versepool", t.Name())
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &repository{db: db}, pool
+}
+
+func TestGetRandomVerse(t *testing.T) {
+	var verses []Verse
+	for i := 1; i <= 10; i++ {
+		verses = append(verses, Verse{ID: i, Reference: fmt.Sprintf("Ref %d", i), Translation: "KJV", CreatedAt: time.Now()})
+	}
+	verses = append(verses, Verse{ID: 11, Translation: "NIV"})
+	repo, pool := newVersePoolRepo(t, verses)
+
+	t.Run("stays within the translation at the edges", func(t *testing.T) {
+		defer func(orig func() float64) { randFloat = orig }(randFloat)
+		for frac, wantID := range map[float64]int{0: 1, 0.5: 6, 0.9999: 10} {
+			randFloat = func() float64 { return frac }
+			v, err := repo.GetRandomVerse(context.Background(), 1, "KJV")
+			if err != nil {
+				t.Fatalf("frac %v: unexpected error: %v", frac, err)
+			}
+			if v.ID != wantID {
+				t.Errorf("frac %v: expected verse %d, got %d", frac, wantID, v.ID)
+			}
+		}
+	})
+
+	t.Run("selection is roughly uniform", func(t *testing.T) {
+		const draws = 10000
+		counts := map[int]int{}
+		for i := 0; i < draws; i++ {
+			v, err := repo.GetRandomVerse(context.Background(), 1, "KJV")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			counts[v.ID]++
+		}
+		for id := 1; id <= 10; id++ {
+			// Expected 1000 each; 800 is more than six standard deviations out
+			if n := counts[id]; n < 800 || n > 1200 {
+				t.Errorf("verse %d picked %d times out of %d", id, n, draws)
+			}
+		}
+	})
+
+	t.Run("empty translation is not found", func(t *testing.T) {
+		if _, err := repo.GetRandomVerse(context.Background(), 1, "ESV"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	if strings.Contains(strings.ToUpper(pool.queries[0]), "RANDOM()") {
+		t.Error("GetRandomVerse should not sort the candidates with ORDER BY RANDOM()")
+	}
+}
+
+// BenchmarkGetRandomVerse compares the offset pick with the ORDER BY RANDOM()
+// query it replaced on a seeded table. It needs a Postgres DSN in
+// MEMORY_VERSE_BENCH_DSN; the data lives in temporary tables that shadow the
+// real ones for the benchmark's session only.
+func BenchmarkGetRandomVerse(b *testing.B) {
+	dsn := os.Getenv("MEMORY_VERSE_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("MEMORY_VERSE_BENCH_DSN not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		b.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	// Temporary tables are per session, so keep to one connection
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TEMP TABLE memory_verses (
+			id SERIAL PRIMARY KEY, reference TEXT, verse TEXT, translation TEXT,
+			created_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE TEMP TABLE favourite_verses (user_id INT, verse_id INT)`,
+		`INSERT INTO memory_verses (reference, verse, translation)
+		 SELECT 'Ref ' || g, repeat('word ', 30), (ARRAY['KJV', 'NIV', 'ESV', 'NKJV'])[1 + g % 4]
+		 FROM generate_series(1, 200000) g`,
+		`CREATE INDEX ON memory_verses (translation, id)`,
+		`ANALYZE memory_verses`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			b.Fatalf("failed to seed: %v", err)
+		}
+	}
+
+	repo := &repository{db: db}
+
+	b.Run("offset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetRandomVerse(ctx, 1, "KJV"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("order_by_random", func(b *testing.B) {
+		query := `
+			SELECT mv.id, mv.reference, mv.verse, mv.translation, mv.created_at,
+				EXISTS (SELECT 1 FROM favourite_verses fv WHERE fv.user_id = $1 AND fv.verse_id = mv.id)
+			FROM memory_verses mv
+			WHERE mv.translation = $2
+			ORDER BY RANDOM()
+			LIMIT 1
+		`
+		for i := 0; i < b.N; i++ {
+			var v Verse
+			err := db.QueryRowContext(ctx, query, 1, "KJV").Scan(&v.ID, &v.Reference, &v.Verse, &v.Translation, &v.CreatedAt, &v.IsFavourite)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// handleDriver answers every query with no rows, recording which handle (the
+// DSN) it arrived on.
+type handleDriver struct{}
+
+var (
+	handleQueriesMu sync.Mutex
+	handleQueries   = map[string]int{}
+)
+
+func (handleDriver) Open(name string) (driver.Conn, error) { return handleConn{name: name}, nil }
+
+type handleConn struct{ name string }
+
+func (c handleConn) Prepare(query string) (driver.Stmt, error) { return handleStmt(c), nil }
+func (handleConn) Close() error                                { return nil }
+func (handleConn) Begin() (driver.Tx, error)                   { return nil, errors.New("not supported") }
+
+type handleStmt struct{ name string }
+
+func (handleStmt) Close() error  { return nil }
+func (handleStmt) NumInput() int { return -1 }
+func (s handleStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.record()
+	return driver.RowsAffected(1), nil
+}
+func (s handleStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.record()
+	return emptyRows{}, nil
+}
+func (s handleStmt) record() {
+	handleQueriesMu.Lock()
+	defer handleQueriesMu.Unlock()
+	handleQueries[s.name]++
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("handles", handleDriver{})
+}
+
+func TestReadHeavyQueriesUseReadReplica(t *testing.T) {
+	open := func(name string) *sql.DB {
+		db, err := sql.Open("handles", name)
+		if err != nil {
+			t.Fatalf("failed to open test db: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	primary, replica := t.Name()+"/primary", t.Name()+"/replica"
+	repo := &repository{db: open(primary), readDB: open(replica)}
+	ctx := context.Background()
+
+	// Results are irrelevant; only where the queries went matters
+	repo.GetUserVerseHistoryPage(ctx, 1, nil, 10)
+	repo.StreamUserVerseHistory(ctx, 1, HistoryRange{}, func(VerseHistory) error { return nil })
+	repo.GetUserFavouriteVerses(ctx, 1, SortPosition, nil, 10)
+	repo.GetFavouriteSummary(ctx, 1)
+	repo.SearchUserNotes(ctx, 1, "love", 10, 0)
+	repo.GetEmailOpenStats(ctx)
+	repo.GetSubjectVariantStats(ctx)
+	repo.GetImpressionStats(ctx, time.Now().AddDate(0, 0, -7), 5)
+	repo.GetTranslationCoverage(ctx)
+	repo.GetTopicCoverage(ctx)
+
+	handleQueriesMu.Lock()
+	reads, writes := handleQueries[replica], handleQueries[primary]
+	handleQueriesMu.Unlock()
+	if reads == 0 || writes != 0 {
+		t.Fatalf("expected reads on the replica only, got %d replica and %d primary queries", reads, writes)
+	}
+
+	if err := repo.SaveDeliveredVerse(ctx, 1, 1); err != nil {
+		t.Fatalf("SaveDeliveredVerse returned error: %v", err)
+	}
+	handleQueriesMu.Lock()
+	defer handleQueriesMu.Unlock()
+	if handleQueries[primary] == 0 || handleQueries[replica] != reads {
+		t.Error("expected writes to go to the primary")
+	}
+
+	// Without a replica, reads fall back to the primary
+	if (&repository{db: repo.db}).reader() != repo.db {
+		t.Error("expected reader to fall back to the primary")
+	}
+}
+
+// TestDedupeFavouritesMigration runs the favourite de-duplication migration
+// against seeded duplicates. It needs a Postgres DSN in MEMORY_VERSE_TEST_DSN;
+// like the benchmark, it works on a temporary table that shadows the real one.
+func TestDedupeFavouritesMigration(t *testing.T) {
+	dsn := os.Getenv("MEMORY_VERSE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MEMORY_VERSE_TEST_DSN not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	seed := []string{
+		`CREATE TEMP TABLE favourite_verses (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_id INT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(), position INT NOT NULL DEFAULT 0
+		)`,
+		// User 1 favourited verse 10 twice; the second row is the earlier one
+		`INSERT INTO favourite_verses (user_id, verse_id, created_at) VALUES
+			(1, 10, '2024-01-02'), (1, 10, '2024-01-01'), (1, 11, '2024-01-01'),
+			(2, 10, '2024-01-03'), (2, 10, '2024-01-03'), (2, 10, '2024-01-03')`,
+	}
+	statements := append(seed, migrationStatements(t, "000033_dedupe_favourite_verses.up.sql")...)
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("failed to run %q: %v", stmt, err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM favourite_verses ORDER BY id`)
+	if err != nil {
+		t.Fatalf("failed to list favourites: %v", err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("failed to scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if fmt.Sprint(ids) != "[2 3 4]" {
+		t.Errorf("expected the earliest row per user and verse to survive, got %v", ids)
+	}
+
+	if _, err := db.ExecContext(ctx, `INSERT INTO favourite_verses (user_id, verse_id) VALUES (1, 10)`); err == nil {
+		t.Error("expected the unique index to reject a duplicate favourite")
+	}
+}
+
+// migrationStatements splits a migration into statements, as the extended
+// protocol won't run several at once.
+func migrationStatements(t *testing.T, name string) []string {
+	t.Helper()
+	migration, err := os.ReadFile("../../migrations/" + name)
+	if err != nil {
+		t.Fatalf("failed to read migration: %v", err)
+	}
+	var statements []string
+	for _, stmt := range strings.Split(string(migration), ";\n") {
+		if strings.TrimSpace(stmt) != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
+
+// testSchemaDB opens MEMORY_VERSE_TEST_DSN on a throwaway schema, runs ddl in
+// it and drops it when the test ends. Unlike temporary tables the schema is
+// visible to every connection, so concurrent statements really race.
+func testSchemaDB(t *testing.T, ddl ...string) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("MEMORY_VERSE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MEMORY_VERSE_TEST_DSN not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	schema := fmt.Sprintf("mv_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	switch {
+	case !strings.Contains(dsn, "://"):
+		dsn += " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	default:
+		dsn += "?search_path=" + schema
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, stmt := range ddl {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to run %q: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+// TestToggleFavouriteVerseConcurrent races toggles of one favourite against a
+// real Postgres. The unique index must absorb colliding inserts without
+// errors or duplicate rows.
+func TestToggleFavouriteVerseConcurrent(t *testing.T) {
+	ddl := append([]string{
+		`CREATE TABLE favourite_verses (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_id INT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(), position INT NOT NULL DEFAULT 0
+		)`,
+	}, migrationStatements(t, "000033_dedupe_favourite_verses.up.sql")...)
+	db := testSchemaDB(t, ddl...)
+	db.SetMaxOpenConns(10)
+	repo := &repository{db: db, readDB: db}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.ToggleFavouriteVerse(context.Background(), 1, 1, 0); err != nil {
+				t.Errorf("toggle failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM favourite_verses WHERE user_id = 1 AND verse_id = 1`).Scan(&rows); err != nil {
+		t.Fatalf("failed to count favourites: %v", err)
+	}
+	if rows > 1 {
+		t.Errorf("expected at most one favourite row, got %d", rows)
+	}
+}
+
+// TestSearchUserNotes runs the full-text search against a real Postgres:
+// matches are stemmed, ranked, limited to the user's own notes and carry
+// their attachments and the total across pages.
+func TestSearchUserNotes(t *testing.T) {
+	ddl := []string{
+		`CREATE TABLE user_notes (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_reference TEXT, content TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(), updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+	}
+	ddl = append(ddl, migrationStatements(t, "000003_add_user_notes_search_index.up.sql")...)
+	ddl = append(ddl, migrationStatements(t, "000031_create_attachments.up.sql")...)
+	ddl = append(ddl,
+		`INSERT INTO user_notes (user_id, verse_reference, content) VALUES
+			(1, 'John 3:16', 'God so loved the world; love is patient and love is kind'),
+			(1, 'Psalm 23:1', 'The Lord is my shepherd'),
+			(1, '1 John 4:8', 'Whoever does not love does not know God'),
+			(2, 'John 3:16', 'love love love')`,
+		`INSERT INTO attachments (note_id, url, content_type) VALUES (3, 'https://files.example.com/a.png', 'image/png')`,
+	)
+	db := testSchemaDB(t, ddl...)
+	repo := &repository{db: db, readDB: db}
+	ctx := context.Background()
+
+	results, total, err := repo.SearchUserNotes(ctx, 1, "love", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchUserNotes returned error: %v", err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("expected two of user 1's notes, got %d results and total %d", len(results), total)
+	}
+	if results[0].ID != 1 || results[1].ID != 3 {
+		t.Errorf("expected the note mentioning love most first, got ids %d, %d", results[0].ID, results[1].ID)
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("expected descending rank, got %v then %v", results[0].Rank, results[1].Rank)
+	}
+	if !strings.Contains(results[0].Snippet, "<b>") {
+		t.Errorf("expected a highlighted snippet, got %q", results[0].Snippet)
+	}
+	if len(results[1].Attachments) != 1 {
+		t.Errorf("expected note 3's attachment, got %+v", results[1].Attachments)
+	}
+
+	page, total, err := repo.SearchUserNotes(ctx, 1, "love", 1, 1)
+	if err != nil {
+		t.Fatalf("SearchUserNotes returned error: %v", err)
+	}
+	if total != 2 || len(page) != 1 || page[0].ID != 3 {
+		t.Errorf("expected note 3 alone on the second page with total 2, got %+v (total %d)", page, total)
+	}
+
+	stemmed, _, err := repo.SearchUserNotes(ctx, 1, "shepherds", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchUserNotes returned error: %v", err)
+	}
+	if len(stemmed) != 1 || stemmed[0].ID != 2 {
+		t.Errorf("expected shepherds to match the Psalm note, got %+v", stemmed)
+	}
+}
+
+// TestVerseReports checks the report queries against a real Postgres: the
+// partial unique index allows one open report per user and verse, and
+// resolving keeps the first resolved_at.
+func TestVerseReports(t *testing.T) {
+	ddl := []string{
+		`CREATE TABLE memory_verses (id SERIAL PRIMARY KEY)`,
+		`CREATE TABLE users (id SERIAL PRIMARY KEY)`,
+		`INSERT INTO memory_verses DEFAULT VALUES`,
+		`INSERT INTO users DEFAULT VALUES`,
+	}
+	ddl = append(ddl, migrationStatements(t, "000006_create_verse_reports.up.sql")...)
+	db := testSchemaDB(t, ddl...)
+	repo := &repository{db: db, readDB: db}
+	ctx := context.Background()
+
+	if _, err := repo.CreateVerseReport(ctx, 1, 99, "typo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown verse: expected ErrNotFound, got %v", err)
+	}
+
+	first, err := repo.CreateVerseReport(ctx, 1, 1, "typo")
+	if err != nil {
+		t.Fatalf("CreateVerseReport returned error: %v", err)
+	}
+	if first.Status != ReportStatusOpen || first.ResolvedAt != nil {
+		t.Errorf("expected an open report, got %+v", first)
+	}
+	if _, err := repo.CreateVerseReport(ctx, 1, 1, "again"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("second open report: expected ErrAlreadyExists, got %v", err)
+	}
+
+	resolved, err := repo.ResolveVerseReport(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("ResolveVerseReport returned error: %v", err)
+	}
+	if resolved.Status != ReportStatusResolved || resolved.ResolvedAt == nil {
+		t.Fatalf("expected a resolved report, got %+v", resolved)
+	}
+	again, err := repo.ResolveVerseReport(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("ResolveVerseReport returned error: %v", err)
+	}
+	if !again.ResolvedAt.Equal(*resolved.ResolvedAt) {
+		t.Errorf("expected resolved_at to stay %v, got %v", resolved.ResolvedAt, again.ResolvedAt)
+	}
+	if _, err := repo.ResolveVerseReport(ctx, 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown report: expected ErrNotFound, got %v", err)
+	}
+
+	second, err := repo.CreateVerseReport(ctx, 1, 1, "still a typo")
+	if err != nil {
+		t.Fatalf("expected a new report once the first is resolved, got %v", err)
+	}
+
+	open, total, err := repo.GetVerseReports(ctx, ReportStatusOpen, 10, 0)
+	if err != nil {
+		t.Fatalf("GetVerseReports returned error: %v", err)
+	}
+	if total != 1 || len(open) != 1 || open[0].ID != second.ID {
+		t.Errorf("expected only the new report open, got %+v (total %d)", open, total)
+	}
+	all, total, err := repo.GetVerseReports(ctx, "", 1, 0)
+	if err != nil {
+		t.Fatalf("GetVerseReports returned error: %v", err)
+	}
+	if total != 2 || len(all) != 1 {
+		t.Errorf("expected one of two reports on the page, got %d (total %d)", len(all), total)
+	}
+}
+
+// TestLockFavouritesHoldsOffAdds checks that while a reorder holds the
+// favourites lock, a new favourite waits for it instead of slipping in.
+func TestLockFavouritesHoldsOffAdds(t *testing.T) {
+	ddl := append([]string{
+		`CREATE TABLE favourite_verses (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_id INT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(), position INT NOT NULL DEFAULT 0
+		)`,
+	}, migrationStatements(t, "000033_dedupe_favourite_verses.up.sql")...)
+	db := testSchemaDB(t, ddl...)
+	repo := &repository{db: db, readDB: db}
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if err := lockFavourites(ctx, tx, 1); err != nil {
+		t.Fatalf("lockFavourites returned error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.ToggleFavouriteVerse(ctx, 1, 1, 0)
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected the toggle to wait for the lock")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("toggle failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the toggle to finish once the lock was released")
+	}
+}
+
+// TestGetUserVerseHistoryPageOutsideUTC pages through history on a session
+// whose time zone isn't UTC. The cursor must compare against delivered_at
+// as a plain timestamp or the pages overlap or skip rows.
+func TestGetUserVerseHistoryPageOutsideUTC(t *testing.T) {
+	db := testSchemaDB(t,
+		`CREATE TABLE memory_verses (
+			id SERIAL PRIMARY KEY, reference TEXT, verse TEXT, translation TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE user_verse_history (user_id INT NOT NULL, verse_id INT NOT NULL, delivered_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO memory_verses (reference, verse, translation)
+			SELECT 'Ref ' || i, 'Verse ' || i, 'KJV' FROM generate_series(1, 5) i`,
+		`INSERT INTO user_verse_history (user_id, verse_id, delivered_at)
+			SELECT 1, i, TIMESTAMP '2025-03-01 08:00' + make_interval(hours => 2 * i) FROM generate_series(1, 5) i`,
+	)
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`SET TIME ZONE 'America/New_York'`); err != nil {
+		t.Fatalf("failed to set time zone: %v", err)
+	}
+	repo := &repository{db: db, readDB: db}
+
+	var (
+		got   []int
+		after *pagination.Cursor
+	)
+	for page := 0; page < 5; page++ {
+		histories, err := repo.GetUserVerseHistoryPage(context.Background(), 1, after, 2)
+		if err != nil {
+			t.Fatalf("GetUserVerseHistoryPage returned error: %v", err)
+		}
+		if len(histories) == 0 {
+			break
+		}
+		for _, h := range histories {
+			got = append(got, h.VerseID)
+		}
+		last := histories[len(histories)-1]
+		after = &pagination.Cursor{LastID: last.VerseID, LastCreatedAt: last.DeliveredAt}
+	}
+
+	if want := []int{5, 4, 3, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("expected history %v, got %v", want, got)
+	}
+}
+
+// TestAttachNoteFileConcurrentCap races attaches of different files to one
+// note against a real Postgres. The cap must hold however they interleave.
+// TestFavouriteCapConcurrentAdds adds favourites from many goroutines at
+// once; the cap is checked under the favourites lock, so none slip past it.
+func TestFavouriteCapConcurrentAdds(t *testing.T) {
+	ddl := append([]string{
+		`CREATE TABLE memory_verses (id SERIAL PRIMARY KEY)`,
+		`INSERT INTO memory_verses (id) SELECT g FROM generate_series(1, 20) g`,
+		`CREATE TABLE favourite_verses (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_id INT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(), position INT NOT NULL DEFAULT 0
+		)`,
+	}, migrationStatements(t, "000033_dedupe_favourite_verses.up.sql")...)
+	db := testSchemaDB(t, ddl...)
+	db.SetMaxOpenConns(10)
+	repo := &repository{db: db, readDB: db}
+	ctx := context.Background()
+
+	const limit = 3
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(verseID int) {
+			defer wg.Done()
+			var err error
+			if verseID%2 == 0 {
+				_, err = repo.ToggleFavouriteVerse(ctx, 1, verseID, limit)
+			} else {
+				_, err = repo.BulkToggleFavourites(ctx, 1, []int{verseID}, nil, limit)
+			}
+			if err != nil && !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("add failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM favourite_verses WHERE user_id = 1`).Scan(&count); err != nil {
+		t.Fatalf("failed to count favourites: %v", err)
+	}
+	if count != limit {
+		t.Errorf("expected exactly %d favourites, got %d", limit, count)
+	}
+
+	// Swapping one favourite for another doesn't grow the set
+	var kept int
+	if err := db.QueryRow(`SELECT verse_id FROM favourite_verses WHERE user_id = 1 LIMIT 1`).Scan(&kept); err != nil {
+		t.Fatalf("failed to read a favourite: %v", err)
+	}
+	var spare int
+	if err := db.QueryRow(`SELECT MIN(id) FROM memory_verses WHERE id NOT IN (SELECT verse_id FROM favourite_verses)`).Scan(&spare); err != nil {
+		t.Fatalf("failed to pick a spare verse: %v", err)
+	}
+	if _, err := repo.BulkToggleFavourites(ctx, 1, []int{spare}, []int{kept}, limit); err != nil {
+		t.Errorf("expected a swap at the cap to succeed, got %v", err)
+	}
+}
+
+// TestSaveUserNoteConcurrentCap saves distinct notes concurrently; the cap is
+// checked under the notes lock, so none slip past it.
+func TestSaveUserNoteConcurrentCap(t *testing.T) {
+	db := testSchemaDB(t,
+		`CREATE TABLE user_notes (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_reference TEXT, content TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(), updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+	)
+	db.SetMaxOpenConns(10)
+	repo := &repository{db: db, readDB: db}
+
+	const limit = 3
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repo.SaveUserNote(context.Background(), 1, "John 3:16", fmt.Sprintf("note %d", i), time.Minute, limit)
+			if err != nil && !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("save failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_notes WHERE user_id = 1`).Scan(&count); err != nil {
+		t.Fatalf("failed to count notes: %v", err)
+	}
+	if count != limit {
+		t.Errorf("expected exactly %d notes, got %d", limit, count)
+	}
+}
+
+func TestAttachNoteFileConcurrentCap(t *testing.T) {
+	ddl := append([]string{
+		`CREATE TABLE user_notes (
+			id SERIAL PRIMARY KEY, user_id INT NOT NULL, verse_reference TEXT, content TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(), updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`INSERT INTO user_notes (user_id, verse_reference, content) VALUES (1, 'John 3:16', 'note')`,
+	}, migrationStatements(t, "000031_create_attachments.up.sql")...)
+	db := testSchemaDB(t, ddl...)
+	db.SetMaxOpenConns(10)
+	repo := &repository{db: db, readDB: db}
+
+	const limit = 3
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := fmt.Sprintf("https://files.example.com/%d.png", i)
+			_, err := repo.AttachNoteFile(context.Background(), 1, 1, url, "image/png", limit)
+			if err != nil && !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("attach failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM attachments WHERE note_id = 1`).Scan(&count); err != nil {
+		t.Fatalf("failed to count attachments: %v", err)
+	}
+	if count != limit {
+		t.Errorf("expected exactly %d attachments, got %d", limit, count)
+	}
+
+	// Re-attaching a file already on the note is an update, not a new slot
+	if _, err := repo.AttachNoteFile(context.Background(), 1, 1, "https://files.example.com/x.png", "image/png", limit); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected a new file past the cap to be refused, got %v", err)
+	}
+	var url string
+	if err := db.QueryRow(`SELECT url FROM attachments WHERE note_id = 1 LIMIT 1`).Scan(&url); err != nil {
+		t.Fatalf("failed to read an attachment: %v", err)
+	}
+	if _, err := repo.AttachNoteFile(context.Background(), 1, 1, url, "image/webp", limit); err != nil {
+		t.Errorf("expected re-attaching %s at the cap to succeed, got %v", url, err)
+	}
+	if _, err := repo.AttachNoteFile(context.Background(), 2, 1, "https://files.example.com/y.png", "image/png", limit); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another user's note to be not found, got %v", err)
+	}
+}
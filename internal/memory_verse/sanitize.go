@@ -0,0 +1,30 @@
+package memoryverse
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Script and style bodies are code, not verse text, so they go with their tags
+	scriptBlockPattern = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+	htmlTagPattern     = regexp.MustCompile(`<[^>]*>`)
+
+	quoteReplacer = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "′", "'",
+		"“", `"`, "”", `"`, "„", `"`, "″", `"`,
+	)
+)
+
+// sanitizeVerseText cleans verse text from an import for emails and JSON: it
+// drops HTML tags, decodes entities, straightens curly quotes and collapses
+// runs of whitespace (including non-breaking spaces) to single spaces.
+func sanitizeVerseText(s string) string {
+	s = scriptBlockPattern.ReplaceAllString(s, " ")
+	// A space keeps words either side of <br> and friends apart
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	s = quoteReplacer.Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -3,43 +3,132 @@ package memoryverse
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/robfig/cron/v3"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
 )
 
-// StartScheduler runs the verse delivery job on a schedule.
-// - In dev: runs every 1 minute.
-// - In prod: runs every 24 hours (daily check for users).
-func (s *MemoryVerseService) StartScheduler(ctx context.Context) {
-	tickerDuration :=  time.Minute // default for testing (local/dev)
+// ParseSchedulerCron reads a SCHEDULER_CRON spec: the standard five fields,
+// a descriptor such as @daily, or @every <duration>. Specs are evaluated in
+// UTC. A spec that never fires, such as February 31st, is rejected too.
+func ParseSchedulerCron(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCHEDULER_CRON %q: %w", spec, err)
+	}
+	if schedule.Next(time.Now().UTC()).IsZero() {
+		return nil, fmt.Errorf("SCHEDULER_CRON %q never fires", spec)
+	}
+	return schedule, nil
+}
 
+// StartScheduler runs the verse delivery job whenever schedule fires, in UTC
+// so runs land on exact minutes, until ctx is cancelled. Runs never overlap:
+// a firing while the last run is still going is skipped.
+func (s *MemoryVerseService) StartScheduler(ctx context.Context, schedule cron.Schedule) {
 	log.Println("Current time:", time.Now())
 
-	appEnv := config.GetAppEnv()
-	if appEnv == "production" {
-		tickerDuration = 24 * time.Hour // daily check in prod
+	interval := scheduleInterval(schedule, time.Now().UTC())
+	s.scheduler.started(time.Now(), interval)
+	if interval > 0 {
+		go s.watchScheduler(ctx, interval)
 	}
 
-	ticker := time.NewTicker(tickerDuration)
-	defer ticker.Stop()
+	s.catchUpMissedSends(ctx, time.Now())
+
+	c := cron.New(cron.WithLocation(time.UTC), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	c.Schedule(schedule, cron.FuncJob(func() {
+		s.runVerseDistribution(ctx)
+		// cron would otherwise just never fire again without a word
+		if schedule.Next(time.Now().UTC()).IsZero() {
+			log.Println("Warning: SCHEDULER_CRON has no further run times, no more verses will be sent until it is changed")
+		}
+	}))
+	c.Start()
 
-	log.Printf("MemoryVerse Scheduler started (%s interval)\n", tickerDuration)
+	<-ctx.Done()
+	<-c.Stop().Done()
+	log.Println("Scheduler stopped gracefully")
+}
+
+// scheduleInterval estimates the gap between runs from the two firings after
+// now, or returns 0 when the schedule stops firing.
+func scheduleInterval(sched cron.Schedule, now time.Time) time.Duration {
+	next := sched.Next(now)
+	if next.IsZero() {
+		return 0
+	}
+	after := sched.Next(next)
+	if after.IsZero() {
+		return 0
+	}
+	return after.Sub(next)
+}
+
+// watchScheduler logs a warning each interval while the scheduler is stale.
+func (s *MemoryVerseService) watchScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Scheduler stopped gracefully")
 			return
 		case <-ticker.C:
-			s.runVerseDistribution(ctx)
+			if st := s.SchedulerStatus(); st.Stale {
+				log.Printf("Warning: verse scheduler has not started a run for over twice its %s interval (last started %v, running %t)",
+					interval, st.LastRunStartedAt, st.Running)
+			}
 		}
 	}
 }
 
+// SchedulerStatus reports the verse distribution job's last run.
+func (s *MemoryVerseService) SchedulerStatus() SchedulerStatus {
+	return s.scheduler.status(time.Now())
+}
+
 // runVerseDistribution checks each user's verse pace and last sent date.
 func (s *MemoryVerseService) runVerseDistribution(ctx context.Context) {
+	s.distribute(ctx, time.Now(), isVerseDue)
+}
+
+// catchUpMissedSends sends verses whose slot passed while the server was
+// down, so users don't wait for the next scheduled run. Only sends that fell
+// due within the SCHEDULER_CATCHUP_GRACE window are made; older ones are
+// left to the regular runs.
+func (s *MemoryVerseService) catchUpMissedSends(ctx context.Context, now time.Time) {
+	if s.cfg == nil || s.cfg.CatchUpGrace <= 0 {
+		return
+	}
+	grace := s.cfg.CatchUpGrace
+
+	log.Printf("Catching up verse sends missed in the last %s", grace)
+	s.distribute(ctx, now, func(user auth.User, slots []time.Time, now time.Time) bool {
+		if !isVerseDue(user, slots, now) {
+			return false
+		}
+		due := dueSince(user, slots, now)
+		return !due.IsZero() && now.Sub(due) <= grace
+	})
+}
+
+// distribute sends a verse to every deliverable user for whom due reports true.
+func (s *MemoryVerseService) distribute(ctx context.Context, now time.Time, due func(auth.User, []time.Time, time.Time) bool) {
+	s.scheduler.runStarted(time.Now())
+	var checked int
+	defer func() { s.scheduler.runFinished(time.Now(), checked) }()
+
 	users, err := s.authRepo.GetAllUsersWithVersePace(ctx)
 	if err != nil {
 		log.Printf("Failed to fetch users for verse distribution: %v", err)
@@ -47,56 +136,338 @@ func (s *MemoryVerseService) runVerseDistribution(ctx context.Context) {
 	}
 
 	log.Printf("Running verse distribution check for %d users\n", len(users))
+	checked = len(users)
 
+	userIDs := make([]int, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	deliveryTimes, err := s.authRepo.GetDeliveryTimesForUsers(ctx, userIDs)
+	if err != nil {
+		log.Printf("Failed to fetch delivery times for verse distribution: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
 	for _, user := range users {
 
 		if !user.IsSubscribed {
 			log.Printf("Skipping user %s (unsubscribed)", user.Email)
 			continue
 		}
+		if !user.IsProfileCompleted {
+			log.Printf("Skipping user %s (profile incomplete)", user.Email)
+			continue
+		}
 		log.Printf("user versePace is: %s", user.VersePace)
 
-		// Determine next send time based on pace
-		var sendInterval time.Duration
-		switch user.VersePace {
-		case "weekly":
-			sendInterval = 7 * 24 * time.Hour
-		default:
-			// default to daily
-			sendInterval = 5 * time.Second
+		channels := user.DeliveryChannels()
+		if len(channels) == 0 {
+			log.Printf("Skipping user %s (notifications disabled)", user.Email)
+			continue
 		}
+		// Email is the only channel we can deliver on until web push lands
+		if !slices.Contains(channels, auth.ChannelEmail) {
+			log.Printf("Skipping user %s (no supported delivery channel in %v)", user.Email, channels)
+			continue
+		}
+
+		if due(user, deliveryTimes[user.ID], now) {
+			wg.Add(1)
+			go func(user auth.User) {
+				defer wg.Done()
 
-		if user.LastVerseSentAt == nil || time.Since(user.LastVerseSentAt.UTC()) >= sendInterval {
-			go func(uID int) {
-				_, verse, _, _, err := s.GetUserDashboard(ctx, uID)
-				if err != nil {
-					log.Printf("Skipping user %d: %v", uID, err)
+				// Verses seen on the dashboard count towards the goal too
+				if s.checkVerseGoal(ctx, user) {
 					return
 				}
 
-				data := map[string]interface{}{
-					"UserName":       user.UserName,
-					"Verse":          verse.Verse,
-					"Reference":      verse.Reference,
-					"Pace":           user.VersePace,
-					"DashboardURL":   "https://memoryverse.app/dashboard",
-					"UnsubscribeURL": "https://memoryverse.app/unsubscribe",
+				if user.VersePace == auth.PaceWeekly {
+					s.sendWeeklyDigest(ctx, user)
+				} else {
+					s.sendVerse(ctx, user)
 				}
+				s.checkVerseGoal(ctx, user)
+			}(user)
+		}
+	}
 
-				subject := fmt.Sprintf("Your %s Memoryverse is", user.VersePace)
+	// Wait for this run's sends so runs never overlap
+	wg.Wait()
+}
 
-				if err := s.mail.SendHTML(user.Email, subject, "verse.html", data); err != nil {
-					log.Printf("Failed to send verse to %s: %v", user.Email, err)
-					return
-				}
+// sendVerse delivers the user's current dashboard verse by email.
+func (s *MemoryVerseService) sendVerse(ctx context.Context, user auth.User) {
+	_, verse, _, _, err := s.GetUserDashboard(ctx, user.ID)
+	if err != nil {
+		log.Printf("Skipping user %d: %v", user.ID, err)
+		return
+	}
+	// This runs in its own goroutine, where a panic would take down the
+	// whole process, so never trust the verse to be there
+	if verse == nil {
+		log.Printf("Warning: skipping user %d, dashboard returned no verse", user.ID)
+		return
+	}
 
-				// Update last sent timestamp
-				if err := s.authRepo.UpdateLastVerseSentAt(ctx, uID, time.Now()); err != nil {
-					log.Printf("Could not update last sent date for %d: %v", uID, err)
-				}
+	data := map[string]interface{}{
+		"UserName":     user.UserName,
+		"Verse":        verse.Verse,
+		"Reference":    verse.Reference,
+		"Pace":         user.VersePace,
+		"DashboardURL": s.cfg.AppURL("/dashboard"),
+		"AppURL":       s.cfg.AppURL(""),
+	}
+
+	variant, subject := s.subjectVariant(user)
+
+	if !s.sendAndMarkSent(ctx, user, verse.ID, variant, subject, "verse.html", data) {
+		return
+	}
+
+	log.Printf("Verse sent to %s (%s)", user.Email, verse.Reference)
+}
+
+// weeklyDigestSize is how many verses a weekly digest contains.
+const weeklyDigestSize = 7
+
+// weeklyReflections rotate through the digests, one per week of the year.
+var weeklyReflections = []string{
+	"Which of these verses spoke to you most this week, and why?",
+	"Pick one verse to carry into the week ahead. How will you live it out?",
+	"Read these verses slowly. What do they reveal about God's character?",
+	"Who could you share one of these verses with this week?",
+}
+
+// sendWeeklyDigest delivers a week's worth of verses plus a reflection to a weekly-pace user.
+func (s *MemoryVerseService) sendWeeklyDigest(ctx context.Context, user auth.User) {
+	verses, err := s.repo.GetWeeklyVerses(ctx, user.ID, weeklyDigestSize)
+	if err != nil {
+		log.Printf("Skipping weekly digest for user %d: %v", user.ID, err)
+		return
+	}
+	if len(verses) == 0 {
+		log.Printf("Skipping weekly digest for user %d: no verses available", user.ID)
+		return
+	}
+
+	// Plain maps keep template field names intact through the outbox's JSON payload
+	digestVerses := make([]map[string]string, 0, len(verses))
+	for _, v := range verses {
+		digestVerses = append(digestVerses, map[string]string{
+			"Verse":     v.Verse,
+			"Reference": v.Reference,
+		})
+	}
+
+	_, week := time.Now().ISOWeek()
 
-				log.Printf("Verse sent to %s (%s)", user.Email, verse.Reference)
-			}(user.ID)
+	data := map[string]interface{}{
+		"UserName":     user.UserName,
+		"Verses":       digestVerses,
+		"Reflection":   weeklyReflections[week%len(weeklyReflections)],
+		"DashboardURL": s.cfg.AppURL("/dashboard"),
+		"AppURL":       s.cfg.AppURL(""),
+	}
+
+	if !s.sendAndMarkSent(ctx, user, 0, "", "Your weekly Memoryverse digest", "weekly_digest.html", data) {
+		return
+	}
+
+	// Only now are the verses delivered; after a failed send they stay
+	// eligible for the next digest
+	for _, v := range verses {
+		if err := s.repo.SaveDeliveredVerse(ctx, user.ID, v.ID); err != nil {
+			log.Printf("Could not record delivered verse %d for %d: %v", v.ID, user.ID, err)
+		}
+	}
+
+	log.Printf("Weekly digest sent to %s (%d verses)", user.Email, len(verses))
+}
+
+// sendAndMarkSent emails the template to the user with an unsubscribe link and
+// headers, and records the send time. Sends are tracked: the email gets an
+// open pixel and its dashboard and unsubscribe links go through the click
+// redirect. verseID is 0 for emails without a single verse, and variant is
+// the subject line's A/B variant, empty when the subject isn't under test.
+// It reports whether the send succeeded.
+func (s *MemoryVerseService) sendAndMarkSent(ctx context.Context, user auth.User, verseID int, variant, subject, templateName string, data map[string]interface{}) bool {
+	var headers map[string]string
+	data["UnsubscribeURL"], headers = s.unsubscribeLinks(user.ID)
+
+	trackingToken, err := util.GenerateTrackingToken()
+	if err != nil {
+		log.Printf("Could not build tracking links for %d: %v", user.ID, err)
+	} else {
+		data["TrackingPixelURL"] = s.trackingPixelURL(trackingToken)
+		for _, key := range []string{"DashboardURL", "UnsubscribeURL"} {
+			if dest, ok := data[key].(string); ok {
+				data[key] = s.trackedClickURL(trackingToken, dest)
+			}
+		}
+	}
+
+	if err := s.mail.SendHTMLWithHeaders(user.Email, subject, templateName, data, headers); err != nil {
+		log.Printf("Failed to send %s to %s: %v", templateName, user.Email, err)
+		return false
+	}
+
+	if trackingToken != "" {
+		if err := s.repo.CreateEmailSend(ctx, trackingToken, user.ID, verseID, variant); err != nil {
+			log.Printf("Could not record tracked send for %d: %v", user.ID, err)
 		}
 	}
+
+	// Update last sent timestamp
+	if err := s.authRepo.UpdateLastVerseSentAt(ctx, user.ID, time.Now()); err != nil {
+		log.Printf("Could not update last sent date for %d: %v", user.ID, err)
+	}
+
+	return true
+}
+
+// controlSubjectVariant names the original verse email subject, which stays
+// in every A/B test as the baseline.
+const controlSubjectVariant = "control"
+
+// subjectVariant picks the verse email subject for the user. Users are split
+// evenly between the control subject and each VERSE_SUBJECT_VARIANTS entry,
+// named v1, v2, ... in order, by a hash of their ID, so a user keeps their
+// variant from send to send for as long as the list is unchanged.
+func (s *MemoryVerseService) subjectVariant(user auth.User) (variant, subject string) {
+	var templates []string
+	if s.cfg != nil {
+		templates = s.cfg.SubjectVariants
+	}
+
+	i := variantIndex(user.ID, len(templates)+1)
+	if i == 0 {
+		return controlSubjectVariant, fmt.Sprintf("Your %s Memoryverse is", user.VersePace)
+	}
+
+	fill := strings.NewReplacer("{pace}", user.VersePace, "{name}", user.UserName)
+	return "v" + strconv.Itoa(i), fill.Replace(templates[i-1])
+}
+
+// variantIndex buckets a user into one of n variants.
+func variantIndex(userID, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.Itoa(userID)))
+	return int(h.Sum32() % uint32(n))
+}
+
+// isVerseDue decides whether a user should be sent a verse at now. Users
+// without delivery slots are sent one whenever their pace interval has
+// elapsed; users with slots are sent one per slot occurrence. A pace that
+// auth.PaceInterval doesn't accept is never due.
+func isVerseDue(user auth.User, slots []time.Time, now time.Time) bool {
+	// Snoozed users are skipped until the snooze lapses
+	if user.SnoozedUntil != nil && now.Before(*user.SnoozedUntil) {
+		return false
+	}
+	// As are users paused at their verse goal, until they reset it
+	if user.GoalPaused() {
+		return false
+	}
+
+	if _, ok := auth.PaceInterval(user.VersePace, user.PaceDays); !ok {
+		return false
+	}
+
+	if len(slots) == 0 {
+		return user.LastVerseSentAt == nil || paceElapsed(user.VersePace, user.PaceDays, *user.LastVerseSentAt, now)
+	}
+
+	if user.LastVerseSentAt == nil {
+		return true
+	}
+
+	// Already sent for the most recent slot
+	if !user.LastVerseSentAt.Before(latestSlot(now, slots)) {
+		return false
+	}
+
+	// Daily users get every slot; longer paces still wait out their interval
+	if user.VersePace == auth.PaceDaily {
+		return true
+	}
+	return paceElapsed(user.VersePace, user.PaceDays, *user.LastVerseSentAt, now)
+}
+
+// dueSince returns when the user's pending send fell due: the latest slot
+// for users with delivery slots, or the end of the pace interval since the
+// last send otherwise. It is zero for users who have never been sent one.
+func dueSince(user auth.User, slots []time.Time, now time.Time) time.Time {
+	if user.LastVerseSentAt == nil {
+		return time.Time{}
+	}
+	interval, ok := auth.PaceInterval(user.VersePace, user.PaceDays)
+	if !ok {
+		return time.Time{}
+	}
+
+	due := user.LastVerseSentAt.UTC().Add(interval)
+	if len(slots) == 0 {
+		return due
+	}
+	// Daily users get every slot; longer paces wait out the interval first
+	slot := latestSlot(now, slots)
+	if user.VersePace == auth.PaceDaily || slot.After(due) {
+		return slot
+	}
+	return due
+}
+
+// latestSlot returns the most recent occurrence (at or before now) of any of
+// the given UTC times of day.
+func latestSlot(now time.Time, slots []time.Time) time.Time {
+	now = now.UTC()
+	var latest time.Time
+	for _, slot := range slots {
+		occurrence := time.Date(now.Year(), now.Month(), now.Day(),
+			slot.Hour(), slot.Minute(), slot.Second(), 0, time.UTC)
+		if occurrence.After(now) {
+			occurrence = occurrence.Add(-24 * time.Hour)
+		}
+		if occurrence.After(latest) {
+			latest = occurrence
+		}
+	}
+	return latest
+}
+
+// trackingPixelURL is the open-tracking image URL for a sent email.
+func (s *MemoryVerseService) trackingPixelURL(token string) string {
+	return fmt.Sprintf("%s/memory-verse-api/v1/track/open/%s.gif",
+		strings.TrimRight(s.cfg.ApiBaseURL, "/"), token)
+}
+
+// trackedClickURL wraps dest in the click-tracking redirect for a sent email.
+func (s *MemoryVerseService) trackedClickURL(token, dest string) string {
+	return fmt.Sprintf("%s/memory-verse-api/v1/track/click/%s?to=%s",
+		strings.TrimRight(s.cfg.ApiBaseURL, "/"), token, url.QueryEscape(dest))
+}
+
+// unsubscribeLinks returns the user's tokenised unsubscribe page URL and
+// List-Unsubscribe headers. Without a token the link falls back to the
+// logged-in unsubscribe page and there are no headers.
+func (s *MemoryVerseService) unsubscribeLinks(userID int) (string, map[string]string) {
+	token, err := util.GenerateUnsubscribeToken(userID)
+	if err != nil {
+		log.Printf("Could not build unsubscribe links for %d: %v", userID, err)
+		return s.cfg.AppURL("/unsubscribe"), nil
+	}
+	return s.cfg.AppURL("/unsubscribe?token=" + url.QueryEscape(token)), s.unsubscribeHeaders(token)
+}
+
+// unsubscribeHeaders builds the List-Unsubscribe headers pointing at the
+// one-click unsubscribe URL for the given token.
+func (s *MemoryVerseService) unsubscribeHeaders(token string) map[string]string {
+	unsubscribeURL := fmt.Sprintf("%s/memory-verse-api/v1/unsubscribe/one-click?token=%s",
+		strings.TrimRight(s.cfg.ApiBaseURL, "/"), url.QueryEscape(token))
+
+	return map[string]string{
+		"List-Unsubscribe":      fmt.Sprintf("<%s>", unsubscribeURL),
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
 }
@@ -0,0 +1,128 @@
+package memoryverse
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// SchedulerStatus describes the verse distribution job's most recent run.
+// Stale means no run has started for over twice the schedule's interval, so
+// the scheduler may be stuck.
+type SchedulerStatus struct {
+	Running            bool       `json:"running"`
+	LastRunStartedAt   *time.Time `json:"last_run_started_at"`
+	LastRunFinishedAt  *time.Time `json:"last_run_finished_at"`
+	LastRunDurationSec float64    `json:"last_run_duration_seconds"`
+	LastRunUsers       int        `json:"last_run_users"`
+	IntervalSec        float64    `json:"interval_seconds"`
+	Stale              bool       `json:"stale"`
+}
+
+// schedulerMonitor records scheduler runs. It is shared by pointer so every
+// copy of the service, including the admin handler's, sees the running
+// scheduler's runs.
+type schedulerMonitor struct {
+	mu        sync.Mutex
+	startedAt time.Time // when the scheduler itself started
+	interval  time.Duration
+	running   bool
+	lastStart time.Time
+	lastEnd   time.Time
+	lastUsers int
+}
+
+func newSchedulerMonitor() *schedulerMonitor {
+	return &schedulerMonitor{}
+}
+
+// started notes that the scheduler is up and firing about every interval.
+func (m *schedulerMonitor) started(now time.Time, interval time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startedAt = now
+	m.interval = interval
+}
+
+func (m *schedulerMonitor) runStarted(now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = true
+	m.lastStart = now
+}
+
+func (m *schedulerMonitor) runFinished(now time.Time, users int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = false
+	m.lastEnd = now
+	m.lastUsers = users
+}
+
+func (m *schedulerMonitor) status(now time.Time) SchedulerStatus {
+	if m == nil {
+		return SchedulerStatus{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := SchedulerStatus{
+		Running:      m.running,
+		LastRunUsers: m.lastUsers,
+		IntervalSec:  m.interval.Seconds(),
+	}
+	if !m.lastStart.IsZero() {
+		start := m.lastStart
+		st.LastRunStartedAt = &start
+	}
+	if !m.lastEnd.IsZero() {
+		end := m.lastEnd
+		st.LastRunFinishedAt = &end
+		st.LastRunDurationSec = m.lastEnd.Sub(m.lastStart).Seconds()
+	}
+
+	// Before the first run, measure from when the scheduler started
+	since := m.lastStart
+	if since.IsZero() {
+		since = m.startedAt
+	}
+	st.Stale = m.interval > 0 && !since.IsZero() && now.Sub(since) > 2*m.interval
+	return st
+}
+
+// writePrometheus writes st as gauges in the Prometheus text format.
+func (st SchedulerStatus) writePrometheus(w io.Writer) {
+	gauge := func(name, help string, v float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+	}
+	unix := func(t *time.Time) float64 {
+		if t == nil {
+			return 0
+		}
+		return float64(t.UnixNano()) / 1e9
+	}
+	boolean := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	gauge("verse_scheduler_last_run_start_timestamp_seconds", "Unix time the last verse distribution run started.", unix(st.LastRunStartedAt))
+	gauge("verse_scheduler_last_run_end_timestamp_seconds", "Unix time the last verse distribution run finished.", unix(st.LastRunFinishedAt))
+	gauge("verse_scheduler_last_run_duration_seconds", "How long the last finished verse distribution run took.", st.LastRunDurationSec)
+	gauge("verse_scheduler_last_run_users", "Users checked by the last finished verse distribution run.", float64(st.LastRunUsers))
+	gauge("verse_scheduler_interval_seconds", "Expected time between verse distribution runs.", st.IntervalSec)
+	gauge("verse_scheduler_running", "1 while a verse distribution run is in progress.", boolean(st.Running))
+	gauge("verse_scheduler_stale", "1 when no run has started for over twice the interval.", boolean(st.Stale))
+}
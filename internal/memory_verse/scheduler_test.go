@@ -0,0 +1,666 @@
+package memoryverse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+func TestUnsubscribeHeaders(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s := &MemoryVerseService{cfg: &config.Config{ApiBaseURL: "https://api.memoryverse.app/"}}
+
+	token, err := util.GenerateUnsubscribeToken(42)
+	if err != nil {
+		t.Fatalf("GenerateUnsubscribeToken returned error: %v", err)
+	}
+	headers := s.unsubscribeHeaders(token)
+
+	if got := headers["List-Unsubscribe-Post"]; got != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected List-Unsubscribe-Post header: %q", got)
+	}
+
+	value := headers["List-Unsubscribe"]
+	if !strings.HasPrefix(value, "<") || !strings.HasSuffix(value, ">") {
+		t.Fatalf("List-Unsubscribe must be wrapped in angle brackets, got %q", value)
+	}
+
+	u, err := url.Parse(strings.Trim(value, "<>"))
+	if err != nil {
+		t.Fatalf("invalid unsubscribe URL: %v", err)
+	}
+	if u.Scheme != "https" || u.Host != "api.memoryverse.app" || u.Path != "/memory-verse-api/v1/unsubscribe/one-click" {
+		t.Errorf("unexpected unsubscribe URL: %s", u)
+	}
+
+	userID, err := util.ValidateUnsubscribeToken(u.Query().Get("token"))
+	if err != nil {
+		t.Fatalf("token in URL is invalid: %v", err)
+	}
+	if userID != 42 {
+		t.Errorf("expected token for user 42, got %d", userID)
+	}
+}
+
+func TestSendVerseLinksUseConfiguredAppURL(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, _, _, mailer := newTestScheduler([]auth.User{
+		{ID: 1, Email: "daily@example.com", VersePace: "daily"},
+	})
+	s.cfg.AppBaseURL = "https://staging.example.org/"
+
+	s.sendVerse(context.Background(), auth.User{ID: 1, Email: "daily@example.com", VersePace: "daily"})
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected one email, got %d", len(mailer.sent))
+	}
+	data := mailer.sent[0].Data.(map[string]interface{})
+
+	if got := untrackedURL(t, s, data["DashboardURL"]); got != "https://staging.example.org/dashboard" {
+		t.Errorf("unexpected DashboardURL: %v", got)
+	}
+	if got := data["AppURL"]; got != "https://staging.example.org" {
+		t.Errorf("unexpected AppURL: %v", got)
+	}
+
+	u, err := url.Parse(untrackedURL(t, s, data["UnsubscribeURL"]))
+	if err != nil {
+		t.Fatalf("invalid UnsubscribeURL: %v", err)
+	}
+	if u.Host != "staging.example.org" || u.Path != "/unsubscribe" {
+		t.Errorf("expected unsubscribe page on the configured host, got %s", u)
+	}
+	if userID, err := util.ValidateUnsubscribeToken(u.Query().Get("token")); err != nil || userID != 1 {
+		t.Errorf("expected a valid unsubscribe token for user 1, got user %d, err %v", userID, err)
+	}
+
+	pixel, _ := data["TrackingPixelURL"].(string)
+	if !strings.HasPrefix(pixel, s.cfg.ApiBaseURL) || !strings.HasSuffix(pixel, ".gif") {
+		t.Errorf("unexpected TrackingPixelURL: %q", pixel)
+	}
+}
+
+// untrackedURL unwraps a click-tracking redirect link to its destination.
+func untrackedURL(t *testing.T, s *MemoryVerseService, link interface{}) string {
+	t.Helper()
+	str, _ := link.(string)
+	u, err := url.Parse(str)
+	if err != nil {
+		t.Fatalf("invalid link %q: %v", str, err)
+	}
+	if !strings.HasPrefix(str, strings.TrimRight(s.cfg.ApiBaseURL, "/")+"/memory-verse-api/v1/track/click/") {
+		t.Fatalf("expected a click-tracking link, got %q", str)
+	}
+	return u.Query().Get("to")
+}
+
+func TestIsVerseDueTwoSlotsSendsTwicePerDay(t *testing.T) {
+	morning := time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(0, 1, 1, 20, 0, 0, 0, time.UTC)
+	slots := []time.Time{morning, evening}
+
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	lastSent := start.Add(-time.Hour) // sent for yesterday's evening slot
+	user := auth.User{VersePace: "daily", LastVerseSentAt: &lastSent}
+
+	var sends []time.Time
+	for now := start; now.Before(start.Add(24 * time.Hour)); now = now.Add(time.Minute) {
+		if isVerseDue(user, slots, now) {
+			sent := now
+			user.LastVerseSentAt = &sent
+			sends = append(sends, sent)
+		}
+	}
+
+	if len(sends) != 2 {
+		t.Fatalf("expected 2 sends in a day, got %d: %v", len(sends), sends)
+	}
+	if sends[0].Hour() != 8 || sends[1].Hour() != 20 {
+		t.Errorf("expected sends at 08:00 and 20:00, got %v", sends)
+	}
+}
+
+func TestIsVerseDueSkipsSnoozedUsersUntilSnoozeLapses(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	lastSent := now.Add(-48 * time.Hour)
+	snoozedUntil := now.Add(72 * time.Hour)
+	user := auth.User{VersePace: "daily", LastVerseSentAt: &lastSent, SnoozedUntil: &snoozedUntil}
+
+	if isVerseDue(user, nil, now) {
+		t.Error("expected snoozed user to be skipped")
+	}
+
+	if !isVerseDue(user, nil, snoozedUntil.Add(time.Minute)) {
+		t.Error("expected user to resume once the snooze lapses")
+	}
+}
+
+func newTestScheduler(users []auth.User) (*MemoryVerseService, *fakeAuthRepo, *fakeVerseRepo, *fakeMailer) {
+	authRepo := &fakeAuthRepo{users: users, profiles: map[int]*auth.CompleteProfileRequest{}}
+	for _, u := range users {
+		authRepo.profiles[u.ID] = &auth.CompleteProfileRequest{VersePace: u.VersePace, BibleTranslation: "KJV"}
+	}
+	verseRepo := &fakeVerseRepo{verses: []Verse{
+		{ID: 1, Reference: "John 3:16", Verse: "For God so loved the world", Translation: "KJV"},
+		{ID: 2, Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"},
+	}}
+	mailer := &fakeMailer{}
+
+	s := &MemoryVerseService{
+		repo:      verseRepo,
+		authRepo:  authRepo,
+		mail:      mailer,
+		cfg:       &config.Config{ApiBaseURL: "https://api.memoryverse.app"},
+		scheduler: newSchedulerMonitor(),
+	}
+	return s, authRepo, verseRepo, mailer
+}
+
+func TestRunVerseDistributionSendsDigestOnlyToWeeklyUsers(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, _, verseRepo, mailer := newTestScheduler([]auth.User{
+		{ID: 1, Email: "daily@example.com", VersePace: "daily", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+		{ID: 2, Email: "weekly@example.com", VersePace: "weekly", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+	})
+
+	s.runVerseDistribution(context.Background())
+
+	if got := mailer.templatesFor("daily@example.com"); len(got) != 1 || got[0] != "verse.html" {
+		t.Errorf("expected daily user to get verse.html only, got %v", got)
+	}
+	if got := mailer.templatesFor("weekly@example.com"); len(got) != 1 || got[0] != "weekly_digest.html" {
+		t.Errorf("expected weekly user to get weekly_digest.html only, got %v", got)
+	}
+
+	if got := len(verseRepo.delivered[2]); got != 2 {
+		t.Errorf("expected every digest verse to be recorded as delivered, got %d", got)
+	}
+}
+
+func TestWeeklyDigestNotRecordedWhenSendFails(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, _, verseRepo, mailer := newTestScheduler([]auth.User{
+		{ID: 2, Email: "weekly@example.com", VersePace: "weekly", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+	})
+	mailer.err = errors.New("smtp down")
+
+	s.runVerseDistribution(context.Background())
+
+	if got := len(verseRepo.delivered[2]); got != 0 {
+		t.Errorf("expected no verses recorded after a failed digest, got %d", got)
+	}
+}
+
+func TestRunVerseDistributionRespectsDeliveryChannels(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	tests := []struct {
+		name      string
+		enable    bool
+		email     bool
+		web       bool
+		wantEmail bool
+	}{
+		{"all on", true, true, true, true},
+		{"email only", true, true, false, true},
+		{"web only", true, false, true, false},
+		{"both channels off", true, false, false, false},
+		{"master switch off", false, true, true, false},
+		{"everything off", false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, mailer := newTestScheduler([]auth.User{{
+				ID: 1, Email: "user@example.com", VersePace: "daily",
+				IsSubscribed: true, IsProfileCompleted: true,
+				EnableNotification: tt.enable, IsEmailNotification: tt.email, IsWebNotification: tt.web,
+			}})
+
+			s.runVerseDistribution(context.Background())
+
+			got := len(mailer.templatesFor("user@example.com")) > 0
+			if got != tt.wantEmail {
+				t.Errorf("expected email sent = %v, got %v", tt.wantEmail, got)
+			}
+		})
+	}
+}
+
+func TestRunVerseDistributionLoadsDeliveryTimesInOneBatch(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	sentAt := time.Now().Add(-2 * time.Hour)
+	user := func(id int, email string) auth.User {
+		return auth.User{
+			ID: id, Email: email, VersePace: "daily", LastVerseSentAt: &sentAt,
+			IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true,
+		}
+	}
+	s, authRepo, _, mailer := newTestScheduler([]auth.User{
+		user(1, "slot@example.com"),
+		user(2, "noslot@example.com"),
+		user(3, "other@example.com"),
+	})
+	// Only user 1 has a slot since the last send; the others aren't due for a day
+	hourAgo := time.Now().UTC().Add(-time.Hour)
+	authRepo.slots = map[int][]time.Time{1: {time.Date(0, 1, 1, hourAgo.Hour(), hourAgo.Minute(), 0, 0, time.UTC)}}
+
+	s.runVerseDistribution(context.Background())
+
+	if authRepo.deliveryTimeBatches != 1 {
+		t.Errorf("expected delivery times loaded in 1 query, got %d", authRepo.deliveryTimeBatches)
+	}
+	if len(mailer.templatesFor("slot@example.com")) == 0 {
+		t.Error("expected the user with a due slot to be sent a verse")
+	}
+	if len(mailer.templatesFor("noslot@example.com")) > 0 || len(mailer.templatesFor("other@example.com")) > 0 {
+		t.Error("expected users without slots to wait for their pace interval")
+	}
+}
+
+func TestIsVerseDueUsesPaceInterval(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	slot := []time.Time{time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name     string
+		pace     string
+		days     int
+		sentAgo  time.Duration
+		slots    []time.Time
+		wantSent bool
+	}{
+		{"daily within the day", "daily", 0, time.Hour, nil, false},
+		{"daily after a day", "daily", 0, 24 * time.Hour, nil, true},
+		{"weekly after six days", "weekly", 0, 6 * 24 * time.Hour, nil, false},
+		{"monthly after 29 days", "monthly", 0, 29 * 24 * time.Hour, nil, false},
+		{"monthly after 30 days", "monthly", 0, 30 * 24 * time.Hour, nil, true},
+		{"every 3 days after 2 days", "every_n_days", 3, 2 * 24 * time.Hour, nil, false},
+		{"every 3 days after 3 days", "every_n_days", 3, 3 * 24 * time.Hour, nil, true},
+		{"every 3 days at a slot before 3 days", "every_n_days", 3, 2 * 24 * time.Hour, slot, false},
+		{"every n days without n", "every_n_days", 0, 90 * 24 * time.Hour, nil, false},
+		{"unknown pace", "hourly", 0, 90 * 24 * time.Hour, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastSent := now.Add(-tt.sentAgo)
+			user := auth.User{VersePace: tt.pace, PaceDays: tt.days, LastVerseSentAt: &lastSent}
+			if got := isVerseDue(user, tt.slots, now); got != tt.wantSent {
+				t.Errorf("expected due = %v, got %v", tt.wantSent, got)
+			}
+		})
+	}
+}
+
+// The dashboard shows a new verse exactly when the scheduler would send one,
+// for every pace and on both sides of its interval.
+func TestDashboardAndSchedulerAgreeOnDueVerses(t *testing.T) {
+	paces := []struct {
+		pace string
+		days int
+	}{
+		{auth.PaceDaily, 0},
+		{auth.PaceWeekly, 0},
+		{auth.PaceMonthly, 0},
+		{auth.PaceEveryNDays, 3},
+	}
+	offsets := []time.Duration{-time.Hour, -time.Minute, time.Minute, time.Hour}
+
+	for _, p := range paces {
+		interval, _ := auth.PaceInterval(p.pace, p.days)
+		for _, offset := range offsets {
+			elapsed := interval + offset
+			t.Run(fmt.Sprintf("%s after %s", p.pace, elapsed), func(t *testing.T) {
+				last := time.Now().Add(-elapsed)
+
+				s, repo := newDashboardService([]VerseHistory{{VerseID: 2, DeliveredAt: last, Verse: Verse{ID: 2}}})
+				profile := s.authRepo.(*fakeAuthRepo).profiles[1]
+				profile.VersePace, profile.PaceDays = p.pace, p.days
+				if _, _, _, _, err := s.GetUserDashboard(context.Background(), 1); err != nil {
+					t.Fatalf("GetUserDashboard returned error: %v", err)
+				}
+				dashboardDue := len(repo.delivered[1]) > 0
+
+				user := auth.User{VersePace: p.pace, PaceDays: p.days, LastVerseSentAt: &last}
+				schedulerDue := isVerseDue(user, nil, time.Now())
+
+				if dashboardDue != schedulerDue {
+					t.Errorf("dashboard due = %v but scheduler due = %v", dashboardDue, schedulerDue)
+				}
+				if want := offset > 0; schedulerDue != want {
+					t.Errorf("expected due = %v", want)
+				}
+			})
+		}
+	}
+}
+
+// nilVerseRepo hands back no verse and no error, as a misbehaving repository might.
+type nilVerseRepo struct {
+	*fakeVerseRepo
+}
+
+func (nilVerseRepo) GetRandomVerse(ctx context.Context, userID int, translation string) (*Verse, error) {
+	return nil, nil
+}
+
+func TestRunVerseDistributionSkipsNilVerse(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, authRepo, verseRepo, mailer := newTestScheduler([]auth.User{
+		{ID: 1, Email: "daily@example.com", VersePace: "daily", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+	})
+	s.repo = nilVerseRepo{verseRepo}
+
+	s.runVerseDistribution(context.Background())
+
+	if got := mailer.templatesFor("daily@example.com"); len(got) != 0 {
+		t.Errorf("expected no email without a verse, got %v", got)
+	}
+	if _, ok := authRepo.lastSent[1]; ok {
+		t.Error("expected last sent time to be left alone")
+	}
+}
+
+func TestCatchUpMissedSendsWithinGrace(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	deliverable := func(id int, email string, lastSent *time.Time) auth.User {
+		return auth.User{ID: id, Email: email, VersePace: "daily", LastVerseSentAt: lastSent,
+			IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true}
+	}
+
+	s, authRepo, _, mailer := newTestScheduler([]auth.User{
+		// Slot passed an hour ago while the server was down
+		deliverable(1, "missed@example.com", ago(25*time.Hour)),
+		// Slot passed ten hours ago, outside the grace window
+		deliverable(2, "stale@example.com", ago(34*time.Hour)),
+		// Already sent for the latest slot
+		deliverable(3, "sent@example.com", ago(30*time.Minute)),
+	})
+	s.cfg.CatchUpGrace = 6 * time.Hour
+	authRepo.slots = map[int][]time.Time{
+		1: {now.Add(-time.Hour)},
+		2: {now.Add(-10 * time.Hour)},
+		3: {now.Add(-time.Hour)},
+	}
+
+	s.catchUpMissedSends(context.Background(), now)
+
+	if got := mailer.templatesFor("missed@example.com"); len(got) != 1 {
+		t.Errorf("expected the missed send to be caught up, got %v", got)
+	}
+	if got := mailer.templatesFor("stale@example.com"); len(got) != 0 {
+		t.Errorf("expected a send missed beyond the grace window to wait for the next run, got %v", got)
+	}
+	if got := mailer.templatesFor("sent@example.com"); len(got) != 0 {
+		t.Errorf("expected no repeat send, got %v", got)
+	}
+
+	// With the catch-up off nothing is sent
+	s, authRepo, _, mailer = newTestScheduler([]auth.User{deliverable(1, "missed@example.com", ago(25*time.Hour))})
+	authRepo.slots = map[int][]time.Time{1: {now.Add(-time.Hour)}}
+	s.catchUpMissedSends(context.Background(), now)
+	if got := mailer.templatesFor("missed@example.com"); len(got) != 0 {
+		t.Errorf("expected no catch-up with SCHEDULER_CATCHUP_GRACE at 0, got %v", got)
+	}
+}
+
+func TestStartSchedulerRunsOnCron(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, _, _, mailer := newTestScheduler([]auth.User{
+		{ID: 1, Email: "daily@example.com", VersePace: "daily", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+	})
+	// cron's finest step is a second
+	schedule, err := ParseSchedulerCron("@every 1s")
+	if err != nil {
+		t.Fatalf("ParseSchedulerCron returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.StartScheduler(ctx, schedule)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(mailer.templatesFor("daily@example.com")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a cron-triggered run to send the verse")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop after cancel")
+	}
+}
+
+func TestRunVerseDistributionPausesAtVerseGoal(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	user := auth.User{ID: 1, Email: "daily@example.com", UserName: "ada", VersePace: "daily", VerseGoal: 2,
+		IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true}
+	s, authRepo, verseRepo, mailer := newTestScheduler([]auth.User{user})
+	// One verse down, one to go
+	verseRepo.delivered = map[int][]int{1: {2}}
+
+	s.runVerseDistribution(context.Background())
+
+	got := mailer.templatesFor("daily@example.com")
+	if len(got) != 2 || got[0] != "verse.html" || got[1] != "verse_goal.html" {
+		t.Fatalf("expected the last verse then the goal email, got %v", got)
+	}
+	if !authRepo.reached[1] {
+		t.Fatal("expected delivery to be paused at the goal")
+	}
+	if progress := s.GetGoalProgress(context.Background(), user); progress == nil || progress.Current != 2 || progress.Target != 2 {
+		t.Errorf("expected progress 2/2, got %+v", progress)
+	}
+
+	// Later runs neither send verses nor congratulate again
+	s.runVerseDistribution(context.Background())
+	if got := mailer.templatesFor("daily@example.com"); len(got) != 2 {
+		t.Errorf("expected no more emails while paused, got %v", got)
+	}
+}
+
+func TestIsVerseDueSkipsUsersPausedAtGoal(t *testing.T) {
+	now := time.Now()
+	user := auth.User{VersePace: "daily", VerseGoal: 5, GoalReachedAt: &now}
+
+	if isVerseDue(user, nil, now) {
+		t.Error("expected no verse while paused at the goal")
+	}
+
+	user.VerseGoal = 0
+	if !isVerseDue(user, nil, now) {
+		t.Error("expected clearing the goal to lift the pause")
+	}
+}
+
+func TestVariantIndexIsStablePerUser(t *testing.T) {
+	counts := make([]int, 3)
+	for userID := 1; userID <= 3000; userID++ {
+		i := variantIndex(userID, 3)
+		if i < 0 || i >= 3 {
+			t.Fatalf("user %d: index %d out of range", userID, i)
+		}
+		for n := 0; n < 3; n++ {
+			if again := variantIndex(userID, 3); again != i {
+				t.Fatalf("user %d moved from variant %d to %d", userID, i, again)
+			}
+		}
+		counts[i]++
+	}
+
+	// Roughly a third each, so variants are compared on similar audiences
+	for i, c := range counts {
+		if c < 800 || c > 1200 {
+			t.Errorf("variant %d got %d of 3000 users", i, c)
+		}
+	}
+}
+
+func TestSendVerseUsesSubjectVariant(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	// One user in each of the two buckets
+	ids := map[int]int{}
+	for id := 1; len(ids) < 2; id++ {
+		if _, ok := ids[variantIndex(id, 2)]; !ok {
+			ids[variantIndex(id, 2)] = id
+		}
+	}
+	newUser := func(id int, email string) auth.User {
+		return auth.User{ID: id, Email: email, UserName: "ada", VersePace: "daily",
+			IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true}
+	}
+
+	s, _, verseRepo, mailer := newTestScheduler([]auth.User{
+		newUser(ids[0], "control@example.com"),
+		newUser(ids[1], "variant@example.com"),
+	})
+	s.cfg.SubjectVariants = []string{"{name}, your {pace} verse is here"}
+
+	s.runVerseDistribution(context.Background())
+
+	subjects := map[string]string{}
+	for _, m := range mailer.sent {
+		subjects[m.To] = m.Subject
+	}
+	if got := subjects["control@example.com"]; got != "Your daily Memoryverse is" {
+		t.Errorf("expected the control subject, got %q", got)
+	}
+	if got := subjects["variant@example.com"]; got != "ada, your daily verse is here" {
+		t.Errorf("expected the filled-in variant subject, got %q", got)
+	}
+
+	recorded := map[int]string{}
+	for token, userID := range verseRepo.sends {
+		recorded[userID] = verseRepo.variants[token]
+	}
+	if recorded[ids[0]] != controlSubjectVariant || recorded[ids[1]] != "v1" {
+		t.Errorf("expected sends recorded as control and v1, got %v", recorded)
+	}
+}
+
+func TestSchedulerStatusReflectsRecordedRun(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	s, _, _, _ := newTestScheduler([]auth.User{
+		{ID: 1, Email: "daily@example.com", VersePace: "daily", IsSubscribed: true, IsProfileCompleted: true, EnableNotification: true, IsEmailNotification: true},
+		{ID: 2, Email: "off@example.com", VersePace: "daily"},
+	})
+	s.scheduler.started(time.Now(), time.Hour)
+
+	if st := s.SchedulerStatus(); st.LastRunStartedAt != nil || st.Stale {
+		t.Fatalf("expected no runs and not stale yet, got %+v", st)
+	}
+
+	before := time.Now()
+	s.runVerseDistribution(context.Background())
+
+	st := s.SchedulerStatus()
+	if st.Running || st.LastRunStartedAt == nil || st.LastRunFinishedAt == nil {
+		t.Fatalf("expected a finished run, got %+v", st)
+	}
+	if st.LastRunStartedAt.Before(before) || st.LastRunFinishedAt.Before(*st.LastRunStartedAt) {
+		t.Errorf("unexpected run times %v to %v", st.LastRunStartedAt, st.LastRunFinishedAt)
+	}
+	if st.LastRunUsers != 2 || st.IntervalSec != 3600 || st.Stale {
+		t.Errorf("expected 2 users, an hour interval and not stale, got %+v", st)
+	}
+
+	var metrics strings.Builder
+	st.writePrometheus(&metrics)
+	for _, want := range []string{
+		"# TYPE verse_scheduler_last_run_users gauge\nverse_scheduler_last_run_users 2\n",
+		"verse_scheduler_interval_seconds 3600\n",
+		"verse_scheduler_stale 0\n",
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, metrics.String())
+		}
+	}
+}
+
+func TestSchedulerStatusStaleAfterTwoIntervals(t *testing.T) {
+	start := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	m := newSchedulerMonitor()
+	m.started(start, time.Hour)
+
+	if m.status(start.Add(2 * time.Hour)).Stale {
+		t.Error("expected exactly two intervals without a run not to be stale")
+	}
+	if !m.status(start.Add(2*time.Hour + time.Minute)).Stale {
+		t.Error("expected no run for over two intervals to be stale")
+	}
+
+	// A run that starts but never finishes goes stale too
+	m.runStarted(start.Add(3 * time.Hour))
+	if st := m.status(start.Add(4 * time.Hour)); st.Stale || !st.Running {
+		t.Errorf("expected a fresh running run, got %+v", st)
+	}
+	if st := m.status(start.Add(6 * time.Hour)); !st.Stale {
+		t.Errorf("expected a stuck run to be stale, got %+v", st)
+	}
+}
+
+func TestScheduleInterval(t *testing.T) {
+	now := time.Date(2025, 3, 12, 6, 59, 30, 0, time.UTC)
+	for spec, want := range map[string]time.Duration{
+		"@hourly":      time.Hour,
+		"@daily":       24 * time.Hour,
+		"*/15 * * * *": 15 * time.Minute,
+		"0 0 31 2 *":   0,
+	} {
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			t.Fatalf("%s: ParseStandard returned error: %v", spec, err)
+		}
+		if got := scheduleInterval(sched, now); got != want {
+			t.Errorf("%s: expected %s, got %s", spec, want, got)
+		}
+	}
+}
+
+func TestParseSchedulerCron(t *testing.T) {
+	for _, spec := range []string{"0 6 * * *", "*/15 * * * *", "@daily", "@every 1h"} {
+		if _, err := ParseSchedulerCron(spec); err != nil {
+			t.Errorf("%s: unexpected error: %v", spec, err)
+		}
+	}
+	// Malformed, six fields, out of range, and a date that never comes
+	for _, spec := range []string{"", "every day", "0 0 6 * * *", "0 24 * * *", "0 0 31 2 *"} {
+		if _, err := ParseSchedulerCron(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -6,24 +6,65 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"mime"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
 	"github.com/taiwoajasa245/memory-verse-api/internal/mail"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/cache"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
 )
 
 type MemoryVerseService struct {
 	repo     MemoryVerseRepo
 	authRepo auth.Repository
-	mail     *mail.Mailer
+	mail     mail.Sender
+	cfg      *config.Config
+
+	// Slow-changing, read-heavy lookups served from memory for cfg.VerseCacheTTL.
+	// They live in this process only: InvalidateVerseCaches clears them here,
+	// and other instances catch up once their entries expire.
+	popular      *cache.Cache[[]Verse]
+	translations *TranslationsCache
+	// recent is shared across users, so favourite flags are added per request
+	recent *cache.Cache[RecentVersesPage]
+	// verseOfDay holds one verse per UTC date, and dailyStreak the streak
+	// counted once that date's verse is recorded
+	verseOfDay  *cache.Cache[Verse]
+	dailyStreak *cache.Cache[int]
+
+	scheduler *schedulerMonitor
 }
 
-func NewMemoryVerseService(repo MemoryVerseRepo, authRepo auth.Repository, mail *mail.Mailer) MemoryVerseService {
+// recentVersesCacheTTL keeps the recent feed fresh while absorbing bursts.
+const recentVersesCacheTTL = 30 * time.Second
+
+func NewMemoryVerseService(repo MemoryVerseRepo, authRepo auth.Repository, mail mail.Sender, cfg *config.Config) MemoryVerseService {
+	// Emails build links from cfg, so a nil one falls back to the defaults
+	if cfg == nil {
+		cfg = &config.Config{AppBaseURL: config.DefaultAppBaseURL, VerseCacheTTL: config.DefaultVerseCacheTTL}
+	}
+	cacheTTL := cfg.VerseCacheTTL
+
 	return MemoryVerseService{
-		repo:     repo,
-		authRepo: authRepo,
-		mail:     mail,
+		repo:         repo,
+		authRepo:     authRepo,
+		mail:         mail,
+		cfg:          cfg,
+		popular:      cache.New[[]Verse](cacheTTL),
+		translations: NewTranslationsCache(repo.GetTranslations, cacheTTL),
+		recent:       cache.New[RecentVersesPage](recentVersesCacheTTL),
+		verseOfDay:   cache.New[Verse](24 * time.Hour),
+		dailyStreak:  cache.New[int](24 * time.Hour),
+		scheduler:    newSchedulerMonitor(),
 	}
 }
 
@@ -33,36 +74,25 @@ func (s *MemoryVerseService) GetUserDashboard(ctx context.Context, userID int) (
 		log.Printf("error fetching user: %v", err)
 		return nil, nil, nil, nil, errors.New("user not found")
 	}
-
-	if !user.IsProfileCompleted {
-		return nil, nil, nil, nil, errors.New("please complete your profile to receive memory verses")
-	}
+	user.CompletionPercent = auth.ComputeProfileCompletion(user, profile)
 
 	pace := strings.ToLower(profile.VersePace)
-	if pace != "daily" && pace != "weekly" {
+	interval, ok := auth.PaceInterval(pace, profile.PaceDays)
+	if !ok {
 		return nil, nil, nil, nil, fmt.Errorf("invalid verse pace: %s", pace)
 	}
 
+	// A user with no history yet simply gets their first verse below
 	lastDelivered, err := s.repo.GetLastDeliveredVerse(ctx, userID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		lastDelivered = nil
+	} else if err != nil {
 		log.Printf("error fetching last delivered: %v", err)
 		return nil, nil, nil, nil, err
 	}
 
-	fmt.Println("last delivered: ", lastDelivered)
-
-	now := time.Now()
-	shouldSend := false
-
-	switch pace {
-	case "daily":
-		shouldSend = lastDelivered == nil || now.Sub(lastDelivered.DeliveredAt).Hours() <= 24
-	case "weekly":
-		shouldSend = lastDelivered == nil || now.Sub(lastDelivered.DeliveredAt).Hours() >= 168
-	}
-
 	// Always load user notes once
-	notes, err := s.repo.GetUserNotes(ctx, userID)
+	notes, err := s.repo.GetUserNotes(ctx, userID, SortCreatedDesc)
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("failed to get user notes: %w", err)
 	}
@@ -73,48 +103,1147 @@ func (s *MemoryVerseService) GetUserDashboard(ctx context.Context, userID int) (
 		return nil, nil, nil, nil, fmt.Errorf("failed to get user verse history: %w", err)
 	}
 
-	// If shouldSend, fetch a new verse and save it
-	if shouldSend {
-		verse, err := s.repo.GetRandomVerse(ctx, userID, profile.BibleTranslation)
+	now := time.Now()
+	user.Streak = currentStreak(pace, profile.PaceDays, histories, now)
+	if user.IsSubscribed && !user.GoalPaused() {
+		user.NextVerseAt = nextVerseAt(interval, user.LastVerseSentAt, profile.SelectedTimes, user.SnoozedUntil, now)
+	}
+
+	// While paused at the verse goal, the last delivered verse is shown again
+	if lastDelivered != nil && user.GoalPaused() {
+		verse := lastDelivered.Verse
+		verse.Prompt = s.reflectionPrompt(ctx, userID, verse.ID, now)
+		return user, &verse, notes, histories, nil
+	}
+
+	verse, fresh, err := s.selectVerse(ctx, userID, profile, lastDelivered, now)
+	if err != nil {
+		log.Printf("error fetching random verse: %v", err)
+		return nil, nil, nil, nil, err
+	}
+
+	// record that we sent it, unless a concurrent load already recorded its
+	// own pick, in which case show that one. Nothing is recorded during
+	// maintenance; the next load after it picks again.
+	if fresh && !auth.InMaintenance(ctx) {
+		var after *time.Time
+		if lastDelivered != nil {
+			after = &lastDelivered.DeliveredAt
+		}
+		claimed, err := s.repo.ClaimDeliveredVerse(ctx, userID, verse.ID, after)
+		if err != nil {
+			log.Printf("could not record delivered verse %d for %d: %v", verse.ID, userID, err)
+		} else if claimed != nil {
+			verse = claimed
+		}
+	}
+
+	verse.Prompt = s.reflectionPrompt(ctx, userID, verse.ID, now)
+	return user, verse, notes, histories, nil
+}
+
+// selectVerse picks the verse a user gets at the given time: the last
+// delivered one while still inside the pace window, otherwise a fresh one.
+// It writes nothing; fresh tells the caller the verse is new and should be
+// recorded if it is actually delivered.
+func (s *MemoryVerseService) selectVerse(ctx context.Context, userID int, profile *auth.CompleteProfileRequest, lastDelivered *VerseHistory, at time.Time) (*Verse, bool, error) {
+	if lastDelivered != nil && !paceElapsed(strings.ToLower(profile.VersePace), profile.PaceDays, lastDelivered.DeliveredAt, at) {
+		verse := lastDelivered.Verse
+		return &verse, false, nil
+	}
+
+	verse, err := s.repo.GetRandomVerse(ctx, userID, profile.BibleTranslation)
+	if err != nil {
+		return nil, false, err
+	}
+	if verse == nil {
+		return nil, false, ErrNotFound
+	}
+	return verse, true, nil
+}
+
+// UpcomingService previews the user's next delivery: when it goes out and
+// which verse(s) it would carry. Nothing is recorded as delivered.
+func (s *MemoryVerseService) UpcomingService(ctx context.Context, userID int) (*UpcomingDelivery, error) {
+	user, profile, err := s.authRepo.GetUserWithProfile(ctx, userID)
+	if err != nil {
+		log.Printf("error fetching user: %v", err)
+		return nil, errors.New("user not found")
+	}
+
+	pace := strings.ToLower(profile.VersePace)
+	interval, ok := auth.PaceInterval(pace, profile.PaceDays)
+	if !ok {
+		return nil, fmt.Errorf("invalid verse pace: %s", pace)
+	}
+
+	upcoming := &UpcomingDelivery{Pace: pace, Verses: []Verse{}}
+	switch {
+	case !user.IsSubscribed:
+		upcoming.PausedReason = "unsubscribed"
+		return upcoming, nil
+	case user.GoalPaused():
+		upcoming.PausedReason = "goal_reached"
+		return upcoming, nil
+	}
+
+	now := time.Now()
+	upcoming.NextSendAt = nextVerseAt(interval, user.LastVerseSentAt, profile.SelectedTimes, user.SnoozedUntil, now)
+
+	if pace == auth.PaceWeekly {
+		verses, err := s.repo.GetWeeklyVerses(ctx, userID, weeklyDigestSize)
+		if err != nil {
+			return nil, err
+		}
+		upcoming.Verses = append(upcoming.Verses, verses...)
+		return upcoming, nil
+	}
+
+	lastDelivered, err := s.repo.GetLastDeliveredVerse(ctx, userID)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		lastDelivered = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	at := now
+	if upcoming.NextSendAt != nil {
+		at = *upcoming.NextSendAt
+	}
+	verse, _, err := s.selectVerse(ctx, userID, profile, lastDelivered, at)
+	if err != nil {
+		return nil, err
+	}
+	upcoming.Verses = append(upcoming.Verses, *verse)
+	return upcoming, nil
+}
+
+// RecordEmailOpenService records a tracking pixel load. Unknown or malformed
+// tokens are ignored so the pixel can always be served.
+func (s *MemoryVerseService) RecordEmailOpenService(ctx context.Context, token string) {
+	if !trackingTokenPattern.MatchString(token) || auth.InMaintenance(ctx) {
+		return
+	}
+	if _, err := s.repo.RecordEmailOpen(ctx, token); err != nil {
+		log.Printf("could not record email open: %v", err)
+	}
+}
+
+var ErrInvalidRedirect = errors.New("redirect destination is not allowed")
+
+// TrackClickService records a tracked link click and returns the destination
+// to redirect to. Only http(s) URLs on the app or API host are allowed, so the
+// endpoint can't be used as an open redirect; unknown tokens still redirect.
+func (s *MemoryVerseService) TrackClickService(ctx context.Context, token, to string) (string, error) {
+	dest, err := url.Parse(to)
+	if err != nil || (dest.Scheme != "https" && dest.Scheme != "http") || !s.isAllowedRedirectHost(dest.Host) {
+		return "", ErrInvalidRedirect
+	}
+
+	if trackingTokenPattern.MatchString(token) && !auth.InMaintenance(ctx) {
+		// Only the page is recorded: the query can carry secrets such as the
+		// unsubscribe token
+		recorded := url.URL{Scheme: dest.Scheme, Host: dest.Host, Path: dest.Path}
+		if _, err := s.repo.RecordEmailClick(ctx, token, recorded.String()); err != nil {
+			log.Printf("could not record email click: %v", err)
+		}
+	}
+
+	return dest.String(), nil
+}
+
+// isAllowedRedirectHost reports whether host is the configured app or API host.
+func (s *MemoryVerseService) isAllowedRedirectHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, base := range []string{s.cfg.AppBaseURL, s.cfg.ApiBaseURL} {
+		if u, err := url.Parse(base); err == nil && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackingTokenPattern matches util.GenerateTrackingToken output.
+var trackingTokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// GetEmailOpenStatsService returns open counts with the open rate filled in.
+func (s *MemoryVerseService) GetEmailOpenStatsService(ctx context.Context) (*EmailOpenStats, error) {
+	stats, err := s.repo.GetEmailOpenStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Sent > 0 {
+		stats.OpenRatePct = math.Round(float64(stats.Opened)*1000/float64(stats.Sent)) / 10
+	}
+	return stats, nil
+}
+
+// GetSubjectVariantStatsService returns per-variant open rates for the verse
+// email subject test.
+func (s *MemoryVerseService) GetSubjectVariantStatsService(ctx context.Context) ([]SubjectVariantStats, error) {
+	stats, err := s.repo.GetSubjectVariantStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		if stats[i].Sent > 0 {
+			stats[i].OpenRatePct = math.Round(float64(stats[i].Opened)*1000/float64(stats[i].Sent)) / 10
+		}
+	}
+	if stats == nil {
+		stats = []SubjectVariantStats{}
+	}
+	return stats, nil
+}
+
+// impressionWriteTimeout bounds the background write of one impression.
+const impressionWriteTimeout = 5 * time.Second
+
+// RecordImpression logs that the user was shown a verse on their dashboard.
+// The write happens in the background and outlives the request, so a slow
+// or failing insert never holds up or fails the dashboard.
+func (s *MemoryVerseService) RecordImpression(ctx context.Context, userID, verseID int) {
+	if auth.InMaintenance(ctx) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), impressionWriteTimeout)
+	go func() {
+		defer cancel()
+		if err := s.repo.RecordVerseImpression(ctx, userID, verseID); err != nil {
+			log.Printf("could not record impression of verse %d for %d: %v", verseID, userID, err)
+		}
+	}()
+}
+
+// Impression report window: ?days= defaults to a month and is capped at a year
+const (
+	defaultImpressionDays = 30
+	maxImpressionDays     = 365
+	topImpressionVerses   = 10
+)
+
+// GetImpressionStatsService summarises dashboard views over the last days.
+func (s *MemoryVerseService) GetImpressionStatsService(ctx context.Context, days int) (*ImpressionStats, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	stats, err := s.repo.GetImpressionStats(ctx, since, topImpressionVerses)
+	if err != nil {
+		return nil, err
+	}
+	if stats.TopVerses == nil {
+		stats.TopVerses = []VerseImpressions{}
+	}
+	return stats, nil
+}
+
+// GetVerseCoverageService reports verse counts per translation and topic
+// demand, flagging translations users have chosen that have no verses.
+func (s *MemoryVerseService) GetVerseCoverageService(ctx context.Context) (*VerseCoverage, error) {
+	translations, err := s.repo.GetTranslationCoverage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := s.repo.GetTopicCoverage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := &VerseCoverage{
+		Translations:        make([]TranslationCoverage, 0, len(translations)),
+		Topics:              topics,
+		MissingTranslations: []string{},
+	}
+	for _, t := range translations {
+		t.Missing = t.VerseCount == 0 && t.UserCount > 0
+		if t.Missing {
+			coverage.MissingTranslations = append(coverage.MissingTranslations, t.Translation)
+		}
+		coverage.Translations = append(coverage.Translations, t)
+	}
+	if coverage.Topics == nil {
+		coverage.Topics = []TopicCoverage{}
+	}
+	return coverage, nil
+}
+
+// DefaultReflectionPrompt is shown for verses without prompts of their own.
+const DefaultReflectionPrompt = "How does this verse apply to you today?"
+
+// reflectionPrompt picks today's prompt for the verse, falling back to the
+// generic one when the verse has none or they can't be loaded.
+func (s *MemoryVerseService) reflectionPrompt(ctx context.Context, userID, verseID int, now time.Time) string {
+	prompts, err := s.repo.GetVersePrompts(ctx, verseID)
+	if err != nil {
+		log.Printf("could not load prompts for verse %d: %v", verseID, err)
+	}
+	return selectPrompt(prompts, userID, now)
+}
+
+// selectPrompt rotates through prompts once per UTC day, offset by user so
+// people sharing a verse don't all see the same question.
+func selectPrompt(prompts []string, userID int, now time.Time) string {
+	if len(prompts) == 0 {
+		return DefaultReflectionPrompt
+	}
+	day := int(now.UTC().Unix() / 86400)
+	return prompts[(day+userID)%len(prompts)]
+}
+
+// SetVersePromptsService replaces a verse's prompts, dropping blank ones.
+func (s *MemoryVerseService) SetVersePromptsService(ctx context.Context, verseID int, prompts []string) ([]string, error) {
+	cleaned := make([]string, 0, len(prompts))
+	for _, p := range prompts {
+		if p = strings.TrimSpace(p); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+
+	if err := s.repo.SetVersePrompts(ctx, verseID, cleaned); err != nil {
+		return nil, err
+	}
+	return cleaned, nil
+}
+
+// GetVerseCommentaryService returns a verse's commentary. A verse without
+// commentary is reported as ErrNotFound, the same as a missing verse.
+func (s *MemoryVerseService) GetVerseCommentaryService(ctx context.Context, verseID int) (*VerseCommentary, error) {
+	commentary, err := s.repo.GetVerseCommentary(ctx, verseID)
+	if err != nil {
+		return nil, err
+	}
+	if commentary == "" {
+		return nil, ErrNotFound
+	}
+	return &VerseCommentary{VerseID: verseID, Commentary: commentary}, nil
+}
+
+// SetVerseCommentaryService replaces a verse's commentary; blank text clears it.
+func (s *MemoryVerseService) SetVerseCommentaryService(ctx context.Context, verseID int, commentary string) (*VerseCommentary, error) {
+	commentary = strings.TrimSpace(commentary)
+	if err := s.repo.SetVerseCommentary(ctx, verseID, commentary); err != nil {
+		return nil, err
+	}
+	return &VerseCommentary{VerseID: verseID, Commentary: commentary}, nil
+}
+
+var ErrInvalidVerse = errors.New("verse needs a reference, text and translation")
+
+// cleanNewVerse sanitises an added verse, keeping the text as it arrived in
+// RawVerse. A verse left with nothing to show is rejected.
+func cleanNewVerse(v NewVerse) (NewVerse, error) {
+	v.RawVerse = v.Verse
+	v.Verse = sanitizeVerseText(v.Verse)
+	v.Reference = strings.Join(strings.Fields(v.Reference), " ")
+	v.Translation = strings.ToUpper(strings.TrimSpace(v.Translation))
+	if v.Reference == "" || v.Verse == "" || v.Translation == "" {
+		return v, ErrInvalidVerse
+	}
+	return v, nil
+}
+
+// CreateVerseService adds one sanitised verse to the catalogue.
+func (s *MemoryVerseService) CreateVerseService(ctx context.Context, verse NewVerse) (*Verse, error) {
+	verse, err := cleanNewVerse(verse)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.repo.CreateVerse(ctx, verse)
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateVerseCaches()
+	return created, nil
+}
+
+// ImportVersesService sanitises and adds a batch of verses. Nothing is added
+// if any verse is invalid; the error names the first one.
+func (s *MemoryVerseService) ImportVersesService(ctx context.Context, verses []NewVerse) (int, error) {
+	cleaned := make([]NewVerse, len(verses))
+	for i, v := range verses {
+		c, err := cleanNewVerse(v)
 		if err != nil {
-			log.Printf("error fetching random verse: %v", err)
-			return nil, nil, nil, nil, err
+			return 0, fmt.Errorf("%w (verse %d)", err, i+1)
+		}
+		cleaned[i] = c
+	}
+	n, err := s.repo.BulkInsertVerses(ctx, cleaned)
+	if err != nil {
+		return 0, err
+	}
+	s.InvalidateVerseCaches()
+	return n, nil
+}
+
+// guestHistoryWindow is how long a verse is kept from repeating for a guest.
+const guestHistoryWindow = 24 * time.Hour
+
+// guestIDPattern bounds client-chosen guest IDs to something safe to store.
+var guestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// GetGuestVerseService returns a random verse for a signed-out visitor. With
+// a valid guestID, verses served to it recently are skipped until none are
+// left; an invalid or missing guestID just gets a random verse.
+func (s *MemoryVerseService) GetGuestVerseService(ctx context.Context, guestID, translation string) (*Verse, error) {
+	translation = strings.ToUpper(strings.TrimSpace(translation))
+	if !guestIDPattern.MatchString(guestID) {
+		guestID = ""
+	}
+
+	verse, err := s.repo.GetRandomGuestVerse(ctx, guestID, translation, time.Now().Add(-guestHistoryWindow))
+	if errors.Is(err, ErrNotFound) && guestID != "" {
+		// The guest has seen everything recently; start repeating
+		verse, err = s.repo.GetRandomGuestVerse(ctx, "", translation, time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if guestID != "" && !auth.InMaintenance(ctx) {
+		if err := s.repo.SaveGuestVerse(ctx, guestID, verse.ID); err != nil {
+			log.Printf("could not record guest verse %d: %v", verse.ID, err)
+		}
+	}
+
+	return verse, nil
+}
+
+// verseOfTheDay returns the verse for the given UTC date, picked at random
+// once and shared by everyone, or nil when there are no verses yet. The pick
+// is recorded in daily_verses for the archive; if another instance got there
+// first, its verse is used instead.
+func (s *MemoryVerseService) verseOfTheDay(ctx context.Context, day string) (*Verse, error) {
+	if verse, ok := s.verseOfDay.Get(day); ok {
+		return &verse, nil
+	}
+
+	verse, err := s.repo.GetRandomGuestVerse(ctx, "", "", time.Now())
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve an unrecorded pick during maintenance; the day is settled after
+	if auth.InMaintenance(ctx) {
+		return verse, nil
+	}
+
+	saved, err := s.repo.SaveDailyVerse(ctx, day, verse.ID)
+	if err != nil {
+		// Serve this pick but don't cache it, so the next request adopts
+		// whatever the day was recorded with
+		log.Printf("could not record verse of the day for %s: %v", day, err)
+		return verse, nil
+	}
+	s.verseOfDay.Set(day, *saved)
+	return saved, nil
+}
+
+// GetFavouriteSummaryService counts the user's favourites per translation.
+func (s *MemoryVerseService) GetFavouriteSummaryService(ctx context.Context, userID int) (*FavouriteSummary, error) {
+	groups, err := s.repo.GetFavouriteSummary(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favourite summary: %w", err)
+	}
+
+	summary := &FavouriteSummary{Translations: groups}
+	if summary.Translations == nil {
+		summary.Translations = []TranslationFavourites{}
+	}
+	for _, g := range groups {
+		summary.Total += g.Count
+	}
+	return summary, nil
+}
+
+// maxDailyArchiveDays caps how many days one archive request may span.
+const maxDailyArchiveDays = 90
+
+// GetDailyVerseArchiveService lists past verses of the day within rng,
+// newest first.
+func (s *MemoryVerseService) GetDailyVerseArchiveService(ctx context.Context, rng HistoryRange, limit, offset int) ([]PublicDailyVerse, int, error) {
+	days, total, err := s.repo.GetDailyVerseArchive(ctx, rng, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get daily verse archive: %w", err)
+	}
+	if days == nil {
+		days = []PublicDailyVerse{}
+	}
+	return days, total, nil
+}
+
+// DailyVerse returns today's verse of the day, or nil when there are no
+// verses yet.
+func (s *MemoryVerseService) DailyVerse(ctx context.Context) (*auth.DailyVerse, error) {
+	verse, err := s.verseOfTheDay(ctx, time.Now().UTC().Format(time.DateOnly))
+	if err != nil || verse == nil {
+		return nil, err
+	}
+
+	return &auth.DailyVerse{
+		Reference:   verse.Reference,
+		Text:        verse.Verse,
+		Translation: verse.Translation,
+	}, nil
+}
+
+// PublicDailyVerseService returns today's verse of the day for embedding on
+// other sites, or ErrNotFound when there are no verses yet. It includes when
+// the verse changes and how many days in a row there has been one.
+func (s *MemoryVerseService) PublicDailyVerseService(ctx context.Context) (*PublicDailyVerse, error) {
+	now := time.Now().UTC()
+	day := now.Format(time.DateOnly)
+	verse, err := s.verseOfTheDay(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	if verse == nil {
+		return nil, ErrNotFound
+	}
+
+	streak := s.dailyVerseStreak(ctx, day)
+
+	nextRefresh := nextUTCMidnight(now)
+	return &PublicDailyVerse{
+		Reference:     verse.Reference,
+		Verse:         verse.Verse,
+		Translation:   verse.Translation,
+		Date:          day,
+		NextRefreshAt: &nextRefresh,
+		Streak:        streak,
+	}, nil
+}
+
+// dailyVerseStreak counts the days in a row up to day that have a verse of
+// the day. The count can't change once day's verse is recorded, so from then
+// on it is cached for the day. The streak is a nicety; on error it is 0 and
+// the widget still works.
+func (s *MemoryVerseService) dailyVerseStreak(ctx context.Context, day string) int {
+	if streak, ok := s.dailyStreak.Get(day); ok {
+		return streak
+	}
+
+	streak, err := s.repo.CountDailyVerseStreak(ctx, day)
+	if err != nil {
+		log.Printf("could not count the verse of the day streak: %v", err)
+		return 0
+	}
+	// Only a recorded verse is cached, so a pick that wasn't saved leaves the
+	// streak to be counted again
+	if _, recorded := s.verseOfDay.Get(day); recorded {
+		s.dailyStreak.Set(day, streak)
+	}
+	return streak
+}
+
+// nextUTCMidnight returns the start of the UTC day after now.
+func nextUTCMidnight(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+var ErrInvalidBatchIDs = errors.New("ids must be positive verse ids")
+
+// GetVersesByIDsService fetches verses in the order requested, dropping
+// duplicate and unknown ids.
+func (s *MemoryVerseService) GetVersesByIDsService(ctx context.Context, userID int, ids []int) ([]Verse, error) {
+	ids = uniqueVerseIDs(ids, nil)
+	if len(ids) > MaxBatchVerseIDs {
+		return nil, ErrInvalidBatchIDs
+	}
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, ErrInvalidBatchIDs
+		}
+	}
+
+	return s.repo.GetVersesByIDs(ctx, userID, ids)
+}
+
+// paceElapsed reports whether a full pace interval has passed since last.
+// The dashboard and the scheduler both decide with it, so they never
+// disagree about whether a verse is due. An unknown pace never elapses.
+func paceElapsed(pace string, days int, last, now time.Time) bool {
+	interval, ok := auth.PaceInterval(pace, days)
+	return ok && now.Sub(last) >= interval
+}
+
+// nextVerseAt estimates when the next verse goes out: one pace interval after
+// the last send, moved forward to the next delivery slot and past any snooze.
+func nextVerseAt(interval time.Duration, lastSent *time.Time, slots []time.Time, snoozedUntil *time.Time, now time.Time) *time.Time {
+	next := now.UTC()
+	if lastSent != nil {
+		if due := lastSent.UTC().Add(interval); due.After(next) {
+			next = due
+		}
+	}
+	if snoozedUntil != nil && snoozedUntil.After(next) {
+		next = snoozedUntil.UTC()
+	}
+	if len(slots) > 0 {
+		next = nextSlot(next, slots)
+	}
+	return &next
+}
+
+// nextSlot returns the first occurrence (at or after t) of any of the given
+// UTC times of day.
+func nextSlot(t time.Time, slots []time.Time) time.Time {
+	t = t.UTC()
+	var next time.Time
+	for _, slot := range slots {
+		occurrence := time.Date(t.Year(), t.Month(), t.Day(),
+			slot.Hour(), slot.Minute(), slot.Second(), 0, time.UTC)
+		if occurrence.Before(t) {
+			occurrence = occurrence.Add(24 * time.Hour)
+		}
+		if next.IsZero() || occurrence.Before(next) {
+			next = occurrence
 		}
+	}
+	return next
+}
 
-		// record that we sent it
-		_ = s.repo.SaveDeliveredVerse(ctx, userID, verse.ID)
-		return user, verse, notes, histories, nil
+// currentStreak counts consecutive pace periods (see pacePeriod) with at
+// least one delivered verse. The current period may still be pending, so a
+// streak ending in the previous period is kept alive.
+func currentStreak(pace string, days int, histories []VerseHistory, now time.Time) int {
+	delivered := make(map[time.Time]bool, len(histories))
+	for _, h := range histories {
+		delivered[pacePeriod(pace, days, h.DeliveredAt)] = true
 	}
 
-	// otherwise return last one
-	if lastDelivered != nil {
-		return user, &lastDelivered.Verse, notes, histories, nil
+	step := func(period time.Time) time.Time {
+		return pacePeriod(pace, days, period.AddDate(0, 0, -1))
 	}
 
-	return user, nil, notes, histories, fmt.Errorf("no verse available")
+	period := pacePeriod(pace, days, now)
+	if !delivered[period] {
+		period = step(period)
+	}
+
+	streak := 0
+	for delivered[period] {
+		streak++
+		period = step(period)
+	}
+	return streak
+}
+
+// pacePeriod returns the start of the UTC period containing t: the day, the
+// week starting Monday, the calendar month, or for every_n_days the n-day
+// block counted from the Unix epoch.
+func pacePeriod(pace string, days int, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch pace {
+	case auth.PaceWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case auth.PaceMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case auth.PaceEveryNDays:
+		if days > 1 {
+			sinceEpoch := int(day.Unix() / (24 * 60 * 60))
+			return day.AddDate(0, 0, -(sinceEpoch % days))
+		}
+	}
+	return day
 }
 
 func (s *MemoryVerseService) ToggleSubscribeUserService(ctx context.Context, userID int) error {
 	return s.authRepo.UnsubscribeUser(ctx, userID)
 }
 
-func (s *MemoryVerseService) ToggleFavouriteVerseService(ctx context.Context, userID int, verseID int) (bool, error) {
+// OneClickUnsubscribeService unsubscribes the user identified by an emailed unsubscribe token.
+func (s *MemoryVerseService) OneClickUnsubscribeService(ctx context.Context, token string) error {
+	userID, err := util.ValidateUnsubscribeToken(token)
+	if err != nil {
+		return err
+	}
+
+	return s.authRepo.SetSubscription(ctx, userID, false)
+}
+
+// MaxSnoozeWindow is the furthest into the future delivery can be paused.
+const MaxSnoozeWindow = 90 * 24 * time.Hour
+
+var ErrInvalidSnooze = errors.New("snooze date must be in the future and within 90 days")
+
+// SnoozeService pauses verse delivery for the user until the given time.
+func (s *MemoryVerseService) SnoozeService(ctx context.Context, userID int, until time.Time) error {
+	now := time.Now()
+	if !until.After(now) || until.Sub(now) > MaxSnoozeWindow {
+		return ErrInvalidSnooze
+	}
+
+	return s.authRepo.SetSnoozedUntil(ctx, userID, &until)
+}
+
+// UnsnoozeService resumes verse delivery immediately.
+func (s *MemoryVerseService) UnsnoozeService(ctx context.Context, userID int) error {
+	return s.authRepo.SetSnoozedUntil(ctx, userID, nil)
+}
+
+// GetGoalProgress counts the user's deliveries towards their verse goal. It
+// returns nil when the user has no goal or the count can't be loaded.
+func (s *MemoryVerseService) GetGoalProgress(ctx context.Context, user auth.User) *GoalProgress {
+	if user.VerseGoal <= 0 {
+		return nil
+	}
+
+	var since time.Time
+	if user.GoalStartedAt != nil {
+		since = *user.GoalStartedAt
+	}
+	count, err := s.repo.CountDeliveredVersesSince(ctx, user.ID, since)
+	if err != nil {
+		log.Printf("could not count verses towards the goal of %d: %v", user.ID, err)
+		return nil
+	}
+
+	return &GoalProgress{Current: min(count, user.VerseGoal), Target: user.VerseGoal, ReachedAt: user.GoalReachedAt}
+}
+
+// checkVerseGoal pauses delivery once the user has been sent as many verses
+// as their goal, emailing them the first time it happens. It reports whether
+// delivery is paused.
+func (s *MemoryVerseService) checkVerseGoal(ctx context.Context, user auth.User) bool {
+	if user.GoalPaused() {
+		return true
+	}
+
+	progress := s.GetGoalProgress(ctx, user)
+	if progress == nil || progress.Current < progress.Target {
+		return false
+	}
+
+	marked, err := s.authRepo.MarkVerseGoalReached(ctx, user.ID)
+	if err != nil {
+		log.Printf("could not pause delivery at the verse goal for %d: %v", user.ID, err)
+		return false
+	}
+	if !marked {
+		return true
+	}
+
+	data := map[string]interface{}{
+		"UserName":     user.UserName,
+		"Goal":         user.VerseGoal,
+		"DashboardURL": s.cfg.AppURL("/dashboard"),
+	}
+	if err := s.mail.SendHTML(user.Email, "🎉 You reached your verse goal", "verse_goal.html", data); err != nil {
+		log.Printf("Failed to send verse_goal.html to %s: %v", user.Email, err)
+	}
+	log.Printf("User %d reached their goal of %d verses, delivery paused", user.ID, user.VerseGoal)
+	return true
+}
+
+// ResetVerseGoalService restarts the count towards the user's verse goal and
+// resumes delivery if it was paused there.
+func (s *MemoryVerseService) ResetVerseGoalService(ctx context.Context, userID int) error {
+	return s.authRepo.ResetVerseGoal(ctx, userID)
+}
+
+// ErrLimitExceeded is matched by LimitExceededError.
+var ErrLimitExceeded = errors.New("limit reached")
+
+// LimitExceededError rejects an add that would take the user past a cap:
+// the configured MAX_FAVOURITES and MAX_NOTES_PER_USER, or the fixed
+// maxAttachmentsPerNote.
+type LimitExceededError struct {
+	Resource string // "favourites", "notes" or "attachments"
+	Limit    int
+	Count    int // how many the user has now
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit of %d reached, you have %d", e.Resource, e.Limit, e.Count)
+}
+
+func (e *LimitExceededError) Unwrap() error { return ErrLimitExceeded }
+
+// favouriteLimit and noteLimit return the per-user caps, 0 meaning unlimited.
+func (s *MemoryVerseService) favouriteLimit() int {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.MaxFavourites
+}
+
+func (s *MemoryVerseService) noteLimit() int {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.MaxNotes
+}
+
+// referencePattern splits a reference such as "1 Corinthians 13:4-7" into
+// its book and chapter; anything after the chapter's colon is ignored.
+var referencePattern = regexp.MustCompile(`^(.+?)\s+(\d+)(?::\d.*)?$`)
+
+// chapterOf returns the "Book Chapter" a reference belongs to, e.g. "John 3"
+// for "John 3:16", or false when the reference doesn't look like one.
+func chapterOf(reference string) (string, bool) {
+	m := referencePattern.FindStringSubmatch(strings.Join(strings.Fields(reference), " "))
+	if m == nil {
+		return "", false
+	}
+	return m[1] + " " + m[2], true
+}
+
+// defaultRelatedLimit is how many related verses are suggested by default.
+const defaultRelatedLimit = 10
+
+// GetRelatedVersesService suggests other verses from the same chapter as
+// verseID, in the same translation.
+func (s *MemoryVerseService) GetRelatedVersesService(ctx context.Context, userID, verseID, limit int) ([]Verse, error) {
+	verses, err := s.repo.GetRelatedVerses(ctx, userID, verseID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if verses == nil {
+		verses = []Verse{}
+	}
+	return verses, nil
+}
+
+// duplicateNoteWindow is how long an identical note counts as a double
+// submit. Saving it again within the window returns the first note.
+const duplicateNoteWindow = 10 * time.Second
+
+// SaveUserNoteService stores the note under the canonical form of its verse
+// reference, so "Jhn 3 16" and "John 3:16" are the same verse. Unparseable
+// references are rejected with util.ErrInvalidReference.
+func (s *MemoryVerseService) SaveUserNoteService(ctx context.Context, userID int, req SaveNoteRequest) (*UserNotes, error) {
+	ref, err := util.ParseReference(req.VerseReference)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository checks the cap under the same lock as the insert
+	note, err := s.repo.SaveUserNote(ctx, userID, ref.String(), req.Content, duplicateNoteWindow, s.noteLimit())
+	if err != nil {
+		log.Println("Error saving user note:", err)
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// maxAttachmentsPerNote caps how many files one note can carry.
+const maxAttachmentsPerNote = 5
+
+// attachmentContentTypes are the file types a note may have attached.
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/heic": true,
+}
+
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// AttachNoteFileService links an already uploaded file to one of the user's
+// notes. Only http(s) URLs to image types are accepted.
+func (s *MemoryVerseService) AttachNoteFileService(ctx context.Context, userID, noteID int, req AttachNoteFileRequest) (*NoteAttachment, error) {
+	fileURL := strings.TrimSpace(req.URL)
+	u, err := url.Parse(fileURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidAttachment)
+	}
+
+	contentType, _, err := mime.ParseMediaType(req.ContentType)
+	if err != nil || !attachmentContentTypes[contentType] {
+		return nil, fmt.Errorf("%w: content type %q is not a supported image type", ErrInvalidAttachment, req.ContentType)
+	}
+
+	return s.repo.AttachNoteFile(ctx, userID, noteID, fileURL, contentType, maxAttachmentsPerNote)
+}
+
+func (s *MemoryVerseService) DetachNoteFileService(ctx context.Context, userID, noteID, attachmentID int) error {
+	return s.repo.DetachNoteFile(ctx, userID, noteID, attachmentID)
+}
+
+func (s *MemoryVerseService) SearchUserNotesService(ctx context.Context, userID int, query string, limit, offset int) ([]NoteSearchResult, int, error) {
+	results, total, err := s.repo.SearchUserNotes(ctx, userID, query, limit, offset)
+	if err != nil {
+		log.Println("Error searching user notes:", err)
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+func (s *MemoryVerseService) StreamVerseHistoryService(ctx context.Context, userID int, rng HistoryRange, fn func(VerseHistory) error) error {
+	return s.repo.StreamUserVerseHistory(ctx, userID, rng, fn)
+}
 
-	isFav, err := s.repo.ToggleFavouriteVerse(ctx, userID, verseID)
+func (s *MemoryVerseService) ToggleFavouriteVerseService(ctx context.Context, userID int, verseID int) (*FavouriteState, error) {
+	// Only a toggle that adds counts towards the cap
+	state, err := s.repo.ToggleFavouriteVerse(ctx, userID, verseID, s.favouriteLimit())
 	if err != nil {
 		log.Println("Error toggling favourite:", err)
-		return false, err
+		return nil, err
 	}
 
-	return isFav, nil
+	return state, nil
 }
 
-func (s *MemoryVerseService) GetUserFavouriteVersesService(ctx context.Context, userID int) ([]FavouriteVerse, error) {
-	favourites, err := s.repo.GetUserFavouriteVerses(ctx, userID)
+var ErrInvalidBulkFavourites = errors.New("add or remove must contain at least one positive verse id")
+
+// BulkToggleFavouritesService applies a batch of favourite changes from an
+// offline client. Duplicate ids are collapsed and an id in both lists is
+// removed, so the outcome never depends on request ordering.
+func (s *MemoryVerseService) BulkToggleFavouritesService(ctx context.Context, userID int, req BulkFavouritesRequest) ([]FavouriteState, error) {
+	remove := uniqueVerseIDs(req.Remove, nil)
+	add := uniqueVerseIDs(req.Add, remove)
+	if add == nil && remove == nil {
+		return nil, ErrInvalidBulkFavourites
+	}
+	for _, id := range append(append([]int{}, add...), remove...) {
+		if id <= 0 {
+			return nil, ErrInvalidBulkFavourites
+		}
+	}
+
+	states, err := s.repo.BulkToggleFavourites(ctx, userID, add, remove, s.favouriteLimit())
+	if err != nil {
+		log.Println("Error applying bulk favourites:", err)
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// uniqueVerseIDs returns ids without duplicates or anything in exclude,
+// keeping first-seen order.
+func uniqueVerseIDs(ids, exclude []int) []int {
+	seen := map[int]bool{}
+	for _, id := range exclude {
+		seen[id] = true
+	}
+
+	var out []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+var ErrInvalidFavouriteOrder = errors.New("ordered_ids must list each of your favourite verses exactly once")
+
+// ReorderFavouritesService sets the order favourites are listed in by default.
+func (s *MemoryVerseService) ReorderFavouritesService(ctx context.Context, userID int, verseIDs []int) error {
+	seen := make(map[int]bool, len(verseIDs))
+	for _, id := range verseIDs {
+		if id <= 0 || seen[id] {
+			return ErrInvalidFavouriteOrder
+		}
+		seen[id] = true
+	}
+	return s.repo.ReorderFavourites(ctx, userID, verseIDs)
+}
+
+// checkFavouriteOrder reports which ids are missing from, or not among, the
+// user's favourites.
+func checkFavouriteOrder(favourited map[int]bool, verseIDs []int) error {
+	var foreign []int
+	listed := make(map[int]bool, len(verseIDs))
+	for _, id := range verseIDs {
+		listed[id] = true
+		if !favourited[id] {
+			foreign = append(foreign, id)
+		}
+	}
+	var missing []int
+	for id := range favourited {
+		if !listed[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(foreign) == 0 && len(missing) == 0 {
+		return nil
+	}
+
+	slices.Sort(missing)
+	return fmt.Errorf("%w: missing %v, not favourited %v", ErrInvalidFavouriteOrder, missing, foreign)
+}
+
+var ErrInvalidSort = errors.New("unsupported sort order")
+
+// GetUserFavouriteVersesService lists one page of favourites after the
+// cursor; sort must be empty or one of favouriteSortOrders.
+func (s *MemoryVerseService) GetUserFavouriteVersesService(ctx context.Context, userID int, sort string, after *pagination.Cursor, limit int) ([]FavouriteVerse, *pagination.CursorPage, error) {
+	if sort == "" {
+		sort = SortPosition
+	}
+	if _, ok := favouriteSortOrders[sort]; !ok {
+		return nil, nil, ErrInvalidSort
+	}
+
+	// One extra row tells us whether there is another page
+	favourites, err := s.repo.GetUserFavouriteVerses(ctx, userID, sort, after, limit+1)
 	if err != nil {
 		log.Println("Error fetching user favourites:", err)
+		return nil, nil, err
+	}
+
+	favourites, page := pagination.Page(favourites, limit, func(f FavouriteVerse) pagination.Cursor {
+		return pagination.Cursor{LastID: f.ID, LastCreatedAt: f.CreatedAt}
+	})
+	return favourites, page, nil
+}
+
+// GetVerseHistoryService lists one page of delivered verses after the
+// cursor, newest first.
+func (s *MemoryVerseService) GetVerseHistoryService(ctx context.Context, userID int, after *pagination.Cursor, limit int) ([]VerseHistory, *pagination.CursorPage, error) {
+	histories, err := s.repo.GetUserVerseHistoryPage(ctx, userID, after, limit+1)
+	if err != nil {
+		log.Println("Error fetching verse history:", err)
+		return nil, nil, err
+	}
+
+	histories, page := pagination.Page(histories, limit, func(h VerseHistory) pagination.Cursor {
+		return pagination.Cursor{LastID: h.VerseID, LastCreatedAt: h.DeliveredAt}
+	})
+	return histories, page, nil
+}
+
+// GetUserNotesService lists notes; sort must be empty or one of noteSortOrders.
+func (s *MemoryVerseService) GetUserNotesService(ctx context.Context, userID int, sort string) ([]UserNotes, error) {
+	if sort == "" {
+		sort = SortCreatedDesc
+	}
+	if _, ok := noteSortOrders[sort]; !ok {
+		return nil, ErrInvalidSort
+	}
+
+	return s.repo.GetUserNotes(ctx, userID, sort)
+}
+
+var ErrInvalidCollectionName = errors.New("collection name must not be blank")
+
+func (s *MemoryVerseService) CreateCollectionService(ctx context.Context, userID int, name string) (*Collection, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidCollectionName
+	}
+
+	collection, err := s.repo.CreateCollection(ctx, userID, name)
+	if err != nil {
+		log.Println("Error creating collection:", err)
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (s *MemoryVerseService) GetUserCollectionsService(ctx context.Context, userID int) ([]Collection, error) {
+	return s.repo.GetUserCollections(ctx, userID)
+}
+
+func (s *MemoryVerseService) AddVerseToCollectionService(ctx context.Context, userID, collectionID, verseID int) error {
+	return s.repo.AddVerseToCollection(ctx, userID, collectionID, verseID)
+}
+
+func (s *MemoryVerseService) RemoveVerseFromCollectionService(ctx context.Context, userID, collectionID, verseID int) error {
+	return s.repo.RemoveVerseFromCollection(ctx, userID, collectionID, verseID)
+}
+
+func (s *MemoryVerseService) GetCollectionVersesService(ctx context.Context, userID, collectionID int) ([]Verse, error) {
+	return s.repo.GetCollectionVerses(ctx, userID, collectionID)
+}
+
+// GetPopularVersesService returns the most favourited verses. Rankings are
+// cached per limit since favourite counts move slowly.
+func (s *MemoryVerseService) GetPopularVersesService(ctx context.Context, limit int) ([]Verse, error) {
+	key := strconv.Itoa(limit)
+	if verses, ok := s.popular.Get(key); ok {
+		return verses, nil
+	}
+
+	verses, err := s.repo.GetPopularVerses(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.popular.Set(key, verses)
+	return verses, nil
+}
+
+// GetRecentVersesService returns a page of the newest verses with the user's
+// favourite flags. Pages are cached briefly; the flags never are.
+func (s *MemoryVerseService) GetRecentVersesService(ctx context.Context, userID, limit, offset int) (*RecentVersesPage, error) {
+	key := fmt.Sprintf("%d:%d", limit, offset)
+	page, ok := s.recent.Get(key)
+	if !ok {
+		verses, total, err := s.repo.GetRecentVerses(ctx, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		page = RecentVersesPage{Verses: verses, Total: total}
+		s.recent.Set(key, page)
+	}
+
+	verses := make([]Verse, len(page.Verses))
+	copy(verses, page.Verses)
+	if len(verses) > 0 {
+		ids := make([]int, len(verses))
+		for i, v := range verses {
+			ids[i] = v.ID
+		}
+		favourited, err := s.repo.GetFavouritedVerseIDs(ctx, userID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range verses {
+			verses[i].IsFavourite = favourited[verses[i].ID]
+		}
+	}
+
+	return &RecentVersesPage{Verses: verses, Total: page.Total}, nil
+}
+
+// GetTranslationsService returns the Bible translations verses are available in.
+func (s *MemoryVerseService) GetTranslationsService(ctx context.Context) ([]string, error) {
+	return s.translations.Get(ctx)
+}
+
+// AvailableTranslations implements auth.TranslationSource.
+func (s *MemoryVerseService) AvailableTranslations(ctx context.Context) ([]string, error) {
+	return s.translations.Get(ctx)
+}
+
+// InvalidateVerseCaches drops cached verse lookups so changes to the verse
+// catalogue are visible immediately rather than after the TTL. The caches are
+// per process, so other instances still wait out their TTL.
+func (s *MemoryVerseService) InvalidateVerseCaches() {
+	s.popular.Clear()
+	s.translations.Invalidate()
+	s.recent.Clear()
+}
+
+func (s *MemoryVerseService) ReportVerseService(ctx context.Context, userID, verseID int, reason string) (*VerseReport, error) {
+	report, err := s.repo.CreateVerseReport(ctx, userID, verseID, strings.TrimSpace(reason))
+	if err != nil {
+		log.Println("Error reporting verse:", err)
 		return nil, err
 	}
 
-	return favourites, nil
+	return report, nil
+}
+
+func (s *MemoryVerseService) GetVerseReportsService(ctx context.Context, status string, limit, offset int) ([]VerseReport, int, error) {
+	return s.repo.GetVerseReports(ctx, status, limit, offset)
+}
+
+func (s *MemoryVerseService) ResolveVerseReportService(ctx context.Context, reportID int) (*VerseReport, error) {
+	return s.repo.ResolveVerseReport(ctx, reportID)
 }
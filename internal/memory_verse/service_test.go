@@ -0,0 +1,1037 @@
+package memoryverse
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/cache"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+func TestGetPopularVersesOrdersByFavouriteCount(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16"},
+			{ID: 2, Reference: "Psalm 23:1"},
+			{ID: 3, Reference: "Romans 8:28"},
+		},
+		favourites: map[int][]int{
+			10: {1, 2, 3},
+			11: {2, 3},
+			12: {3},
+		},
+	}
+	s := &MemoryVerseService{repo: repo, popular: cache.New[[]Verse](time.Minute)}
+
+	verses, err := s.GetPopularVersesService(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetPopularVersesService returned error: %v", err)
+	}
+
+	if len(verses) != 3 {
+		t.Fatalf("expected 3 popular verses, got %d", len(verses))
+	}
+	for i, want := range []struct{ id, count int }{{3, 3}, {2, 2}, {1, 1}} {
+		if verses[i].ID != want.id || verses[i].FavouriteCount != want.count {
+			t.Errorf("position %d: expected verse %d with %d favourites, got verse %d with %d",
+				i, want.id, want.count, verses[i].ID, verses[i].FavouriteCount)
+		}
+	}
+}
+
+func TestGetPopularVersesIsCached(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses:     []Verse{{ID: 1, Reference: "John 3:16"}},
+		favourites: map[int][]int{10: {1}},
+	}
+	s := &MemoryVerseService{repo: repo, popular: cache.New[[]Verse](time.Minute)}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetPopularVersesService(context.Background(), 5); err != nil {
+			t.Fatalf("GetPopularVersesService returned error: %v", err)
+		}
+	}
+	if repo.popularCalls != 1 {
+		t.Errorf("expected repeated calls to hit the cache, repo was queried %d times", repo.popularCalls)
+	}
+
+	// A different limit is a different ranking
+	if _, err := s.GetPopularVersesService(context.Background(), 1); err != nil {
+		t.Fatalf("GetPopularVersesService returned error: %v", err)
+	}
+	if repo.popularCalls != 2 {
+		t.Errorf("expected a new limit to query the repo, got %d calls", repo.popularCalls)
+	}
+}
+
+func TestGetRecentVersesService(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16", CreatedAt: base},
+			{ID: 2, Reference: "Psalm 23:1", CreatedAt: base.Add(2 * time.Hour)},
+			{ID: 3, Reference: "Romans 8:28", CreatedAt: base.Add(time.Hour)},
+		},
+		favourites: map[int][]int{10: {3}, 11: {2}},
+	}
+	s := &MemoryVerseService{repo: repo, recent: cache.New[RecentVersesPage](time.Minute)}
+
+	page, err := s.GetRecentVersesService(context.Background(), 10, 2, 0)
+	if err != nil {
+		t.Fatalf("GetRecentVersesService returned error: %v", err)
+	}
+	if page.Total != 3 || len(page.Verses) != 2 {
+		t.Fatalf("expected 2 of 3 verses, got %d of %d", len(page.Verses), page.Total)
+	}
+	if page.Verses[0].ID != 2 || page.Verses[1].ID != 3 {
+		t.Errorf("expected newest first [2 3], got [%d %d]", page.Verses[0].ID, page.Verses[1].ID)
+	}
+	if page.Verses[0].IsFavourite || !page.Verses[1].IsFavourite {
+		t.Errorf("unexpected favourite flags for user 10: %+v", page.Verses)
+	}
+
+	// The cached page is shared, but favourite flags belong to each user
+	page, err = s.GetRecentVersesService(context.Background(), 11, 2, 0)
+	if err != nil {
+		t.Fatalf("GetRecentVersesService returned error: %v", err)
+	}
+	if !page.Verses[0].IsFavourite || page.Verses[1].IsFavourite {
+		t.Errorf("unexpected favourite flags for user 11: %+v", page.Verses)
+	}
+	if repo.recentCalls != 1 {
+		t.Errorf("expected the page to be cached, repo was queried %d times", repo.recentCalls)
+	}
+
+	page, err = s.GetRecentVersesService(context.Background(), 10, 2, 2)
+	if err != nil {
+		t.Fatalf("GetRecentVersesService returned error: %v", err)
+	}
+	if len(page.Verses) != 1 || page.Verses[0].ID != 1 {
+		t.Errorf("expected verse 1 on the second page, got %+v", page.Verses)
+	}
+}
+func TestGetTranslationsIsCachedUntilInvalidated(t *testing.T) {
+	repo := &fakeVerseRepo{verses: []Verse{
+		{ID: 1, Translation: "KJV"},
+		{ID: 2, Translation: "NIV"},
+		{ID: 3, Translation: "KJV"},
+	}}
+	s := NewMemoryVerseService(repo, nil, nil, &config.Config{VerseCacheTTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		translations, err := s.GetTranslationsService(context.Background())
+		if err != nil {
+			t.Fatalf("GetTranslationsService returned error: %v", err)
+		}
+		if len(translations) != 2 || translations[0] != "KJV" || translations[1] != "NIV" {
+			t.Fatalf("unexpected translations: %v", translations)
+		}
+	}
+	// Profile validation reads the same cache
+	if _, err := s.AvailableTranslations(context.Background()); err != nil {
+		t.Fatalf("AvailableTranslations returned error: %v", err)
+	}
+	if repo.translationCalls != 1 {
+		t.Errorf("expected a cache hit to skip the repo, got %d calls", repo.translationCalls)
+	}
+
+	s.InvalidateVerseCaches()
+	if _, err := s.GetTranslationsService(context.Background()); err != nil {
+		t.Fatalf("GetTranslationsService returned error: %v", err)
+	}
+	if repo.translationCalls != 2 {
+		t.Errorf("expected invalidation to force a repo call, got %d calls", repo.translationCalls)
+	}
+}
+
+func TestAddingVersesInvalidatesCaches(t *testing.T) {
+	repo := &fakeVerseRepo{verses: []Verse{{ID: 1, Reference: "John 3:16", Translation: "KJV"}}}
+	// No config falls back to the default cache TTL
+	s := NewMemoryVerseService(repo, nil, nil, nil)
+
+	if got, _ := s.GetTranslationsService(context.Background()); len(got) != 1 {
+		t.Fatalf("expected one translation, got %v", got)
+	}
+
+	if _, err := s.CreateVerseService(context.Background(), NewVerse{Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "NIV"}); err != nil {
+		t.Fatalf("CreateVerseService returned error: %v", err)
+	}
+	if got, _ := s.GetTranslationsService(context.Background()); len(got) != 2 {
+		t.Errorf("expected the new translation after adding a verse, got %v", got)
+	}
+
+	if _, err := s.ImportVersesService(context.Background(), []NewVerse{{Reference: "John 11:35", Verse: "Jesus wept.", Translation: "ESV"}}); err != nil {
+		t.Fatalf("ImportVersesService returned error: %v", err)
+	}
+	if got, _ := s.GetTranslationsService(context.Background()); len(got) != 3 {
+		t.Errorf("expected the imported translation, got %v", got)
+	}
+	if repo.translationCalls != 3 {
+		t.Errorf("expected each write to force one reload, got %d repo calls", repo.translationCalls)
+	}
+}
+
+func newDashboardService(history []VerseHistory) (*MemoryVerseService, *fakeVerseRepo) {
+	authRepo := &fakeAuthRepo{
+		users:    []auth.User{{ID: 1, Email: "user@example.com"}},
+		profiles: map[int]*auth.CompleteProfileRequest{1: {VersePace: "daily", BibleTranslation: "KJV"}},
+	}
+	repo := &fakeVerseRepo{
+		verses:  []Verse{{ID: 1, Reference: "John 3:16"}},
+		history: map[int][]VerseHistory{1: history},
+	}
+	return &MemoryVerseService{repo: repo, authRepo: authRepo}, repo
+}
+
+func TestGetUserDashboard(t *testing.T) {
+	previous := Verse{ID: 2, Reference: "Psalm 23:1"}
+
+	tests := []struct {
+		name          string
+		history       []VerseHistory
+		wantVerseID   int
+		wantDelivered bool
+	}{
+		{"brand-new user", nil, 1, true},
+		{"within pace window", []VerseHistory{{VerseID: 2, DeliveredAt: time.Now().Add(-time.Hour), Verse: previous}}, 2, false},
+		{"past pace window", []VerseHistory{{VerseID: 2, DeliveredAt: time.Now().Add(-25 * time.Hour), Verse: previous}}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newDashboardService(tt.history)
+
+			_, verse, _, _, err := s.GetUserDashboard(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("GetUserDashboard returned error: %v", err)
+			}
+			if verse == nil || verse.ID != tt.wantVerseID {
+				t.Fatalf("expected verse %d, got %+v", tt.wantVerseID, verse)
+			}
+
+			delivered := len(repo.delivered[1]) > 0
+			if delivered != tt.wantDelivered {
+				t.Errorf("expected new delivery recorded = %v, got %v", tt.wantDelivered, delivered)
+			}
+		})
+	}
+}
+
+func TestUpcomingServiceHasNoSideEffects(t *testing.T) {
+	previous := Verse{ID: 2, Reference: "Psalm 23:1"}
+
+	tests := []struct {
+		name        string
+		history     []VerseHistory
+		wantVerseID int
+	}{
+		{"brand-new user", nil, 1},
+		// The next send falls after the pace window, so a fresh verse goes out
+		{"within pace window", []VerseHistory{{VerseID: 2, DeliveredAt: time.Now().Add(-time.Hour), Verse: previous}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newDashboardService(tt.history)
+			s.authRepo.(*fakeAuthRepo).users[0].IsSubscribed = true
+			if len(tt.history) > 0 {
+				sent := tt.history[0].DeliveredAt
+				s.authRepo.(*fakeAuthRepo).users[0].LastVerseSentAt = &sent
+			}
+
+			upcoming, err := s.UpcomingService(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("UpcomingService returned error: %v", err)
+			}
+			if len(upcoming.Verses) != 1 || upcoming.Verses[0].ID != tt.wantVerseID {
+				t.Fatalf("expected verse %d, got %+v", tt.wantVerseID, upcoming.Verses)
+			}
+			if upcoming.NextSendAt == nil || upcoming.PausedReason != "" {
+				t.Errorf("expected a next send time, got %v (paused %q)", upcoming.NextSendAt, upcoming.PausedReason)
+			}
+			if len(repo.delivered[1]) != 0 {
+				t.Errorf("preview recorded a delivery: %v", repo.delivered[1])
+			}
+		})
+	}
+
+	t.Run("unsubscribed", func(t *testing.T) {
+		s, _ := newDashboardService(nil)
+
+		upcoming, err := s.UpcomingService(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("UpcomingService returned error: %v", err)
+		}
+		if upcoming.PausedReason != "unsubscribed" || upcoming.NextSendAt != nil || len(upcoming.Verses) != 0 {
+			t.Errorf("expected a paused preview, got %+v", upcoming)
+		}
+	})
+}
+
+func TestBulkToggleFavourites(t *testing.T) {
+	newService := func() (*MemoryVerseService, *fakeVerseRepo) {
+		repo := &fakeVerseRepo{
+			verses:     []Verse{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}},
+			favourites: map[int][]int{7: {2, 3}},
+		}
+		return &MemoryVerseService{repo: repo}, repo
+	}
+
+	t.Run("adds and removes with overlap", func(t *testing.T) {
+		s, repo := newService()
+
+		// 4 is in both lists, so it ends up removed; 1 is listed twice
+		states, err := s.BulkToggleFavouritesService(context.Background(), 7, BulkFavouritesRequest{
+			Add:    []int{1, 4, 1},
+			Remove: []int{3, 4},
+		})
+		if err != nil {
+			t.Fatalf("BulkToggleFavouritesService returned error: %v", err)
+		}
+
+		want := []FavouriteState{{1, true, 1}, {3, false, 0}, {4, false, 0}}
+		if len(states) != len(want) {
+			t.Fatalf("expected states %v, got %v", want, states)
+		}
+		for i := range want {
+			if states[i] != want[i] {
+				t.Errorf("state %d: expected %+v, got %+v", i, want[i], states[i])
+			}
+		}
+
+		if got := repo.favourites[7]; len(got) != 2 || got[0] != 1 || got[1] != 2 {
+			t.Errorf("expected favourites [1 2], got %v", got)
+		}
+	})
+
+	t.Run("unknown verse id rejects the whole batch", func(t *testing.T) {
+		s, repo := newService()
+
+		_, err := s.BulkToggleFavouritesService(context.Background(), 7, BulkFavouritesRequest{
+			Add:    []int{1},
+			Remove: []int{2, 99},
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		if got := repo.favourites[7]; len(got) != 2 || got[0] != 2 || got[1] != 3 {
+			t.Errorf("expected favourites untouched, got %v", got)
+		}
+	})
+
+	t.Run("empty request", func(t *testing.T) {
+		s, _ := newService()
+
+		_, err := s.BulkToggleFavouritesService(context.Background(), 7, BulkFavouritesRequest{})
+		if !errors.Is(err, ErrInvalidBulkFavourites) {
+			t.Fatalf("expected ErrInvalidBulkFavourites, got %v", err)
+		}
+	})
+}
+
+func TestCollections(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeVerseRepo{verses: []Verse{
+		{ID: 1, Reference: "Philippians 4:6"},
+		{ID: 2, Reference: "1 Peter 5:7"},
+	}}
+	s := &MemoryVerseService{repo: repo}
+
+	collection, err := s.CreateCollectionService(ctx, 7, "  Anxiety ")
+	if err != nil {
+		t.Fatalf("CreateCollectionService returned error: %v", err)
+	}
+	if collection.Name != "Anxiety" || collection.UserID != 7 {
+		t.Fatalf("unexpected collection: %+v", collection)
+	}
+
+	t.Run("names are unique per user", func(t *testing.T) {
+		if _, err := s.CreateCollectionService(ctx, 7, "anxiety"); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("expected ErrAlreadyExists for a duplicate name, got %v", err)
+		}
+		if _, err := s.CreateCollectionService(ctx, 8, "Anxiety"); err != nil {
+			t.Errorf("expected another user to reuse the name, got %v", err)
+		}
+		if _, err := s.CreateCollectionService(ctx, 7, "   "); !errors.Is(err, ErrInvalidCollectionName) {
+			t.Errorf("expected ErrInvalidCollectionName for a blank name, got %v", err)
+		}
+	})
+
+	t.Run("membership", func(t *testing.T) {
+		for _, id := range []int{1, 2, 1} {
+			if err := s.AddVerseToCollectionService(ctx, 7, collection.ID, id); err != nil {
+				t.Fatalf("AddVerseToCollectionService(%d) returned error: %v", id, err)
+			}
+		}
+		if err := s.AddVerseToCollectionService(ctx, 7, collection.ID, 99); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound for an unknown verse, got %v", err)
+		}
+		if err := s.RemoveVerseFromCollectionService(ctx, 7, collection.ID, 1); err != nil {
+			t.Fatalf("RemoveVerseFromCollectionService returned error: %v", err)
+		}
+
+		verses, err := s.GetCollectionVersesService(ctx, 7, collection.ID)
+		if err != nil {
+			t.Fatalf("GetCollectionVersesService returned error: %v", err)
+		}
+		if len(verses) != 1 || verses[0].ID != 2 {
+			t.Errorf("expected only verse 2 in the collection, got %+v", verses)
+		}
+	})
+
+	t.Run("other users cannot see or change it", func(t *testing.T) {
+		if _, err := s.GetCollectionVersesService(ctx, 8, collection.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound listing another user's collection, got %v", err)
+		}
+		if err := s.AddVerseToCollectionService(ctx, 8, collection.ID, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound adding to another user's collection, got %v", err)
+		}
+		if err := s.RemoveVerseFromCollectionService(ctx, 8, collection.ID, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound removing from another user's collection, got %v", err)
+		}
+	})
+}
+
+// rotatingVerseRepo hands out a different random verse on every call.
+type rotatingVerseRepo struct {
+	*fakeVerseRepo
+	calls atomic.Int32
+}
+
+func (r *rotatingVerseRepo) GetRandomVerse(ctx context.Context, userID int, translation string) (*Verse, error) {
+	v := r.verses[int(r.calls.Add(1)-1)%len(r.verses)]
+	return &v, nil
+}
+
+func TestGetUserDashboardConcurrentLoadsRecordOneDelivery(t *testing.T) {
+	s, fake := newDashboardService(nil)
+	fake.verses = []Verse{{ID: 1}, {ID: 2}}
+	s.repo = &rotatingVerseRepo{fakeVerseRepo: fake}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		served = map[int]bool{}
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, verse, _, _, err := s.GetUserDashboard(context.Background(), 1)
+			if err != nil {
+				t.Errorf("GetUserDashboard returned error: %v", err)
+				return
+			}
+			mu.Lock()
+			served[verse.ID] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if got := len(fake.delivered[1]); got != 1 {
+		t.Errorf("expected a single history row, got %d", got)
+	}
+	if len(served) != 1 {
+		t.Errorf("expected both loads to show the same verse, got %v", served)
+	}
+}
+
+func TestNextVerseAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) // Wednesday
+	lastSent := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	slot := time.Date(0, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		pace     string
+		lastSent *time.Time
+		slots    []time.Time
+		want     time.Time
+	}{
+		{"daily", "daily", &lastSent, nil, lastSent.Add(24 * time.Hour)},
+		{"weekly", "weekly", &lastSent, nil, lastSent.Add(7 * 24 * time.Hour)},
+		{"daily with selected time", "daily", &lastSent, []time.Time{slot}, time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)},
+		{"weekly with selected time", "weekly", &lastSent, []time.Time{slot}, time.Date(2024, 5, 8, 9, 30, 0, 0, time.UTC)},
+		{"monthly", "monthly", &lastSent, nil, lastSent.Add(30 * 24 * time.Hour)},
+		{"never sent", "daily", nil, nil, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval, _ := auth.PaceInterval(tt.pace, 0)
+			got := nextVerseAt(interval, tt.lastSent, tt.slots, nil, now)
+			if got == nil || !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetUserDashboardNextVerseOnlyForSubscribers(t *testing.T) {
+	for _, subscribed := range []bool{true, false} {
+		s, _ := newDashboardService(nil)
+		s.authRepo.(*fakeAuthRepo).users[0].IsSubscribed = subscribed
+
+		user, _, _, _, err := s.GetUserDashboard(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("GetUserDashboard returned error: %v", err)
+		}
+		if (user.NextVerseAt != nil) != subscribed {
+			t.Errorf("subscribed=%v: unexpected next_verse_at %v", subscribed, user.NextVerseAt)
+		}
+	}
+}
+
+func TestCurrentStreak(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) // Wednesday
+	at := func(days int) VerseHistory {
+		return VerseHistory{DeliveredAt: now.AddDate(0, 0, -days)}
+	}
+
+	tests := []struct {
+		name      string
+		pace      string
+		days      int
+		histories []VerseHistory
+		want      int
+	}{
+		{"no history", "daily", 0, nil, 0},
+		{"daily through today", "daily", 0, []VerseHistory{at(0), at(1), at(2)}, 3},
+		{"daily pending today", "daily", 0, []VerseHistory{at(1), at(2)}, 2},
+		{"daily broken", "daily", 0, []VerseHistory{at(0), at(2)}, 1},
+		{"weekly", "weekly", 0, []VerseHistory{at(0), at(7), at(14), at(28)}, 3},
+		// May, April and March, then nothing in February
+		{"monthly", "monthly", 0, []VerseHistory{at(0), at(20), at(45), at(100)}, 3},
+		{"monthly pending this month", "monthly", 0, []VerseHistory{at(5), at(40)}, 2},
+		// 2024-05-01 ends the 3-day block that started on 2024-04-29
+		{"every 3 days", "every_n_days", 3, []VerseHistory{at(0), at(2), at(3), at(7), at(12)}, 3},
+		{"every 3 days broken", "every_n_days", 3, []VerseHistory{at(0), at(6)}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := currentStreak(tt.pace, tt.days, tt.histories, now); got != tt.want {
+				t.Errorf("expected streak %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToggleFavouriteVerseReturnsCount(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses:     []Verse{{ID: 1}},
+		favourites: map[int][]int{10: {1}, 11: {1}},
+	}
+	s := &MemoryVerseService{repo: repo}
+
+	steps := []struct {
+		userID    int
+		wantFav   bool
+		wantCount int
+	}{
+		{12, true, 3},  // a third user saves it
+		{10, false, 2}, // an existing fan removes it
+		{12, false, 1},
+		{10, true, 2},
+	}
+	for i, step := range steps {
+		state, err := s.ToggleFavouriteVerseService(context.Background(), step.userID, 1)
+		if err != nil {
+			t.Fatalf("step %d: toggle failed: %v", i, err)
+		}
+		if state.IsFavourite != step.wantFav || state.FavouriteCount != step.wantCount {
+			t.Errorf("step %d: expected favourite=%v count=%d, got %+v", i, step.wantFav, step.wantCount, state)
+		}
+	}
+}
+
+func TestSelectPrompt(t *testing.T) {
+	day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	prompts := []string{"first", "second", "third"}
+
+	if got := selectPrompt(nil, 1, day); got != DefaultReflectionPrompt {
+		t.Errorf("expected fallback prompt, got %q", got)
+	}
+
+	// Stable within a day, rotating across days
+	today := selectPrompt(prompts, 1, day)
+	if later := selectPrompt(prompts, 1, day.Add(10*time.Hour)); later != today {
+		t.Errorf("prompt changed within the day: %q then %q", today, later)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < len(prompts); i++ {
+		seen[selectPrompt(prompts, 1, day.AddDate(0, 0, i))] = true
+	}
+	if len(seen) != len(prompts) {
+		t.Errorf("expected every prompt over %d days, saw %v", len(prompts), seen)
+	}
+}
+
+func TestGetUserDashboardIncludesPrompt(t *testing.T) {
+	t.Run("verse prompt", func(t *testing.T) {
+		s, repo := newDashboardService(nil)
+		repo.prompts = map[int][]string{1: {"What does love cost you today?"}}
+
+		_, verse, _, _, err := s.GetUserDashboard(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("GetUserDashboard returned error: %v", err)
+		}
+		if verse.Prompt != "What does love cost you today?" {
+			t.Errorf("unexpected prompt %q", verse.Prompt)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		s, _ := newDashboardService(nil)
+
+		_, verse, _, _, err := s.GetUserDashboard(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("GetUserDashboard returned error: %v", err)
+		}
+		if verse.Prompt != DefaultReflectionPrompt {
+			t.Errorf("expected fallback prompt, got %q", verse.Prompt)
+		}
+	})
+}
+
+func TestGetGuestVerseService(t *testing.T) {
+	newService := func() *MemoryVerseService {
+		return &MemoryVerseService{repo: &fakeVerseRepo{verses: []Verse{
+			{ID: 1, Reference: "John 3:16", Translation: "KJV"},
+			{ID: 2, Reference: "John 3:16", Translation: "NIV"},
+			{ID: 3, Reference: "Psalm 23:1", Translation: "NIV"},
+		}}}
+	}
+
+	t.Run("translation filter", func(t *testing.T) {
+		s := newService()
+		verse, err := s.GetGuestVerseService(context.Background(), "", " niv ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if verse.Translation != "NIV" {
+			t.Errorf("expected an NIV verse, got %+v", verse)
+		}
+
+		if _, err := s.GetGuestVerseService(context.Background(), "", "ESV"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound for unknown translation, got %v", err)
+		}
+	})
+
+	t.Run("guest dedup", func(t *testing.T) {
+		s := newService()
+		const guest = "guest-1234"
+
+		first, err := s.GetGuestVerseService(context.Background(), guest, "NIV")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := s.GetGuestVerseService(context.Background(), guest, "NIV")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first.ID == second.ID {
+			t.Errorf("guest saw verse %d twice", first.ID)
+		}
+
+		// Once every verse has been seen, repeats are allowed rather than failing
+		if _, err := s.GetGuestVerseService(context.Background(), guest, "NIV"); err != nil {
+			t.Errorf("expected a repeat verse, got %v", err)
+		}
+	})
+
+	t.Run("invalid guest id is not tracked", func(t *testing.T) {
+		s := newService()
+		for i := 0; i < 2; i++ {
+			verse, err := s.GetGuestVerseService(context.Background(), "x", "KJV")
+			if err != nil || verse.ID != 1 {
+				t.Fatalf("expected verse 1, got %+v, %v", verse, err)
+			}
+		}
+		if guests := s.repo.(*fakeVerseRepo).guests; len(guests) != 0 {
+			t.Errorf("expected no guest history, got %v", guests)
+		}
+	})
+}
+
+func TestGetVerseCoverageService(t *testing.T) {
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Translation: "KJV"},
+			{ID: 2, Translation: "KJV"},
+			{ID: 3, Translation: "ESV"},
+		},
+		profileTranslations: map[int]string{1: "KJV", 2: "NIV", 3: "NIV"},
+		inspirations:        map[int][]string{1: {"hope", "faith"}, 2: {"hope"}},
+	}
+	s := MemoryVerseService{repo: repo}
+
+	coverage, err := s.GetVerseCoverageService(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []TranslationCoverage{
+		{Translation: "ESV", VerseCount: 1},
+		{Translation: "KJV", VerseCount: 2, UserCount: 1},
+		{Translation: "NIV", UserCount: 2, Missing: true},
+	}
+	if !reflect.DeepEqual(coverage.Translations, want) {
+		t.Errorf("unexpected translations\n got %+v\nwant %+v", coverage.Translations, want)
+	}
+	if !reflect.DeepEqual(coverage.MissingTranslations, []string{"NIV"}) {
+		t.Errorf("expected NIV flagged as missing, got %v", coverage.MissingTranslations)
+	}
+
+	wantTopics := []TopicCoverage{{Topic: "hope", UserCount: 2}, {Topic: "faith", UserCount: 1}}
+	if !reflect.DeepEqual(coverage.Topics, wantTopics) {
+		t.Errorf("unexpected topics %+v", coverage.Topics)
+	}
+}
+
+func TestFavouriteLimit(t *testing.T) {
+	verses := []Verse{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	newService := func(limit int) (*MemoryVerseService, *fakeVerseRepo) {
+		repo := &fakeVerseRepo{verses: verses, favourites: map[int][]int{7: {1, 2}}}
+		return &MemoryVerseService{repo: repo, cfg: &config.Config{MaxFavourites: limit}}, repo
+	}
+
+	t.Run("toggle blocks the add past the cap", func(t *testing.T) {
+		s, repo := newService(3)
+		if _, err := s.ToggleFavouriteVerseService(context.Background(), 7, 3); err != nil {
+			t.Fatalf("add up to the cap failed: %v", err)
+		}
+
+		_, err := s.ToggleFavouriteVerseService(context.Background(), 7, 4)
+		var limitErr *LimitExceededError
+		if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expected LimitExceededError, got %v", err)
+		}
+		if limitErr.Limit != 3 || limitErr.Count != 3 {
+			t.Errorf("expected limit 3 and count 3, got %+v", limitErr)
+		}
+		if len(repo.favourites[7]) != 3 {
+			t.Errorf("blocked add was saved: %v", repo.favourites[7])
+		}
+
+		// Removing is always allowed at the cap
+		if state, err := s.ToggleFavouriteVerseService(context.Background(), 7, 1); err != nil || state.IsFavourite {
+			t.Errorf("expected removal at the cap to succeed, got %+v, %v", state, err)
+		}
+	})
+
+	t.Run("bulk counts the net change", func(t *testing.T) {
+		s, _ := newService(3)
+		_, err := s.BulkToggleFavouritesService(context.Background(), 7, BulkFavouritesRequest{Add: []int{1, 3, 4}})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("expected two new favourites over a cap of 3 to fail, got %v", err)
+		}
+
+		if _, err := s.BulkToggleFavouritesService(context.Background(), 7, BulkFavouritesRequest{Add: []int{3, 4}, Remove: []int{1}}); err != nil {
+			t.Errorf("expected a swap that lands on the cap to succeed, got %v", err)
+		}
+	})
+
+	t.Run("zero is unlimited", func(t *testing.T) {
+		s, repo := newService(0)
+		for _, id := range []int{3, 4} {
+			if _, err := s.ToggleFavouriteVerseService(context.Background(), 7, id); err != nil {
+				t.Fatalf("unlimited toggle failed: %v", err)
+			}
+		}
+		if len(repo.favourites[7]) != 4 {
+			t.Errorf("expected 4 favourites, got %v", repo.favourites[7])
+		}
+	})
+}
+
+func TestNoteLimit(t *testing.T) {
+	req := SaveNoteRequest{VerseReference: "John 3:16", Content: "note"}
+
+	repo := &fakeVerseRepo{}
+	s := &MemoryVerseService{repo: repo, cfg: &config.Config{MaxNotes: 2}}
+	// Distinct content, so no save is taken for a double submit
+	for i := 0; i < 2; i++ {
+		req := SaveNoteRequest{VerseReference: "John 3:16", Content: "note " + strconv.Itoa(i)}
+		if _, err := s.SaveUserNoteService(context.Background(), 7, req); err != nil {
+			t.Fatalf("note %d failed: %v", i+1, err)
+		}
+	}
+	_, err := s.SaveUserNoteService(context.Background(), 7, req)
+	var limitErr *LimitExceededError
+	if !errors.As(err, &limitErr) || limitErr.Resource != "notes" || limitErr.Count != 2 {
+		t.Fatalf("expected the third note to hit the cap, got %v", err)
+	}
+	if _, err := s.SaveUserNoteService(context.Background(), 8, req); err != nil {
+		t.Errorf("cap should be per user, got %v", err)
+	}
+
+	unlimited := &MemoryVerseService{repo: &fakeVerseRepo{}, cfg: &config.Config{}}
+	for i := 0; i < 50; i++ {
+		req := SaveNoteRequest{VerseReference: "John 3:16", Content: "note " + strconv.Itoa(i)}
+		if _, err := unlimited.SaveUserNoteService(context.Background(), 7, req); err != nil {
+			t.Fatalf("unlimited note %d failed: %v", i+1, err)
+		}
+	}
+}
+
+func newShareService() (*MemoryVerseService, *fakeVerseRepo) {
+	repo := &fakeVerseRepo{
+		verses: []Verse{
+			{ID: 1, Reference: "John 3:16"},
+			{ID: 2, Reference: "Psalm 23:1"},
+			{ID: 3, Reference: "Romans 8:28"},
+		},
+		favourites: map[int][]int{1: {1, 2}},
+	}
+	authRepo := &fakeAuthRepo{
+		users:    []auth.User{{ID: 1, Email: "grace@example.com"}},
+		profiles: map[int]*auth.CompleteProfileRequest{1: {UserName: "grace"}},
+	}
+	cfg := &config.Config{AppBaseURL: "https://memoryverse.app"}
+	return &MemoryVerseService{repo: repo, authRepo: authRepo, cfg: cfg}, repo
+}
+
+func TestFavouriteShareIsASnapshot(t *testing.T) {
+	s, _ := newShareService()
+	ctx := context.Background()
+
+	link, err := s.CreateFavouriteShareService(ctx, 1)
+	if err != nil {
+		t.Fatalf("CreateFavouriteShareService returned error: %v", err)
+	}
+	if link.ShareURL != "https://memoryverse.app/share/favourites/"+link.Token {
+		t.Errorf("unexpected share URL %q", link.ShareURL)
+	}
+
+	// Favourites changing after the share is created must not show through
+	for _, verseID := range []int{1, 3} {
+		if _, err := s.repo.ToggleFavouriteVerse(ctx, 1, verseID, 0); err != nil {
+			t.Fatalf("ToggleFavouriteVerse returned error: %v", err)
+		}
+	}
+
+	shared, err := s.GetSharedFavouritesService(ctx, link.Token)
+	if err != nil {
+		t.Fatalf("GetSharedFavouritesService returned error: %v", err)
+	}
+	if shared.DisplayName != "grace" {
+		t.Errorf("expected display name grace, got %q", shared.DisplayName)
+	}
+	var refs []string
+	for _, v := range shared.Verses {
+		refs = append(refs, v.Reference)
+	}
+	if want := []string{"Psalm 23:1", "John 3:16"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("expected snapshot %v, got %v", want, refs)
+	}
+
+	if _, err := s.GetSharedFavouritesService(ctx, "not-a-token"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("expected ErrShareNotFound for unknown token, got %v", err)
+	}
+}
+
+func TestFavouriteShareExpires(t *testing.T) {
+	s, repo := newShareService()
+	ctx := context.Background()
+
+	link, err := s.CreateFavouriteShareService(ctx, 1)
+	if err != nil {
+		t.Fatalf("CreateFavouriteShareService returned error: %v", err)
+	}
+	if d := time.Until(link.ExpiresAt); d <= favouriteShareTTL-time.Minute || d > favouriteShareTTL {
+		t.Errorf("expected share to expire in %s, got %s", favouriteShareTTL, d)
+	}
+
+	repo.shares[util.HashFeedToken(link.Token)].ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, err := s.GetSharedFavouritesService(ctx, link.Token); !errors.Is(err, ErrShareExpired) {
+		t.Errorf("expected ErrShareExpired, got %v", err)
+	}
+}
+
+func TestChapterOf(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      string
+		ok        bool
+	}{
+		{"John 3:16", "John 3", true},
+		{"John 3:16-17", "John 3", true},
+		{"1 Corinthians 13:4", "1 Corinthians 13", true},
+		{"Song of Solomon 2:1", "Song of Solomon 2", true},
+		{"Psalm 23", "Psalm 23", true},
+		{"  Psalm   119:105 ", "Psalm 119", true},
+		{"Jude", "", false},
+		{"3:16", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := chapterOf(tt.reference)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("chapterOf(%q) = %q, %v; want %q, %v", tt.reference, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSanitizeVerseText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text untouched", "For God so loved the world", "For God so loved the world"},
+		{"inline tags", "For <b>God</b> so <i>loved</i> the world", "For God so loved the world"},
+		{"line breaks keep words apart", "Jesus wept.<br/>Then said", "Jesus wept. Then said"},
+		{"script dropped with its body", "In the beginning<script>alert(1)</script> was the Word", "In the beginning was the Word"},
+		{"entities decoded", "Love is patient &amp; kind&nbsp;&#8212; always", "Love is patient & kind — always"},
+		{"whitespace collapsed", "  The Lord\tis my\n\nshepherd  ", "The Lord is my shepherd"},
+		{"smart quotes straightened", "“Fear not,” he said; ‘I am’", `"Fear not," he said; 'I am'`},
+		{"empty", " <p></p> ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeVerseText(tt.in); got != tt.want {
+				t.Errorf("sanitizeVerseText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateVerseServiceSanitizes(t *testing.T) {
+	repo := &fakeVerseRepo{}
+	s := NewMemoryVerseService(repo, nil, nil, nil)
+
+	verse, err := s.CreateVerseService(context.Background(), NewVerse{
+		Reference:   " John  3:16 ",
+		Verse:       "For <b>God</b> so  loved&nbsp;the “world”",
+		Translation: "kjv",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `For God so loved the "world"`; verse.Verse != want {
+		t.Errorf("expected stored text %q, got %q", want, verse.Verse)
+	}
+	if verse.Reference != "John 3:16" || verse.Translation != "KJV" {
+		t.Errorf("expected tidied reference and translation, got %q %q", verse.Reference, verse.Translation)
+	}
+	if raw := repo.raw[verse.ID]; raw != "For <b>God</b> so  loved&nbsp;the “world”" {
+		t.Errorf("expected the original kept as raw_verse, got %q", raw)
+	}
+
+	clean, err := s.CreateVerseService(context.Background(), NewVerse{Reference: "Psalm 23:1", Verse: "The Lord is my shepherd", Translation: "KJV"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := repo.raw[clean.ID]; ok {
+		t.Error("expected no raw_verse when cleaning changed nothing")
+	}
+}
+
+func TestImportVersesServiceIsAllOrNothing(t *testing.T) {
+	repo := &fakeVerseRepo{}
+	s := NewMemoryVerseService(repo, nil, nil, nil)
+
+	_, err := s.ImportVersesService(context.Background(), []NewVerse{
+		{Reference: "John 3:16", Verse: "For God so loved the world", Translation: "KJV"},
+		{Reference: "John 11:35", Verse: "<p> </p>", Translation: "KJV"},
+	})
+	if !errors.Is(err, ErrInvalidVerse) {
+		t.Fatalf("expected ErrInvalidVerse for a verse with no text, got %v", err)
+	}
+	if len(repo.verses) != 0 {
+		t.Fatalf("expected nothing imported, got %d verses", len(repo.verses))
+	}
+
+	n, err := s.ImportVersesService(context.Background(), []NewVerse{
+		{Reference: "John 3:16", Verse: "For God so loved<br>the world", Translation: "KJV"},
+		{Reference: "John 11:35", Verse: "Jesus wept.", Translation: "KJV"},
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 imported, got %d (%v)", n, err)
+	}
+	if got := repo.verses[0].Verse; got != "For God so loved the world" {
+		t.Errorf("expected imported text sanitised, got %q", got)
+	}
+}
+
+func TestTranslationsCacheLoadsOnceUnderConcurrency(t *testing.T) {
+	var loads atomic.Int32
+	c := NewTranslationsCache(func(ctx context.Context) ([]string, error) {
+		loads.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return []string{"KJV"}, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := c.Get(context.Background()); err != nil || len(got) != 1 {
+				t.Errorf("Get returned %v, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := loads.Load(); got != 1 {
+		t.Fatalf("expected concurrent misses to share one load, got %d", got)
+	}
+
+	c.Invalidate()
+	if _, err := c.Get(context.Background()); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got := loads.Load(); got != 2 {
+		t.Errorf("expected Invalidate to force a reload, got %d loads", got)
+	}
+}
+
+// dailySaveFailRepo can't record the verse of the day while fail is set.
+type dailySaveFailRepo struct {
+	*fakeVerseRepo
+	fail bool
+}
+
+func (r *dailySaveFailRepo) SaveDailyVerse(ctx context.Context, day string, verseID int) (*Verse, error) {
+	if r.fail {
+		return nil, ErrInternalServer
+	}
+	return r.fakeVerseRepo.SaveDailyVerse(ctx, day, verseID)
+}
+
+func TestVerseOfTheDayNotCachedUntilRecorded(t *testing.T) {
+	repo := &dailySaveFailRepo{
+		fakeVerseRepo: &fakeVerseRepo{verses: []Verse{{ID: 1}, {ID: 2}}},
+		fail:          true,
+	}
+	s := &MemoryVerseService{repo: repo, verseOfDay: cache.New[Verse](24 * time.Hour), dailyStreak: cache.New[int](24 * time.Hour)}
+	const day = "2024-05-01"
+
+	verse, err := s.verseOfTheDay(context.Background(), day)
+	if err != nil || verse == nil || verse.ID != 1 {
+		t.Fatalf("expected this instance's pick to be served, got %v, %v", verse, err)
+	}
+
+	// Another instance recorded the day meanwhile
+	repo.fail = false
+	repo.daily = map[string]int{day: 2}
+
+	verse, err = s.verseOfTheDay(context.Background(), day)
+	if err != nil || verse == nil || verse.ID != 2 {
+		t.Errorf("expected the recorded verse once saving works, got %v, %v", verse, err)
+	}
+}
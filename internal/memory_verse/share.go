@@ -0,0 +1,72 @@
+package memoryverse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
+)
+
+// favouriteShareTTL is how long a shared favourites page stays readable.
+const favouriteShareTTL = 30 * 24 * time.Hour
+
+var (
+	ErrShareNotFound = errors.New("share not found")
+	ErrShareExpired  = errors.New("share has expired")
+)
+
+// CreateFavouriteShareService snapshots the user's current favourites behind
+// a new share token. Only its hash is stored, so the token is returned once.
+func (s *MemoryVerseService) CreateFavouriteShareService(ctx context.Context, userID int) (*FavouriteShareLink, error) {
+	_, profile, err := s.authRepo.GetUserWithProfile(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	displayName := ""
+	if profile != nil {
+		displayName = profile.UserName
+	}
+
+	token, err := util.GenerateFeedToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().UTC().Add(favouriteShareTTL)
+	if err := s.repo.CreateFavouriteShare(ctx, userID, util.HashFeedToken(token), displayName, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &FavouriteShareLink{
+		Token:     token,
+		ShareURL:  s.cfg.AppURL("/share/favourites/" + token),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GetSharedFavouritesService returns the snapshot behind a share token.
+func (s *MemoryVerseService) GetSharedFavouritesService(ctx context.Context, token string) (*SharedFavourites, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrShareNotFound
+	}
+
+	shared, err := s.repo.GetFavouriteShare(ctx, util.HashFeedToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(shared.ExpiresAt) {
+		return nil, ErrShareExpired
+	}
+
+	if shared.Verses == nil {
+		shared.Verses = []Verse{}
+	}
+	return shared, nil
+}
@@ -0,0 +1,54 @@
+package memoryverse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/cache"
+)
+
+// TranslationsCache holds the translations in the verse catalogue for a TTL.
+// Profile saves check against it, so it is read far more often than the
+// catalogue changes. It is safe for concurrent use.
+type TranslationsCache struct {
+	load  func(ctx context.Context) ([]string, error)
+	cache *cache.Cache[[]string]
+
+	// loadMu lets one caller reload after a miss while the rest wait for it
+	loadMu sync.Mutex
+}
+
+const translationsCacheKey = "all"
+
+func NewTranslationsCache(load func(ctx context.Context) ([]string, error), ttl time.Duration) *TranslationsCache {
+	return &TranslationsCache{load: load, cache: cache.New[[]string](ttl)}
+}
+
+// Get returns the cached translations, loading them on the first call after
+// the TTL lapses or Invalidate is called.
+func (c *TranslationsCache) Get(ctx context.Context) ([]string, error) {
+	if translations, ok := c.cache.Get(translationsCacheKey); ok {
+		return translations, nil
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	// Another caller may have reloaded while this one waited
+	if translations, ok := c.cache.Get(translationsCacheKey); ok {
+		return translations, nil
+	}
+
+	translations, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Set(translationsCacheKey, translations)
+	return translations, nil
+}
+
+// Invalidate forces the next Get to reload, e.g. after verses are added or removed.
+func (c *TranslationsCache) Invalidate() {
+	c.cache.Clear()
+}
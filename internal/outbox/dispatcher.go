@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/mail"
+)
+
+const (
+	// MaxAttempts is how many times a message is tried before it is marked failed.
+	MaxAttempts = 5
+	batchSize   = 50
+)
+
+// Dispatcher drains the outbox, delivering due messages through a real mailer.
+type Dispatcher struct {
+	repo     Repository
+	mail     mail.Sender
+	interval time.Duration
+}
+
+func NewDispatcher(repo Repository, mail mail.Sender, interval time.Duration) *Dispatcher {
+	return &Dispatcher{repo: repo, mail: mail, interval: interval}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	log.Printf("Outbox dispatcher started (%s interval)\n", d.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox dispatcher stopped gracefully")
+			return
+		case <-ticker.C:
+			d.dispatchOnce(ctx)
+		}
+	}
+}
+
+// dispatchOnce delivers one batch of due messages.
+func (d *Dispatcher) dispatchOnce(ctx context.Context) {
+	messages, err := d.repo.ClaimDue(ctx, batchSize)
+	if err != nil {
+		log.Printf("Failed to claim outbox messages: %v", err)
+		return
+	}
+
+	for _, m := range messages {
+		var data map[string]interface{}
+		if err := json.Unmarshal(m.Payload, &data); err != nil {
+			d.fail(ctx, m, err, true)
+			continue
+		}
+
+		if err := d.mail.SendHTMLWithHeaders(m.To, m.Subject, m.Template, data, m.Headers); err != nil {
+			d.fail(ctx, m, err, m.Attempts+1 >= MaxAttempts)
+			continue
+		}
+
+		if err := d.repo.MarkSent(ctx, m.ID); err != nil {
+			log.Printf("Could not mark outbox message %d as sent: %v", m.ID, err)
+		}
+	}
+}
+
+func (d *Dispatcher) fail(ctx context.Context, m Message, sendErr error, permanent bool) {
+	log.Printf("Outbox message %d to %s failed (attempt %d): %v", m.ID, m.To, m.Attempts+1, sendErr)
+
+	if err := d.repo.MarkFailed(ctx, m.ID, sendErr.Error(), time.Now().Add(backoff(m.Attempts+1)), permanent); err != nil {
+		log.Printf("Could not mark outbox message %d as failed: %v", m.ID, err)
+	}
+}
+
+// backoff grows quadratically with the attempt number: 30s, 2m, 4.5m, 8m...
+func backoff(attempt int) time.Duration {
+	return time.Duration(attempt*attempt) * 30 * time.Second
+}
@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+)
+
+type OutboxHandler struct {
+	repo Repository
+}
+
+func NewHandler(repo Repository) OutboxHandler {
+	return OutboxHandler{repo: repo}
+}
+
+func (h *OutboxHandler) ListOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != StatusPending && status != StatusSent && status != StatusFailed {
+		response.Error(w, http.StatusBadRequest, "Invalid status", "status must be pending, sent or failed")
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	messages, total, err := h.repo.List(r.Context(), status, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to get outbox", err.Error())
+		return
+	}
+
+	if messages == nil {
+		messages = []Message{}
+	}
+
+	response.SuccessWithMeta(w, messages, response.NewMeta(total, limit, offset), "successfully")
+}
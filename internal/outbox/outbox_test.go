@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRepo struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *memoryRepo) Enqueue(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg.ID = len(m.messages) + 1
+	msg.Status = StatusPending
+	msg.NextAttemptAt = time.Now().Add(-time.Second)
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *memoryRepo) ClaimDue(ctx context.Context, limit int) ([]Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Message
+	for _, msg := range m.messages {
+		if msg.Status == StatusPending && !msg.NextAttemptAt.After(time.Now()) && len(due) < limit {
+			due = append(due, msg)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryRepo) MarkSent(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[id-1].Status = StatusSent
+	m.messages[id-1].Attempts++
+	return nil
+}
+
+func (m *memoryRepo) MarkFailed(ctx context.Context, id int, errMsg string, next time.Time, permanent bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := &m.messages[id-1]
+	msg.Attempts++
+	msg.LastError = &errMsg
+	msg.NextAttemptAt = next
+	if permanent {
+		msg.Status = StatusFailed
+	}
+	return nil
+}
+
+func (m *memoryRepo) List(ctx context.Context, status string, limit, offset int) ([]Message, int, error) {
+	return m.messages, len(m.messages), nil
+}
+
+type recordingSender struct {
+	fail  error
+	sent  []string
+	datas []map[string]interface{}
+}
+
+func (r *recordingSender) SendHTML(to, subject, templateName string, data interface{}) error {
+	return r.SendHTMLWithHeaders(to, subject, templateName, data, nil)
+}
+
+func (r *recordingSender) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	if r.fail != nil {
+		return r.fail
+	}
+	r.sent = append(r.sent, to+":"+templateName)
+	r.datas = append(r.datas, data.(map[string]interface{}))
+	return nil
+}
+
+func TestQueueEnqueueThenDispatch(t *testing.T) {
+	repo := &memoryRepo{}
+	queue := NewQueue(repo)
+
+	err := queue.SendHTML("user@example.com", "Welcome", "welcome.html", map[string]interface{}{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	if len(repo.messages) != 1 || repo.messages[0].Status != StatusPending {
+		t.Fatalf("expected one pending message, got %+v", repo.messages)
+	}
+
+	sender := &recordingSender{}
+	NewDispatcher(repo, sender, time.Minute).dispatchOnce(context.Background())
+
+	if len(sender.sent) != 1 || sender.sent[0] != "user@example.com:welcome.html" {
+		t.Fatalf("expected welcome.html to be delivered, got %v", sender.sent)
+	}
+	if sender.datas[0]["Name"] != "Ada" {
+		t.Errorf("expected payload to round-trip, got %v", sender.datas[0])
+	}
+	if repo.messages[0].Status != StatusSent {
+		t.Errorf("expected message to be marked sent, got %s", repo.messages[0].Status)
+	}
+}
+
+func TestDispatchRetriesThenFails(t *testing.T) {
+	repo := &memoryRepo{}
+	NewQueue(repo).SendHTML("user@example.com", "Verse", "verse.html", map[string]interface{}{})
+
+	d := NewDispatcher(repo, &recordingSender{fail: errors.New("smtp down")}, time.Minute)
+
+	for i := 1; i <= MaxAttempts; i++ {
+		d.dispatchOnce(context.Background())
+
+		msg := repo.messages[0]
+		if msg.Attempts != i {
+			t.Fatalf("expected %d attempts, got %d", i, msg.Attempts)
+		}
+		if i < MaxAttempts {
+			if msg.Status != StatusPending || !msg.NextAttemptAt.After(time.Now()) {
+				t.Fatalf("expected message to be rescheduled after attempt %d, got %+v", i, msg)
+			}
+			repo.messages[0].NextAttemptAt = time.Now().Add(-time.Second) // fast-forward backoff
+		}
+	}
+
+	if repo.messages[0].Status != StatusFailed {
+		t.Errorf("expected message to be failed after %d attempts, got %s", MaxAttempts, repo.messages[0].Status)
+	}
+}
@@ -0,0 +1,40 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Queue implements mail.Sender by persisting emails to the outbox for the
+// Dispatcher to deliver, so sends survive crashes and can be retried.
+type Queue struct {
+	repo Repository
+}
+
+func NewQueue(repo Repository) *Queue {
+	return &Queue{repo: repo}
+}
+
+func (q *Queue) SendHTML(to, subject, templateName string, data interface{}) error {
+	return q.SendHTMLWithHeaders(to, subject, templateName, data, nil)
+}
+
+func (q *Queue) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode email payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return q.repo.Enqueue(ctx, Message{
+		To:       to,
+		Subject:  subject,
+		Template: templateName,
+		Payload:  payload,
+		Headers:  headers,
+	})
+}
@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/database"
+)
+
+// Repository persists queued emails.
+type Repository interface {
+	Enqueue(ctx context.Context, msg Message) error
+	ClaimDue(ctx context.Context, limit int) ([]Message, error)
+	MarkSent(ctx context.Context, id int) error
+	MarkFailed(ctx context.Context, id int, errMsg string, nextAttemptAt time.Time, permanent bool) error
+	List(ctx context.Context, status string, limit, offset int) ([]Message, int, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(dbService database.Service) Repository {
+	return &repository{db: dbService.DB()}
+}
+
+func (r *repository) Enqueue(ctx context.Context, msg Message) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO outbox (to_address, subject, template, payload, headers)
+		VALUES ($1, $2, $3, $4, $5)
+	`, msg.To, msg.Subject, msg.Template, []byte(msg.Payload), headers)
+	return err
+}
+
+// ClaimDue returns pending messages whose next attempt is due, pushing their
+// next attempt forward so concurrent dispatchers don't pick them up twice.
+func (r *repository) ClaimDue(ctx context.Context, limit int) ([]Message, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox
+		SET next_attempt_at = NOW() + INTERVAL '5 minutes'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, to_address, subject, template, payload, headers, status,
+		          attempts, last_error, next_attempt_at, created_at, sent_at
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMessages(rows, nil)
+}
+
+func (r *repository) MarkSent(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = 'sent', attempts = attempts + 1, sent_at = NOW(), last_error = NULL
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r *repository) MarkFailed(ctx context.Context, id int, errMsg string, nextAttemptAt time.Time, permanent bool) error {
+	status := StatusPending
+	if permanent {
+		status = StatusFailed
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $4
+	`, status, errMsg, nextAttemptAt.UTC(), id)
+	return err
+}
+
+func (r *repository) List(ctx context.Context, status string, limit, offset int) ([]Message, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, to_address, subject, template, payload, headers, status,
+		       attempts, last_error, next_attempt_at, created_at, sent_at,
+		       COUNT(*) OVER() AS total
+		FROM outbox
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var total int
+	messages, err := scanMessages(rows, &total)
+	return messages, total, err
+}
+
+func scanMessages(rows *sql.Rows, total *int) ([]Message, error) {
+	var messages []Message
+	for rows.Next() {
+		var (
+			m       Message
+			payload []byte
+			headers []byte
+		)
+		dest := []interface{}{
+			&m.ID, &m.To, &m.Subject, &m.Template, &payload, &headers, &m.Status,
+			&m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt, &m.SentAt,
+		}
+		if total != nil {
+			dest = append(dest, total)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		m.Payload = payload
+		if err := json.Unmarshal(headers, &m.Headers); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
@@ -2,41 +2,94 @@ package server
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 
 	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	"github.com/taiwoajasa245/memory-verse-api/internal/idempotency"
 	memoryverse "github.com/taiwoajasa245/memory-verse-api/internal/memory_verse"
+	"github.com/taiwoajasa245/memory-verse-api/internal/outbox"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/buildinfo"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/tracing"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
 	r := chi.NewRouter()
+	// Behind a trusted proxy, log and throttle by the client's IP rather
+	// than the proxy's
+	if s.cfg != nil && s.cfg.TrustProxyHeaders {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(tracing.Middleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	// Serve HEAD from the matching GET route
+	r.Use(middleware.GetHead)
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	r.Use(corsHandler)
+	// Login stays open during maintenance so admins can still sign in
+	r.Use(auth.Maintenance(s.cfg != nil && s.cfg.Maintenance, "/memory-verse-api/v1/auth/login"))
+
+	// Keep router-level errors in the same envelope as handler errors.
+	// These must be set before Route so subrouters inherit them.
+	r.NotFound(s.NotFoundHandler)
+	r.MethodNotAllowed(s.MethodNotAllowedHandler)
 
 	// Get home route
 	r.Get("/", s.ServerIsWorking)
 	r.Get("/memory-verse-api/v1", s.ServerIsWorking)
+	r.Get("/version", s.VersionHandler)
+
+	// One auth handler serves both route groups so per-user limits, such as
+	// welcome resends, are shared between the user and admin endpoints
+	authHandler := s.newAuthHandler()
 
 	r.Route("/memory-verse-api/v1", func(r chi.Router) {
-		s.loadAuthRoutes(r)
+		s.loadAuthRoutes(r, authHandler)
 		s.loadVerseRoutes(r)
+		s.loadAdminRoutes(r, authHandler)
 	})
 
 	return r
 }
 
+// publicPathPrefix holds the embeddable, credential-free endpoints.
+const publicPathPrefix = "/memory-verse-api/v1/public/"
+
+var (
+	appCORS = cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"https://*", "http://*"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", idempotency.HeaderKey, memoryverse.GuestIDHeader},
+		ExposedHeaders:   []string{"Idempotent-Replayed"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	})
+	// Any site may read public endpoints, but only with GET and no cookies
+	publicCORS = cors.Handler(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "HEAD"},
+		AllowedHeaders: []string{"Accept"},
+		MaxAge:         3600,
+	})
+)
+
+// corsHandler applies publicCORS under publicPathPrefix and appCORS elsewhere.
+func corsHandler(next http.Handler) http.Handler {
+	app, public := appCORS(next), publicCORS(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, publicPathPrefix) {
+			public.ServeHTTP(w, r)
+			return
+		}
+		app.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) ServerIsWorking(w http.ResponseWriter, r *http.Request) {
 	resp := make(map[string]string)
 	resp["message"] = "Welcome to Memory verse api"
@@ -44,34 +97,158 @@ func (s *Server) ServerIsWorking(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, resp, "Success")
 }
 
-func (s *Server) loadAuthRoutes(router chi.Router) {
+func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, http.StatusNotFound, "Not found", "no route for "+r.URL.Path)
+}
+
+func (s *Server) MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, http.StatusMethodNotAllowed, "Method not allowed", r.Method+" is not supported for "+r.URL.Path)
+}
 
-	authRepo := auth.NewRepository(s.db)
-	authServie := auth.NewAuthService(authRepo, s.mail)
-	authHandler := auth.NewHandler(authServie)
+// VersionHandler reports which build is deployed
+func (s *Server) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, buildinfo.Get(), "Success")
+}
 
+// newAuthHandler builds the auth handler shared by the auth and admin routes.
+func (s *Server) newAuthHandler() auth.AuthHandler {
+	authServie := auth.NewAuthService(s.authRepo, s.mail, s.cfg)
+	authServie.SetDailyVerseSource(&s.mvService)
+	authServie.SetTranslationSource(&s.mvService)
+	// Reset codes and email links are mailed directly so they never sit in the outbox
+	authServie.SetOTPMailer(s.directMail)
+	return auth.NewHandler(authServie)
+}
+
+func (s *Server) loadAuthRoutes(router chi.Router, authHandler auth.AuthHandler) {
 	router.Post("/auth/login", authHandler.LoginHandler)
 	router.Post("/auth/register-with-email", authHandler.RegisterHandler)
+	router.Post("/auth/validate-registration", authHandler.ValidateRegistrationHandler)
+	// Throttled so it can't be used to enumerate accounts
+	router.With(auth.Throttle(checkEmailThrottlePerMinute)).Get("/auth/check-email", authHandler.CheckEmailHandler)
+	router.Get("/auth/inspirations", authHandler.InspirationsHandler)
+	router.With(auth.Throttle(otpThrottlePerMinute)).Post("/auth/forget-password", authHandler.ForgetPasswordHandler)
+	router.With(auth.Throttle(otpThrottlePerMinute)).Post("/auth/verify-otp", authHandler.VerifyOTPHandler)
+	router.With(auth.Throttle(otpThrottlePerMinute)).Post("/auth/reset-password", authHandler.ResetPasswordHandler)
+	router.With(auth.Throttle(otpThrottlePerMinute)).Post("/auth/confirm-email", authHandler.ConfirmEmailChangeHandler)
 
 	router.Group(func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
+		r.Get("/auth/me", authHandler.MeHandler)
+		r.With(auth.RejectInMaintenance).Get("/auth/me/feed-token", authHandler.FeedTokenHandler)
+		r.Get("/auth/inspirations/mine", authHandler.MyInspirationsHandler)
+		r.Put("/auth/inspirations/mine", authHandler.ReplaceMyInspirationsHandler)
 		r.Post("/auth/complete-profile", authHandler.CompleteProfileHandler)
+		r.Patch("/auth/profile", authHandler.UpdateProfileHandler)
+		r.Post("/auth/resend-welcome", authHandler.ResendWelcomeHandler)
 	})
 
 }
 
+// Per-client request budgets for throttled endpoints
+const (
+	verseThrottlePerMinute  = 30
+	searchThrottlePerMinute = 30
+	otpThrottlePerMinute    = 5
+	// Signup forms check as the user types, so allow a little more
+	checkEmailThrottlePerMinute = 20
+)
+
 func (s *Server) loadVerseRoutes(router chi.Router) {
-	authRepo := auth.NewRepository(s.db)
-	memoryVerseRepo := memoryverse.NewMemoryVerseRepo(s.db)
-	memeoryVerseService := memoryverse.NewMemoryVerseService(memoryVerseRepo, authRepo, s.mail)
-	memeoryVerseHandler := memoryverse.NewMemoryVerseHandler(memeoryVerseService)
+	authRepo := s.authRepo
+	// The server's own service, so its verse caches are the ones admin verse
+	// writes invalidate
+	memeoryVerseHandler := memoryverse.NewMemoryVerseHandler(s.mvService)
+	idempotencyRepo := s.idemRepo
+
+	// One-click unsubscribe from the List-Unsubscribe email header (RFC 8058)
+	router.Post("/unsubscribe/one-click", memeoryVerseHandler.OneClickUnsubscribeHandler)
+	router.Get("/translations", memeoryVerseHandler.GetTranslationsHandler)
+	router.With(auth.Throttle(verseThrottlePerMinute)).Get("/daily-verse", memeoryVerseHandler.GetDailyVerseHandler)
+	router.With(auth.Throttle(verseThrottlePerMinute)).Get("/daily-archive", memeoryVerseHandler.GetDailyVerseArchiveHandler)
+
+	// Embeddable verse-of-the-day widget, see publicPathPrefix
+	router.Get("/public/daily-verse.json", memeoryVerseHandler.PublicDailyVerseHandler)
+
+	// RSS readers can't send headers, so the feed authenticates by token
+	router.Get("/feed.xml", memeoryVerseHandler.FeedHandler)
+
+	// Read-only favourites pages shared by link
+	router.Get("/share/favourites/{token}", memeoryVerseHandler.SharedFavouritesHandler)
+
+	// Open-tracking pixel and click redirect embedded in verse emails
+	router.Get("/track/open/{token}.gif", memeoryVerseHandler.TrackOpenHandler)
+	router.Get("/track/click/{token}", memeoryVerseHandler.TrackClickHandler)
 
 	router.Group(func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Get("/dashboard", memeoryVerseHandler.GetDashboardVerseHandler)
-		r.Get("/unsubscribe", memeoryVerseHandler.UnsubscribeHandler)
-		r.Get("/get-favourite-verses", memeoryVerseHandler.GetUserFavouriteVersesHandler)
-		r.Patch("/toggle-favourite-verse", memeoryVerseHandler.ToggleFavouriteVerseHandler)
+
+		// Delivery settings, shared verse lookups, reports and history export
+		// stay open while onboarding so users can manage their account
+		r.With(auth.RejectInMaintenance).Get("/unsubscribe", memeoryVerseHandler.UnsubscribeHandler)
+		r.Post("/snooze", memeoryVerseHandler.SnoozeHandler)
+		r.Post("/unsnooze", memeoryVerseHandler.UnsnoozeHandler)
+		r.Post("/goal/reset", memeoryVerseHandler.ResetVerseGoalHandler)
+		r.Get("/popular", memeoryVerseHandler.GetPopularVersesHandler)
+		r.With(auth.Throttle(verseThrottlePerMinute)).Get("/recent", memeoryVerseHandler.GetRecentVersesHandler)
+		r.Post("/verses/batch", memeoryVerseHandler.GetVersesByIDsHandler)
+		r.Post("/verse/{id}/report", memeoryVerseHandler.ReportVerseHandler)
+		r.Get("/verse/{id}/commentary", memeoryVerseHandler.GetVerseCommentaryHandler)
+		r.Get("/verse/{id}/related", memeoryVerseHandler.GetRelatedVersesHandler)
+		r.Get("/auth/me/history", memeoryVerseHandler.GetVerseHistoryHandler)
+		r.Get("/auth/me/history.csv", memeoryVerseHandler.ExportVerseHistoryHandler)
+
+		// Anything that builds up the user's own verse state (dashboard,
+		// favourites, notes, collections) needs a completed profile, since
+		// without one no verses are ever delivered
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireCompletedProfile(authRepo))
+			r.With(auth.Throttle(verseThrottlePerMinute)).Get("/dashboard", memeoryVerseHandler.GetDashboardVerseHandler)
+			r.Get("/upcoming", memeoryVerseHandler.GetUpcomingHandler)
+			r.Get("/get-favourite-verses", memeoryVerseHandler.GetUserFavouriteVersesHandler)
+			r.Patch("/toggle-favourite-verse", memeoryVerseHandler.ToggleFavouriteVerseHandler)
+			r.Post("/favourites/bulk", memeoryVerseHandler.BulkFavouritesHandler)
+			r.Post("/favourites/reorder", memeoryVerseHandler.ReorderFavouritesHandler)
+			r.With(auth.RejectInMaintenance).Get("/favourites/share", memeoryVerseHandler.ShareFavouritesHandler)
+			r.Get("/favourites/summary", memeoryVerseHandler.GetFavouriteSummaryHandler)
+			r.Get("/notes", memeoryVerseHandler.GetUserNotesHandler)
+			r.With(auth.Throttle(searchThrottlePerMinute)).Get("/notes/search", memeoryVerseHandler.SearchUserNotesHandler)
+			r.With(idempotency.Middleware(idempotencyRepo)).Post("/save-note", memeoryVerseHandler.SaveUserNoteHandler)
+			r.Post("/notes/{id}/attachments", memeoryVerseHandler.AttachNoteFileHandler)
+			r.Delete("/notes/{id}/attachments/{attachmentID}", memeoryVerseHandler.DetachNoteFileHandler)
+			r.Post("/collections", memeoryVerseHandler.CreateCollectionHandler)
+			r.Get("/collections", memeoryVerseHandler.GetUserCollectionsHandler)
+			r.Get("/collections/{id}/verses", memeoryVerseHandler.GetCollectionVersesHandler)
+			r.Post("/collections/{id}/verses", memeoryVerseHandler.AddVerseToCollectionHandler)
+			r.Delete("/collections/{id}/verses/{verseID}", memeoryVerseHandler.RemoveVerseFromCollectionHandler)
+		})
 	})
 
 }
+
+func (s *Server) loadAdminRoutes(router chi.Router, authHandler auth.AuthHandler) {
+	// Scheduler status must come from the service running the scheduler, and
+	// verse writes must clear the caches the verse routes read from
+	memeoryVerseHandler := memoryverse.NewMemoryVerseHandler(s.mvService)
+
+	outboxHandler := outbox.NewHandler(s.outboxRepo)
+
+	router.Route("/admin", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Use(auth.RequireRole(auth.RoleAdmin))
+		r.Get("/users", authHandler.ListUsersHandler)
+		r.Post("/users/{id}/resend-welcome", authHandler.AdminResendWelcomeHandler)
+		r.Get("/verse-reports", memeoryVerseHandler.GetVerseReportsHandler)
+		r.Patch("/verse-reports/{id}", memeoryVerseHandler.ResolveVerseReportHandler)
+		r.Post("/verses", memeoryVerseHandler.CreateVerseHandler)
+		r.Post("/verses/import", memeoryVerseHandler.ImportVersesHandler)
+		r.Get("/verses/coverage", memeoryVerseHandler.GetVerseCoverageHandler)
+		r.Put("/verses/{id}/prompts", memeoryVerseHandler.SetVersePromptsHandler)
+		r.Put("/verses/{id}/commentary", memeoryVerseHandler.SetVerseCommentaryHandler)
+		r.Get("/metrics", memeoryVerseHandler.GetMetricsHandler)
+		r.Get("/metrics/impressions", memeoryVerseHandler.GetImpressionMetricsHandler)
+		r.Get("/metrics/ab", memeoryVerseHandler.GetSubjectVariantMetricsHandler)
+		r.Get("/scheduler/status", memeoryVerseHandler.GetSchedulerStatusHandler)
+		r.Get("/outbox", outboxHandler.ListOutboxHandler)
+	})
+}
@@ -1,10 +1,24 @@
 package server
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
+	memoryverse "github.com/taiwoajasa245/memory-verse-api/internal/memory_verse"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/buildinfo"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/response"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +43,317 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestVersionHandler(t *testing.T) {
+	defer func(version, commit string) { buildinfo.Version, buildinfo.Commit = version, commit }(buildinfo.Version, buildinfo.Commit)
+	buildinfo.Version, buildinfo.Commit = "v1.2.0", "abc123"
+
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body struct {
+		Success bool           `json:"success"`
+		Data    buildinfo.Info `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON envelope, got %q", rec.Body.String())
+	}
+	if !body.Success || body.Data != buildinfo.Get() {
+		t.Errorf("expected build info %+v, got %+v", buildinfo.Get(), body)
+	}
+}
+
+// stubDB satisfies database.Service so routes can be registered without Postgres.
+type stubDB struct{}
+
+func (stubDB) Health() map[string]string { return map[string]string{"status": "up"} }
+func (stubDB) Close() error              { return nil }
+func (stubDB) DB() *sql.DB               { return nil }
+func (stubDB) ReadDB() *sql.DB           { return nil }
+
+func newTestRouter() http.Handler {
+	s := &Server{db: stubDB{}, cfg: &config.Config{}, authRepo: auth.NewRepository(stubDB{})}
+	return s.RegisterRoutes()
+}
+
+func TestRouterErrorsUseResponseEnvelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"unknown path", http.MethodGet, "/memory-verse-api/v1/does-not-exist", http.StatusNotFound},
+		{"unknown nested path", http.MethodGet, "/memory-verse-api/v1/auth/typo", http.StatusNotFound},
+		{"unknown top-level path", http.MethodGet, "/nope", http.StatusNotFound},
+		{"wrong method", http.MethodDelete, "/memory-verse-api/v1/auth/login", http.StatusMethodNotAllowed},
+	}
+
+	router := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, rec.Code)
+			}
+
+			var body response.APIResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("expected JSON envelope, got %q", rec.Body.String())
+			}
+			if body.Status != tt.status || body.Success {
+				t.Errorf("unexpected envelope: %+v", body)
+			}
+		})
+	}
+}
+
+func TestRouterServesHeadForGetRoutes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected HEAD /version to return 200, got %d", rec.Code)
+	}
+}
+
+func TestThrottleUsesForwardedIPOnlyWhenTrusted(t *testing.T) {
+	// Requests without an email fail validation, so nothing reaches the DB
+	checkEmail := func(router http.Handler, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/memory-verse-api/v1/auth/check-email", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, trusted := range []bool{true, false} {
+		s := &Server{db: stubDB{}, cfg: &config.Config{TrustProxyHeaders: trusted}, authRepo: auth.NewRepository(stubDB{})}
+		router := s.RegisterRoutes()
+
+		for i := 0; i < checkEmailThrottlePerMinute; i++ {
+			if code := checkEmail(router, "203.0.113.1"); code == http.StatusTooManyRequests {
+				t.Fatalf("trusted=%t: throttled after %d requests", trusted, i)
+			}
+		}
+		if code := checkEmail(router, "203.0.113.1"); code != http.StatusTooManyRequests {
+			t.Errorf("trusted=%t: expected the client to be throttled, got %d", trusted, code)
+		}
+
+		// Every request shares httptest's RemoteAddr, so another client is
+		// only told apart when the forwarded IP is trusted
+		want := http.StatusBadRequest
+		if !trusted {
+			want = http.StatusTooManyRequests
+		}
+		if code := checkEmail(router, "203.0.113.2"); code != want {
+			t.Errorf("trusted=%t: expected %d for another forwarded client, got %d", trusted, want, code)
+		}
+	}
+}
+
+// onboardingRepo reports every user as not having completed their profile.
+type onboardingRepo struct {
+	auth.Repository
+}
+
+func (onboardingRepo) GetUserWithProfile(ctx context.Context, userID int) (*auth.User, *auth.CompleteProfileRequest, error) {
+	return &auth.User{ID: userID, IsProfileCompleted: false}, &auth.CompleteProfileRequest{}, nil
+}
+
+func (onboardingRepo) UnsubscribeUser(ctx context.Context, userID int) error { return nil }
+
+func (onboardingRepo) SetSnoozedUntil(ctx context.Context, userID int, until *time.Time) error {
+	return nil
+}
+
+func TestIncompleteProfileGate(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	token, err := util.GenerateJWT(1, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	cfg := &config.Config{}
+	s := &Server{db: stubDB{}, cfg: cfg, authRepo: onboardingRepo{}}
+	s.mvService = memoryverse.NewMemoryVerseService(memoryverse.NewMemoryVerseRepo(stubDB{}), onboardingRepo{}, nil, cfg)
+	router := s.RegisterRoutes()
+
+	tests := []struct {
+		method string
+		path   string
+		gated  bool
+	}{
+		{http.MethodGet, "/dashboard", true},
+		{http.MethodGet, "/upcoming", true},
+		{http.MethodGet, "/get-favourite-verses", true},
+		{http.MethodPatch, "/toggle-favourite-verse", true},
+		{http.MethodPost, "/favourites/bulk", true},
+		{http.MethodGet, "/notes", true},
+		{http.MethodGet, "/notes/search?q=love", true},
+		{http.MethodPost, "/save-note", true},
+		{http.MethodPost, "/collections", true},
+		{http.MethodGet, "/collections", true},
+		{http.MethodGet, "/collections/1/verses", true},
+		{http.MethodPost, "/collections/1/verses", true},
+		{http.MethodDelete, "/collections/1/verses/2", true},
+		{http.MethodGet, "/unsubscribe", false},
+		{http.MethodPost, "/unsnooze", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/memory-verse-api/v1"+tt.path, strings.NewReader("{}"))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if tt.gated && rec.Code != http.StatusForbidden {
+				t.Errorf("expected 403 for incomplete profile, got %d", rec.Code)
+			}
+			if !tt.gated && rec.Code != http.StatusOK {
+				t.Errorf("expected 200 for incomplete profile, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// unreachableDriver fails every connection, so services built on it return
+// errors rather than panicking on a nil *sql.DB.
+type unreachableDriver struct{}
+
+func (unreachableDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("database unreachable")
+}
+
+func init() {
+	sql.Register("unreachable", unreachableDriver{})
+}
+
+// unreachableDB is a database.Service whose queries all fail.
+type unreachableDB struct{ stubDB }
+
+func (unreachableDB) DB() *sql.DB {
+	db, _ := sql.Open("unreachable", "")
+	return db
+}
+
+// countingMailer counts sends without delivering anything.
+type countingMailer struct{ sent int }
+
+func (m *countingMailer) SendHTML(to, subject, templateName string, data interface{}) error {
+	m.sent++
+	return nil
+}
+
+func (m *countingMailer) SendHTMLWithHeaders(to, subject, templateName string, data interface{}, headers map[string]string) error {
+	return m.SendHTML(to, subject, templateName, data)
+}
+
+func TestWelcomeResendQuotaSharedWithAdminRoute(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	userToken, err := util.GenerateJWT(1, "user@example.com", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	adminToken, err := util.GenerateJWT(2, "admin@example.com", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	cfg := &config.Config{}
+	mailer := &countingMailer{}
+	s := &Server{db: stubDB{}, cfg: cfg, mail: mailer, directMail: mailer, authRepo: onboardingRepo{}}
+	s.mvService = memoryverse.NewMemoryVerseService(memoryverse.NewMemoryVerseRepo(unreachableDB{}), onboardingRepo{}, mailer, cfg)
+	router := s.RegisterRoutes()
+
+	resend := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/memory-verse-api/v1"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := resend("/auth/resend-welcome", userToken); code != http.StatusOK {
+			t.Fatalf("resend %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := resend("/admin/users/1/resend-welcome", adminToken); code != http.StatusTooManyRequests {
+		t.Errorf("expected the admin resend to share the user's quota and get 429, got %d", code)
+	}
+	if mailer.sent != 3 {
+		t.Errorf("expected 3 welcome emails, got %d", mailer.sent)
+	}
+}
+
+func TestCORSPolicyByPath(t *testing.T) {
+	handler := corsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	const origin = "https://blog.example.com"
+
+	request := func(method, path string, headers map[string]string) http.Header {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	t.Run("public endpoints allow any origin without credentials", func(t *testing.T) {
+		h := request(http.MethodGet, "/memory-verse-api/v1/public/daily-verse.json", nil)
+		if got := h.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("expected wildcard origin, got %q", got)
+		}
+		if got := h.Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("expected no credentials, got %q", got)
+		}
+	})
+
+	t.Run("public endpoints are GET only", func(t *testing.T) {
+		h := request(http.MethodOptions, "/memory-verse-api/v1/public/daily-verse.json", map[string]string{
+			"Access-Control-Request-Method": http.MethodPost,
+		})
+		if got := h.Get("Access-Control-Allow-Methods"); got != "" {
+			t.Errorf("expected POST preflight to be refused, got Allow-Methods %q", got)
+		}
+	})
+
+	t.Run("app endpoints allow the Idempotency-Key header", func(t *testing.T) {
+		h := request(http.MethodOptions, "/memory-verse-api/v1/memory-verse/save-note", map[string]string{
+			"Access-Control-Request-Method":  http.MethodPost,
+			"Access-Control-Request-Headers": "Authorization, Idempotency-Key",
+		})
+		if got := h.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Idempotency-Key") {
+			t.Errorf("expected Idempotency-Key to be allowed, got %q", got)
+		}
+	})
+
+	t.Run("app endpoints allow the X-Guest-ID header", func(t *testing.T) {
+		h := request(http.MethodOptions, "/memory-verse-api/v1/memory-verse/daily-verse", map[string]string{
+			"Access-Control-Request-Method":  http.MethodGet,
+			"Access-Control-Request-Headers": "X-Guest-ID",
+		})
+		if got := h.Get("Access-Control-Allow-Headers"); got == "" {
+			t.Error("expected X-Guest-ID to be allowed")
+		}
+	})
+
+	t.Run("app endpoints keep the credentialed policy", func(t *testing.T) {
+		h := request(http.MethodGet, "/memory-verse-api/v1/auth/me", nil)
+		if got := h.Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("expected origin to be echoed, got %q", got)
+		}
+		if got := h.Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("expected credentials allowed, got %q", got)
+		}
+	})
+}
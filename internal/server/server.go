@@ -8,24 +8,30 @@ import (
 	// "log"
 	"net/http"
 
-	"time"
-
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/taiwoajasa245/memory-verse-api/internal/auth"
 	"github.com/taiwoajasa245/memory-verse-api/internal/database"
+	"github.com/taiwoajasa245/memory-verse-api/internal/idempotency"
 	"github.com/taiwoajasa245/memory-verse-api/internal/mail"
 	memoryverse "github.com/taiwoajasa245/memory-verse-api/internal/memory_verse"
+	"github.com/taiwoajasa245/memory-verse-api/internal/outbox"
 	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
 )
 
 type Server struct {
-	port      string
-	db        database.Service
-	handler   http.Handler
-	cfg       *config.Config
-	mail      *mail.Mailer
-	mvService memoryverse.MemoryVerseService
-	cancel    context.CancelFunc
+	port        string
+	db          database.Service
+	handler     http.Handler
+	cfg         *config.Config
+	mail        mail.Sender
+	directMail  mail.Sender // skips the outbox, for mail that mustn't be stored
+	outboxRepo  outbox.Repository
+	idemRepo    idempotency.Repository
+	dispatcher  *outbox.Dispatcher
+	authRepo    auth.Repository
+	authService auth.AuthService
+	mvService   memoryverse.MemoryVerseService
+	cancel      context.CancelFunc
 }
 
 // NewServer constructs your app server with all dependencies injected.
@@ -33,11 +39,16 @@ func NewServer(db database.Service, cfg *config.Config) *Server {
 	stats := db.Health()
 	mail := mail.NewMail(
 		cfg.SmtpFrom,
-		"Memory Verse",
+		cfg.SmtpFromName,
+		cfg.SmtpReplyTo,
 		cfg.SmtpPassword,
 		cfg.SmtpHost,
 		cfg.SmtpPort,
 	)
+	mail.Timeout = cfg.SmtpTimeout
+	mail.MaxRetries = cfg.SmtpRetries
+	mail.RequireTLS = cfg.SmtpRequireTLS
+	mail.PreviewLength = cfg.EmailPreview
 
 	fmt.Println("Database Health:", stats)
 
@@ -48,41 +59,89 @@ func NewServer(db database.Service, cfg *config.Config) *Server {
 		log.Println("Database connection successful")
 	}
 
+	// Emails are queued in the outbox and delivered by the dispatcher
+	outboxRepo := outbox.NewRepository(db)
+	queue := outbox.NewQueue(outboxRepo)
+	dispatcher := outbox.NewDispatcher(outboxRepo, mail, cfg.OutboxInterval)
+
 	authRepo := auth.NewRepository(db)
+	authService := auth.NewAuthService(authRepo, queue, cfg)
+	if err := authService.BootstrapAdmin(context.Background(), cfg.AdminEmail); err != nil {
+		log.Printf("Failed to bootstrap admin %s: %v", cfg.AdminEmail, err)
+	}
+
 	memoryVerseRepo := memoryverse.NewMemoryVerseRepo(db)
-	mvService := memoryverse.NewMemoryVerseService(memoryVerseRepo, authRepo, mail)
+	mvService := memoryverse.NewMemoryVerseService(memoryVerseRepo, authRepo, queue, cfg)
 
 	s := &Server{
-		port:      cfg.Port,
-		db:        db,
-		cfg:       cfg,
-		mail:      mail,
-		mvService: mvService,
+		port:        cfg.Port,
+		db:          db,
+		cfg:         cfg,
+		mail:        queue,
+		directMail:  mail,
+		outboxRepo:  outboxRepo,
+		idemRepo:    idempotency.NewRepository(db),
+		dispatcher:  dispatcher,
+		authRepo:    authRepo,
+		authService: authService,
+		mvService:   mvService,
 	}
 
 	s.handler = s.RegisterRoutes()
 	return s
 }
 
-// HTTPServer returns the actual *http.Server instance
+// HTTPServer returns the actual *http.Server instance.
+// WriteTimeout applies to every response; streaming endpoints such as the
+// CSV history export lift it for their own request via http.ResponseController.
 func (s *Server) HTTPServer() *http.Server {
 	return &http.Server{
 		Addr:         fmt.Sprintf(":%s", s.port),
 		Handler:      s.handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  s.cfg.ReadTimeout,
+		WriteTimeout: s.cfg.WriteTimeout,
+		IdleTimeout:  s.cfg.IdleTimeout,
 	}
 }
 
-// StartBackgroundJobs runs scheduled jobs
-func (s *Server) StartBackgroundJobs() {
+// StartBackgroundJobs runs scheduled jobs. It fails, before starting any,
+// when SCHEDULER_CRON can't be used.
+func (s *Server) StartBackgroundJobs() error {
+	spec := s.cfg.SchedulerCron
+	if spec == "" {
+		spec = config.DefaultSchedulerCron(config.GetAppEnv())
+	}
+	schedule, err := memoryverse.ParseSchedulerCron(spec)
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	s.cancel = cancel
 
+	// Purge expired password reset codes
+	go s.authService.StartCleanupJob(ctx, s.cfg.CleanupEvery)
+
+	// Purge expired idempotency keys
+	go idempotency.StartCleanupJob(ctx, s.idemRepo, s.cfg.CleanupEvery)
+
+	// Jobs that deliver verses and email stay paused during maintenance;
+	// queued emails wait in the outbox until it is switched off
+	if s.cfg.Maintenance {
+		log.Println("Maintenance mode: scheduler, inactivity and outbox jobs paused")
+		return nil
+	}
+
 	// Start Memory Verse scheduler in background
-	go s.mvService.StartScheduler(ctx)
-	log.Println("MemoryVerse scheduler started")
+	go s.mvService.StartScheduler(ctx, schedule)
+	log.Printf("MemoryVerse scheduler started (%s)", spec)
+
+	// Deliver queued emails in background
+	go s.dispatcher.Run(ctx)
+
+	// Remind users who stopped opening their verses
+	go s.mvService.StartInactivityJob(ctx, s.cfg.InactivityEvery)
+	return nil
 }
 
 func (s *Server) StopBackgroundJobs() {
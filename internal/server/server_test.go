@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/config"
+)
+
+func TestHTTPServerUsesConfiguredTimeouts(t *testing.T) {
+	s := &Server{port: "9090", cfg: &config.Config{
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  90 * time.Second,
+	}}
+
+	srv := s.HTTPServer()
+
+	if srv.Addr != ":9090" {
+		t.Errorf("unexpected Addr: %q", srv.Addr)
+	}
+	if srv.ReadTimeout != 5*time.Second {
+		t.Errorf("expected ReadTimeout 5s, got %v", srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != 2*time.Minute {
+		t.Errorf("expected WriteTimeout 2m, got %v", srv.WriteTimeout)
+	}
+	if srv.IdleTimeout != 90*time.Second {
+		t.Errorf("expected IdleTimeout 90s, got %v", srv.IdleTimeout)
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_delivery_times (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delivery_time TIME NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, delivery_time)
+);
+
+-- Existing profiles keep their single selected_time as a one-slot list
+INSERT INTO user_delivery_times (user_id, delivery_time)
+SELECT user_id, (selected_time AT TIME ZONE 'UTC')::time
+FROM user_profiles
+WHERE selected_time IS NOT NULL
+ON CONFLICT DO NOTHING;
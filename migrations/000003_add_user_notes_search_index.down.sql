@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_user_notes_search;
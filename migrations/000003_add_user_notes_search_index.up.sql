@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_user_notes_search
+    ON user_notes
+    USING GIN (to_tsvector('english', coalesce(content, '') || ' ' || coalesce(verse_reference, '')));
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS verse_reports (
+    id          SERIAL PRIMARY KEY,
+    verse_id    INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP NULL
+);
+
+-- A user may only have one open report per verse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_verse_reports_open
+    ON verse_reports (user_id, verse_id)
+    WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS idx_verse_reports_status ON verse_reports (status, created_at);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id              SERIAL PRIMARY KEY,
+    to_address      VARCHAR(255) NOT NULL,
+    subject         TEXT NOT NULL,
+    template        VARCHAR(255) NOT NULL,
+    payload         JSONB NOT NULL DEFAULT '{}',
+    headers         JSONB NOT NULL DEFAULT '{}',
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at         TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+-- Supports the case-insensitive lookup behind UNIQUE_USERNAMES. Existing data
+-- may already contain duplicates, so uniqueness is enforced by the service
+-- rather than by a unique index.
+CREATE INDEX IF NOT EXISTS idx_user_profiles_username_lower ON user_profiles (LOWER(username));
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Collection names are unique per user, ignoring case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_collections_user_name
+    ON collections (user_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS collection_verses (
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    verse_id      INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    added_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, verse_id)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_verse_history_daily;
+ALTER TABLE user_verse_history DROP COLUMN IF EXISTS delivery_date;
@@ -0,0 +1,15 @@
+ALTER TABLE user_verse_history ADD COLUMN IF NOT EXISTS delivery_date DATE NOT NULL DEFAULT CURRENT_DATE;
+
+UPDATE user_verse_history SET delivery_date = delivered_at::date;
+
+-- Drop duplicates left by concurrent dashboard loads before adding the guard
+DELETE FROM user_verse_history a
+USING user_verse_history b
+WHERE a.ctid > b.ctid
+  AND a.user_id = b.user_id
+  AND a.verse_id = b.verse_id
+  AND a.delivery_date = b.delivery_date;
+
+-- A verse is recorded at most once per user per day
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_verse_history_daily
+    ON user_verse_history (user_id, verse_id, delivery_date);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS password_resets;
+
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS phone_number,
+    DROP COLUMN IF EXISTS otp_channel;
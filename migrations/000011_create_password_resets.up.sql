@@ -0,0 +1,14 @@
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS otp_channel VARCHAR(10) NOT NULL DEFAULT 'email',
+    ADD COLUMN IF NOT EXISTS phone_number VARCHAR(20);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets (user_id);
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires_at ON password_resets (expires_at);
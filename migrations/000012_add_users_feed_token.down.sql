@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_feed_token_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS feed_token_hash;
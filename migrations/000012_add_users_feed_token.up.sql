@@ -0,0 +1,6 @@
+-- Only a hash of the feed token is stored; the token itself is shown once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS feed_token_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_feed_token_hash
+    ON users (feed_token_hash)
+    WHERE feed_token_hash IS NOT NULL;
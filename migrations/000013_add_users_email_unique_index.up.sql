@@ -0,0 +1,2 @@
+-- CreateUser relies on this index, not a pre-check, to reject duplicate emails
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_unique ON users (email);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS verse_prompts (
+    id         SERIAL PRIMARY KEY,
+    verse_id   INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    prompt     TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_verse_prompts_verse ON verse_prompts (verse_id, id);
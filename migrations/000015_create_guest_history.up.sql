@@ -0,0 +1,9 @@
+-- Lightweight history for signed-out visitors, keyed by a client-chosen ID
+CREATE TABLE IF NOT EXISTS guest_history (
+    guest_id  VARCHAR(64) NOT NULL,
+    verse_id  INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    served_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (guest_id, verse_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guest_history_served_at ON guest_history (served_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS email_opens;
+DROP TABLE IF EXISTS email_sends;
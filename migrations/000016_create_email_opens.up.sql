@@ -0,0 +1,16 @@
+-- One row per tracked email; the token is embedded in the open pixel URL
+CREATE TABLE IF NOT EXISTS email_sends (
+    token    VARCHAR(64) PRIMARY KEY,
+    user_id  INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    verse_id INTEGER REFERENCES memory_verses(id) ON DELETE SET NULL,
+    sent_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Every pixel load is kept, so repeat opens are counted
+CREATE TABLE IF NOT EXISTS email_opens (
+    id        SERIAL PRIMARY KEY,
+    token     VARCHAR(64) NOT NULL REFERENCES email_sends(token) ON DELETE CASCADE,
+    opened_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_opens_token ON email_opens (token);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS email_clicks (
+    id          SERIAL PRIMARY KEY,
+    token       VARCHAR(64) NOT NULL REFERENCES email_sends(token) ON DELETE CASCADE,
+    destination TEXT NOT NULL,
+    clicked_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_clicks_token ON email_clicks (token);
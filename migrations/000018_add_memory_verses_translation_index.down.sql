@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_memory_verses_translation_id;
@@ -0,0 +1,3 @@
+-- Random verse selection counts and skips through a translation in id order;
+-- this index lets both run without touching the table.
+CREATE INDEX IF NOT EXISTS idx_memory_verses_translation_id ON memory_verses (translation, id);
@@ -0,0 +1,26 @@
+-- The catalogue of inspirations users can pick during onboarding. Submitted
+-- values are matched against slug.
+CREATE TABLE IF NOT EXISTS inspirations (
+    slug       VARCHAR(50) PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0
+);
+
+INSERT INTO inspirations (slug, name, sort_order) VALUES
+    ('faith', 'Faith', 1),
+    ('hope', 'Hope', 2),
+    ('love', 'Love', 3),
+    ('peace', 'Peace', 4),
+    ('strength', 'Strength', 5),
+    ('wisdom', 'Wisdom', 6),
+    ('comfort', 'Comfort', 7),
+    ('courage', 'Courage', 8),
+    ('forgiveness', 'Forgiveness', 9),
+    ('gratitude', 'Gratitude', 10),
+    ('guidance', 'Guidance', 11),
+    ('healing', 'Healing', 12),
+    ('joy', 'Joy', 13),
+    ('patience', 'Patience', 14),
+    ('prayer', 'Prayer', 15),
+    ('salvation', 'Salvation', 16)
+ON CONFLICT (slug) DO NOTHING;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS email_change_history (
+    id         SERIAL PRIMARY KEY,
+    user_id    INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email  VARCHAR(255) NOT NULL,
+    new_email  VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_change_history_user ON email_change_history (user_id, changed_at DESC);
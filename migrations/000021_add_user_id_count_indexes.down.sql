@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_notes_user_id;
+DROP INDEX IF EXISTS idx_favourite_verses_user_id;
@@ -0,0 +1,3 @@
+-- The per-user favourite and note caps count a user's rows before each add.
+CREATE INDEX IF NOT EXISTS idx_favourite_verses_user_id ON favourite_verses (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_notes_user_id ON user_notes (user_id);
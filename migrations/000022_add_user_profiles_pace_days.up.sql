@@ -0,0 +1,2 @@
+-- The gap in days for the every_n_days verse pace; NULL for other paces.
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS pace_days INT CHECK (pace_days BETWEEN 1 AND 90);
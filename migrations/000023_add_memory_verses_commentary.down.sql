@@ -0,0 +1 @@
+ALTER TABLE memory_verses DROP COLUMN IF EXISTS commentary;
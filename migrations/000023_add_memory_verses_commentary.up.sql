@@ -0,0 +1,2 @@
+-- Optional study notes on a verse, served separately from the verse itself.
+ALTER TABLE memory_verses ADD COLUMN IF NOT EXISTS commentary TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS favourite_share_verses;
+DROP TABLE IF EXISTS favourite_shares;
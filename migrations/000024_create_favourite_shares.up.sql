@@ -0,0 +1,18 @@
+-- Read-only snapshots of a user's favourites, opened by an unguessable token.
+-- Only the token's hash is stored.
+CREATE TABLE IF NOT EXISTS favourite_shares (
+    id           SERIAL PRIMARY KEY,
+    token_hash   VARCHAR(64) NOT NULL UNIQUE,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    display_name VARCHAR(100) NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMP NOT NULL
+);
+
+-- The verses as they were favourited when the share was created, in order
+CREATE TABLE IF NOT EXISTS favourite_share_verses (
+    share_id INTEGER NOT NULL REFERENCES favourite_shares(id) ON DELETE CASCADE,
+    verse_id INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (share_id, verse_id)
+);
@@ -0,0 +1,7 @@
+-- The verse of the day for each UTC date. The first instance to pick a verse
+-- for a day wins, so every instance serves the same one.
+CREATE TABLE IF NOT EXISTS daily_verses (
+    day        DATE PRIMARY KEY,
+    verse_id   INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+-- One row each time a verse is shown on a user's dashboard.
+CREATE TABLE IF NOT EXISTS verse_impressions (
+    id        BIGSERIAL PRIMARY KEY,
+    user_id   INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    verse_id  INTEGER NOT NULL REFERENCES memory_verses(id) ON DELETE CASCADE,
+    viewed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_verse_impressions_viewed_at ON verse_impressions (viewed_at);
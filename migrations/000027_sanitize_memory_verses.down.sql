@@ -0,0 +1,2 @@
+UPDATE memory_verses SET verse = raw_verse WHERE raw_verse IS NOT NULL;
+ALTER TABLE memory_verses DROP COLUMN IF EXISTS raw_verse;
@@ -0,0 +1,5 @@
+-- Verse text is cleaned by sanitizeVerseText when it is added or imported;
+-- raw_verse keeps the text exactly as it arrived when cleaning changed it.
+-- Rows already in the table are not rewritten here, since SQL can't match
+-- the Go cleaning exactly (entities, script blocks).
+ALTER TABLE memory_verses ADD COLUMN IF NOT EXISTS raw_verse TEXT;
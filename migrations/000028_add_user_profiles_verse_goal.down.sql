@@ -0,0 +1,4 @@
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS goal_reached_at,
+    DROP COLUMN IF EXISTS goal_started_at,
+    DROP COLUMN IF EXISTS verse_goal;
@@ -0,0 +1,6 @@
+-- Optional memorisation goal: delivery pauses once verse_goal verses have
+-- been delivered since goal_started_at, until the user resets it.
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS verse_goal INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS goal_started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS goal_reached_at TIMESTAMP;
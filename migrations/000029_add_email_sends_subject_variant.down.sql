@@ -0,0 +1 @@
+ALTER TABLE email_sends DROP COLUMN IF EXISTS subject_variant;
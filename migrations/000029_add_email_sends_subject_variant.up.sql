@@ -0,0 +1,2 @@
+-- Which subject line a verse email was sent with, for A/B open-rate comparison
+ALTER TABLE email_sends ADD COLUMN IF NOT EXISTS subject_variant VARCHAR(32);
@@ -0,0 +1 @@
+ALTER TABLE favourite_verses DROP COLUMN IF EXISTS position;
@@ -0,0 +1,3 @@
+-- Manual ordering of favourites. New favourites get 0 and so sort first,
+-- newest first, ahead of any the user has reordered (1..n).
+ALTER TABLE favourite_verses ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
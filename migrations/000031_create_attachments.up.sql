@@ -0,0 +1,10 @@
+-- Files attached to a user's note. Only the URL of an already-uploaded file
+-- is kept here; uploading and storing the file happen elsewhere.
+CREATE TABLE IF NOT EXISTS attachments (
+    id           SERIAL PRIMARY KEY,
+    note_id      INTEGER NOT NULL REFERENCES user_notes(id) ON DELETE CASCADE,
+    url          TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (note_id, url)
+);
@@ -0,0 +1,3 @@
+-- When the inactivity reminder was last sent, so each stretch of inactivity
+-- gets at most one.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_reminder_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_favourite_verses_user_verse;
@@ -0,0 +1,16 @@
+-- The old toggle could insert the same favourite twice under a race. Keep each
+-- user's earliest row per verse before adding the guard.
+DELETE FROM favourite_verses
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY user_id, verse_id ORDER BY created_at, id
+        ) AS rn
+        FROM favourite_verses
+    ) ranked
+    WHERE rn > 1
+);
+
+-- A verse is favourited at most once per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_favourite_verses_user_verse
+    ON favourite_verses (user_id, verse_id);
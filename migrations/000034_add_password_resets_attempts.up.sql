@@ -0,0 +1,2 @@
+-- Wrong guesses against the code; it is deleted once OTP_MAX_ATTEMPTS is hit.
+ALTER TABLE password_resets ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Inactivity checks look up each user's latest email open and click.
+CREATE INDEX IF NOT EXISTS idx_email_sends_user ON email_sends (user_id);
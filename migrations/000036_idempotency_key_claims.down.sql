@@ -0,0 +1,6 @@
+DELETE FROM idempotency_keys WHERE status_code IS NULL OR body IS NULL;
+
+ALTER TABLE idempotency_keys
+    DROP COLUMN IF EXISTS request_hash,
+    ALTER COLUMN status_code SET NOT NULL,
+    ALTER COLUMN body SET NOT NULL;
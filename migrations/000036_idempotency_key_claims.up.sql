@@ -0,0 +1,6 @@
+-- Keys are claimed before the handler runs: status_code and body stay NULL
+-- until it finishes. request_hash binds the key to one method, path and body.
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS request_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ALTER COLUMN status_code DROP NOT NULL,
+    ALTER COLUMN body DROP NOT NULL;
@@ -0,0 +1,3 @@
+-- Renamed duplicates are left as they are.
+DROP INDEX IF EXISTS idx_user_profiles_username_unique;
+CREATE INDEX IF NOT EXISTS idx_user_profiles_username_lower ON user_profiles (LOWER(username));
@@ -0,0 +1,15 @@
+-- Usernames are unique ignoring case. Profiles that already share a name keep
+-- it on the lowest user id; the others get their user id appended so the
+-- index can be built, and can pick a new name afterwards.
+UPDATE user_profiles p
+SET username = p.username || '-' || p.user_id
+FROM (
+    SELECT user_id, ROW_NUMBER() OVER (PARTITION BY LOWER(username) ORDER BY user_id) AS rn
+    FROM user_profiles
+    WHERE username <> ''
+) d
+WHERE d.user_id = p.user_id AND d.rn > 1;
+
+DROP INDEX IF EXISTS idx_user_profiles_username_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_profiles_username_unique
+    ON user_profiles (LOWER(username)) WHERE username <> '';
@@ -0,0 +1 @@
+-- The stripped query strings can't be restored, so there is nothing to undo.
@@ -0,0 +1,5 @@
+-- Clicks used to be recorded with the full destination URL, including the
+-- unsubscribe token in its query string. Keep only the page.
+UPDATE email_clicks
+SET destination = regexp_replace(destination, '[?#].*$', '')
+WHERE destination ~ '[?#]';
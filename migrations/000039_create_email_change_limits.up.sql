@@ -0,0 +1,14 @@
+-- One row per user counting email changes in the current window, so the
+-- limit is checked and counted by a single upsert.
+CREATE TABLE IF NOT EXISTS email_change_limits (
+    user_id      INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    window_start TIMESTAMP NOT NULL,
+    count        INT NOT NULL
+);
+
+-- Start each user's window at their latest recorded change
+INSERT INTO email_change_limits (user_id, window_start, count)
+SELECT user_id, MAX(changed_at), 1
+FROM email_change_history
+GROUP BY user_id
+ON CONFLICT (user_id) DO NOTHING;
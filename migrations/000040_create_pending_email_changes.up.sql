@@ -0,0 +1,9 @@
+-- A requested email change waits here until the link mailed to the new
+-- address is followed. Only a hash of the link's token is stored.
+CREATE TABLE IF NOT EXISTS pending_email_changes (
+    user_id    INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    new_email  VARCHAR(255) NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,27 @@
+// Build metadata injected at link time
+package buildinfo
+
+// These are overridden at build time, e.g.
+//
+//	go build -ldflags "-X github.com/taiwoajasa245/memory-verse-api/pkg/buildinfo.Version=v1.2.0"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the build metadata returned by the /version endpoint
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// Get returns the build metadata of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+}
@@ -0,0 +1,17 @@
+package buildinfo
+
+import "testing"
+
+func TestGetDefaults(t *testing.T) {
+	info := Get()
+
+	if info.Version != "dev" {
+		t.Errorf("expected default version to be dev, got %q", info.Version)
+	}
+	if info.Commit != "unknown" {
+		t.Errorf("expected default commit to be unknown, got %q", info.Commit)
+	}
+	if info.BuildTime != "unknown" {
+		t.Errorf("expected default build time to be unknown, got %q", info.BuildTime)
+	}
+}
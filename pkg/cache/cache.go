@@ -0,0 +1,68 @@
+// In-memory key/value cache with per-entry expiry
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache stores values for a fixed TTL. It is safe for concurrent use and is
+// meant for small, read-heavy data that changes slowly.
+type Cache[V any] struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	items map[string]entry[V]
+	now   func() time.Time
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func New[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		ttl:   ttl,
+		items: map[string]entry[V]{},
+		now:   time.Now,
+	}
+}
+
+// Get returns the value for key if it is present and has not expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok || !c.now().Before(e.expiresAt) {
+		delete(c.items, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key for the cache's TTL.
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = entry[V]{value: value, expiresAt: c.now().Add(c.ttl)}
+}
+
+// Delete removes key so the next Get misses.
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
+
+// Clear removes every entry, e.g. after the underlying data changes.
+func (c *Cache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = map[string]entry[V]{}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheExpiresEntries(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	c := New[string](time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("verse", "John 3:16")
+
+	if v, ok := c.Get("verse"); !ok || v != "John 3:16" {
+		t.Fatalf("expected cached value, got %q, %v", v, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("verse"); ok {
+		t.Error("expected entry to expire after the TTL")
+	}
+}
+
+func TestCacheDeleteAndClear(t *testing.T) {
+	c := New[int](time.Hour)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected deleted key to miss")
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("expected other keys to survive Delete, got %d, %v", v, ok)
+	}
+
+	c.Clear()
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected Clear to remove every entry")
+	}
+}
@@ -2,25 +2,82 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/taiwoajasa245/memory-verse-api/pkg/util"
 )
 
 type Config struct {
-	AppEnv       string
-	Port         string
-	DBHost       string
-	DBPort       string
-	DBName       string
-	DBUser       string
-	DBPassword   string
-	DBSchema     string
-	JWTSecret    string
-	SmtpFrom     string
-	SmtpPassword string
-	SmtpHost     string
-	SmtpPort     string
+	AppEnv         string
+	Port           string
+	DBHost         string
+	DBPort         string
+	DBName         string
+	DBUser         string
+	DBPassword     string
+	DBSchema       string
+	DBReadHost     string // read replica for heavy reads, empty to read from the primary
+	JWTSecret      string
+	JWTSecretPrev  string // previous secret, still accepted during a key rotation
+	JWTKeyID       string // opaque kid put in token headers for JWTSecret
+	JWTKeyIDPrev   string // kid of JWTSecretPrev
+	SmtpFrom       string
+	SmtpFromName   string
+	SmtpReplyTo    string
+	SmtpPassword   string
+	SmtpHost       string
+	SmtpPort       string
+	SmtpTimeout    time.Duration
+	SmtpRetries    int
+	SmtpRequireTLS bool
+	EmailPreview   int // max verse characters shown in emails, 0 for no limit
+	ApiBaseURL     string
+	AppBaseURL     string // frontend the emails link back to
+	SendWelcome    bool
+	AdminEmail     string
+	OutboxInterval time.Duration
+	CleanupEvery   time.Duration // how often expired reset codes and idempotency keys are purged
+	OTPLength      int
+	OTPCharset     string
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	VerseCacheTTL  time.Duration
+	MaxFavourites  int    // per user, 0 for no limit
+	MaxNotes       int    // per user, 0 for no limit
+	OTLPEndpoint   string // OTLP/HTTP collector for traces, empty to disable tracing
+	ServiceName    string // service.name reported on traces
+	SchedulerCron  string // when the verse scheduler runs, in UTC
+	Maintenance    bool   // reject writes from everyone but admins with a 503
+	// InactivityDays is how long without opening the dashboard or a verse
+	// email before a comeback reminder is sent, 0 (the default) to never send
+	// one. InactivityEvery is how often inactive users are looked for.
+	InactivityDays  int
+	InactivityEvery time.Duration
+	// OTPExpiryMinutes is how long a password reset code stays valid; use
+	// OTPExpiry, which falls back to the default when it isn't positive
+	OTPExpiryMinutes int
+	// OTPMaxAttempts is how many wrong guesses burn a reset code; use
+	// OTPAttempts, which falls back to the default when it isn't positive
+	OTPMaxAttempts int
+	// CatchUpGrace is how far back the scheduler looks on startup for sends
+	// missed while the server was down, 0 to skip the catch-up
+	CatchUpGrace time.Duration
+	// CheckPwned rejects new passwords found in the Have I Been Pwned
+	// breach corpus. Lookups fail open when the API can't be reached.
+	CheckPwned bool
+	// SubjectVariants are verse email subjects A/B tested against the
+	// built-in one; {pace} and {name} are filled in per user
+	SubjectVariants []string
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For/X-Real-IP,
+	// for running behind a reverse proxy that sets them. Leave it off when
+	// clients can reach the server directly, or they can spoof their IP.
+	TrustProxyHeaders bool
 }
 
 // LoadConfig loads environment variables from the .env file
@@ -34,24 +91,120 @@ func LoadConfig() *Config {
 	// }
 
 	cfg := &Config{
-		AppEnv:       getEnv("APP_ENV", "development"),
-		Port:         getEnv("PORT", "8080"),
-		DBHost:       getEnv("BLUEPRINT_DB_HOST", "localhost"),
-		DBPort:       getEnv("BLUEPRINT_DB_PORT", "5432"),
-		DBName:       getEnv("BLUEPRINT_DB_DATABASE", "memory_verse"),
-		DBUser:       getEnv("BLUEPRINT_DB_USERNAME", "postgres"),
-		DBPassword:   getEnv("BLUEPRINT_DB_PASSWORD", ""),
-		DBSchema:     getEnv("BLUEPRINT_DB_SCHEMA", "public"),
-		JWTSecret:    getEnv("JWT_SECRET", ""),
-		SmtpFrom:     getEnv("SMTP_FROM", ""),
-		SmtpPassword: getEnv("SMTP_PASSWORD", ""),
-		SmtpHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SmtpPort:     getEnv("SMTP_PORT", "587"),
+		AppEnv:         getEnv("APP_ENV", "development"),
+		Port:           getEnv("PORT", "8080"),
+		DBHost:         getEnv("BLUEPRINT_DB_HOST", "localhost"),
+		DBPort:         getEnv("BLUEPRINT_DB_PORT", "5432"),
+		DBName:         getEnv("BLUEPRINT_DB_DATABASE", "memory_verse"),
+		DBUser:         getEnv("BLUEPRINT_DB_USERNAME", "postgres"),
+		DBPassword:     getEnv("BLUEPRINT_DB_PASSWORD", ""),
+		DBSchema:       getEnv("BLUEPRINT_DB_SCHEMA", "public"),
+		DBReadHost:     getEnv("DB_READ_HOST", ""),
+		JWTSecret:      getEnv("JWT_SECRET", ""),
+		JWTSecretPrev:  getEnv("JWT_SECRET_PREVIOUS", ""),
+		JWTKeyID:       getEnv("JWT_KEY_ID", ""),
+		JWTKeyIDPrev:   getEnv("JWT_KEY_ID_PREVIOUS", ""),
+		SmtpFrom:       getEnv("SMTP_FROM", ""),
+		SmtpFromName:   getEnv("SMTP_FROM_NAME", "Memory Verse"),
+		SmtpReplyTo:    getEnv("SMTP_REPLY_TO", ""),
+		SmtpPassword:   getEnv("SMTP_PASSWORD", ""),
+		SmtpHost:       getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SmtpPort:       getEnv("SMTP_PORT", "587"),
+		SmtpTimeout:    getEnvDuration("SMTP_TIMEOUT", 10*time.Second),
+		SmtpRetries:    getEnvInt("SMTP_MAX_RETRIES", 3),
+		SmtpRequireTLS: getEnvBool("SMTP_REQUIRE_TLS", false),
+		EmailPreview:   getEnvInt("EMAIL_VERSE_PREVIEW_LENGTH", 280),
+		ApiBaseURL:     getEnv("API_BASE_URL", "http://localhost:8080"),
+		AppBaseURL:     getEnv("APP_BASE_URL", DefaultAppBaseURL),
+		SendWelcome:    getEnvBool("SEND_WELCOME_EMAIL", true),
+		AdminEmail:     getEnv("ADMIN_EMAIL", ""),
+		OutboxInterval: getEnvDuration("OUTBOX_INTERVAL", 15*time.Second),
+		CleanupEvery:   getEnvDuration("CLEANUP_INTERVAL", time.Hour),
+		OTPLength:      getEnvInt("OTP_LENGTH", 6),
+		OTPCharset:     getEnv("OTP_CHARSET", "numeric"),
+		ReadTimeout:    getEnvDuration("READ_TIMEOUT", 10*time.Second),
+		WriteTimeout:   getEnvDuration("WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:    getEnvDuration("IDLE_TIMEOUT", 60*time.Second),
+		VerseCacheTTL:  getEnvDuration("VERSE_CACHE_TTL", DefaultVerseCacheTTL),
+		MaxFavourites:  getEnvInt("MAX_FAVOURITES", 1000),
+		MaxNotes:       getEnvInt("MAX_NOTES_PER_USER", 5000),
+		OTLPEndpoint:   getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName:    getEnv("OTEL_SERVICE_NAME", "memory-verse-api"),
+		SchedulerCron:  getEnv("SCHEDULER_CRON", DefaultSchedulerCron(GetAppEnv())),
+		Maintenance:    getEnvBool("MAINTENANCE_MODE", false),
+		// Days without opening the dashboard or an email, and how often to
+		// check. Off by default so turning it on is a deliberate choice
+		InactivityDays:  getEnvInt("INACTIVITY_REMINDER_DAYS", 0),
+		InactivityEvery: getEnvDuration("INACTIVITY_CHECK_INTERVAL", 24*time.Hour),
+		// Minutes before a password reset code lapses
+		OTPExpiryMinutes: getEnvInt("OTP_EXPIRY_MINUTES", DefaultOTPExpiryMinutes),
+		// Wrong guesses before a reset code is burned
+		OTPMaxAttempts: getEnvInt("OTP_MAX_ATTEMPTS", DefaultOTPMaxAttempts),
+		// Sends missed while down are caught up on startup within this window
+		CatchUpGrace: getEnvDuration("SCHEDULER_CATCHUP_GRACE", 6*time.Hour),
+		// Off by default since it calls an external API
+		CheckPwned: getEnvBool("CHECK_PWNED_PASSWORDS", false),
+		// Separated by | since subjects may contain commas
+		SubjectVariants: getEnvList("VERSE_SUBJECT_VARIANTS", "|"),
+		// Only behind a proxy that overwrites the forwarding headers
+		TrustProxyHeaders: getEnvBool("TRUST_PROXY_HEADERS", false),
+	}
+
+	if cfg.OTPExpiryMinutes <= 0 {
+		log.Printf("OTP_EXPIRY_MINUTES must be positive, got %d, using default %d", cfg.OTPExpiryMinutes, DefaultOTPExpiryMinutes)
+		cfg.OTPExpiryMinutes = DefaultOTPExpiryMinutes
 	}
 
 	return cfg
 }
 
+// DefaultVerseCacheTTL is how long slow-changing verse lookups are served
+// from memory unless VERSE_CACHE_TTL says otherwise.
+const DefaultVerseCacheTTL = 5 * time.Minute
+
+// DefaultOTPExpiryMinutes is how long a password reset code stays valid
+// unless OTP_EXPIRY_MINUTES says otherwise.
+const DefaultOTPExpiryMinutes = 10
+
+// OTPExpiry returns how long a password reset code stays valid, using the
+// default when OTPExpiryMinutes isn't positive.
+func (c *Config) OTPExpiry() time.Duration {
+	if c.OTPExpiryMinutes <= 0 {
+		return DefaultOTPExpiryMinutes * time.Minute
+	}
+	return time.Duration(c.OTPExpiryMinutes) * time.Minute
+}
+
+// DefaultOTPMaxAttempts is how many wrong guesses a reset code survives
+// unless OTP_MAX_ATTEMPTS says otherwise.
+const DefaultOTPMaxAttempts = 5
+
+// OTPAttempts returns how many wrong guesses burn a reset code, using the
+// default when OTPMaxAttempts isn't positive.
+func (c *Config) OTPAttempts() int {
+	if c.OTPMaxAttempts <= 0 {
+		return DefaultOTPMaxAttempts
+	}
+	return c.OTPMaxAttempts
+}
+
+// OTPCharacters returns the character set OTPs are drawn from: digits for
+// "numeric" (the default) or letters and digits for "alphanumeric".
+func (c *Config) OTPCharacters() string {
+	if c.OTPCharset == "alphanumeric" {
+		return util.OTPCharsetAlphanumeric
+	}
+	return util.OTPCharsetNumeric
+}
+
+// DefaultAppBaseURL is the frontend emails link to unless APP_BASE_URL is set.
+const DefaultAppBaseURL = "https://memoryverse.app"
+
+// AppURL joins path onto the frontend base URL
+func (c *Config) AppURL(path string) string {
+	return strings.TrimRight(c.AppBaseURL, "/") + path
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -59,6 +212,56 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("Invalid integer for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("Invalid boolean for %s: %q, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Invalid duration for %s: %q, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList splits the variable on sep, dropping blank entries.
+func getEnvList(key, sep string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// DefaultSchedulerCron runs the verse scheduler daily in production and
+// hourly elsewhere.
+func DefaultSchedulerCron(appEnv string) string {
+	if appEnv == "production" {
+		return "@daily"
+	}
+	return "@every 1h"
+}
+
 func GetAppEnv() string {
 	if value, exists := os.LookupEnv("APP_ENV"); exists {
 		return value
@@ -0,0 +1,66 @@
+// Opaque cursors for keyset pagination
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last row of a page; the next page starts strictly after
+// it in the list's sort order. Cursors are not signed: they only position a
+// query within rows the caller may already read.
+type Cursor struct {
+	LastID        int       `json:"last_id"`
+	LastCreatedAt time.Time `json:"last_created_at"`
+}
+
+// CursorPage is the meta sent with a keyset-paged list. NextCursor is empty
+// on the last page.
+type CursorPage struct {
+	PerPage    int    `json:"per_page"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// EncodeCursor returns c as URL-safe base64 JSON.
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor made by EncodeCursor, rejecting anything that
+// isn't exactly one well-formed cursor.
+func DecodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var c Cursor
+	if err := dec.Decode(&c); err != nil || dec.More() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.LastID <= 0 || c.LastCreatedAt.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Page trims items fetched with a limit of limit+1 down to one page and
+// describes it, taking the next cursor from the last item kept.
+func Page[T any](items []T, limit int, cursorOf func(T) Cursor) ([]T, *CursorPage) {
+	page := &CursorPage{PerPage: limit}
+	if len(items) > limit {
+		items = items[:limit]
+		page.HasMore = true
+		page.NextCursor = EncodeCursor(cursorOf(items[len(items)-1]))
+	}
+	return items, page
+}
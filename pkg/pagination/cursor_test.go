@@ -0,0 +1,74 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{LastID: 42, LastCreatedAt: time.Date(2025, 3, 10, 8, 30, 15, 123456000, time.UTC)}
+
+	encoded := EncodeCursor(want)
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Errorf("cursor should be URL-safe, got %q", encoded)
+	}
+
+	got, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor returned error: %v", err)
+	}
+	if got.LastID != want.LastID || !got.LastCreatedAt.Equal(want.LastCreatedAt) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	valid := EncodeCursor(Cursor{LastID: 42, LastCreatedAt: time.Now()})
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := map[string]string{
+		"empty":         "",
+		"not base64":    "not a cursor!",
+		"truncated":     valid[:len(valid)/2],
+		"flipped byte":  "X" + valid[1:],
+		"not json":      raw("hello"),
+		"unknown field": raw(`{"last_id":1,"last_created_at":"2025-03-10T08:00:00Z","user_id":2}`),
+		"trailing data": raw(`{"last_id":1,"last_created_at":"2025-03-10T08:00:00Z"}{}`),
+		"zero id":       raw(`{"last_id":0,"last_created_at":"2025-03-10T08:00:00Z"}`),
+		"negative id":   raw(`{"last_id":-5,"last_created_at":"2025-03-10T08:00:00Z"}`),
+		"missing time":  raw(`{"last_id":1}`),
+		"wrong id type": raw(`{"last_id":"1","last_created_at":"2025-03-10T08:00:00Z"}`),
+	}
+
+	for name, cursor := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor for %q, got %v", cursor, err)
+			}
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	base := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	cursorOf := func(id int) Cursor {
+		return Cursor{LastID: id, LastCreatedAt: base.Add(time.Duration(id) * time.Minute)}
+	}
+
+	items, page := Page([]int{5, 4, 3}, 2, cursorOf)
+	if len(items) != 2 || !page.HasMore || page.PerPage != 2 {
+		t.Fatalf("expected a full page with more to come, got %v %+v", items, page)
+	}
+	next, err := DecodeCursor(page.NextCursor)
+	if err != nil || next.LastID != 4 {
+		t.Errorf("expected next cursor after item 4, got %+v, %v", next, err)
+	}
+
+	items, page = Page([]int{2, 1}, 2, cursorOf)
+	if len(items) != 2 || page.HasMore || page.NextCursor != "" {
+		t.Errorf("expected the last page to have no next cursor, got %v %+v", items, page)
+	}
+}
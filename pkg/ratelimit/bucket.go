@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket allows bursts of up to Capacity events per key, refilling at
+// Rate tokens per second. Like Limiter, state is per instance.
+type TokenBucket struct {
+	Capacity float64
+	Rate     float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a bucket that sustains perMinute events per key and
+// allows a full minute's worth as a burst.
+func NewTokenBucket(perMinute int) *TokenBucket {
+	return &TokenBucket{
+		Capacity: float64(perMinute),
+		Rate:     float64(perMinute) / 60,
+		buckets:  map[string]*bucket{},
+		now:      time.Now,
+	}
+}
+
+// Allow takes a token for key if one is available. When none is, retryAfter
+// is how long until the next token refills.
+func (tb *TokenBucket) Allow(key string) (ok bool, retryAfter time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+	b, exists := tb.buckets[key]
+	if !exists {
+		tb.prune(now)
+		b = &bucket{tokens: tb.Capacity, last: now}
+		tb.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * tb.Rate
+	if b.tokens > tb.Capacity {
+		b.tokens = tb.Capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / tb.Rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have refilled completely, since a new bucket
+// would start in the same state.
+func (tb *TokenBucket) prune(now time.Time) {
+	for key, b := range tb.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*tb.Rate >= tb.Capacity {
+			delete(tb.buckets, key)
+		}
+	}
+}
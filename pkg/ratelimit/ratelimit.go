@@ -0,0 +1,63 @@
+// In-memory fixed-window and token-bucket rate limiting
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to Limit events per key in each Window. State lives in
+// process memory, so limits are per instance and reset on restart.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*window
+	now     func() time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func New(limit int, per time.Duration) *Limiter {
+	return &Limiter{
+		Limit:   limit,
+		Window:  per,
+		windows: map[string]*window{},
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+// When it is not, retryAfter is how long until the key's window resets.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, exists := l.windows[key]
+	if !exists || now.Sub(w.start) >= l.Window {
+		l.prune(now)
+		l.windows[key] = &window{start: now, count: 1}
+		return true, 0
+	}
+
+	if w.count >= l.Limit {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// prune drops expired windows so idle keys don't accumulate.
+func (l *Limiter) prune(now time.Time) {
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllowsUpToLimitPerWindow(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("user:1"); !ok {
+			t.Fatalf("expected event %d to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("user:1")
+	if ok {
+		t.Fatal("expected third event in the window to be rejected")
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("expected retry after 1m, got %v", retryAfter)
+	}
+
+	if ok, _ := l.Allow("user:2"); !ok {
+		t.Error("expected other keys to have their own limit")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("user:1"); !ok {
+		t.Error("expected the limit to reset once the window has passed")
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	tb := NewTokenBucket(60) // one token per second
+	tb.now = func() time.Time { return now }
+
+	for i := 0; i < 60; i++ {
+		if ok, _ := tb.Allow("ip:1.2.3.4"); !ok {
+			t.Fatalf("expected burst event %d to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := tb.Allow("ip:1.2.3.4")
+	if ok {
+		t.Fatal("expected an empty bucket to reject")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", retryAfter)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, retryAfter := tb.Allow("ip:1.2.3.4"); ok || retryAfter != 500*time.Millisecond {
+		t.Errorf("expected half a token to wait 500ms, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+
+	now = now.Add(2500 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if ok, _ := tb.Allow("ip:1.2.3.4"); !ok {
+			t.Fatalf("expected refilled token %d to be allowed", i+1)
+		}
+	}
+	if ok, _ := tb.Allow("ip:1.2.3.4"); ok {
+		t.Error("expected only the refilled tokens to be available")
+	}
+}
@@ -0,0 +1,26 @@
+package response
+
+// Machine-readable error codes sent in APIResponse.Code. Clients should switch
+// on these rather than on HTTP status or message text, which may change.
+const (
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+	CodeTooManyRequests    = "TOO_MANY_REQUESTS"
+	CodeLimitExceeded      = "LIMIT_EXCEEDED"
+	CodeUserExists         = "USER_EXISTS"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+	CodeUserNameTaken      = "USER_NAME_TAKEN"
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeProfileIncomplete  = "PROFILE_INCOMPLETE"
+	CodeInvalidOTP         = "INVALID_OTP"
+	CodeOTPExpired         = "OTP_EXPIRED"
+	CodeOTPAttempts        = "OTP_ATTEMPTS_EXCEEDED"
+	CodeInvalidFeedToken   = "INVALID_FEED_TOKEN"
+	CodeInvalidEmailLink   = "INVALID_EMAIL_LINK"
+	CodeNoVerses           = "NO_VERSES"
+	CodeShareExpired       = "SHARE_EXPIRED"
+	CodeMaintenance        = "MAINTENANCE"
+)
@@ -3,16 +3,45 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
 )
 
+// APIResponse is the envelope for every JSON response. Code is set on errors
+// that clients may need to handle specifically; see codes.go.
 type APIResponse struct {
 	Status  int         `json:"status"`
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
+	Code    string      `json:"code,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"` // *MetaInfo or *pagination.CursorPage
 	Errors  interface{} `json:"errors,omitempty"`
 }
 
+// MetaInfo carries pagination details for list endpoints
+type MetaInfo struct {
+	Total   int  `json:"total"`
+	Page    int  `json:"page"`
+	PerPage int  `json:"per_page"`
+	HasMore bool `json:"has_more"`
+}
+
+// NewMeta builds pagination metadata from a total count and limit/offset paging
+func NewMeta(total, limit, offset int) *MetaInfo {
+	page := 1
+	if limit > 0 {
+		page = offset/limit + 1
+	}
+
+	return &MetaInfo{
+		Total:   total,
+		Page:    page,
+		PerPage: limit,
+		HasMore: offset+limit < total,
+	}
+}
+
 func JSON(w http.ResponseWriter, statusCode int, resp APIResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -29,6 +58,27 @@ func Success(w http.ResponseWriter, data interface{}, message string) {
 	})
 }
 
+func SuccessWithMeta(w http.ResponseWriter, data interface{}, meta *MetaInfo, message string) {
+	JSON(w, http.StatusOK, APIResponse{
+		Status:  http.StatusOK,
+		Success: true,
+		Message: message,
+		Data:    data,
+		Meta:    meta,
+	})
+}
+
+// SuccessWithCursor sends one page of a keyset-paged list.
+func SuccessWithCursor(w http.ResponseWriter, data interface{}, page *pagination.CursorPage, message string) {
+	JSON(w, http.StatusOK, APIResponse{
+		Status:  http.StatusOK,
+		Success: true,
+		Message: message,
+		Data:    data,
+		Meta:    page,
+	})
+}
+
 func Error(w http.ResponseWriter, statusCode int, message string, errs interface{}) {
 	JSON(w, statusCode, APIResponse{
 		Status:  statusCode,
@@ -37,3 +87,14 @@ func Error(w http.ResponseWriter, statusCode int, message string, errs interface
 		Errors:  errs,
 	})
 }
+
+// ErrorWithCode is Error with a machine-readable code clients can switch on.
+func ErrorWithCode(w http.ResponseWriter, statusCode int, code, message string, errs interface{}) {
+	JSON(w, statusCode, APIResponse{
+		Status:  statusCode,
+		Success: false,
+		Message: message,
+		Code:    code,
+		Errors:  errs,
+	})
+}
@@ -0,0 +1,127 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/taiwoajasa245/memory-verse-api/pkg/pagination"
+)
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	return body
+}
+
+func TestSuccessOmitsMeta(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Success(rec, []int{1, 2}, "ok")
+
+	if _, ok := decode(t, rec)["meta"]; ok {
+		t.Error("expected meta to be omitted when not supplied")
+	}
+}
+
+func TestSuccessWithMeta(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SuccessWithMeta(rec, []int{1, 2}, NewMeta(45, 20, 20), "ok")
+
+	meta, ok := decode(t, rec)["meta"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected meta to be present")
+	}
+
+	want := map[string]interface{}{"total": 45.0, "page": 2.0, "per_page": 20.0, "has_more": true}
+	for k, v := range want {
+		if meta[k] != v {
+			t.Errorf("expected meta.%s to be %v, got %v", k, v, meta[k])
+		}
+	}
+}
+
+func TestNewMetaLastPage(t *testing.T) {
+	meta := NewMeta(45, 20, 40)
+	if meta.Page != 3 || meta.HasMore {
+		t.Errorf("expected last page 3 without more results, got %+v", meta)
+	}
+}
+
+func TestErrorWithCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ErrorWithCode(rec, http.StatusBadRequest, CodeOTPExpired, "Reset code has expired", "reset code has expired")
+
+	body := decode(t, rec)
+	if rec.Code != http.StatusBadRequest || body["code"] != CodeOTPExpired {
+		t.Errorf("expected 400 with code %s, got %d %v", CodeOTPExpired, rec.Code, body["code"])
+	}
+	if body["success"] != false {
+		t.Errorf("expected success false, got %v", body["success"])
+	}
+}
+
+func TestErrorOmitsCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Error(rec, http.StatusInternalServerError, "Failed", "boom")
+
+	if _, ok := decode(t, rec)["code"]; ok {
+		t.Error("expected code to be omitted when not supplied")
+	}
+}
+
+// The documented shapes must stay in step with what APIResponse sends.
+func TestSchemaTypesMirrorAPIResponse(t *testing.T) {
+	fields := func(v interface{}) map[string]string {
+		out := map[string]string{}
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			out[f.Name] = f.Tag.Get("json")
+		}
+		return out
+	}
+
+	envelope := fields(APIResponse{})
+	for name, doc := range map[string]interface{}{"SuccessResponse": SuccessResponse{}, "ErrorResponse": ErrorResponse{}} {
+		for field, tag := range fields(doc) {
+			if envelope[field] != tag {
+				t.Errorf("%s.%s has json tag %q, APIResponse has %q", name, field, tag, envelope[field])
+			}
+		}
+	}
+
+	for field := range envelope {
+		_, inSuccess := fields(SuccessResponse{})[field]
+		_, inError := fields(ErrorResponse{})[field]
+		if !inSuccess && !inError {
+			t.Errorf("APIResponse.%s is not documented", field)
+		}
+	}
+}
+
+// PageMeta must document every field either kind of list meta sends.
+func TestPageMetaCoversBothPagingModes(t *testing.T) {
+	names := func(v interface{}) map[string]bool {
+		out := map[string]bool{}
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			out[name] = true
+		}
+		return out
+	}
+
+	want := names(MetaInfo{})
+	for name := range names(pagination.CursorPage{}) {
+		want[name] = true
+	}
+	if got := names(PageMeta{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("PageMeta documents %v, list responses send %v", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package response
+
+// SuccessResponse and ErrorResponse describe the two shapes APIResponse takes
+// on the wire, for API docs such as Swagger annotations. Handlers keep
+// sending APIResponse; these only document it.
+
+// SuccessResponse is a successful APIResponse. Meta is only present on list
+// endpoints.
+type SuccessResponse struct {
+	Status  int         `json:"status" example:"200"`
+	Success bool        `json:"success" example:"true"`
+	Message string      `json:"message,omitempty" example:"successfully"`
+	Data    interface{} `json:"data,omitempty"`
+	Meta    *PageMeta   `json:"meta,omitempty"`
+}
+
+// PageMeta documents the meta of a list response. Offset-paged lists send
+// MetaInfo, with total and page; keyset-paged lists send
+// pagination.CursorPage, with next_cursor until the last page.
+type PageMeta struct {
+	Total      int    `json:"total,omitempty" example:"45"`
+	Page       int    `json:"page,omitempty" example:"2"`
+	PerPage    int    `json:"per_page" example:"20"`
+	HasMore    bool   `json:"has_more" example:"true"`
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJsYXN0X2lkIjo0Mn0"`
+}
+
+// ErrorResponse is a failed APIResponse. Errors is either a message string or
+// a map of field name to validation message.
+type ErrorResponse struct {
+	Status  int         `json:"status" example:"400"`
+	Success bool        `json:"success" example:"false"`
+	Message string      `json:"message,omitempty" example:"Validation failed"`
+	Code    string      `json:"code,omitempty" example:"VALIDATION_FAILED"`
+	Errors  interface{} `json:"errors,omitempty" swaggertype:"object"`
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per request with otelhttp, continuing the
+// caller's trace from its traceparent header. Once chi has matched a route
+// the span is renamed after the pattern, so requests for different IDs
+// group together.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(nameAfterRoute(next), "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// nameAfterRoute runs next, then names the active span after the route chi
+// matched. chi fills in the route context as it routes, so it is only
+// complete once next returns.
+func nameAfterRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + rctx.RoutePattern())
+		span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+	})
+}
@@ -0,0 +1,47 @@
+// Package tracing wires the service into OpenTelemetry: Setup installs an
+// SDK tracer provider exporting over OTLP/HTTP, and the helpers here trace
+// requests, database calls and mail sends through it. Until Setup runs the
+// global provider is OpenTelemetry's no-op one, so tracing costs nothing.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans this service creates itself.
+const instrumentationName = "github.com/taiwoajasa245/memory-verse-api"
+
+// Setup exports spans in batches to the OTLP/HTTP collector at endpoint (a
+// base URL such as http://localhost:4318, as OTEL_EXPORTER_OTLP_ENDPOINT is
+// defined) and installs the provider and W3C trace context propagation
+// globally. The returned shutdown flushes pending spans.
+func Setup(ctx context.Context, endpoint, serviceName string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for the service's own spans. It is looked up on
+// each call so it always follows the provider installed by Setup.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
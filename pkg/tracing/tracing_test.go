@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// stubDriver accepts any statement and returns no rows.
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) { return stubConn{}, nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (stubConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return stubRows{}, nil
+}
+
+type stubRows struct{}
+
+func (stubRows) Columns() []string              { return []string{"id"} }
+func (stubRows) Close() error                   { return nil }
+func (stubRows) Next(dest []driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("tracingstub", stubDriver{})
+}
+
+// record installs an SDK provider that keeps finished spans in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestMiddlewareNestsDBSpans(t *testing.T) {
+	rec := record(t)
+
+	db, err := OpenDB("tracingstub", "")
+	if err != nil {
+		t.Fatalf("OpenDB returned error: %v", err)
+	}
+	defer db.Close()
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/verses/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		if err := db.QueryRowContext(r.Context(), "SELECT id FROM memory_verses WHERE id = $1", 1).Scan(&id); err != sql.ErrNoRows {
+			t.Errorf("expected no rows, got %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/verses/7", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected a db span and a server span, got %d", len(spans))
+	}
+	dbSpan, server := spans[0], spans[1]
+
+	if server.SpanKind() != trace.SpanKindServer || server.Name() != "GET /verses/{id}" || server.Parent().IsValid() {
+		t.Errorf("unexpected server span %q kind %s parent %s", server.Name(), server.SpanKind(), server.Parent().SpanID())
+	}
+	if got := attr(server, "http.route"); got != "/verses/{id}" {
+		t.Errorf("expected route attribute, got %q", got)
+	}
+	if got := attr(server, "http.status_code"); got != "204" {
+		t.Errorf("expected status 204, got %q", got)
+	}
+
+	if dbSpan.SpanKind() != trace.SpanKindClient || dbSpan.Name() != "db SELECT" {
+		t.Errorf("unexpected db span %q kind %s", dbSpan.Name(), dbSpan.SpanKind())
+	}
+	if got := attr(dbSpan, "db.statement"); got != "SELECT id FROM memory_verses WHERE id = $1" {
+		t.Errorf("expected the statement recorded, got %q", got)
+	}
+	if dbSpan.SpanContext().TraceID() != server.SpanContext().TraceID() || dbSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("db span is not a child of the server span")
+	}
+}
+
+func TestMiddlewareContinuesTraceparent(t *testing.T) {
+	rec := record(t)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	if spans[0].SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || spans[0].Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("trace not continued: trace %s parent %s", spans[0].SpanContext().TraceID(), spans[0].Parent().SpanID())
+	}
+}
+
+func TestQuerySpanRecordsErrors(t *testing.T) {
+	rec := record(t)
+
+	_, span := startQuerySpan(context.Background(), "  delete from notes")
+	endQuerySpan(span, io.ErrUnexpectedEOF)
+	_, skipped := startQuerySpan(context.Background(), "SELECT 1")
+	endQuerySpan(skipped, driver.ErrSkip)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "db DELETE" || spans[0].Status().Code != codes.Error {
+		t.Errorf("expected a failed db DELETE span, got %q %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Status().Code == codes.Error {
+		t.Error("expected driver.ErrSkip not to mark the span failed")
+	}
+}
+
+func TestOTLPExportPostsSpans(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer collector.Close()
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	shutdown, err := Setup(context.Background(), collector.URL+"/", "memory-verse-api")
+	if err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	_, span := Tracer().Start(context.Background(), "parent")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 || paths[0] != "/v1/traces" {
+		t.Errorf("expected spans posted to /v1/traces, got %v", paths)
+	}
+}
@@ -0,0 +1,50 @@
+// Feed and tracking token generation/hashing
+
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateFeedToken returns a random token for authenticating feed readers,
+// which can't send an Authorization header.
+func GenerateFeedToken() (string, error) {
+	return randomHex(32)
+}
+
+// GenerateConfirmationToken returns a random token for an emailed
+// confirmation link.
+func GenerateConfirmationToken() (string, error) {
+	return randomHex(32)
+}
+
+// GenerateTrackingToken returns a random token identifying one sent email.
+func GenerateTrackingToken() (string, error) {
+	return randomHex(16)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashFeedToken returns the hex SHA-256 of a feed token as stored in the database.
+func HashFeedToken(token string) string {
+	return hashToken(token)
+}
+
+// HashConfirmationToken returns the hex SHA-256 of a confirmation token as
+// stored in the database.
+func HashConfirmationToken(token string) string {
+	return hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestGenerateFeedTokenIsRandomAndHashStable(t *testing.T) {
+	a, err := GenerateFeedToken()
+	if err != nil {
+		t.Fatalf("GenerateFeedToken returned error: %v", err)
+	}
+	b, err := GenerateFeedToken()
+	if err != nil {
+		t.Fatalf("GenerateFeedToken returned error: %v", err)
+	}
+	if a == b {
+		t.Fatal("expected distinct tokens")
+	}
+
+	if HashFeedToken(a) != HashFeedToken(a) || HashFeedToken(a) == HashFeedToken(b) {
+		t.Error("expected hash to be stable per token and distinct across tokens")
+	}
+	if HashFeedToken(a) == a {
+		t.Error("hash must not equal the token")
+	}
+}
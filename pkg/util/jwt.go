@@ -3,9 +3,9 @@
 package util
 
 import (
-	"time"
-	"os"
 	"errors"
+	"os"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -14,19 +14,44 @@ import (
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Email  string `json:"email"`
+	Role   string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a signed token
-func GenerateJWT(userID int, email string) (string, error) {
+// signingKey is an HMAC secret and the kid that identifies it in token headers
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+// signingKeys returns the current key followed by JWT_SECRET_PREVIOUS, if set.
+// During a rotation the old secret moves to JWT_SECRET_PREVIOUS so tokens it
+// signed keep validating until they expire. Kids come from JWT_KEY_ID and
+// JWT_KEY_ID_PREVIOUS; they are opaque labels, never derived from the secret,
+// and both must be set to rotate so the header can say which key to use.
+func signingKeys() ([]signingKey, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
-		return "", errors.New("JWT_SECRET not set")
+		return nil, errors.New("JWT_SECRET not set")
 	}
 
+	keys := []signingKey{{id: os.Getenv("JWT_KEY_ID"), secret: []byte(secret)}}
+	if previous := os.Getenv("JWT_SECRET_PREVIOUS"); previous != "" && previous != secret {
+		previousID := os.Getenv("JWT_KEY_ID_PREVIOUS")
+		if keys[0].id == "" || previousID == "" || previousID == keys[0].id {
+			return nil, errors.New("JWT_KEY_ID and a different JWT_KEY_ID_PREVIOUS must be set with JWT_SECRET_PREVIOUS")
+		}
+		keys = append(keys, signingKey{id: previousID, secret: []byte(previous)})
+	}
+	return keys, nil
+}
+
+// GenerateJWT generates a signed token
+func GenerateJWT(userID int, email, role string) (string, error) {
 	claims := Claims{
 		UserID: userID,
 		Email:  email,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // token valid for 24h
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -34,16 +59,46 @@ func GenerateJWT(userID int, email string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return signJWT(claims)
 }
 
+// signJWT signs claims with the current key, recording its kid in the header
+// when one is configured
+func signJWT(claims Claims) (string, error) {
+	keys, err := signingKeys()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if keys[0].id != "" {
+		token.Header["kid"] = keys[0].id
+	}
+	return token.SignedString(keys[0].secret)
+}
 
 // ValidateJWT validates and parses a JWT token
 func ValidateJWT(tokenStr string) (*Claims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New("JWT_SECRET not set")
+	claims, err := parseJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Purpose-specific tokens (e.g. unsubscribe links) are not login tokens
+	if claims.Subject != "" {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	return claims, nil
+}
+
+// parseJWT verifies the signature and expiry of a token and returns its claims.
+// The kid header picks the key; tokens without one are checked against the
+// current key.
+func parseJWT(tokenStr string) (*Claims, error) {
+	keys, err := signingKeys()
+	if err != nil {
+		return nil, err
 	}
 
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
@@ -51,7 +106,17 @@ func ValidateJWT(tokenStr string) (*Claims, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(secret), nil
+
+		kid, hasKid := t.Header["kid"]
+		if !hasKid {
+			return keys[0].secret, nil
+		}
+		for _, key := range keys {
+			if key.id != "" && kid == key.id {
+				return key.secret, nil
+			}
+		}
+		return nil, errors.New("unknown signing key")
 	})
 
 	if err != nil {
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateJWTAcceptsPreviousKeyDuringRotation(t *testing.T) {
+	t.Setenv("JWT_SECRET", "old-secret")
+	t.Setenv("JWT_KEY_ID", "2025-01")
+	token, err := GenerateJWT(1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+
+	// Rotate: the old secret and its kid become the previous key
+	t.Setenv("JWT_SECRET", "new-secret")
+	t.Setenv("JWT_KEY_ID", "2025-06")
+	t.Setenv("JWT_SECRET_PREVIOUS", "old-secret")
+	t.Setenv("JWT_KEY_ID_PREVIOUS", "2025-01")
+
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("expected a token signed with the previous key to validate, got %v", err)
+	}
+	if claims.UserID != 1 {
+		t.Errorf("expected user 1, got %d", claims.UserID)
+	}
+
+	// Once the previous key is dropped, its tokens stop working
+	t.Setenv("JWT_SECRET_PREVIOUS", "")
+	t.Setenv("JWT_KEY_ID_PREVIOUS", "")
+	if _, err := ValidateJWT(token); err == nil {
+		t.Error("expected a token from a retired key to be rejected")
+	}
+}
+
+func TestGenerateJWTSignsWithCurrentKey(t *testing.T) {
+	t.Setenv("JWT_SECRET", "new-secret")
+	t.Setenv("JWT_KEY_ID", "2025-06")
+	t.Setenv("JWT_SECRET_PREVIOUS", "old-secret")
+	t.Setenv("JWT_KEY_ID_PREVIOUS", "2025-01")
+
+	token, err := GenerateJWT(1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "2025-06" {
+		t.Errorf("expected the configured kid of the current key, got %v", kid)
+	}
+}
+
+func TestGenerateJWTWithoutKeyID(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("JWT_KEY_ID", "")
+
+	token, err := GenerateJWT(1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if _, ok := parsed.Header["kid"]; ok {
+		t.Errorf("expected no kid without JWT_KEY_ID, got %v", parsed.Header["kid"])
+	}
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("expected the token to validate against the current key, got %v", err)
+	}
+}
+
+func TestRotationNeedsKeyIDs(t *testing.T) {
+	tests := []struct {
+		name, current, previous string
+	}{
+		{"no ids", "", ""},
+		{"no current id", "", "2025-01"},
+		{"no previous id", "2025-06", ""},
+		{"same id", "2025-06", "2025-06"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "new-secret")
+			t.Setenv("JWT_SECRET_PREVIOUS", "old-secret")
+			t.Setenv("JWT_KEY_ID", tt.current)
+			t.Setenv("JWT_KEY_ID_PREVIOUS", tt.previous)
+
+			if _, err := GenerateJWT(1, "user@example.com", "user"); err == nil {
+				t.Error("expected rotation without distinct key ids to be refused")
+			}
+		})
+	}
+}
+
+func TestValidateJWTRejectsUnknownKid(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+
+	claims := Claims{
+		UserID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	// Correct secret, but a kid that isn't one of the configured keys
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "unknown"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	if _, err := ValidateJWT(signed); err == nil {
+		t.Error("expected a token with an unknown kid to be rejected")
+	}
+}
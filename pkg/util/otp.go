@@ -0,0 +1,43 @@
+// One-time password generation
+
+package util
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	OTPCharsetNumeric      = "0123456789"
+	OTPCharsetAlphanumeric = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I to avoid misreads
+)
+
+// GenerateOTP returns a numeric one-time password of the given length.
+// Leading zeros are preserved since the code is built as a string.
+func GenerateOTP(length int) (string, error) {
+	return GenerateOTPFromCharset(length, OTPCharsetNumeric)
+}
+
+// GenerateOTPFromCharset returns a one-time password of the given length whose
+// characters are drawn uniformly from charset using crypto/rand.
+func GenerateOTPFromCharset(length int, charset string) (string, error) {
+	if length <= 0 {
+		return "", errors.New("otp length must be positive")
+	}
+	if charset == "" {
+		return "", errors.New("otp charset is empty")
+	}
+
+	max := big.NewInt(int64(len(charset)))
+	otp := make([]byte, length)
+	for i := range otp {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		otp[i] = charset[n.Int64()]
+	}
+
+	return string(otp), nil
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateOTPLengthAndCharset(t *testing.T) {
+	for _, length := range []int{4, 6, 8} {
+		otp, err := GenerateOTP(length)
+		if err != nil {
+			t.Fatalf("GenerateOTP(%d) returned error: %v", length, err)
+		}
+		if len(otp) != length {
+			t.Errorf("expected length %d, got %q", length, otp)
+		}
+		if strings.Trim(otp, OTPCharsetNumeric) != "" {
+			t.Errorf("expected digits only, got %q", otp)
+		}
+	}
+
+	otp, err := GenerateOTPFromCharset(10, OTPCharsetAlphanumeric)
+	if err != nil {
+		t.Fatalf("GenerateOTPFromCharset returned error: %v", err)
+	}
+	if strings.Trim(otp, OTPCharsetAlphanumeric) != "" {
+		t.Errorf("expected alphanumeric charset only, got %q", otp)
+	}
+}
+
+func TestGenerateOTPRejectsInvalidLength(t *testing.T) {
+	if _, err := GenerateOTP(0); err == nil {
+		t.Error("expected error for zero length")
+	}
+}
+
+func TestGenerateOTPDiffers(t *testing.T) {
+	a, _ := GenerateOTP(12)
+	b, _ := GenerateOTP(12)
+	if a == b {
+		t.Errorf("expected two generations to differ, both were %q", a)
+	}
+}
+
+func TestGenerateOTPDigitsAreUniform(t *testing.T) {
+	const samples = 20000
+	counts := make(map[rune]int)
+	for i := 0; i < samples/10; i++ {
+		otp, err := GenerateOTP(10)
+		if err != nil {
+			t.Fatalf("GenerateOTP returned error: %v", err)
+		}
+		for _, c := range otp {
+			counts[c]++
+		}
+	}
+
+	// Chi-square with 9 degrees of freedom; 27.88 is the p=0.001 critical value
+	expected := float64(samples) / 10
+	var chi2 float64
+	for _, d := range OTPCharsetNumeric {
+		diff := float64(counts[d]) - expected
+		chi2 += diff * diff / expected
+	}
+	if chi2 > 27.88 {
+		t.Errorf("digit distribution looks non-uniform (chi2=%.2f): %v", chi2, counts)
+	}
+}
@@ -0,0 +1,59 @@
+// Breached password lookups against Have I Been Pwned
+
+package util
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pwnedRangeURL is the Pwned Passwords range API; the hash prefix is appended.
+const pwnedRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// pwnedClient does the range lookups. Tests swap its transport.
+var pwnedClient = &http.Client{Timeout: 5 * time.Second}
+
+// IsPasswordCompromised reports whether password appears in a known breach.
+// Only the first five hex characters of its SHA-1 hash leave the process
+// (k-anonymity); the matching suffixes are compared locally.
+func IsPasswordCompromised(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pwnedRangeURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides how many suffixes share the prefix
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := pwnedClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned passwords range lookup: unexpected status %d", resp.StatusCode)
+	}
+
+	// Each line is SUFFIX:COUNT; padding entries have a count of 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		return err == nil && n > 0, nil
+	}
+	return false, scanner.Err()
+}
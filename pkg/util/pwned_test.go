@@ -0,0 +1,79 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// stubPwned answers range lookups with body and records the requested URL.
+func stubPwned(t *testing.T, status int, body string, err error) *string {
+	t.Helper()
+	var requested string
+	old := pwnedClient
+	pwnedClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requested = r.URL.String()
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	t.Cleanup(func() { pwnedClient = old })
+	return &requested
+}
+
+func TestIsPasswordCompromised(t *testing.T) {
+	// SHA-1("password") is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	const body = "0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n" +
+		"1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n" +
+		"011053FD0102E94D6AE2F8B83D76FAF94F6:1\r\n"
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"a-much-less-common-passphrase-42", false},
+	}
+	for _, tt := range tests {
+		requested := stubPwned(t, http.StatusOK, body, nil)
+
+		got, err := IsPasswordCompromised(context.Background(), tt.password)
+		if err != nil {
+			t.Fatalf("%s: IsPasswordCompromised returned error: %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.password, tt.want, got)
+		}
+		if tt.password == "password" && *requested != pwnedRangeURL+"5BAA6" {
+			t.Errorf("expected only the hash prefix to be sent, got %s", *requested)
+		}
+	}
+}
+
+func TestIsPasswordCompromisedIgnoresPadding(t *testing.T) {
+	stubPwned(t, http.StatusOK, "1E4C9B93F3F0682250B6CF8331B7EE68FD8:0\r\n", nil)
+
+	if got, err := IsPasswordCompromised(context.Background(), "password"); err != nil || got {
+		t.Errorf("expected a padding entry not to count, got %v, %v", got, err)
+	}
+}
+
+func TestIsPasswordCompromisedReportsLookupFailures(t *testing.T) {
+	stubPwned(t, 0, "", errors.New("connection refused"))
+	if _, err := IsPasswordCompromised(context.Background(), "password"); err == nil {
+		t.Error("expected an error when the API is unreachable")
+	}
+
+	stubPwned(t, http.StatusServiceUnavailable, "", nil)
+	if _, err := IsPasswordCompromised(context.Background(), "password"); err == nil {
+		t.Error("expected an error on a non-200 response")
+	}
+}
@@ -0,0 +1,227 @@
+// Bible reference parsing and normalisation
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidReference = errors.New("invalid verse reference")
+
+// Reference is a parsed Bible reference. Verse is 0 for a whole chapter.
+// EndChapter is set only when a range runs into a later chapter, and
+// EndVerse only when a range ends on a verse.
+type Reference struct {
+	Book       string `json:"book"`
+	Chapter    int    `json:"chapter"`
+	Verse      int    `json:"verse,omitempty"`
+	EndChapter int    `json:"end_chapter,omitempty"`
+	EndVerse   int    `json:"end_verse,omitempty"`
+}
+
+// String formats the reference canonically, e.g. "John 3:16-18",
+// "1 Corinthians 13" or "Genesis 1:1-2:3".
+func (r Reference) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d", r.Book, r.Chapter)
+	if r.Verse > 0 {
+		fmt.Fprintf(&b, ":%d", r.Verse)
+	}
+	switch {
+	case r.EndChapter > 0:
+		fmt.Fprintf(&b, "-%d", r.EndChapter)
+		if r.EndVerse > 0 {
+			fmt.Fprintf(&b, ":%d", r.EndVerse)
+		}
+	case r.EndVerse > 0:
+		fmt.Fprintf(&b, "-%d", r.EndVerse)
+	}
+	return b.String()
+}
+
+// book is one book of the Bible with its chapter count and the short forms
+// it is commonly written as. Numbered books list their aliases without the
+// number, which is added when the alias table is built.
+type book struct {
+	name     string
+	number   int // 1-3 for numbered books such as 1 Samuel, else 0
+	base     string
+	chapters int
+	aliases  []string
+}
+
+var books = []book{
+	{name: "Genesis", chapters: 50, aliases: []string{"gen", "ge", "gn"}},
+	{name: "Exodus", chapters: 40, aliases: []string{"exod", "exo", "ex"}},
+	{name: "Leviticus", chapters: 27, aliases: []string{"lev", "le", "lv"}},
+	{name: "Numbers", chapters: 36, aliases: []string{"num", "nu", "nm", "nb"}},
+	{name: "Deuteronomy", chapters: 34, aliases: []string{"deut", "de", "dt"}},
+	{name: "Joshua", chapters: 24, aliases: []string{"josh", "jos", "jsh"}},
+	{name: "Judges", chapters: 21, aliases: []string{"judg", "jdg", "jg", "jdgs"}},
+	{name: "Ruth", chapters: 4, aliases: []string{"rth", "ru"}},
+	{number: 1, base: "Samuel", chapters: 31, aliases: []string{"sam", "sa", "sm"}},
+	{number: 2, base: "Samuel", chapters: 24, aliases: []string{"sam", "sa", "sm"}},
+	{number: 1, base: "Kings", chapters: 22, aliases: []string{"kgs", "ki", "kin"}},
+	{number: 2, base: "Kings", chapters: 25, aliases: []string{"kgs", "ki", "kin"}},
+	{number: 1, base: "Chronicles", chapters: 29, aliases: []string{"chron", "chr", "ch"}},
+	{number: 2, base: "Chronicles", chapters: 36, aliases: []string{"chron", "chr", "ch"}},
+	{name: "Ezra", chapters: 10, aliases: []string{"ezr"}},
+	{name: "Nehemiah", chapters: 13, aliases: []string{"neh", "ne"}},
+	{name: "Esther", chapters: 10, aliases: []string{"esth", "est", "es"}},
+	{name: "Job", chapters: 42, aliases: []string{"jb"}},
+	{name: "Psalm", chapters: 150, aliases: []string{"psalms", "ps", "psa", "psm", "pss"}},
+	{name: "Proverbs", chapters: 31, aliases: []string{"prov", "pro", "prv", "pr"}},
+	{name: "Ecclesiastes", chapters: 12, aliases: []string{"eccl", "eccles", "ecc", "ec", "qoh"}},
+	{name: "Song of Solomon", chapters: 8, aliases: []string{"song", "song of songs", "song of sol", "sos", "so", "canticles"}},
+	{name: "Isaiah", chapters: 66, aliases: []string{"isa", "is"}},
+	{name: "Jeremiah", chapters: 52, aliases: []string{"jer", "je", "jr"}},
+	{name: "Lamentations", chapters: 5, aliases: []string{"lam", "la"}},
+	{name: "Ezekiel", chapters: 48, aliases: []string{"ezek", "eze", "ezk"}},
+	{name: "Daniel", chapters: 12, aliases: []string{"dan", "da", "dn"}},
+	{name: "Hosea", chapters: 14, aliases: []string{"hos", "ho"}},
+	{name: "Joel", chapters: 3, aliases: []string{"jl"}},
+	{name: "Amos", chapters: 9, aliases: []string{"am"}},
+	{name: "Obadiah", chapters: 1, aliases: []string{"obad", "ob"}},
+	{name: "Jonah", chapters: 4, aliases: []string{"jnh", "jon"}},
+	{name: "Micah", chapters: 7, aliases: []string{"mic", "mc"}},
+	{name: "Nahum", chapters: 3, aliases: []string{"nah", "na"}},
+	{name: "Habakkuk", chapters: 3, aliases: []string{"hab", "hb"}},
+	{name: "Zephaniah", chapters: 3, aliases: []string{"zeph", "zep", "zp"}},
+	{name: "Haggai", chapters: 2, aliases: []string{"hag", "hg"}},
+	{name: "Zechariah", chapters: 14, aliases: []string{"zech", "zec", "zc"}},
+	{name: "Malachi", chapters: 4, aliases: []string{"mal", "ml"}},
+	{name: "Matthew", chapters: 28, aliases: []string{"matt", "mat", "mt"}},
+	{name: "Mark", chapters: 16, aliases: []string{"mrk", "mk", "mr"}},
+	{name: "Luke", chapters: 24, aliases: []string{"luk", "lk"}},
+	{name: "John", chapters: 21, aliases: []string{"jhn", "jn", "joh"}},
+	{name: "Acts", chapters: 28, aliases: []string{"act", "ac"}},
+	{name: "Romans", chapters: 16, aliases: []string{"rom", "ro", "rm"}},
+	{number: 1, base: "Corinthians", chapters: 16, aliases: []string{"cor", "co"}},
+	{number: 2, base: "Corinthians", chapters: 13, aliases: []string{"cor", "co"}},
+	{name: "Galatians", chapters: 6, aliases: []string{"gal", "ga"}},
+	{name: "Ephesians", chapters: 6, aliases: []string{"eph", "ephes"}},
+	{name: "Philippians", chapters: 4, aliases: []string{"phil", "php", "pp"}},
+	{name: "Colossians", chapters: 4, aliases: []string{"col"}},
+	{number: 1, base: "Thessalonians", chapters: 5, aliases: []string{"thess", "thes", "th"}},
+	{number: 2, base: "Thessalonians", chapters: 3, aliases: []string{"thess", "thes", "th"}},
+	{number: 1, base: "Timothy", chapters: 6, aliases: []string{"tim", "ti"}},
+	{number: 2, base: "Timothy", chapters: 4, aliases: []string{"tim", "ti"}},
+	{name: "Titus", chapters: 3, aliases: []string{"tit"}},
+	{name: "Philemon", chapters: 1, aliases: []string{"philem", "phm", "pm"}},
+	{name: "Hebrews", chapters: 13, aliases: []string{"heb"}},
+	{name: "James", chapters: 5, aliases: []string{"jas", "jm"}},
+	{number: 1, base: "Peter", chapters: 5, aliases: []string{"pet", "pe", "pt"}},
+	{number: 2, base: "Peter", chapters: 3, aliases: []string{"pet", "pe", "pt"}},
+	{number: 1, base: "John", chapters: 5, aliases: []string{"jn", "jhn", "jo"}},
+	{number: 2, base: "John", chapters: 1, aliases: []string{"jn", "jhn", "jo"}},
+	{number: 3, base: "John", chapters: 1, aliases: []string{"jn", "jhn", "jo"}},
+	{name: "Jude", chapters: 1, aliases: []string{"jud", "jd"}},
+	{name: "Revelation", chapters: 22, aliases: []string{"rev", "re", "revelations"}},
+}
+
+// bookIndex maps every bookKey spelling of a book to it.
+var bookIndex = func() map[string]*book {
+	index := map[string]*book{}
+	for i := range books {
+		b := &books[i]
+		names := append([]string{b.name}, b.aliases...)
+		prefix := ""
+		if b.number > 0 {
+			b.name = fmt.Sprintf("%d %s", b.number, b.base)
+			names = append([]string{b.base}, b.aliases...)
+			prefix = strconv.Itoa(b.number)
+		}
+		for _, n := range names {
+			index[bookKey(prefix+" "+n)] = b
+		}
+	}
+	return index
+}()
+
+// ordinals are the ways the number of a numbered book is written.
+var ordinals = map[string]string{
+	"1": "1", "i": "1", "1st": "1", "first": "1",
+	"2": "2", "ii": "2", "2nd": "2", "second": "2",
+	"3": "3", "iii": "3", "3rd": "3", "third": "3",
+}
+
+// bookKey reduces a book name to lower case letters and digits, with any
+// leading ordinal ("I", "1st", "First") written as a digit, so "1 Cor.",
+// "1Cor" and "First Corinthians" all meet their alias.
+func bookKey(name string) string {
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(name, ".", " ")))
+	if len(words) > 1 {
+		if n, ok := ordinals[words[0]]; ok {
+			words[0] = n
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// referencePattern matches "<book> <chapter>[:<verse>][-<end>[:<end verse>]]".
+// Chapter and verse may also be separated by a dot or a space, and ranges
+// by an en or em dash.
+var referencePattern = regexp.MustCompile(
+	`(?i)^((?:[1-3]|i{1,3})?\s*[a-z][a-z.\s]*?)\s*(\d+)(?:\s*[:.\s]\s*(\d+))?(?:\s*[-–—]\s*(\d+)(?:\s*[:.]\s*(\d+))?)?$`)
+
+// ParseReference normalises a free-text reference such as "Jhn 3 16" or
+// "1 cor 13:4–7" into a Reference. A lone number after a single-chapter book
+// is a verse, so "Jude 3" is Jude 1:3. Errors wrap ErrInvalidReference and
+// say what was wrong.
+func ParseReference(s string) (Reference, error) {
+	text := strings.Join(strings.Fields(s), " ")
+	m := referencePattern.FindStringSubmatch(text)
+	if m == nil {
+		return Reference{}, fmt.Errorf("%w: %q is not in the form Book Chapter:Verse", ErrInvalidReference, s)
+	}
+
+	b, ok := bookIndex[bookKey(m[1])]
+	if !ok {
+		return Reference{}, fmt.Errorf("%w: unknown book %q", ErrInvalidReference, strings.TrimSpace(m[1]))
+	}
+
+	num := func(i int) int {
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	for _, i := range []int{2, 3, 4, 5} {
+		if m[i] != "" && num(i) == 0 {
+			return Reference{}, fmt.Errorf("%w: chapter and verse numbers start at 1", ErrInvalidReference)
+		}
+	}
+	ref := Reference{Book: b.name, Chapter: num(2), Verse: num(3)}
+	end, endVerse := num(4), num(5)
+
+	switch {
+	case b.chapters == 1 && ref.Verse == 0 && endVerse == 0:
+		// "Jude 3" and "Jude 3-5" name verses of the only chapter
+		ref.Chapter, ref.Verse, ref.EndVerse = 1, ref.Chapter, end
+	case endVerse > 0:
+		if ref.Verse == 0 {
+			return Reference{}, fmt.Errorf("%w: %q ends on a verse but doesn't start on one", ErrInvalidReference, s)
+		}
+		ref.EndChapter, ref.EndVerse = end, endVerse
+	case ref.Verse > 0:
+		ref.EndVerse = end
+	default:
+		ref.EndChapter = end
+	}
+	// A range that stays in its chapter is written as a verse range
+	if ref.EndChapter == ref.Chapter && ref.EndVerse > 0 {
+		ref.EndChapter = 0
+	}
+
+	if ref.Chapter < 1 || ref.Chapter > b.chapters || ref.EndChapter > b.chapters {
+		return Reference{}, fmt.Errorf("%w: %s has %d chapters", ErrInvalidReference, b.name, b.chapters)
+	}
+	if ref.EndChapter > 0 && ref.EndChapter <= ref.Chapter ||
+		ref.EndChapter == 0 && ref.EndVerse > 0 && ref.EndVerse <= ref.Verse {
+		return Reference{}, fmt.Errorf("%w: %q ends before it starts", ErrInvalidReference, s)
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"John 3:16", "John 3:16"},
+		{"Jhn 3:16", "John 3:16"},
+		{"John 3 16", "John 3:16"},
+		{"jn 3.16", "John 3:16"},
+		{"  john   3 : 16 ", "John 3:16"},
+		{"Psalms 23", "Psalm 23"},
+		{"Ps 119:105", "Psalm 119:105"},
+		{"1 Cor 13:4-7", "1 Corinthians 13:4-7"},
+		{"1Cor. 13:4–7", "1 Corinthians 13:4-7"},
+		{"First Corinthians 13", "1 Corinthians 13"},
+		{"II Tim 3:16", "2 Timothy 3:16"},
+		{"1st John 4:8", "1 John 4:8"},
+		{"Song of Songs 2:1", "Song of Solomon 2:1"},
+		{"Rev 21:3-22:5", "Revelation 21:3-22:5"},
+		{"Genesis 1-2", "Genesis 1-2"},
+		{"Genesis 1:1-1:3", "Genesis 1:1-3"},
+		// Single-chapter books are often cited by verse alone
+		{"Jude 3", "Jude 1:3"},
+		{"Philemon 4-6", "Philemon 1:4-6"},
+		{"3 John 1:4", "3 John 1:4"},
+	}
+
+	for _, tt := range tests {
+		ref, err := ParseReference(tt.in)
+		if err != nil {
+			t.Errorf("ParseReference(%q) returned error: %v", tt.in, err)
+			continue
+		}
+		if got := ref.String(); got != tt.want {
+			t.Errorf("ParseReference(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseReferenceFields(t *testing.T) {
+	ref, err := ParseReference("Rom 8:28-9:1")
+	if err != nil {
+		t.Fatalf("ParseReference returned error: %v", err)
+	}
+	want := Reference{Book: "Romans", Chapter: 8, Verse: 28, EndChapter: 9, EndVerse: 1}
+	if ref != want {
+		t.Errorf("expected %+v, got %+v", want, ref)
+	}
+}
+
+func TestParseReferenceRejectsInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"3:16",
+		"John",
+		"Hezekiah 3:16",
+		"John 22:1",
+		"John 0:1",
+		"John 3:0",
+		"John 3:16-15",
+		"John 3:16-2:1",
+		"John 3-4:2",
+		"Genesis 2-1",
+		"John 3:16 and more",
+	} {
+		if ref, err := ParseReference(in); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("ParseReference(%q) = %+v, %v; want ErrInvalidReference", in, ref, err)
+		}
+	}
+}
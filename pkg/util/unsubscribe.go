@@ -0,0 +1,43 @@
+// Unsubscribe token generation/validation
+
+package util
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const unsubscribeSubject = "unsubscribe"
+
+// GenerateUnsubscribeToken creates a long-lived signed token that identifies
+// a user for one-click unsubscribe links in emails.
+func GenerateUnsubscribeToken(userID int) (string, error) {
+	claims := Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   unsubscribeSubject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(90 * 24 * time.Hour)), // links stay valid for 90 days
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "memory-verse-api",
+		},
+	}
+
+	return signJWT(claims)
+}
+
+// ValidateUnsubscribeToken parses an unsubscribe token and returns the user ID it was issued for.
+func ValidateUnsubscribeToken(tokenStr string) (int, error) {
+	claims, err := parseJWT(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+
+	// Login tokens must not be usable as unsubscribe tokens and vice versa
+	if claims.Subject != unsubscribeSubject {
+		return 0, errors.New("invalid unsubscribe token")
+	}
+
+	return claims.UserID, nil
+}
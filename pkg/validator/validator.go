@@ -0,0 +1,138 @@
+// Struct tag based request validation
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError describes a single invalid field in a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks the `validate` struct tags on v and returns every failing field.
+// Supported rules: required, email, min=<n>, max=<n>, oneof=<a b c>.
+// min/max apply to string length, slice length, or numeric value.
+// Field names in errors use the json tag so they match the request body.
+func Validate(v any) []FieldError {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []FieldError
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := jsonName(field)
+		value := rv.Field(i)
+
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := check(rule, value); msg != "" {
+				errs = append(errs, FieldError{Field: name, Message: fmt.Sprintf("%s %s", name, msg)})
+				break // report only the first failing rule per field
+			}
+		}
+	}
+
+	return errs
+}
+
+func check(rule string, value reflect.Value) string {
+	key, param, _ := strings.Cut(rule, "=")
+
+	// Optional fields (nil pointers) are only checked by "required"
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if key == "required" {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch key {
+	case "required":
+		if isZero(value) {
+			return "is required"
+		}
+	case "email":
+		if value.Kind() == reflect.String && value.String() != "" {
+			if _, err := mail.ParseAddress(value.String()); err != nil {
+				return "must be a valid email address"
+			}
+		}
+	case "min", "max":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return ""
+		}
+		size, unit := measure(value)
+		if key == "min" && size < n {
+			return fmt.Sprintf("must be at least %d%s", n, unit)
+		}
+		if key == "max" && size > n {
+			return fmt.Sprintf("must be at most %d%s", n, unit)
+		}
+	case "oneof":
+		allowed := strings.Fields(param)
+		if value.Kind() == reflect.String && value.String() != "" {
+			for _, a := range allowed {
+				if value.String() == a {
+					return ""
+				}
+			}
+			return fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	return ""
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	if t, ok := value.Interface().(time.Time); ok {
+		return t.IsZero()
+	}
+	return value.IsZero()
+}
+
+func measure(value reflect.Value) (int, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return len([]rune(value.String())), " characters"
+	case reflect.Slice, reflect.Map:
+		return value.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), ""
+	}
+	return 0, ""
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package validator
+
+import "testing"
+
+type signup struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"required,min=8"`
+	Pace     string   `json:"pace" validate:"oneof=daily weekly"`
+	Tags     []string `json:"tags" validate:"required"`
+	Nickname *string  `json:"nickname" validate:"min=2"`
+}
+
+func TestValidateMultipleMissingFields(t *testing.T) {
+	errs := Validate(signup{})
+
+	want := map[string]string{
+		"email":    "email is required",
+		"password": "password is required",
+		"tags":     "tags is required",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %+v", len(want), len(errs), errs)
+	}
+	for _, e := range errs {
+		if want[e.Field] != e.Message {
+			t.Errorf("unexpected error for %s: %q", e.Field, e.Message)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	short := "a"
+	errs := Validate(&signup{
+		Email:    "not-an-email",
+		Password: "short",
+		Pace:     "hourly",
+		Tags:     []string{"hope"},
+		Nickname: &short,
+	})
+
+	got := map[string]string{}
+	for _, e := range errs {
+		got[e.Field] = e.Message
+	}
+
+	want := map[string]string{
+		"email":    "email must be a valid email address",
+		"password": "password must be at least 8 characters",
+		"pace":     "pace must be one of: daily, weekly",
+		"nickname": "nickname must be at least 2 characters",
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("expected %s error %q, got %q", field, msg, got[field])
+		}
+	}
+}
+
+func TestValidateValidStruct(t *testing.T) {
+	errs := Validate(signup{
+		Email:    "user@example.com",
+		Password: "long-enough",
+		Pace:     "daily",
+		Tags:     []string{"hope"},
+	})
+	if len(errs) != 0 {
+		t.Errorf("expected no errors, got %+v", errs)
+	}
+}